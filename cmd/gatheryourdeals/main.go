@@ -3,19 +3,24 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gatheryourdeals/data/internal/auth"
 	"github.com/gatheryourdeals/data/internal/config"
 	"github.com/gatheryourdeals/data/internal/handler"
 	"github.com/gatheryourdeals/data/internal/logger"
+	"github.com/gatheryourdeals/data/internal/model"
 	"github.com/gatheryourdeals/data/internal/repository"
 	"github.com/gatheryourdeals/data/internal/repository/postgres"
 	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/webhook"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
@@ -33,6 +38,7 @@ func main() {
 	root.AddCommand(serveCmd())
 	root.AddCommand(initCmd())
 	root.AddCommand(adminCmd())
+	root.AddCommand(keysCmd())
 
 	if err := root.Execute(); err != nil {
 		os.Exit(1)
@@ -48,7 +54,20 @@ type repos struct {
 	Users        repository.UserRepository
 	Meta         repository.MetaFieldRepository
 	Receipts     repository.ReceiptRepository
+	Stores       repository.StoreRepository
+	Products     repository.ProductRepository
+	Categories   repository.CategoryRepository
+	Prices       repository.PriceRepository
+	Analytics    repository.AnalyticsRepository
+	Watches      repository.WatchRepository
+	Webhooks     repository.WebhookRepository
+	Events       repository.EventRepository
+	Rates        repository.ExchangeRateRepository
 	RefreshStore auth.RefreshTokenStore
+	Logins       auth.LoginAttemptStore
+	TwoFactor    auth.TwoFactorStore
+	Invites      auth.InviteStore
+	Idempotency  repository.IdempotencyRepository
 	closer       io.Closer
 }
 
@@ -78,7 +97,20 @@ func openDatabase() (*config.Config, *repos, error) {
 			Users:        postgres.NewUserRepo(db),
 			Meta:         metaRepo,
 			Receipts:     postgres.NewReceiptRepo(db, metaRepo),
+			Stores:       postgres.NewStoreRepo(db),
+			Products:     postgres.NewProductRepo(db),
+			Categories:   postgres.NewCategoryRepo(db),
+			Prices:       postgres.NewPriceRepo(db),
+			Analytics:    postgres.NewAnalyticsRepo(db),
+			Watches:      postgres.NewWatchRepo(db),
+			Webhooks:     postgres.NewWebhookRepo(db),
+			Events:       postgres.NewEventRepo(db),
+			Rates:        postgres.NewExchangeRateRepo(db),
 			RefreshStore: postgres.NewRefreshTokenStore(db),
+			Logins:       postgres.NewLoginAttemptStore(db),
+			TwoFactor:    postgres.NewTwoFactorStore(db),
+			Invites:      postgres.NewInviteStore(db),
+			Idempotency:  postgres.NewIdempotencyRepo(db),
 			closer:       db,
 		}
 	default: // "sqlite"
@@ -92,7 +124,20 @@ func openDatabase() (*config.Config, *repos, error) {
 			Users:        sqlite.NewUserRepo(db),
 			Meta:         metaRepo,
 			Receipts:     sqlite.NewReceiptRepo(db, metaRepo),
+			Stores:       sqlite.NewStoreRepo(db),
+			Products:     sqlite.NewProductRepo(db),
+			Categories:   sqlite.NewCategoryRepo(db),
+			Prices:       sqlite.NewPriceRepo(db),
+			Analytics:    sqlite.NewAnalyticsRepo(db),
+			Watches:      sqlite.NewWatchRepo(db),
+			Webhooks:     sqlite.NewWebhookRepo(db),
+			Events:       sqlite.NewEventRepo(db),
+			Rates:        sqlite.NewExchangeRateRepo(db),
 			RefreshStore: sqlite.NewRefreshTokenStore(db),
+			Logins:       sqlite.NewLoginAttemptStore(db),
+			TwoFactor:    sqlite.NewTwoFactorStore(db),
+			Invites:      sqlite.NewInviteStore(db),
+			Idempotency:  sqlite.NewIdempotencyRepo(db),
 			closer:       db,
 		}
 	}
@@ -129,10 +174,11 @@ func serveCmd() *cobra.Command {
 			slog.SetDefault(appLogger.Logger)
 
 			// Auth
-			secret, err := cfg.JWTSecret()
+			keys, err := loadKeyRing(cfg)
 			if err != nil {
 				return err
 			}
+			slog.Info("auth: loaded signing keys", "active", keys.ActiveID(), "accepted", keys.IDs())
 			authService := auth.NewService(r.Users)
 
 			accessExp, err := cfg.Auth.GetAccessTokenDuration()
@@ -143,7 +189,19 @@ func serveCmd() *cobra.Command {
 			if err != nil {
 				return fmt.Errorf("parse refresh_token_exp: %w", err)
 			}
-			tokenService := auth.NewTokenService(secret, accessExp, refreshExp, r.RefreshStore)
+			tokenService := auth.NewTokenService(keys, accessExp, refreshExp, r.RefreshStore, r.Users,
+				cfg.Auth.TwoFactor.RequireForAdmins)
+			policy, err := loginPolicy(&cfg.Auth.Lockout)
+			if err != nil {
+				return err
+			}
+			loginGuard := auth.NewLoginGuard(r.Logins, policy)
+			twoFactorService := auth.NewTwoFactorService(r.TwoFactor, cfg.Auth.TwoFactor.Issuer)
+			if cfg.Auth.TwoFactor.RequireForAdmins {
+				slog.Info("auth: admins need two-factor authentication")
+			}
+			registration := openRegistration(cfg, r)
+			slog.Info("auth: registration mode", "mode", cfg.Auth.Registration)
 
 			// Guard: require admin to exist before serving traffic
 			ctx := context.Background()
@@ -155,12 +213,61 @@ func serveCmd() *cobra.Command {
 				return fmt.Errorf("no admin account found — run 'gatheryourdeals init' first")
 			}
 
+			// Expired refresh tokens
+			go sweepRefreshTokens(ctx, r.RefreshStore, time.Hour)
+
+			// Forgotten failed logins
+			go sweepLoginAttempts(ctx, loginGuard, time.Hour)
+
+			// Idempotency keys
+			idempotencyTTL, err := cfg.Idempotency.GetTTL()
+			if err != nil {
+				return fmt.Errorf("parse idempotency ttl: %w", err)
+			}
+			go sweepIdempotencyKeys(ctx, r.Idempotency, time.Hour)
+
+			// Price alerts
+			if url := cfg.Alerts.WebhookURL; url != "" {
+				slog.Info("alerts: delivering to webhook", "url", url)
+				go deliverAlerts(ctx, r.Watches, webhook.NewClient(url, 10*time.Second), time.Minute)
+			}
+
+			// Webhook subscriptions
+			webhookTimeout, err := cfg.Webhooks.GetTimeout()
+			if err != nil {
+				return fmt.Errorf("parse webhooks timeout: %w", err)
+			}
+			dispatcher := webhook.NewDispatcher(r.Webhooks, webhookTimeout, cfg.Webhooks.MaxAttempts)
+			go dispatcher.Run(ctx, 15*time.Second)
+
+			// Change feed compaction
+			retention, err := cfg.Changes.GetRetention()
+			if err != nil {
+				return fmt.Errorf("parse changes retention: %w", err)
+			}
+			go compactEvents(ctx, r.Events, retention, time.Hour)
+
 			// Handlers + router
-			authHandler := handler.NewAuthHandler(authService, tokenService)
-			userHandler := handler.NewUserHandler(r.Users)
+			authHandler := handler.NewAuthHandler(authService, tokenService, loginGuard, twoFactorService, registration)
+			userHandler := handler.NewUserHandler(r.Users, tokenService)
 			metaHandler := handler.NewMetaHandler(r.Meta)
-			receiptHandler := handler.NewReceiptHandler(r.Receipts)
-			router := handler.NewRouter(authHandler, userHandler, metaHandler, receiptHandler, tokenService, appLogger.Writer())
+			receiptHandler := handler.NewReceiptHandler(r.Receipts, r.Rates)
+			storeHandler := handler.NewStoreHandler(r.Stores)
+			productHandler := handler.NewProductHandler(r.Products)
+			categoryHandler := handler.NewCategoryHandler(r.Categories)
+			priceHandler := handler.NewPriceHandler(r.Prices, r.Products, r.Categories)
+			analyticsHandler := handler.NewAnalyticsHandler(r.Analytics, r.Meta)
+			watchHandler := handler.NewWatchHandler(r.Watches, r.Products)
+			webhookHandler := handler.NewWebhookHandler(r.Webhooks)
+			eventHandler := handler.NewEventHandler(r.Events, time.Second)
+			exchangeRateHandler := handler.NewExchangeRateHandler(r.Rates)
+			router, err := handler.NewRouter(authHandler, userHandler, metaHandler, receiptHandler, storeHandler,
+				productHandler, categoryHandler, priceHandler, analyticsHandler, watchHandler, webhookHandler,
+				eventHandler, exchangeRateHandler, tokenService,
+				r.Idempotency, idempotencyTTL, cfg.Server.TrustedProxies, appLogger.Writer())
+			if err != nil {
+				return err
+			}
 
 			addr := fmt.Sprintf(":%s", cfg.Server.Port)
 			slog.Info("server starting", "addr", addr)
@@ -216,6 +323,10 @@ func adminCmd() *cobra.Command {
 		Short: "Administrative operations",
 	}
 	cmd.AddCommand(resetPasswordCmd())
+	cmd.AddCommand(sweepTokensCmd())
+	cmd.AddCommand(unlockCmd())
+	cmd.AddCommand(disableTwoFactorCmd())
+	cmd.AddCommand(inviteCmd())
 	return cmd
 }
 
@@ -245,11 +356,540 @@ func resetPasswordCmd() *cobra.Command {
 			if err := svc.ResetPassword(ctx, username, password); err != nil {
 				return fmt.Errorf("reset password: %w", err)
 			}
+			user, err := r.Users.GetUserByUsername(ctx, username)
+			if err != nil || user == nil {
+				return fmt.Errorf("look up user: %w", err)
+			}
+			if err := r.RefreshStore.DeleteAllForUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("revoke sessions: %w", err)
+			}
+
+			fmt.Printf("Password for '%s' has been reset and all of their sessions logged out.\n", username)
+			return nil
+		},
+	}
+}
+
+// keysCmd groups JWT signing key subcommands. They edit the key file named
+// by auth.keys_file (or GYD_JWT_KEYS_FILE); servers load it at startup.
+func keysCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage JWT signing keys",
+		Long: `Manage JWT signing keys without logging users out.
+
+To rotate: "keys add" a new key and restart every server so that all of them
+accept it, then "keys activate" it and restart again. Tokens signed by the old
+key stay valid until it is retired; wait at least refresh_token_exp before
+running "keys retire" on it.`,
+	}
+	cmd.AddCommand(listKeysCmd(), addKeyCmd(), activateKeyCmd(), retireKeyCmd())
+	return cmd
+}
+
+func listKeysCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List signing keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, file, err := openKeyFile()
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Key file: %s\n", path)
+			for _, k := range file.Keys {
+				status := "accepted"
+				if k.ID == file.Active {
+					status = "active"
+				}
+				alg := k.Alg
+				if alg == "" {
+					alg = "HS256"
+				}
+				fmt.Printf("  %-18s %-9s %-6s created %s\n", k.ID, status, alg, time.Unix(k.CreatedAt, 0).UTC().Format(time.RFC3339))
+			}
+			envStatus := "accepted"
+			if file.Active == "" {
+				envStatus = "active"
+			}
+			fmt.Printf("  %-18s %-9s %-6s from GYD_JWT_SECRET, if set\n", auth.EnvKeyID, envStatus, "HS256")
+			return nil
+		},
+	}
+}
+
+func addKeyCmd() *cobra.Command {
+	var activate bool
+	var pemPath string
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Generate a new HS256 signing key, or add an Ed25519 or RSA key from a PEM file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, file, err := openKeyFile()
+			if err != nil {
+				return err
+			}
+			var key *auth.StoredKey
+			if pemPath != "" {
+				if pemPath, err = filepath.Abs(pemPath); err != nil {
+					return err
+				}
+				key, err = file.AddPEMKey(pemPath, time.Now())
+			} else {
+				key, err = file.AddKey(time.Now())
+			}
+			if err != nil {
+				return err
+			}
+			if activate {
+				if err := file.Activate(key.ID); err != nil {
+					return err
+				}
+			}
+			if err := file.Write(path); err != nil {
+				return err
+			}
+			if activate {
+				fmt.Printf("Key %s added and activated. Restart the server to sign with it.\n", key.ID)
+			} else {
+				fmt.Printf("Key %s added. Restart every server, then run 'gatheryourdeals keys activate %s'.\n", key.ID, key.ID)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&activate, "activate", false, "sign new tokens with the key right away (safe with a single server)")
+	cmd.Flags().StringVar(&pemPath, "pem", "", "PEM file of an Ed25519 or RSA private key to sign with (EdDSA or RS256)")
+	return cmd
+}
+
+func activateKeyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "activate <kid>",
+		Short: "Sign new tokens with a key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, file, err := openKeyFile()
+			if err != nil {
+				return err
+			}
+			previous := file.Active
+			if err := file.Activate(args[0]); err != nil {
+				return err
+			}
+			if err := file.Write(path); err != nil {
+				return err
+			}
+			if previous == "" {
+				previous = auth.EnvKeyID
+			}
+			fmt.Printf("Key %s is now active; %s is still accepted. Restart the server to apply.\n", args[0], previous)
+			return nil
+		},
+	}
+}
+
+func retireKeyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "retire <kid>",
+		Short: "Stop accepting tokens signed by a key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, file, err := openKeyFile()
+			if err != nil {
+				return err
+			}
+			if args[0] == auth.EnvKeyID {
+				return fmt.Errorf("the %s key comes from GYD_JWT_SECRET; unset it to retire the key", auth.EnvKeyID)
+			}
+			if err := file.Retire(args[0]); err != nil {
+				return err
+			}
+			if err := file.Write(path); err != nil {
+				return err
+			}
+			fmt.Printf("Key %s retired. Restart the server to apply; tokens it signed are no longer accepted.\n", args[0])
+			return nil
+		},
+	}
+}
+
+// openKeyFile loads the config and the JWT key file it names.
+func openKeyFile() (string, *auth.KeyFile, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("load config: %w", err)
+	}
+	path := cfg.Auth.EffectiveKeysFile()
+	if path == "" {
+		return "", nil, fmt.Errorf("no key file configured: set auth.keys_file or GYD_JWT_KEYS_FILE")
+	}
+	file, err := auth.ReadKeyFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, file, nil
+}
+
+// loadKeyRing builds the server's JWT key ring from the key file, if one is
+// configured, and GYD_JWT_SECRET.
+func loadKeyRing(cfg *config.Config) (*auth.KeyRing, error) {
+	secret, err := cfg.JWTSecret()
+	if err != nil {
+		return nil, err
+	}
+	file := &auth.KeyFile{}
+	dir := "."
+	if path := cfg.Auth.EffectiveKeysFile(); path != "" {
+		if file, err = auth.ReadKeyFile(path); err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(path)
+	}
+	keys, err := file.KeyRing(dir, secret)
+	if errors.Is(err, auth.ErrNoSigningKey) {
+		return nil, fmt.Errorf("no JWT signing key: set GYD_JWT_SECRET or run 'gatheryourdeals keys add --activate'")
+	}
+	return keys, err
+}
+
+// sweepTokensCmd deletes expired refresh tokens once, as the server does
+// hourly, e.g. for a database that is not served or from a cron job.
+func sweepTokensCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sweep-tokens",
+		Short: "Delete expired refresh tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			n, err := r.RefreshStore.DeleteExpired(context.Background())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Deleted %d expired refresh token(s).\n", n)
+			return nil
+		},
+	}
+}
+
+// unlockCmd clears the failed logins of a username or client IP, ending its
+// lockout. It takes effect on running servers at once.
+func unlockCmd() *cobra.Command {
+	var ip bool
+	cmd := &cobra.Command{
+		Use:   "unlock <username>",
+		Short: "Unlock a username, or with --ip an IP address, locked out by failed logins",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			subject, label := model.LoginSubjectUsername, "username"
+			if ip {
+				subject, label = model.LoginSubjectIP, "IP address"
+			}
+			if err := r.Logins.Reset(context.Background(), subject, args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Unlocked %s '%s'.\n", label, args[0])
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&ip, "ip", false, "unlock a client IP address instead of a username")
+	return cmd
+}
+
+// disableTwoFactorCmd turns off a user's two-factor authentication, for
+// users who lost both their authenticator app and their recovery codes.
+func disableTwoFactorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "disable-2fa <username>",
+		Short: "Turn off a user's two-factor authentication and log them out",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			ctx := context.Background()
+			user, err := r.Users.GetUserByUsername(ctx, args[0])
+			if err != nil {
+				return fmt.Errorf("look up user: %w", err)
+			}
+			if user == nil {
+				return fmt.Errorf("user '%s' not found", args[0])
+			}
+			if err := r.TwoFactor.Delete(ctx, user.ID); err != nil {
+				return err
+			}
+			if err := r.RefreshStore.DeleteAllForUser(ctx, user.ID); err != nil {
+				return fmt.Errorf("revoke sessions: %w", err)
+			}
+			if err := r.Users.BumpTokenVersion(ctx, user.ID); err != nil {
+				return fmt.Errorf("revoke access tokens: %w", err)
+			}
+			fmt.Printf("Two-factor authentication for '%s' has been turned off and all of their sessions logged out.\n", args[0])
+			return nil
+		},
+	}
+}
+
+// inviteCmd groups the invite code subcommands, for invite-only registration.
+func inviteCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "invite",
+		Short: "Manage invite codes for invite-only registration",
+		Long: `Manage invite codes. With auth.registration set to "invite" in config.yaml,
+registering needs one of these codes. Each code works for a number of
+registrations until it expires.`,
+	}
+	cmd.AddCommand(createInviteCmd(), listInvitesCmd(), revokeInviteCmd())
+	return cmd
+}
 
-			fmt.Printf("Password for '%s' has been reset.\n", username)
+func createInviteCmd() *cobra.Command {
+	var uses int
+	var expires time.Duration
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create an invite code",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			invite, err := openRegistration(cfg, r).CreateInvite(context.Background(), "", uses, expires)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Invite code: %s\n", invite.Code)
+			fmt.Printf("ID %s, %d use(s), expires %s. The code is not shown again.\n",
+				invite.ID, invite.MaxUses, time.Unix(invite.ExpiresAt, 0).UTC().Format(time.RFC3339))
+			if cfg.Auth.Registration != string(auth.RegistrationInvite) {
+				fmt.Printf("Note: registration is %q, so the code is not needed yet.\n", cfg.Auth.Registration)
+			}
 			return nil
 		},
 	}
+	cmd.Flags().IntVar(&uses, "uses", 1, "number of registrations the code allows")
+	cmd.Flags().DurationVar(&expires, "expires", auth.DefaultInviteExpiry, "how long the code is valid")
+	return cmd
+}
+
+func listInvitesCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List invites",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			invites, err := openRegistration(cfg, r).ListInvites(context.Background())
+			if err != nil {
+				return err
+			}
+			now := time.Now().Unix()
+			for _, inv := range invites {
+				status := "usable"
+				if !inv.Usable(now) {
+					status = "spent"
+				}
+				fmt.Printf("  %-36s %-6s %d/%d used, expires %s\n", inv.ID, status, inv.Uses, inv.MaxUses,
+					time.Unix(inv.ExpiresAt, 0).UTC().Format(time.RFC3339))
+			}
+			if len(invites) == 0 {
+				fmt.Println("No invites.")
+			}
+			return nil
+		},
+	}
+}
+
+func revokeInviteCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <id>",
+		Short: "Delete an invite so its code stops working",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, r, err := openDatabase()
+			if err != nil {
+				return err
+			}
+			defer func() { _ = r.Close() }()
+
+			if err := openRegistration(cfg, r).RevokeInvite(context.Background(), args[0]); err != nil {
+				return err
+			}
+			fmt.Printf("Invite %s revoked.\n", args[0])
+			return nil
+		},
+	}
+}
+
+// openRegistration builds the registration policy from config.
+func openRegistration(cfg *config.Config, r *repos) *auth.Registration {
+	return auth.NewRegistration(auth.RegistrationMode(cfg.Auth.Registration), auth.NewService(r.Users), r.Invites)
+}
+
+// loginPolicy builds the login brute-force protection policy from config.
+func loginPolicy(cfg *config.LockoutConfig) (auth.LoginPolicy, error) {
+	lockout, err := cfg.GetDuration()
+	if err != nil {
+		return auth.LoginPolicy{}, fmt.Errorf("parse lockout duration: %w", err)
+	}
+	maxLockout, err := cfg.GetMaxDuration()
+	if err != nil {
+		return auth.LoginPolicy{}, fmt.Errorf("parse lockout max_duration: %w", err)
+	}
+	resetAfter, err := cfg.GetResetAfter()
+	if err != nil {
+		return auth.LoginPolicy{}, fmt.Errorf("parse lockout reset_after: %w", err)
+	}
+	if resetAfter < maxLockout {
+		return auth.LoginPolicy{}, fmt.Errorf("lockout reset_after (%s) must be at least max_duration (%s)", resetAfter, maxLockout)
+	}
+	return auth.LoginPolicy{
+		MaxAttempts:   cfg.MaxAttempts,
+		IPMaxAttempts: cfg.IPMaxAttempts,
+		Lockout:       lockout,
+		MaxLockout:    maxLockout,
+		ResetAfter:    resetAfter,
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Background jobs
+// ---------------------------------------------------------------------------
+
+// sweepIdempotencyKeys periodically removes idempotency records whose replay
+// window has passed. It runs until ctx is cancelled.
+func sweepIdempotencyKeys(ctx context.Context, store repository.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.DeleteExpired(ctx)
+			if err != nil {
+				slog.Warn("idempotency sweep failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("idempotency sweep", "deleted", n)
+			}
+		}
+	}
+}
+
+// sweepRefreshTokens periodically removes expired refresh tokens, including
+// used ones kept for reuse detection. It runs until ctx is cancelled.
+func sweepRefreshTokens(ctx context.Context, store auth.RefreshTokenStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := store.DeleteExpired(ctx)
+			if err != nil {
+				slog.Warn("refresh token sweep failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("refresh token sweep", "deleted", n)
+			}
+		}
+	}
+}
+
+// sweepLoginAttempts periodically removes failed login counts that have been
+// forgotten. It runs until ctx is cancelled.
+func sweepLoginAttempts(ctx context.Context, guard *auth.LoginGuard, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := guard.Sweep(ctx)
+			if err != nil {
+				slog.Warn("login attempt sweep failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("login attempt sweep", "deleted", n)
+			}
+		}
+	}
+}
+
+// compactEvents periodically removes events older than retention from the
+// change feed. It runs until ctx is cancelled.
+func compactEvents(ctx context.Context, events repository.EventRepository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := events.CompactEvents(ctx, time.Now().Add(-retention).Unix())
+			if err != nil {
+				slog.Warn("event compaction failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("event compaction", "deleted", n)
+			}
+		}
+	}
+}
+
+// deliverAlerts periodically sends alerts that have not yet been delivered
+// to the webhook, oldest first. A failed delivery ends the round and is
+// retried on the next tick. It runs until ctx is cancelled.
+func deliverAlerts(ctx context.Context, watches repository.WatchRepository, client *webhook.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			alerts, err := watches.UndeliveredAlerts(ctx, 100)
+			if err != nil {
+				slog.Warn("alert delivery failed", "error", err)
+				continue
+			}
+			for _, alert := range alerts {
+				if err := client.Send(ctx, "price.alert", alert); err != nil {
+					slog.Warn("alert delivery failed", "alert", alert.ID, "error", err)
+					break
+				}
+				if err := watches.MarkAlertDelivered(ctx, alert.ID); err != nil {
+					slog.Warn("alert delivery failed", "alert", alert.ID, "error", err)
+					break
+				}
+			}
+		}
+	}
 }
 
 // ---------------------------------------------------------------------------
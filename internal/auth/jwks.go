@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// minRSABits is the smallest RSA modulus accepted for RS256 signing.
+const minRSABits = 2048
+
+// JWK is a public signing key in JSON Web Key form (RFC 7517), as served
+// at /.well-known/jwks.json.
+type JWK struct {
+	Kty string `json:"kty"`           // OKP (Ed25519) or RSA
+	Kid string `json:"kid"`           // matches the kid header of the tokens it verifies
+	Alg string `json:"alg"`           // EdDSA or RS256
+	Use string `json:"use"`           // always "sig"
+	Crv string `json:"crv,omitempty"` // Ed25519
+	X   string `json:"x,omitempty"`   // Ed25519 public key
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA public exponent
+}
+
+// JWKSet is a JSON Web Key Set.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the ring's Ed25519 and RSA keys, ordered
+// by ID. HMAC keys are secret and never included, so tokens they sign can
+// only be verified by this service.
+func (r *KeyRing) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range r.IDs() {
+		k := r.keys[id]
+		jwk := JWK{Kid: k.ID, Alg: k.Algorithm(), Use: "sig"}
+		switch public := k.verificationKey().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// ReadPrivateKeyPEM reads an Ed25519 or RSA private key from a PEM file.
+func ReadPrivateKeyPEM(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	return ParsePrivateKeyPEM(data)
+}
+
+// ParsePrivateKeyPEM parses an Ed25519 or RSA private key in a PKCS #8
+// "PRIVATE KEY" block, as written by "openssl genpkey", or an RSA key in a
+// PKCS #1 "RSA PRIVATE KEY" block. RSA keys must be at least 2048 bits.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q: want PRIVATE KEY or RSA PRIVATE KEY", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return k, nil
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA key is %d bits, want at least %d", k.N.BitLen(), minRSABits)
+		}
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T: want Ed25519 or RSA", key)
+	}
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -16,98 +18,287 @@ var ErrInvalidToken = model.ErrInvalidToken
 
 // Claims is the JWT payload embedded in every access token.
 type Claims struct {
-	UserID string     `json:"uid"`
-	Role   model.Role `json:"role"`
+	UserID    string     `json:"uid"`
+	Role      model.Role `json:"role"`
+	SessionID string     `json:"sid,omitempty"` // the refresh token family the token was issued with
+	Version   int64      `json:"ver"`           // the user's token version when the token was issued
 	jwt.RegisteredClaims
 }
 
 // TokenService issues and validates JWTs, and manages refresh tokens.
 type TokenService struct {
-	secret        []byte
-	accessExpiry  time.Duration
-	refreshExpiry time.Duration
-	store         RefreshTokenStore
+	keys                *KeyRing
+	accessExpiry        time.Duration
+	refreshExpiry       time.Duration
+	store               RefreshTokenStore
+	versions            *versionCache
+	adminsNeedTwoFactor bool
 }
 
 // RefreshTokenStore persists refresh tokens for revocation support.
-// A single SQLite table is sufficient.
+// A single SQLite table is sufficient. Implementations store only a hash of
+// each token. Tokens are grouped into families: every token rotated from one
+// login shares the family of the first, which is the login's session.
 type RefreshTokenStore interface {
-	// Save stores a refresh token bound to a user.
-	Save(ctx context.Context, token string, userID string, expiresAt time.Time) error
-	// Find returns the userID for a valid, non-expired token.
+	// Save stores a refresh token for a session: its user, family ID,
+	// client details, timestamps and the token's expiry.
+	Save(ctx context.Context, token string, session *model.Session) error
+	// Find returns the userID for a valid, unused, non-expired token.
 	// Returns ("", model.ErrInvalidToken) if not found or expired.
 	Find(ctx context.Context, token string) (userID string, err error)
-	// Delete revokes a refresh token (logout).
+	// Rotate marks a valid token used and returns its session.
+	// Presenting a token that was already used revokes its whole family and
+	// returns model.ErrRefreshTokenReused along with the session.
+	// Returns model.ErrInvalidToken if not found or expired.
+	Rotate(ctx context.Context, token string) (*model.Session, error)
+	// ListSessions returns a user's active sessions, most recently used first.
+	ListSessions(ctx context.Context, userID string) ([]*model.Session, error)
+	// DeleteSession revokes one of a user's sessions.
+	// Returns model.ErrSessionNotFound if the user has no such session.
+	DeleteSession(ctx context.Context, userID, sessionID string) error
+	// Delete revokes a refresh token and the rest of its family (logout).
 	Delete(ctx context.Context, token string) error
 	// DeleteAllForUser revokes all refresh tokens for a user (force-logout).
 	DeleteAllForUser(ctx context.Context, userID string) error
+	// DeleteExpired removes all tokens past their expiry, used or not, and
+	// returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
 }
 
-func NewTokenService(secret []byte, accessExpiry, refreshExpiry time.Duration, store RefreshTokenStore) *TokenService {
+// NewTokenService creates a token service that signs with the key ring's
+// active key and accepts tokens signed by any of its keys. versions holds
+// the per-user token versions that access tokens are revoked by. If
+// adminsNeedTwoFactor is set, admins get the user role in sessions they
+// did not start with two-factor authentication.
+func NewTokenService(keys *KeyRing, accessExpiry, refreshExpiry time.Duration, store RefreshTokenStore, versions TokenVersionStore, adminsNeedTwoFactor bool) *TokenService {
 	return &TokenService{
-		secret:        secret,
-		accessExpiry:  accessExpiry,
-		refreshExpiry: refreshExpiry,
-		store:         store,
+		keys:                keys,
+		accessExpiry:        accessExpiry,
+		refreshExpiry:       refreshExpiry,
+		store:               store,
+		versions:            newVersionCache(versions, tokenVersionTTL),
+		adminsNeedTwoFactor: adminsNeedTwoFactor,
 	}
 }
 
-// IssueTokenPair creates a new access + refresh token pair for a user.
-func (ts *TokenService) IssueTokenPair(ctx context.Context, user *model.User) (accessToken, refreshToken string, err error) {
-	accessToken, err = ts.newAccessToken(user)
-	if err != nil {
-		return
+// IssueTokenPair creates a new access + refresh token pair for a user who
+// logged in with a password alone, starting a new session on the given client.
+func (ts *TokenService) IssueTokenPair(ctx context.Context, user *model.User, client model.ClientInfo) (accessToken, refreshToken string, err error) {
+	return ts.issueTokenPair(ctx, user, client, false)
+}
+
+// IssueTwoFactorTokenPair is IssueTokenPair for a user who also entered a
+// two-factor code.
+func (ts *TokenService) IssueTwoFactorTokenPair(ctx context.Context, user *model.User, client model.ClientInfo) (accessToken, refreshToken string, err error) {
+	return ts.issueTokenPair(ctx, user, client, true)
+}
+
+// TwoFactorRequired reports whether users with role need two-factor
+// authentication for it to take effect.
+func (ts *TokenService) TwoFactorRequired(role model.Role) bool {
+	return ts.adminsNeedTwoFactor && role == model.RoleAdmin
+}
+
+// IssueTwoFactorChallenge returns a short-lived token proving that a user
+// entered the right password, to be exchanged with a two-factor code for a
+// token pair. It cannot be used as an access or refresh token.
+func (ts *TokenService) IssueTwoFactorChallenge(user *model.User) (string, error) {
+	now := time.Now()
+	return ts.sign("", jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		Subject:   user.ID,
+		Audience:  jwt.ClaimStrings{twoFactorAudience},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(twoFactorChallengeExpiry)),
+	})
+}
+
+// ValidateTwoFactorChallenge returns the user ID of a token issued by
+// IssueTwoFactorChallenge, or ErrInvalidToken.
+func (ts *TokenService) ValidateTwoFactorChallenge(tokenStr string) (string, error) {
+	var claims jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(tokenStr, &claims, ts.verificationKey, jwt.WithAudience(twoFactorAudience))
+	if err != nil || !token.Valid || claims.Subject == "" {
+		return "", ErrInvalidToken
 	}
-	refreshToken, err = ts.newRefreshToken(ctx, user.ID)
-	return
+	return claims.Subject, nil
 }
 
 // ValidateAccessToken parses and validates an access token, returning its claims.
+// The token's kid header selects the verification key. Refresh tokens and
+// two-factor challenges are signed with the same keys, so the token must
+// also carry the access token audience and typ header.
 func (ts *TokenService) ValidateAccessToken(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-		return ts.secret, nil
-	})
-	if err != nil || !token.Valid {
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, ts.verificationKey, jwt.WithAudience(accessAudience))
+	if err != nil || !token.Valid || token.Header["typ"] != accessTokenType {
 		return nil, ErrInvalidToken
 	}
 	claims, ok := token.Claims.(*Claims)
-	if !ok {
+	if !ok || claims.UserID == "" {
 		return nil, ErrInvalidToken
 	}
 	return claims, nil
 }
 
+// Authenticate validates an access token like ValidateAccessToken, and also
+// rejects it with ErrTokenRevoked if its user has been deleted or its
+// token version is out of date. Other errors come from reading the version.
+func (ts *TokenService) Authenticate(ctx context.Context, tokenStr string) (*Claims, error) {
+	claims, err := ts.ValidateAccessToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := ts.versions.check(ctx, claims.UserID, claims.Version); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 // RefreshAccessToken validates a refresh token and issues a new access token.
-// The old refresh token is consumed and a new one is issued (rotation).
-func (ts *TokenService) RefreshAccessToken(ctx context.Context, refreshToken string, users UserLookup) (newAccess, newRefresh string, err error) {
-	userID, err := ts.store.Find(ctx, refreshToken)
+// The old refresh token is consumed and a new one is issued in the same
+// family (rotation). Replaying a consumed token means it was stolen, or the
+// client that holds it was: the whole family is revoked, logging both the
+// thief and the user out, and model.ErrRefreshTokenReused is returned.
+func (ts *TokenService) RefreshAccessToken(ctx context.Context, refreshToken string, users UserLookup, client model.ClientInfo) (newAccess, newRefresh string, err error) {
+	session, err := ts.store.Rotate(ctx, refreshToken)
+	if errors.Is(err, model.ErrRefreshTokenReused) {
+		slog.Warn("refresh token reuse detected, token family revoked", "user", session.UserID, "family", session.ID,
+			"ip", client.IPAddress, "session_ip", session.IPAddress)
+		return "", "", err
+	}
 	if err != nil {
 		return "", "", ErrInvalidToken
 	}
 
-	user, err := users.GetUserByID(ctx, userID)
+	user, err := users.GetUserByID(ctx, session.UserID)
 	if err != nil || user == nil {
 		return "", "", ErrInvalidToken
 	}
 
-	// Rotate: delete old token, issue new pair.
-	if err = ts.store.Delete(ctx, refreshToken); err != nil {
+	// The session keeps its device name and start time; the client details
+	// are those of the latest refresh.
+	client = client.Normalize()
+	session.UserAgent, session.IPAddress = client.UserAgent, client.IPAddress
+	session.LastUsedAt = time.Now().Unix()
+	if newAccess, err = ts.newAccessToken(user, session); err != nil {
 		return "", "", err
 	}
-	return ts.IssueTokenPair(ctx, user)
+	if newRefresh, err = ts.newRefreshToken(ctx, session); err != nil {
+		return "", "", err
+	}
+	return newAccess, newRefresh, nil
 }
 
-// RevokeRefreshToken deletes a refresh token (used on logout).
+// JWKS returns the public keys other services can verify access tokens with.
+func (ts *TokenService) JWKS() JWKSet {
+	return ts.keys.JWKS()
+}
+
+// RevokeRefreshToken deletes a refresh token and its family (used on logout).
 func (ts *TokenService) RevokeRefreshToken(ctx context.Context, refreshToken string) error {
 	return ts.store.Delete(ctx, refreshToken)
 }
 
-// RevokeAllForUser revokes all refresh tokens for a user (used when deleting a user).
+// RevokeAllForUser revokes all refresh and access tokens of a user (used
+// when deleting a user, and by admins to force a logout).
 func (ts *TokenService) RevokeAllForUser(ctx context.Context, userID string) error {
-	return ts.store.DeleteAllForUser(ctx, userID)
+	if err := ts.store.DeleteAllForUser(ctx, userID); err != nil {
+		return err
+	}
+	return ts.RevokeAccessTokens(ctx, userID)
+}
+
+// RevokeAccessTokens revokes every access token issued to a user so far by
+// bumping their token version, e.g. after a role change. Their sessions
+// survive: refreshing issues access tokens with the new version.
+func (ts *TokenService) RevokeAccessTokens(ctx context.Context, userID string) error {
+	return ts.versions.bump(ctx, userID)
+}
+
+// ListSessions returns a user's active sessions, most recently used first.
+// The session currentID, that of the caller's access token, is marked current.
+func (ts *TokenService) ListSessions(ctx context.Context, userID, currentID string) ([]*model.Session, error) {
+	sessions, err := ts.store.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		s.Current = s.ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of a user's sessions. Access tokens already
+// issued for it stay valid until they expire; RenewSession revokes them.
+func (ts *TokenService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return ts.store.DeleteSession(ctx, userID, sessionID)
+}
+
+// RevokeOtherSessions revokes all of a user's sessions except keepID and
+// returns how many were revoked.
+func (ts *TokenService) RevokeOtherSessions(ctx context.Context, userID, keepID string) (int, error) {
+	sessions, err := ts.store.ListSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, s := range sessions {
+		if s.ID == keepID {
+			continue
+		}
+		if err := ts.store.DeleteSession(ctx, userID, s.ID); err != nil && !errors.Is(err, model.ErrSessionNotFound) {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
+
+// RenewSession revokes every access token of the user, as after logging out
+// their other sessions or changing their password, and issues a new token
+// pair for sessionID, the caller's own session, so that it keeps working.
+// Refresh tokens issued earlier for that session stop working. If the session
+// no longer exists, or sessionID is empty, a new session is started instead.
+func (ts *TokenService) RenewSession(ctx context.Context, user *model.User, sessionID string, client model.ClientInfo) (accessToken, refreshToken string, err error) {
+	if err := ts.RevokeAccessTokens(ctx, user.ID); err != nil {
+		return "", "", err
+	}
+	version, err := ts.versions.store.TokenVersion(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	renewed := *user
+	renewed.TokenVersion = version
+
+	sessions, err := ts.store.ListSessions(ctx, user.ID)
+	if err != nil {
+		return "", "", err
+	}
+	var session *model.Session
+	for _, s := range sessions {
+		if s.ID == sessionID {
+			session = s
+		}
+	}
+	if session == nil {
+		return ts.issueTokenPair(ctx, &renewed, client, false)
+	}
+	if err := ts.store.DeleteSession(ctx, user.ID, session.ID); err != nil {
+		return "", "", err
+	}
+
+	// As on refresh, the session keeps its device name, start time and second
+	// factor; the client details are those of this request.
+	client = client.Normalize()
+	session.UserAgent, session.IPAddress = client.UserAgent, client.IPAddress
+	session.LastUsedAt = time.Now().Unix()
+	if accessToken, err = ts.newAccessToken(&renewed, session); err != nil {
+		return "", "", err
+	}
+	if refreshToken, err = ts.newRefreshToken(ctx, session); err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
 }
 
 // UserLookup is satisfied by any type that can retrieve a user by ID.
@@ -118,37 +309,107 @@ type UserLookup interface {
 
 // --- private helpers ---
 
-func (ts *TokenService) newAccessToken(user *model.User) (string, error) {
+// twoFactorAudience marks two-factor challenge tokens, and
+// twoFactorChallengeExpiry is how long the user has to enter their code.
+const (
+	twoFactorAudience        = "2fa"
+	twoFactorChallengeExpiry = 5 * time.Minute
+)
+
+// accessAudience and accessTokenType, the aud claim and typ header of access
+// tokens, tell them apart from the other tokens signed with the same keys.
+// The typ is that of RFC 9068 JWT access tokens.
+const (
+	accessAudience  = "access"
+	accessTokenType = "at+jwt"
+)
+
+func (ts *TokenService) issueTokenPair(ctx context.Context, user *model.User, client model.ClientInfo, twoFactor bool) (accessToken, refreshToken string, err error) {
+	client = client.Normalize()
+	now := time.Now().Unix()
+	session := &model.Session{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		Device:     client.Device,
+		UserAgent:  client.UserAgent,
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		TwoFactor:  twoFactor,
+	}
+	accessToken, err = ts.newAccessToken(user, session)
+	if err != nil {
+		return
+	}
+	refreshToken, err = ts.newRefreshToken(ctx, session)
+	return
+}
+
+// newAccessToken issues an access token for a session. The role is the
+// user's, unless it needs two-factor authentication the session lacks.
+func (ts *TokenService) newAccessToken(user *model.User, session *model.Session) (string, error) {
+	role := user.Role
+	if ts.TwoFactorRequired(role) && !session.TwoFactor {
+		role = model.RoleUser
+	}
 	now := time.Now()
 	claims := &Claims{
-		UserID: user.ID,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Role:      role,
+		SessionID: session.ID,
+		Version:   user.TokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        uuid.NewString(), // jti: unique per token, prevents duplicate tokens
+			Audience:  jwt.ClaimStrings{accessAudience},
 			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(now.Add(ts.accessExpiry)),
 		},
 	}
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ts.secret)
+	return ts.sign(accessTokenType, claims)
 }
 
-func (ts *TokenService) newRefreshToken(ctx context.Context, userID string) (string, error) {
+func (ts *TokenService) newRefreshToken(ctx context.Context, session *model.Session) (string, error) {
 	// Use a signed JWT as the refresh token. A random jti (JWT ID) ensures
 	// uniqueness even if two tokens are issued within the same second.
 	now := time.Now()
 	exp := now.Add(ts.refreshExpiry)
 	claims := jwt.RegisteredClaims{
 		ID:        uuid.NewString(),
-		Subject:   userID,
+		Subject:   session.UserID,
 		IssuedAt:  jwt.NewNumericDate(now),
 		ExpiresAt: jwt.NewNumericDate(exp),
 	}
-	tokenStr, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ts.secret)
+	tokenStr, err := ts.sign("", claims)
 	if err != nil {
 		return "", err
 	}
-	if err := ts.store.Save(ctx, tokenStr, userID, exp); err != nil {
+	session.ExpiresAt = exp.Unix()
+	if err := ts.store.Save(ctx, tokenStr, session); err != nil {
 		return "", err
 	}
 	return tokenStr, nil
 }
+
+// sign signs claims with the active key, naming it in the kid header. A
+// non-empty typ replaces the default typ header "JWT".
+func (ts *TokenService) sign(typ string, claims jwt.Claims) (string, error) {
+	key := ts.keys.active
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(key.signingKey())
+}
+
+// verificationKey returns the key named by a token's kid header. Tokens
+// without one predate key rotation and use the env key. The token's alg
+// must be the key's, so a public key can never be used as an HMAC secret.
+func (ts *TokenService) verificationKey(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	key := ts.keys.key(kid)
+	if key == nil || t.Method.Alg() != key.Algorithm() {
+		return nil, ErrInvalidToken
+	}
+	return key.verificationKey(), nil
+}
@@ -2,9 +2,12 @@ package auth_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+
 	"github.com/gatheryourdeals/data/internal/auth"
 	"github.com/gatheryourdeals/data/internal/model"
 	"github.com/gatheryourdeals/data/internal/repository/sqlite"
@@ -16,6 +19,7 @@ import (
 type tokenTestEnv struct {
 	tokens *auth.TokenService
 	store  *sqlite.RefreshTokenStore
+	users  *sqlite.UserRepo
 	svc    *auth.Service
 }
 
@@ -23,14 +27,17 @@ func newTestTokenEnv(t *testing.T) *tokenTestEnv {
 	t.Helper()
 	db := testutil.NewTestDB(t)
 	store := sqlite.NewRefreshTokenStore(db)
+	users := sqlite.NewUserRepo(db)
 	tokens := auth.NewTokenService(
-		[]byte("test-secret-that-is-long-enough-32c"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("test-secret-that-is-long-enough-32c")}),
 		time.Hour,
 		7*24*time.Hour,
 		store,
+		users,
+		false,
 	)
-	svc := auth.NewService(sqlite.NewUserRepo(db))
-	return &tokenTestEnv{tokens: tokens, store: store, svc: svc}
+	svc := auth.NewService(users)
+	return &tokenTestEnv{tokens: tokens, store: store, users: users, svc: svc}
 }
 
 // newSavedUser creates a user in the DB and returns it.
@@ -55,7 +62,7 @@ func TestIssueTokenPair_ReturnsNonEmptyTokens(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	access, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -74,7 +81,7 @@ func TestIssueTokenPair_AccessTokenContainsClaims(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleAdmin)
 
-	access, _, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -95,7 +102,7 @@ func TestIssueTokenPair_RefreshTokenStoredInDB(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -115,7 +122,7 @@ func TestValidateAccessToken_Valid(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	access, _, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -142,17 +149,19 @@ func TestValidateAccessToken_WrongSecret(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	access, _, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
 
 	otherDB := testutil.NewTestDB(t)
 	otherTokens := auth.NewTokenService(
-		[]byte("completely-different-secret-32chars!"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("completely-different-secret-32chars!")}),
 		time.Hour,
 		7*24*time.Hour,
 		sqlite.NewRefreshTokenStore(otherDB),
+		sqlite.NewUserRepo(otherDB),
+		false,
 	)
 
 	_, err = otherTokens.ValidateAccessToken(access)
@@ -161,18 +170,60 @@ func TestValidateAccessToken_WrongSecret(t *testing.T) {
 	}
 }
 
+func TestValidateAccessToken_RejectsRefreshToken(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if _, err := env.tokens.ValidateAccessToken(refresh); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected refresh token to be rejected as an access token, got %v", err)
+	}
+}
+
+func TestValidateAccessToken_RequiresAudienceAndType(t *testing.T) {
+	env := newTestTokenEnv(t)
+	secret := []byte("test-secret-that-is-long-enough-32c")
+	sign := func(audience, typ string) string {
+		claims := &auth.Claims{UserID: "u1", Role: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"], token.Header["typ"] = "test", typ
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return signed
+	}
+
+	if _, err := env.tokens.ValidateAccessToken(sign("access", "at+jwt")); err != nil {
+		t.Fatalf("expected a well-formed access token to verify, got %v", err)
+	}
+	for _, tc := range []struct{ audience, typ string }{{"2fa", "at+jwt"}, {"", "at+jwt"}, {"access", "JWT"}} {
+		if _, err := env.tokens.ValidateAccessToken(sign(tc.audience, tc.typ)); !errors.Is(err, auth.ErrInvalidToken) {
+			t.Errorf("expected aud %q with typ %q to be rejected, got %v", tc.audience, tc.typ, err)
+		}
+	}
+}
+
 func TestValidateAccessToken_Expired(t *testing.T) {
 	env := newTestTokenEnv(t)
 	// Override with an already-expired access duration
 	expiredTokens := auth.NewTokenService(
-		[]byte("test-secret-that-is-long-enough-32c"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("test-secret-that-is-long-enough-32c")}),
 		-time.Second,
 		7*24*time.Hour,
 		env.store,
+		env.users,
+		false,
 	)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	access, _, err := expiredTokens.IssueTokenPair(context.Background(), user)
+	access, _, err := expiredTokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -189,12 +240,12 @@ func TestRefreshAccessToken_Success(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
 
-	newAccess, newRefresh, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc)
+	newAccess, newRefresh, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("RefreshAccessToken failed: %v", err)
 	}
@@ -213,26 +264,97 @@ func TestRefreshAccessToken_OldTokenRevoked(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
 
-	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc); err != nil {
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc, model.ClientInfo{}); err != nil {
 		t.Fatalf("first RefreshAccessToken failed: %v", err)
 	}
 
 	// Using it again should fail (rotation consumed it)
-	_, _, err = env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc)
+	_, _, err = env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc, model.ClientInfo{})
 	if err == nil {
 		t.Fatal("expected error reusing a consumed refresh token, got nil")
 	}
 }
 
+func TestRefreshAccessToken_ReuseRevokesFamily(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	_, stolen, err := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	_, otherDevice, err := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+
+	// The legitimate client rotates first, twice.
+	_, rotated, err := env.tokens.RefreshAccessToken(ctx, stolen, env.svc, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	if _, rotated, err = env.tokens.RefreshAccessToken(ctx, rotated, env.svc, model.ClientInfo{}); err != nil {
+		t.Fatalf("second RefreshAccessToken failed: %v", err)
+	}
+
+	// Replaying the stolen token revokes every token rotated from it.
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, stolen, env.svc, model.ClientInfo{}); !errors.Is(err, model.ErrRefreshTokenReused) {
+		t.Fatalf("expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, rotated, env.svc, model.ClientInfo{}); err == nil {
+		t.Error("expected the latest token of the family to be revoked")
+	}
+	// Other logins are separate families and stay valid.
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, otherDevice, env.svc, model.ClientInfo{}); err != nil {
+		t.Errorf("expected another login to survive, got %v", err)
+	}
+}
+
+func TestRefreshAccessToken_KeepsSessionAndUpdatesClient(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	access, refresh, err := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{Device: "  Phone ", UserAgent: "app/1", IPAddress: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	claims, err := env.tokens.ValidateAccessToken(access)
+	if err != nil || claims.SessionID == "" {
+		t.Fatalf("expected a session ID in the access token, got %+v, %v", claims, err)
+	}
+
+	newAccess, _, err := env.tokens.RefreshAccessToken(ctx, refresh, env.svc, model.ClientInfo{UserAgent: "app/2", IPAddress: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	if newClaims, _ := env.tokens.ValidateAccessToken(newAccess); newClaims.SessionID != claims.SessionID {
+		t.Errorf("expected the refreshed token to keep session %q, got %q", claims.SessionID, newClaims.SessionID)
+	}
+
+	sessions, err := env.tokens.ListSessions(ctx, user.ID, claims.SessionID)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected one session, got %d", len(sessions))
+	}
+	s := sessions[0]
+	if s.ID != claims.SessionID || !s.Current || s.Device != "Phone" || s.UserAgent != "app/2" || s.IPAddress != "10.0.0.2" {
+		t.Errorf("unexpected session: %+v", s)
+	}
+}
+
 func TestRefreshAccessToken_InvalidToken(t *testing.T) {
 	env := newTestTokenEnv(t)
 
-	_, _, err := env.tokens.RefreshAccessToken(context.Background(), "not-a-real-token", env.svc)
+	_, _, err := env.tokens.RefreshAccessToken(context.Background(), "not-a-real-token", env.svc, model.ClientInfo{})
 	if err == nil {
 		t.Fatal("expected error for invalid refresh token, got nil")
 	}
@@ -244,7 +366,7 @@ func TestRevokeRefreshToken_PreventsReuse(t *testing.T) {
 	env := newTestTokenEnv(t)
 	user := newSavedUser(t, env.svc, model.RoleUser)
 
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -253,7 +375,7 @@ func TestRevokeRefreshToken_PreventsReuse(t *testing.T) {
 		t.Fatalf("RevokeRefreshToken failed: %v", err)
 	}
 
-	_, _, err = env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc)
+	_, _, err = env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc, model.ClientInfo{})
 	if err == nil {
 		t.Fatal("expected error using revoked refresh token, got nil")
 	}
@@ -268,7 +390,7 @@ func TestRevokeAllForUser_RevokesAllTokens(t *testing.T) {
 	// Issue multiple refresh tokens (simulating multiple devices)
 	var refreshTokens []string
 	for i := 0; i < 3; i++ {
-		_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+		_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 		if err != nil {
 			t.Fatalf("IssueTokenPair failed: %v", err)
 		}
@@ -280,9 +402,217 @@ func TestRevokeAllForUser_RevokesAllTokens(t *testing.T) {
 	}
 
 	for _, refresh := range refreshTokens {
-		_, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc)
+		_, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.svc, model.ClientInfo{})
 		if err == nil {
 			t.Error("expected error for revoked token, got nil")
 		}
 	}
 }
+
+// --- Authenticate ---
+
+func TestAuthenticate_Valid(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+
+	access, _, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	claims, err := env.tokens.Authenticate(context.Background(), access)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if claims.UserID != user.ID {
+		t.Errorf("expected UserID %q, got %q", user.ID, claims.UserID)
+	}
+}
+
+func TestAuthenticate_RevokedAccessTokens(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	access, refresh, err := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	// Cache the current version first, so the revocation must invalidate it.
+	if _, err := env.tokens.Authenticate(ctx, access); err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if err := env.tokens.RevokeAccessTokens(ctx, user.ID); err != nil {
+		t.Fatalf("RevokeAccessTokens failed: %v", err)
+	}
+
+	if _, err := env.tokens.Authenticate(ctx, access); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Fatalf("expected ErrTokenRevoked, got %v", err)
+	}
+
+	// The session survives: refreshing issues an access token that works.
+	newAccess, _, err := env.tokens.RefreshAccessToken(ctx, refresh, env.users, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, newAccess); err != nil {
+		t.Errorf("expected refreshed access token to authenticate, got %v", err)
+	}
+}
+
+func TestRenewSession(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	access, refresh, _ := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{Device: "Phone"})
+	claims, _ := env.tokens.ValidateAccessToken(access)
+
+	newAccess, newRefresh, err := env.tokens.RenewSession(ctx, user, claims.SessionID, model.ClientInfo{IPAddress: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("RenewSession failed: %v", err)
+	}
+	if _, err := env.tokens.Authenticate(ctx, access); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("expected the old access token revoked, got %v", err)
+	}
+	newClaims, err := env.tokens.Authenticate(ctx, newAccess)
+	if err != nil || newClaims.SessionID != claims.SessionID {
+		t.Fatalf("expected the new access token to work in session %q, got %+v, %v", claims.SessionID, newClaims, err)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, refresh, env.users, model.ClientInfo{}); err == nil {
+		t.Error("expected the old refresh token to stop working")
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, newRefresh, env.users, model.ClientInfo{}); err != nil {
+		t.Errorf("expected the new refresh token to work, got %v", err)
+	}
+
+	sessions, _ := env.tokens.ListSessions(ctx, user.ID, "")
+	if len(sessions) != 1 || sessions[0].Device != "Phone" {
+		t.Errorf("expected the phone session to continue, got %+v", sessions)
+	}
+}
+
+func TestRenewSession_UnknownSessionStartsOne(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	access, _, err := env.tokens.RenewSession(ctx, user, "", model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RenewSession failed: %v", err)
+	}
+	if claims, err := env.tokens.Authenticate(ctx, access); err != nil || claims.SessionID == "" {
+		t.Errorf("expected a working token in a new session, got %+v, %v", claims, err)
+	}
+}
+
+func TestAuthenticate_DeletedUser(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	ctx := context.Background()
+
+	access, _, err := env.tokens.IssueTokenPair(ctx, user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if err := env.users.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+
+	if _, err := env.tokens.Authenticate(ctx, access); !errors.Is(err, auth.ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+// --- Two-factor ---
+
+func TestTwoFactorChallenge_Valid(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+
+	challenge, err := env.tokens.IssueTwoFactorChallenge(user)
+	if err != nil {
+		t.Fatalf("IssueTwoFactorChallenge failed: %v", err)
+	}
+	userID, err := env.tokens.ValidateTwoFactorChallenge(challenge)
+	if err != nil {
+		t.Fatalf("ValidateTwoFactorChallenge failed: %v", err)
+	}
+	if userID != user.ID {
+		t.Errorf("expected user ID %q, got %q", user.ID, userID)
+	}
+}
+
+func TestTwoFactorChallenge_NotInterchangeableWithAccessToken(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+
+	challenge, _ := env.tokens.IssueTwoFactorChallenge(user)
+	if _, err := env.tokens.ValidateAccessToken(challenge); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected challenge to be rejected as an access token, got %v", err)
+	}
+
+	access, _, _ := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if _, err := env.tokens.ValidateTwoFactorChallenge(access); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected access token to be rejected as a challenge, got %v", err)
+	}
+}
+
+func TestTwoFactorRequiredForAdmins(t *testing.T) {
+	env := newTestTokenEnv(t)
+	tokens := auth.NewTokenService(
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("test-secret-that-is-long-enough-32c")}),
+		time.Hour,
+		7*24*time.Hour,
+		env.store,
+		env.users,
+		true,
+	)
+	admin := newSavedUser(t, env.svc, model.RoleAdmin)
+	ctx := context.Background()
+
+	if !tokens.TwoFactorRequired(model.RoleAdmin) || tokens.TwoFactorRequired(model.RoleUser) {
+		t.Error("expected two-factor authentication to be required for admins only")
+	}
+
+	// A password-only session gets the user role, even after a refresh.
+	access, refresh, err := tokens.IssueTokenPair(ctx, admin, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if claims, _ := tokens.ValidateAccessToken(access); claims.Role != model.RoleUser {
+		t.Errorf("expected role %q without two-factor, got %q", model.RoleUser, claims.Role)
+	}
+	access, _, err = tokens.RefreshAccessToken(ctx, refresh, env.users, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	if claims, _ := tokens.ValidateAccessToken(access); claims.Role != model.RoleUser {
+		t.Errorf("expected role %q after refresh, got %q", model.RoleUser, claims.Role)
+	}
+
+	// A two-factor session keeps the admin role, and the session says so.
+	access, refresh, err = tokens.IssueTwoFactorTokenPair(ctx, admin, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTwoFactorTokenPair failed: %v", err)
+	}
+	if claims, _ := tokens.ValidateAccessToken(access); claims.Role != model.RoleAdmin {
+		t.Errorf("expected role %q with two-factor, got %q", model.RoleAdmin, claims.Role)
+	}
+	access, _, err = tokens.RefreshAccessToken(ctx, refresh, env.users, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshAccessToken failed: %v", err)
+	}
+	claims, _ := tokens.ValidateAccessToken(access)
+	if claims.Role != model.RoleAdmin {
+		t.Errorf("expected role %q after refresh, got %q", model.RoleAdmin, claims.Role)
+	}
+	sessions, err := tokens.ListSessions(ctx, admin.ID, claims.SessionID)
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	for _, s := range sessions {
+		if s.TwoFactor != s.Current {
+			t.Errorf("session %s: expected TwoFactor %v, got %v", s.ID, s.Current, s.TwoFactor)
+		}
+	}
+}
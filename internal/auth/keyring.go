@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// EnvKeyID is the key ID of the secret from the GYD_JWT_SECRET environment
+// variable. Tokens without a kid header were signed before key rotation
+// existed and are verified with this key.
+const EnvKeyID = "env"
+
+var (
+	ErrKeyNotFound  = errors.New("signing key not found")
+	ErrKeyActive    = errors.New("the active signing key cannot be retired")
+	ErrNoSigningKey = errors.New("no active signing key")
+)
+
+// SigningKey is a token signing key, identified in the tokens it signs by
+// the kid header. It is either an HMAC-SHA256 Secret (HS256), or a Private
+// key: ed25519.PrivateKey (EdDSA) or *rsa.PrivateKey (RS256). The public
+// half of a private key is published so that other services can verify
+// tokens without being able to sign them.
+type SigningKey struct {
+	ID      string
+	Secret  []byte
+	Private crypto.Signer
+}
+
+// method returns the JWT signing method of the key.
+func (k *SigningKey) method() jwt.SigningMethod {
+	switch k.Private.(type) {
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// Algorithm returns the JWT alg of the key: HS256, EdDSA or RS256.
+func (k *SigningKey) Algorithm() string {
+	return k.method().Alg()
+}
+
+// signingKey returns the key material jwt signs with.
+func (k *SigningKey) signingKey() any {
+	if k.Private != nil {
+		return k.Private
+	}
+	return k.Secret
+}
+
+// verificationKey returns the key material jwt verifies with.
+func (k *SigningKey) verificationKey() any {
+	if k.Private != nil {
+		return k.Private.Public()
+	}
+	return k.Secret
+}
+
+// KeyRing holds the keys access and refresh tokens are verified with. One of
+// them, the active key, signs new tokens; the others keep the tokens they
+// signed valid until they are retired.
+type KeyRing struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+// NewKeyRing creates a key ring that signs with active and also accepts
+// tokens signed by others.
+func NewKeyRing(active SigningKey, others ...SigningKey) *KeyRing {
+	ring := &KeyRing{keys: map[string]*SigningKey{}}
+	for _, k := range others {
+		ring.keys[k.ID] = &k
+	}
+	ring.active = &active
+	ring.keys[active.ID] = &active
+	return ring
+}
+
+// ActiveID returns the ID of the key that signs new tokens.
+func (r *KeyRing) ActiveID() string {
+	return r.active.ID
+}
+
+// IDs returns the IDs of all keys tokens are accepted from, sorted.
+func (r *KeyRing) IDs() []string {
+	ids := make([]string, 0, len(r.keys))
+	for id := range r.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// key returns the key with the given ID, or nil. An empty ID selects
+// EnvKeyID.
+func (r *KeyRing) key(id string) *SigningKey {
+	if id == "" {
+		id = EnvKeyID
+	}
+	return r.keys[id]
+}
+
+// StoredKey is a signing key as kept in a key file: either an HMAC secret,
+// or the path of a PEM file holding an Ed25519 or RSA private key.
+type StoredKey struct {
+	ID        string `json:"kid"`
+	Alg       string `json:"alg,omitempty"`    // HS256 (default), EdDSA or RS256
+	Secret    string `json:"secret,omitempty"` // hex, for HS256
+	PEM       string `json:"pem,omitempty"`    // private key file, relative to the key file's directory
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// KeyFile is the on-disk key ring managed by the "keys" CLI commands. It
+// holds secrets, so it is written with mode 0600 and must never be
+// committed or shared.
+type KeyFile struct {
+	Active string       `json:"active,omitempty"` // ID of the signing key, "" to sign with the env key
+	Keys   []*StoredKey `json:"keys"`
+}
+
+// ReadKeyFile reads a key file. A file that does not exist yields an empty
+// key file, so the first "keys add" can create it.
+func ReadKeyFile(path string) (*KeyFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &KeyFile{Keys: []*StoredKey{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+	var f KeyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse key file: %w", err)
+	}
+	for _, k := range f.Keys {
+		if k.ID == "" || k.ID == EnvKeyID {
+			return nil, fmt.Errorf("key file: invalid key ID %q", k.ID)
+		}
+		if k.PEM != "" {
+			continue
+		}
+		if secret, err := hex.DecodeString(k.Secret); err != nil || len(secret) < 32 {
+			return nil, fmt.Errorf("key file: key %q must have a hex secret of at least 32 bytes or a pem file", k.ID)
+		}
+	}
+	if f.Active != "" && f.find(f.Active) == nil {
+		return nil, fmt.Errorf("key file: active key %q is not in the file", f.Active)
+	}
+	return &f, nil
+}
+
+// Write saves the key file atomically, readable by its owner only.
+func (f *KeyFile) Write(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal key file: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write key file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write key file: %w", err)
+	}
+	return nil
+}
+
+// AddKey generates a new random 256-bit key and adds it to the file. The
+// key is accepted for verification once servers reload the file, but does
+// not sign tokens until it is activated.
+func (f *KeyFile) AddKey(now time.Time) (*StoredKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("generate key ID: %w", err)
+	}
+	key := &StoredKey{
+		ID:        now.UTC().Format("20060102") + "-" + hex.EncodeToString(suffix),
+		Secret:    hex.EncodeToString(secret),
+		CreatedAt: now.Unix(),
+	}
+	f.Keys = append(f.Keys, key)
+	return key, nil
+}
+
+// AddPEMKey adds the Ed25519 or RSA private key in the PEM file at pemPath.
+// The key is read once to check it and its algorithm; servers read the file
+// again each time they load the key ring.
+func (f *KeyFile) AddPEMKey(pemPath string, now time.Time) (*StoredKey, error) {
+	private, err := ReadPrivateKeyPEM(pemPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := f.AddKey(now)
+	if err != nil {
+		return nil, err
+	}
+	key.Secret, key.PEM = "", pemPath
+	key.Alg = (&SigningKey{Private: private}).Algorithm()
+	return key, nil
+}
+
+// Activate makes the key with the given ID the signing key.
+func (f *KeyFile) Activate(id string) error {
+	if f.find(id) == nil {
+		return fmt.Errorf("%w: %q", ErrKeyNotFound, id)
+	}
+	f.Active = id
+	return nil
+}
+
+// Retire removes a key, invalidating every token it signed. The active key
+// cannot be retired; activate another one first.
+func (f *KeyFile) Retire(id string) error {
+	if id == f.Active {
+		return fmt.Errorf("%w: %q", ErrKeyActive, id)
+	}
+	for i, k := range f.Keys {
+		if k.ID == id {
+			f.Keys = append(f.Keys[:i], f.Keys[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrKeyNotFound, id)
+}
+
+// KeyRing builds the key ring servers use from the file and the optional
+// GYD_JWT_SECRET value envSecret. The env key is accepted alongside the
+// file's keys, and signs when the file has no active key. dir is the key
+// file's directory, which relative PEM paths are resolved against.
+func (f *KeyFile) KeyRing(dir string, envSecret []byte) (*KeyRing, error) {
+	var active *SigningKey
+	var others []SigningKey
+	if envSecret != nil {
+		env := SigningKey{ID: EnvKeyID, Secret: envSecret}
+		if f.Active == "" {
+			active = &env
+		} else {
+			others = append(others, env)
+		}
+	}
+	for _, k := range f.Keys {
+		key := SigningKey{ID: k.ID}
+		if k.PEM != "" {
+			path := k.PEM
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			private, err := ReadPrivateKeyPEM(path)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.ID, err)
+			}
+			key.Private = private
+		} else {
+			secret, err := hex.DecodeString(k.Secret)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.ID, err)
+			}
+			key.Secret = secret
+		}
+		if k.Alg != "" && k.Alg != key.Algorithm() {
+			return nil, fmt.Errorf("key %q: file holds a %s key, not %s", k.ID, key.Algorithm(), k.Alg)
+		}
+		if k.ID == f.Active {
+			active = &key
+		} else {
+			others = append(others, key)
+		}
+	}
+	if active == nil {
+		return nil, ErrNoSigningKey
+	}
+	return NewKeyRing(*active, others...), nil
+}
+
+func (f *KeyFile) find(id string) *StoredKey {
+	for _, k := range f.Keys {
+		if k.ID == id {
+			return k
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+var (
+	oldKey = auth.SigningKey{ID: "old", Secret: []byte("old-secret-that-is-long-enough-32c")}
+	newKey = auth.SigningKey{ID: "new", Secret: []byte("new-secret-that-is-long-enough-32c")}
+)
+
+// tokenKid returns the kid header of a token without verifying it.
+func tokenKid(t *testing.T, tokenStr string) string {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(tokenStr, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestKeyRing_RotationKeepsOldTokensValid(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	service := func(keys *auth.KeyRing) *auth.TokenService {
+		return auth.NewTokenService(keys, time.Hour, 7*24*time.Hour, env.store, env.users, false)
+	}
+
+	before := service(auth.NewKeyRing(oldKey))
+	oldAccess, _, err := before.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if kid := tokenKid(t, oldAccess); kid != "old" {
+		t.Errorf("kid = %q, want old", kid)
+	}
+
+	// After rotation, new tokens are signed by the new key and the old key
+	// still verifies the tokens it signed.
+	rotated := service(auth.NewKeyRing(newKey, oldKey))
+	newAccess, _, err := rotated.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+	if kid := tokenKid(t, newAccess); kid != "new" {
+		t.Errorf("kid = %q, want new", kid)
+	}
+	for _, token := range []string{oldAccess, newAccess} {
+		if claims, err := rotated.ValidateAccessToken(token); err != nil || claims.UserID != user.ID {
+			t.Errorf("expected the rotated ring to accept a %s token, got %v", tokenKid(t, token), err)
+		}
+	}
+
+	// Once the old key is retired, its tokens are rejected.
+	retired := service(auth.NewKeyRing(newKey))
+	if _, err := retired.ValidateAccessToken(oldAccess); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected a token from a retired key to be rejected, got %v", err)
+	}
+}
+
+func TestKeyRing_TokenWithoutKidUsesEnvKey(t *testing.T) {
+	env := newTestTokenEnv(t)
+	envKey := auth.SigningKey{ID: auth.EnvKeyID, Secret: []byte("env-secret-that-is-long-enough-32c")}
+
+	// A token signed with the env key alone, as before key rotation existed,
+	// has no kid header.
+	claims := &auth.Claims{UserID: "u1", Role: model.RoleUser, RegisteredClaims: jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{"access"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unsigned.Header["typ"] = "at+jwt"
+	legacy, err := unsigned.SignedString(envKey.Secret)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	withEnv := auth.NewTokenService(auth.NewKeyRing(newKey, envKey), time.Hour, time.Hour, env.store, env.users, false)
+	if got, err := withEnv.ValidateAccessToken(legacy); err != nil || got.UserID != "u1" {
+		t.Errorf("expected a token without kid to verify with the env key, got %v", err)
+	}
+	withoutEnv := auth.NewTokenService(auth.NewKeyRing(newKey), time.Hour, time.Hour, env.store, env.users, false)
+	if _, err := withoutEnv.ValidateAccessToken(legacy); err == nil {
+		t.Error("expected a token without kid to be rejected once the env key is gone")
+	}
+}
+
+func TestKeyFile_AddActivateRetire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	file, err := auth.ReadKeyFile(path)
+	if err != nil || len(file.Keys) != 0 {
+		t.Fatalf("expected a missing key file to read as empty, got %+v, %v", file, err)
+	}
+	if _, err := file.KeyRing(filepath.Dir(path), nil); !errors.Is(err, auth.ErrNoSigningKey) {
+		t.Errorf("expected ErrNoSigningKey without any key, got %v", err)
+	}
+
+	first, err := file.AddKey(time.Now())
+	if err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	second, err := file.AddKey(time.Now())
+	if err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("expected distinct key IDs, got %q twice", first.ID)
+	}
+	if err := file.Activate(first.ID); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := file.Write(path); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600, got %v, %v", info.Mode().Perm(), err)
+	}
+
+	file, err = auth.ReadKeyFile(path)
+	if err != nil {
+		t.Fatalf("ReadKeyFile failed: %v", err)
+	}
+	ring, err := file.KeyRing(filepath.Dir(path), []byte("env-secret-that-is-long-enough-32c"))
+	if err != nil {
+		t.Fatalf("KeyRing failed: %v", err)
+	}
+	if ring.ActiveID() != first.ID || len(ring.IDs()) != 3 {
+		t.Errorf("expected %s active among 3 keys, got %s among %v", first.ID, ring.ActiveID(), ring.IDs())
+	}
+
+	if err := file.Retire(first.ID); !errors.Is(err, auth.ErrKeyActive) {
+		t.Errorf("expected ErrKeyActive retiring the active key, got %v", err)
+	}
+	if err := file.Activate("missing"); !errors.Is(err, auth.ErrKeyNotFound) {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+	if err := file.Activate(second.ID); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := file.Retire(first.ID); err != nil {
+		t.Fatalf("Retire failed: %v", err)
+	}
+	if ring, err = file.KeyRing(filepath.Dir(path), nil); err != nil || ring.ActiveID() != second.ID || len(ring.IDs()) != 1 {
+		t.Errorf("expected only %s after retiring, got %v, %v", second.ID, ring, err)
+	}
+}
+
+// writePEM writes a PKCS #8 private key to a PEM file in dir.
+func writePEM(t *testing.T, dir, name string, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write PEM: %v", err)
+	}
+	return path
+}
+
+func TestKeyRing_AsymmetricKeysAndJWKS(t *testing.T) {
+	env := newTestTokenEnv(t)
+	user := newSavedUser(t, env.svc, model.RoleUser)
+	dir := t.TempDir()
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	file := &auth.KeyFile{}
+	edKey, err := file.AddPEMKey(writePEM(t, dir, "ed.pem", edPrivate), time.Now())
+	if err != nil || edKey.Alg != "EdDSA" {
+		t.Fatalf("AddPEMKey(Ed25519) = %+v, %v", edKey, err)
+	}
+	rsaKey, err := file.AddPEMKey("rsa.pem", time.Now()) // not written yet
+	if err == nil {
+		t.Fatalf("expected an error for a missing PEM file, got %+v", rsaKey)
+	}
+	writePEM(t, dir, "rsa.pem", rsaPrivate)
+	if rsaKey, err = file.AddPEMKey(filepath.Join(dir, "rsa.pem"), time.Now()); err != nil || rsaKey.Alg != "RS256" {
+		t.Fatalf("AddPEMKey(RSA) = %+v, %v", rsaKey, err)
+	}
+	rsaKey.PEM = "rsa.pem" // relative to the key file's directory
+
+	for _, active := range []*auth.StoredKey{edKey, rsaKey} {
+		if err := file.Activate(active.ID); err != nil {
+			t.Fatalf("Activate failed: %v", err)
+		}
+		ring, err := file.KeyRing(dir, []byte("env-secret-that-is-long-enough-32c"))
+		if err != nil {
+			t.Fatalf("KeyRing failed: %v", err)
+		}
+		tokens := auth.NewTokenService(ring, time.Hour, time.Hour, env.store, env.users, false)
+		access, _, err := tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+		if err != nil {
+			t.Fatalf("IssueTokenPair failed: %v", err)
+		}
+		if claims, err := tokens.ValidateAccessToken(access); err != nil || claims.UserID != user.ID {
+			t.Errorf("%s: expected the token to validate, got %v", active.Alg, err)
+		}
+
+		// Another service verifies the token with the published public key.
+		jwks := tokens.JWKS()
+		if len(jwks.Keys) != 2 {
+			t.Fatalf("expected the two public keys and no HMAC key, got %+v", jwks.Keys)
+		}
+		var published auth.JWK
+		for _, k := range jwks.Keys {
+			if k.Kid == active.ID {
+				published = k
+			}
+		}
+		if published.Alg != active.Alg || published.Use != "sig" {
+			t.Fatalf("unexpected JWK for %s: %+v", active.ID, published)
+		}
+		_, err = jwt.Parse(access, func(token *jwt.Token) (any, error) {
+			return publicKeyFromJWK(t, published), nil
+		}, jwt.WithValidMethods([]string{active.Alg}))
+		if err != nil {
+			t.Errorf("%s: expected the JWK to verify the token, got %v", active.Alg, err)
+		}
+	}
+}
+
+// publicKeyFromJWK decodes an OKP or RSA JWK the way a verifying service would.
+func publicKeyFromJWK(t *testing.T, k auth.JWK) any {
+	t.Helper()
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("failed to decode JWK field: %v", err)
+		}
+		return b
+	}
+	if k.Kty == "OKP" {
+		return ed25519.PublicKey(decode(k.X))
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(k.N)), E: int(new(big.Int).SetBytes(decode(k.E)).Int64())}
+}
+
+func TestKeyRing_RejectsAlgorithmConfusion(t *testing.T) {
+	env := newTestTokenEnv(t)
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+	edKey := auth.SigningKey{ID: "ed", Private: edPrivate}
+	tokens := auth.NewTokenService(auth.NewKeyRing(edKey), time.Hour, time.Hour, env.store, env.users, false)
+
+	// An attacker who knows the public key signs an HS256 token with it.
+	claims := &auth.Claims{UserID: "u1", Role: model.RoleAdmin, RegisteredClaims: jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{"access"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = "ed"
+	forged.Header["typ"] = "at+jwt"
+	signed, err := forged.SignedString([]byte(edPrivate.Public().(ed25519.PublicKey)))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	if _, err := tokens.ValidateAccessToken(signed); err == nil {
+		t.Error("expected an HS256 token naming an EdDSA key to be rejected")
+	}
+}
+
+func TestParsePrivateKeyPEM_RejectsWeakAndUnsupportedKeys(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(weak)})
+	if _, err := auth.ParsePrivateKeyPEM(pkcs1); err == nil {
+		t.Error("expected a 1024-bit RSA key to be rejected")
+	}
+	if _, err := auth.ParsePrivateKeyPEM([]byte("not a key")); err == nil {
+		t.Error("expected an error without a PEM block")
+	}
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte{1}})
+	if _, err := auth.ParsePrivateKeyPEM(public); err == nil {
+		t.Error("expected a public key to be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// LoginAttemptStore persists failed login counts, so that lockouts survive
+// restarts and are shared by every server on the same database.
+type LoginAttemptStore interface {
+	// Get returns the failed login count of a username or IP, nil if it has none.
+	Get(ctx context.Context, subject model.LoginSubject, key string) (*model.LoginAttempts, error)
+	// RecordFailure counts a failed login at the unix time at. A count whose
+	// last failure was before resetBefore starts over at one.
+	RecordFailure(ctx context.Context, subject model.LoginSubject, key string, at, resetBefore int64) error
+	// Reset clears the failed login count of a username or IP.
+	Reset(ctx context.Context, subject model.LoginSubject, key string) error
+	// DeleteStale removes counts whose last failure was before the unix time
+	// before, and returns how many were removed.
+	DeleteStale(ctx context.Context, before int64) (int64, error)
+}
+
+// LoginPolicy configures brute-force protection for logins.
+type LoginPolicy struct {
+	MaxAttempts   int           // failures per username before it is locked
+	IPMaxAttempts int           // failures per client IP before it is locked
+	Lockout       time.Duration // first lockout; doubles with every further failure
+	MaxLockout    time.Duration // longest lockout
+	ResetAfter    time.Duration // a count is forgotten this long after its last failure
+}
+
+// LoginGuard counts failed logins per username and per client IP and locks
+// out either once it reaches its limit. Each failure past the limit doubles
+// the lockout, up to MaxLockout.
+type LoginGuard struct {
+	store  LoginAttemptStore
+	policy LoginPolicy
+}
+
+// NewLoginGuard creates a login guard that enforces policy with counts kept
+// in store.
+func NewLoginGuard(store LoginAttemptStore, policy LoginPolicy) *LoginGuard {
+	return &LoginGuard{store: store, policy: policy}
+}
+
+// Check returns how long a login for username from ip must wait, 0 if it
+// may go ahead. Attempts refused this way are not counted as failures.
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, s := range g.subjects(username, ip) {
+		a, err := g.store.Get(ctx, s.subject, s.key)
+		if err != nil {
+			return 0, err
+		}
+		if d := g.lockedUntil(a, s.limit).Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait, nil
+}
+
+// Fail records a failed login for username from ip, logging a warning when
+// it locks either of them out.
+func (g *LoginGuard) Fail(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	resetBefore := now.Add(-g.policy.ResetAfter).Unix()
+	for _, s := range g.subjects(username, ip) {
+		if err := g.store.RecordFailure(ctx, s.subject, s.key, now.Unix(), resetBefore); err != nil {
+			return err
+		}
+		a, err := g.store.Get(ctx, s.subject, s.key)
+		if err != nil {
+			return err
+		}
+		if d := g.lockout(a, s.limit); d > 0 {
+			slog.Warn("login locked out after failed attempts", "subject", s.subject, "key", s.key,
+				"failures", a.Failures, "lockout", d)
+		}
+	}
+	return nil
+}
+
+// Succeed clears the failed logins of username. Those of the client IP are
+// kept, so an attacker cannot reset them by logging into their own account.
+func (g *LoginGuard) Succeed(ctx context.Context, username string) error {
+	return g.store.Reset(ctx, model.LoginSubjectUsername, username)
+}
+
+// Unlock clears the failed logins of a username or IP, ending any lockout.
+func (g *LoginGuard) Unlock(ctx context.Context, subject model.LoginSubject, key string) error {
+	return g.store.Reset(ctx, subject, key)
+}
+
+// Sweep removes counts that have been forgotten and returns how many.
+func (g *LoginGuard) Sweep(ctx context.Context) (int64, error) {
+	return g.store.DeleteStale(ctx, time.Now().Add(-g.policy.ResetAfter).Unix())
+}
+
+type loginSubject struct {
+	subject model.LoginSubject
+	key     string
+	limit   int
+}
+
+// subjects lists what a login is counted against, with their limits.
+func (g *LoginGuard) subjects(username, ip string) []loginSubject {
+	return []loginSubject{
+		{model.LoginSubjectUsername, username, g.policy.MaxAttempts},
+		{model.LoginSubjectIP, ip, g.policy.IPMaxAttempts},
+	}
+}
+
+// lockedUntil returns when the lockout of a count ends; the zero time if it
+// is not locked out.
+func (g *LoginGuard) lockedUntil(a *model.LoginAttempts, limit int) time.Time {
+	d := g.lockout(a, limit)
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Unix(a.LastFailureAt, 0).Add(d)
+}
+
+// lockout returns how long a count locks its subject out after its last
+// failure: Lockout at the limit, doubling with every failure past it.
+func (g *LoginGuard) lockout(a *model.LoginAttempts, limit int) time.Duration {
+	if a == nil || a.Failures < limit {
+		return 0
+	}
+	d := g.policy.Lockout
+	for i := limit; i < a.Failures && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, g.policy.MaxLockout)
+}
@@ -0,0 +1,124 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func newTestGuard(t *testing.T) (*auth.LoginGuard, *sqlite.LoginAttemptStore) {
+	t.Helper()
+	store := sqlite.NewLoginAttemptStore(testutil.NewTestDB(t))
+	guard := auth.NewLoginGuard(store, auth.LoginPolicy{
+		MaxAttempts:   3,
+		IPMaxAttempts: 5,
+		Lockout:       time.Minute,
+		MaxLockout:    10 * time.Minute,
+		ResetAfter:    time.Hour,
+	})
+	return guard, store
+}
+
+func failLogins(t *testing.T, guard *auth.LoginGuard, username, ip string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := guard.Fail(context.Background(), username, ip); err != nil {
+			t.Fatalf("Fail failed: %v", err)
+		}
+	}
+}
+
+func TestLoginGuard_LocksUsernameAtLimit(t *testing.T) {
+	guard, _ := newTestGuard(t)
+	ctx := context.Background()
+
+	failLogins(t, guard, "alice", "192.0.2.1", 2)
+	if wait, err := guard.Check(ctx, "alice", "192.0.2.1"); err != nil || wait != 0 {
+		t.Fatalf("expected no lockout below the limit, got %v, %v", wait, err)
+	}
+
+	failLogins(t, guard, "alice", "192.0.2.1", 1)
+	wait, err := guard.Check(ctx, "alice", "198.51.100.7")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Errorf("expected a lockout of up to 1m from any IP, got %v", wait)
+	}
+
+	// Other usernames from the same IP are not locked yet.
+	if wait, _ := guard.Check(ctx, "bob", "192.0.2.1"); wait != 0 {
+		t.Errorf("expected bob not to be locked out, got %v", wait)
+	}
+}
+
+func TestLoginGuard_LockoutDoubles(t *testing.T) {
+	guard, store := newTestGuard(t)
+	ctx := context.Background()
+
+	// Five failures, two past the limit of three, the last just now.
+	now := time.Now().Unix()
+	for i := 0; i < 5; i++ {
+		if err := store.RecordFailure(ctx, model.LoginSubjectUsername, "alice", now, 0); err != nil {
+			t.Fatalf("RecordFailure failed: %v", err)
+		}
+	}
+	wait, _ := guard.Check(ctx, "alice", "192.0.2.1")
+	if wait <= 3*time.Minute || wait > 4*time.Minute {
+		t.Errorf("expected a lockout of about 4m, got %v", wait)
+	}
+
+	// Doubling stops at MaxLockout.
+	for i := 0; i < 10; i++ {
+		_ = store.RecordFailure(ctx, model.LoginSubjectUsername, "alice", now, 0)
+	}
+	wait, _ = guard.Check(ctx, "alice", "192.0.2.1")
+	if wait <= 9*time.Minute || wait > 10*time.Minute {
+		t.Errorf("expected a lockout of about 10m, got %v", wait)
+	}
+}
+
+func TestLoginGuard_LocksIP(t *testing.T) {
+	guard, _ := newTestGuard(t)
+	ctx := context.Background()
+
+	// Spread over usernames so that none of them reaches its own limit.
+	for _, username := range []string{"a", "b", "c", "d", "e"} {
+		failLogins(t, guard, username, "192.0.2.1", 1)
+	}
+	if wait, _ := guard.Check(ctx, "f", "192.0.2.1"); wait == 0 {
+		t.Error("expected the IP to be locked out")
+	}
+	if wait, _ := guard.Check(ctx, "f", "198.51.100.7"); wait != 0 {
+		t.Errorf("expected other IPs not to be locked out, got %v", wait)
+	}
+
+	if err := guard.Unlock(ctx, model.LoginSubjectIP, "192.0.2.1"); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if wait, _ := guard.Check(ctx, "f", "192.0.2.1"); wait != 0 {
+		t.Errorf("expected the IP to be unlocked, got %v", wait)
+	}
+}
+
+func TestLoginGuard_SucceedClearsUsernameOnly(t *testing.T) {
+	guard, store := newTestGuard(t)
+	ctx := context.Background()
+
+	failLogins(t, guard, "alice", "192.0.2.1", 2)
+	if err := guard.Succeed(ctx, "alice"); err != nil {
+		t.Fatalf("Succeed failed: %v", err)
+	}
+
+	if got, _ := store.Get(ctx, model.LoginSubjectUsername, "alice"); got != nil {
+		t.Errorf("expected alice's failures to be cleared, got %+v", got)
+	}
+	if got, _ := store.Get(ctx, model.LoginSubjectIP, "192.0.2.1"); got == nil || got.Failures != 2 {
+		t.Errorf("expected the IP to keep its 2 failures, got %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
@@ -8,8 +9,18 @@ import (
 
 const bcryptCost = 12
 
+// maxPasswordBytes is the longest password bcrypt accepts.
+const maxPasswordBytes = 72
+
+// ErrPasswordTooLong is returned for a password bcrypt cannot hash.
+var ErrPasswordTooLong = errors.New("password must be at most 72 bytes")
+
 // HashPassword hashes a plaintext password using bcrypt.
+// Returns ErrPasswordTooLong for a password over maxPasswordBytes.
 func HashPassword(password string) (string, error) {
+	if len(password) > maxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
 	if err != nil {
 		return "", fmt.Errorf("hash password: %w", err)
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRegistrationClosed = errors.New("registration is closed")
+	ErrInviteRequired     = errors.New("an invite code is required")
+	ErrInvalidInvite      = errors.New("invalid or expired invite code")
+	ErrInviteNotFound     = model.ErrInviteNotFound
+)
+
+// RegistrationMode says who may create an account.
+type RegistrationMode string
+
+const (
+	RegistrationOpen   RegistrationMode = "open"   // anyone
+	RegistrationInvite RegistrationMode = "invite" // holders of a valid invite code
+	RegistrationClosed RegistrationMode = "closed" // no one; the admin still exists
+)
+
+// DefaultInviteExpiry is how long an invite lasts when none is given.
+const DefaultInviteExpiry = 7 * 24 * time.Hour
+
+// inviteCodeSize is the number of random bytes in an invite code, shown as
+// hex in four dash-separated groups.
+const inviteCodeSize = 10
+
+// InviteStore persists invite codes.
+type InviteStore interface {
+	// Create stores an invite under the hash of its code.
+	Create(ctx context.Context, invite *model.Invite, codeHash string) error
+	// List returns all invites, newest first.
+	List(ctx context.Context) ([]*model.Invite, error)
+	// Redeem uses the invite with the given code hash once, if it has uses
+	// left and has not expired by the unix time at. Returns false otherwise.
+	Redeem(ctx context.Context, codeHash string, at int64) (bool, error)
+	// Release gives back one use of the invite with the given code hash,
+	// after a redeemed registration failed. Unknown codes are ignored.
+	Release(ctx context.Context, codeHash string) error
+	// Delete removes an invite.
+	// Returns model.ErrInviteNotFound if it does not exist.
+	Delete(ctx context.Context, id string) error
+}
+
+// Registration enforces the registration mode and manages invites.
+type Registration struct {
+	mode    RegistrationMode
+	service *Service
+	invites InviteStore
+}
+
+// NewRegistration creates a registration policy that creates accounts with
+// service and, in invite mode, checks codes against invites.
+func NewRegistration(mode RegistrationMode, service *Service, invites InviteStore) *Registration {
+	return &Registration{mode: mode, service: service, invites: invites}
+}
+
+// Mode returns the registration mode.
+func (r *Registration) Mode() RegistrationMode {
+	return r.mode
+}
+
+// Register creates a regular user account if the registration mode allows
+// it. In invite mode it uses up one use of inviteCode, which is ignored in
+// the other modes; the use is given back if the account is not created.
+// Returns ErrRegistrationClosed, ErrInviteRequired or ErrInvalidInvite if
+// the account may not be created.
+func (r *Registration) Register(ctx context.Context, username, password, inviteCode string) (*model.User, error) {
+	switch r.mode {
+	case RegistrationClosed:
+		return nil, ErrRegistrationClosed
+	case RegistrationInvite:
+		if inviteCode == "" {
+			return nil, ErrInviteRequired
+		}
+		// Check what can be checked first so a bad request does not use up
+		// the invite.
+		if len(password) > maxPasswordBytes {
+			return nil, ErrPasswordTooLong
+		}
+		existing, err := r.service.users.GetUserByUsername(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrUsernameExists
+		}
+		codeHash := hashCode(inviteCode)
+		ok, err := r.invites.Redeem(ctx, codeHash, time.Now().Unix())
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrInvalidInvite
+		}
+		user, err := r.service.Register(ctx, username, password)
+		if err != nil {
+			// Registration can still fail, e.g. when the username is taken
+			// concurrently, so put the use back.
+			if releaseErr := r.invites.Release(context.WithoutCancel(ctx), codeHash); releaseErr != nil {
+				return nil, errors.Join(err, releaseErr)
+			}
+			return nil, err
+		}
+		return user, nil
+	}
+	return r.service.Register(ctx, username, password)
+}
+
+// CreateInvite creates an invite that can be used maxUses times until ttl
+// from now. createdBy is the ID of the admin creating it, "" from the CLI.
+// The returned invite carries its code, which is not stored.
+func (r *Registration) CreateInvite(ctx context.Context, createdBy string, maxUses int, ttl time.Duration) (*model.Invite, error) {
+	if maxUses < 1 {
+		return nil, fmt.Errorf("invite must allow at least one use")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invite expiry must be positive")
+	}
+	b := make([]byte, inviteCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate invite code: %w", err)
+	}
+	code := hex.EncodeToString(b)
+
+	now := time.Now()
+	invite := &model.Invite{
+		ID:        uuid.NewString(),
+		CreatedBy: createdBy,
+		MaxUses:   maxUses,
+		ExpiresAt: now.Add(ttl).Unix(),
+		CreatedAt: now.Unix(),
+	}
+	if err := r.invites.Create(ctx, invite, hashCode(code)); err != nil {
+		return nil, err
+	}
+	invite.Code = code[0:5] + "-" + code[5:10] + "-" + code[10:15] + "-" + code[15:20]
+	return invite, nil
+}
+
+// ListInvites returns all invites, newest first, without their codes.
+func (r *Registration) ListInvites(ctx context.Context) ([]*model.Invite, error) {
+	return r.invites.List(ctx)
+}
+
+// RevokeInvite deletes an invite so its code stops working.
+// Returns ErrInviteNotFound if it does not exist.
+func (r *Registration) RevokeInvite(ctx context.Context, id string) error {
+	return r.invites.Delete(ctx, id)
+}
@@ -0,0 +1,156 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func newTestRegistration(t *testing.T, mode auth.RegistrationMode) *auth.Registration {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	return auth.NewRegistration(mode, auth.NewService(sqlite.NewUserRepo(db)), sqlite.NewInviteStore(db))
+}
+
+func TestRegistration_Open(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationOpen)
+
+	user, err := reg.Register(context.Background(), "alice", "password123", "")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if user.Username != "alice" {
+		t.Errorf("expected username 'alice', got '%s'", user.Username)
+	}
+}
+
+func TestRegistration_Closed(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationClosed)
+	ctx := context.Background()
+
+	invite, err := reg.CreateInvite(ctx, "", 1, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+	if _, err := reg.Register(ctx, "alice", "password123", invite.Code); !errors.Is(err, auth.ErrRegistrationClosed) {
+		t.Errorf("expected ErrRegistrationClosed even with an invite, got %v", err)
+	}
+}
+
+func TestRegistration_InviteRequired(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationInvite)
+	ctx := context.Background()
+
+	if _, err := reg.Register(ctx, "alice", "password123", ""); !errors.Is(err, auth.ErrInviteRequired) {
+		t.Errorf("expected ErrInviteRequired, got %v", err)
+	}
+	if _, err := reg.Register(ctx, "alice", "password123", "00000-00000-00000-00000"); !errors.Is(err, auth.ErrInvalidInvite) {
+		t.Errorf("expected ErrInvalidInvite, got %v", err)
+	}
+}
+
+func TestRegistration_InviteUses(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationInvite)
+	ctx := context.Background()
+
+	invite, err := reg.CreateInvite(ctx, "", 2, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+	if len(invite.Code) != 23 {
+		t.Errorf("expected a 23-character code, got %q", invite.Code)
+	}
+
+	if _, err := reg.Register(ctx, "alice", "password123", invite.Code); err != nil {
+		t.Fatalf("first Register failed: %v", err)
+	}
+	// A taken username does not use up the invite.
+	if _, err := reg.Register(ctx, "alice", "password123", invite.Code); !errors.Is(err, auth.ErrUsernameExists) {
+		t.Fatalf("expected ErrUsernameExists, got %v", err)
+	}
+	if _, err := reg.Register(ctx, "bob", "password123", invite.Code); err != nil {
+		t.Fatalf("second Register failed: %v", err)
+	}
+	if _, err := reg.Register(ctx, "carol", "password123", invite.Code); !errors.Is(err, auth.ErrInvalidInvite) {
+		t.Errorf("expected ErrInvalidInvite once the uses are gone, got %v", err)
+	}
+
+	invites, _ := reg.ListInvites(ctx)
+	if len(invites) != 1 || invites[0].Uses != 2 || invites[0].Code != "" {
+		t.Errorf("expected one used-up invite without its code, got %+v", invites)
+	}
+}
+
+// failingUsers is a user repository whose inserts fail, as when a username
+// is taken between the check and the insert.
+type failingUsers struct {
+	repository.UserRepository
+}
+
+func (failingUsers) CreateUser(context.Context, *model.User) error {
+	return errors.New("insert failed")
+}
+
+func TestRegistration_FailedRegisterKeepsInviteUse(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	ctx := context.Background()
+	invites := sqlite.NewInviteStore(db)
+	failing := auth.NewRegistration(auth.RegistrationInvite, auth.NewService(failingUsers{sqlite.NewUserRepo(db)}), invites)
+	reg := auth.NewRegistration(auth.RegistrationInvite, auth.NewService(sqlite.NewUserRepo(db)), invites)
+
+	invite, err := reg.CreateInvite(ctx, "", 1, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+	if _, err := failing.Register(ctx, "alice", "password123", invite.Code); err == nil {
+		t.Fatal("expected the failing insert to fail Register")
+	}
+	long := strings.Repeat("x", 73)
+	if _, err := reg.Register(ctx, "alice", long, invite.Code); !errors.Is(err, auth.ErrPasswordTooLong) {
+		t.Fatalf("expected ErrPasswordTooLong, got %v", err)
+	}
+	if _, err := reg.Register(ctx, "alice", "password123", invite.Code); err != nil {
+		t.Fatalf("expected the invite to still have its use, got %v", err)
+	}
+
+	list, _ := reg.ListInvites(ctx)
+	if len(list) != 1 || list[0].Uses != 1 {
+		t.Errorf("expected exactly one use recorded, got %+v", list)
+	}
+}
+
+func TestRegistration_RevokeInvite(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationInvite)
+	ctx := context.Background()
+
+	invite, _ := reg.CreateInvite(ctx, "", 1, time.Hour)
+	if err := reg.RevokeInvite(ctx, invite.ID); err != nil {
+		t.Fatalf("RevokeInvite failed: %v", err)
+	}
+	if _, err := reg.Register(ctx, "alice", "password123", invite.Code); !errors.Is(err, auth.ErrInvalidInvite) {
+		t.Errorf("expected ErrInvalidInvite for a revoked invite, got %v", err)
+	}
+	if err := reg.RevokeInvite(ctx, invite.ID); !errors.Is(err, auth.ErrInviteNotFound) {
+		t.Errorf("expected ErrInviteNotFound, got %v", err)
+	}
+}
+
+func TestRegistration_CreateInviteRejectsBadLimits(t *testing.T) {
+	reg := newTestRegistration(t, auth.RegistrationInvite)
+	ctx := context.Background()
+
+	if _, err := reg.CreateInvite(ctx, "", 0, time.Hour); err == nil {
+		t.Error("expected an error for zero uses")
+	}
+	if _, err := reg.CreateInvite(ctx, "", 1, 0); err == nil {
+		t.Error("expected an error for a zero expiry")
+	}
+}
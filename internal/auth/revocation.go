@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// ErrTokenRevoked is returned for a validly signed access token whose user
+// has been deleted or has had their tokens revoked since it was issued.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// tokenVersionTTL is how long a user's token version is cached. Revocations
+// made through the TokenService take effect at once; those made elsewhere,
+// by the CLI or another server, within this time.
+const tokenVersionTTL = 10 * time.Second
+
+// TokenVersionStore reads and bumps the per-user counter access tokens are
+// stamped with. It is satisfied by repository.UserRepository.
+type TokenVersionStore interface {
+	// TokenVersion returns a user's current token version.
+	// Returns model.ErrUserNotFound if the user does not exist.
+	TokenVersion(ctx context.Context, userID string) (int64, error)
+	// BumpTokenVersion increments a user's token version.
+	BumpTokenVersion(ctx context.Context, userID string) error
+}
+
+// versionCache caches token versions so that checking an access token does
+// not cost a database query on every request.
+type versionCache struct {
+	store TokenVersionStore
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]versionEntry
+}
+
+type versionEntry struct {
+	version int64
+	deleted bool // the user no longer exists
+	expires time.Time
+}
+
+func newVersionCache(store TokenVersionStore, ttl time.Duration) *versionCache {
+	return &versionCache{store: store, ttl: ttl, entries: map[string]versionEntry{}}
+}
+
+// check returns ErrTokenRevoked unless version is the user's current token
+// version.
+func (c *versionCache) check(ctx context.Context, userID string, version int64) error {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	c.mu.Unlock()
+
+	if !ok || now.After(entry.expires) {
+		current, err := c.store.TokenVersion(ctx, userID)
+		switch {
+		case errors.Is(err, model.ErrUserNotFound):
+			entry = versionEntry{deleted: true}
+		case err != nil:
+			return err
+		default:
+			entry = versionEntry{version: current}
+		}
+		entry.expires = now.Add(c.ttl)
+		c.mu.Lock()
+		c.entries[userID] = entry
+		c.mu.Unlock()
+	}
+
+	if entry.deleted || entry.version != version {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// bump increments a user's token version and drops the cached one.
+func (c *versionCache) bump(ctx context.Context, userID string) error {
+	defer c.forget(userID)
+	return c.store.BumpTokenVersion(ctx, userID)
+}
+
+// forget drops a user's cached token version, so the next check reads it
+// from the store.
+func (c *versionCache) forget(userID string) {
+	c.mu.Lock()
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
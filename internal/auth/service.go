@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gatheryourdeals/data/internal/model"
 	"github.com/gatheryourdeals/data/internal/repository"
@@ -11,10 +12,12 @@ import (
 )
 
 var (
-	ErrUserNotFound      = errors.New("user not found")
+	ErrUserNotFound      = model.ErrUserNotFound
 	ErrUsernameExists    = errors.New("username already exists")
 	ErrInvalidCredential = errors.New("invalid username or password")
 	ErrAdminExists       = errors.New("admin account already exists")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	ErrAdminSelfDelete   = errors.New("admins cannot delete their own account")
 )
 
 // Service handles authentication and user management business logic.
@@ -67,7 +70,8 @@ func (s *Service) Login(ctx context.Context, username, password string) (*model.
 	return user, nil
 }
 
-// ResetPassword changes a user's password by username. Used by the admin CLI.
+// ResetPassword changes a user's password by username and revokes their
+// access tokens. Used by the admin CLI.
 func (s *Service) ResetPassword(ctx context.Context, username, newPassword string) error {
 	user, err := s.users.GetUserByUsername(ctx, username)
 	if err != nil {
@@ -80,9 +84,58 @@ func (s *Service) ResetPassword(ctx context.Context, username, newPassword strin
 	if err != nil {
 		return err
 	}
+	if err := s.users.UpdatePassword(ctx, user.ID, hash); err != nil {
+		return err
+	}
+	return s.users.BumpTokenVersion(ctx, user.ID)
+}
+
+// ChangePassword changes a user's password after checking their current one.
+// Returns ErrIncorrectPassword if it does not match. Revoking the user's
+// other sessions is up to the caller.
+func (s *Service) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	user, err := s.checkedUser(ctx, userID, currentPassword)
+	if err != nil {
+		return err
+	}
+	hash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
 	return s.users.UpdatePassword(ctx, user.ID, hash)
 }
 
+// UpdateProfile changes a user's username and display name, and returns the
+// updated user. Returns ErrUsernameExists if another user has the username.
+func (s *Service) UpdateProfile(ctx context.Context, userID, username, displayName string) (*model.User, error) {
+	existing, err := s.users.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.ID != userID {
+		return nil, ErrUsernameExists
+	}
+	if err := s.users.UpdateProfile(ctx, userID, username, strings.TrimSpace(displayName)); err != nil {
+		return nil, err
+	}
+	return s.users.GetUserByID(ctx, userID)
+}
+
+// DeleteAccount deletes a user's own account, with all of their receipts,
+// after checking their password. Returns ErrIncorrectPassword if it does not
+// match, and ErrAdminSelfDelete for admins, so that an admin always remains.
+// Revoking the user's tokens is up to the caller.
+func (s *Service) DeleteAccount(ctx context.Context, userID, password string) error {
+	user, err := s.checkedUser(ctx, userID, password)
+	if err != nil {
+		return err
+	}
+	if user.Role == model.RoleAdmin {
+		return ErrAdminSelfDelete
+	}
+	return s.users.DeleteUser(ctx, user.ID)
+}
+
 // HasAdmin checks whether an admin account exists in the system.
 func (s *Service) HasAdmin(ctx context.Context) (bool, error) {
 	return s.users.HasAdmin(ctx)
@@ -93,6 +146,21 @@ func (s *Service) GetUserByID(ctx context.Context, id string) (*model.User, erro
 	return s.users.GetUserByID(ctx, id)
 }
 
+// checkedUser returns a user after checking that password is theirs.
+func (s *Service) checkedUser(ctx context.Context, userID, password string) (*model.User, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := CheckPassword(password, user.PasswordHash); err != nil {
+		return nil, ErrIncorrectPassword
+	}
+	return user, nil
+}
+
 func (s *Service) createUser(ctx context.Context, username, password string, role model.Role) (*model.User, error) {
 	hash, err := HashPassword(password)
 	if err != nil {
@@ -158,6 +158,80 @@ func TestResetPassword_UserNotFound(t *testing.T) {
 	}
 }
 
+func TestChangePassword(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	user, err := svc.Register(ctx, "alice", "oldpassword1")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := svc.ChangePassword(ctx, user.ID, "wrongpassword", "newpassword1"); err != auth.ErrIncorrectPassword {
+		t.Fatalf("expected ErrIncorrectPassword, got %v", err)
+	}
+	if err := svc.ChangePassword(ctx, user.ID, "oldpassword1", "newpassword1"); err != nil {
+		t.Fatalf("ChangePassword failed: %v", err)
+	}
+
+	if _, err := svc.Login(ctx, "alice", "oldpassword1"); err != auth.ErrInvalidCredential {
+		t.Fatal("expected old password to fail after change")
+	}
+	if _, err := svc.Login(ctx, "alice", "newpassword1"); err != nil {
+		t.Fatalf("Login with new password failed: %v", err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	user, _ := svc.Register(ctx, "alice", "password123")
+	if _, err := svc.Register(ctx, "bob", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	got, err := svc.UpdateProfile(ctx, user.ID, "alice2", "  Alice  ")
+	if err != nil {
+		t.Fatalf("UpdateProfile failed: %v", err)
+	}
+	if got.Username != "alice2" || got.DisplayName != "Alice" {
+		t.Errorf("expected alice2 / Alice, got %s / %q", got.Username, got.DisplayName)
+	}
+	if _, err := svc.Login(ctx, "alice2", "password123"); err != nil {
+		t.Errorf("Login with new username failed: %v", err)
+	}
+
+	// Keeping one's own username is fine; taking another user's is not.
+	if _, err := svc.UpdateProfile(ctx, user.ID, "alice2", ""); err != nil {
+		t.Errorf("UpdateProfile with the same username failed: %v", err)
+	}
+	if _, err := svc.UpdateProfile(ctx, user.ID, "bob", ""); err != auth.ErrUsernameExists {
+		t.Errorf("expected ErrUsernameExists, got %v", err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	admin, _ := svc.CreateAdmin(ctx, "admin", "password123")
+	user, _ := svc.Register(ctx, "alice", "password123")
+
+	if err := svc.DeleteAccount(ctx, user.ID, "wrongpassword"); err != auth.ErrIncorrectPassword {
+		t.Fatalf("expected ErrIncorrectPassword, got %v", err)
+	}
+	if err := svc.DeleteAccount(ctx, admin.ID, "password123"); err != auth.ErrAdminSelfDelete {
+		t.Fatalf("expected ErrAdminSelfDelete, got %v", err)
+	}
+	if err := svc.DeleteAccount(ctx, user.ID, "password123"); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+	if got, _ := svc.GetUserByID(ctx, user.ID); got != nil {
+		t.Error("expected the account to be deleted")
+	}
+}
+
 func TestHasAdmin(t *testing.T) {
 	svc := newTestService(t)
 	ctx := context.Background()
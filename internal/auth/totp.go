@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters (RFC 6238). They are the defaults every authenticator app
+// supports, so the provisioning URI does not need to spell them out.
+const (
+	totpPeriod     = 30 * time.Second
+	totpDigits     = 6
+	totpSecretSize = 20 // bytes, the HMAC-SHA1 block size recommended by RFC 4226
+	// totpSkew is how many time steps either side of now a code is accepted
+	// in, to allow for clock drift and slow typing.
+	totpSkew = 1
+)
+
+// Recovery codes are recoveryCodeCount random codes of recoveryCodeSize
+// bytes, shown as hex in two dash-separated halves.
+const (
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 5
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newTOTPSecret returns a random base32 TOTP secret.
+func newTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate totp secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpStep returns the time step t falls in.
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCode returns the code for a secret at a time step (RFC 4226 HOTP with
+// the step as the counter).
+func totpCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1_000_000), nil
+}
+
+// TOTPCode returns the code an authenticator app shows for secret at t.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totpCode(secret, totpStep(t))
+}
+
+// matchTOTP returns the time step code is valid for at now, and whether it
+// matched one. Steps at or before lastStep are not tried, so a code that has
+// been accepted once is not accepted again.
+func matchTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		want, err := totpCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// provisioningURI returns the otpauth:// URI authenticator apps enroll from,
+// usually by scanning it as a QR code.
+func provisioningURI(issuer, account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+// newRecoveryCodes returns a fresh set of recovery codes, and their hashes
+// for storage.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for range recoveryCodeCount {
+		b := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:len(code)/2]+"-"+code[len(code)/2:])
+		hashes = append(hashes, hashCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashCode returns the hex SHA-256 of a recovery or invite code, ignoring
+// case, spaces and dashes. Codes are random enough that a fast hash is safe.
+func hashCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
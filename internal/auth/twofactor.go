@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolled = model.ErrTwoFactorNotEnrolled
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+)
+
+// TwoFactorStore persists TOTP enrollments and recovery codes.
+type TwoFactorStore interface {
+	// Get returns a user's enrollment, nil if they have none.
+	Get(ctx context.Context, userID string) (*model.TwoFactor, error)
+	// Begin starts an enrollment with a new secret at the unix time at,
+	// replacing a pending one. It leaves an enabled enrollment untouched.
+	Begin(ctx context.Context, userID, secret string, at int64) error
+	// Enable confirms a pending enrollment with a code accepted at step, and
+	// replaces the user's recovery codes with the given hashes.
+	// Returns model.ErrTwoFactorNotEnrolled if there is no pending enrollment.
+	Enable(ctx context.Context, userID string, step, at int64, codeHashes []string) error
+	// UseStep records step as the time step of the user's last accepted code.
+	// Returns false if that step or a later one was already recorded.
+	UseStep(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode deletes one of a user's recovery codes by hash.
+	// Returns false if the user has no such code.
+	UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error)
+	// Delete removes a user's enrollment and recovery codes.
+	Delete(ctx context.Context, userID string) error
+}
+
+// Enrollment is a new TOTP secret for a user to add to their authenticator
+// app, either by typing in Secret or by scanning URI as a QR code.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorService handles TOTP (RFC 6238) enrollment and verification.
+type TwoFactorService struct {
+	store  TwoFactorStore
+	issuer string
+}
+
+// NewTwoFactorService creates a two-factor service. issuer names the server
+// in users' authenticator apps.
+func NewTwoFactorService(store TwoFactorStore, issuer string) *TwoFactorService {
+	return &TwoFactorService{store: store, issuer: issuer}
+}
+
+// Status returns a user's enrollment, nil if they have none.
+func (s *TwoFactorService) Status(ctx context.Context, userID string) (*model.TwoFactor, error) {
+	return s.store.Get(ctx, userID)
+}
+
+// Enabled reports whether a user must enter a code to log in.
+func (s *TwoFactorService) Enabled(ctx context.Context, userID string) (bool, error) {
+	tf, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return tf != nil && tf.Enabled, nil
+}
+
+// Enroll starts a user's enrollment with a new secret, replacing a pending
+// one. Returns ErrTwoFactorEnabled if the user is already enrolled.
+func (s *TwoFactorService) Enroll(ctx context.Context, user *model.User) (*Enrollment, error) {
+	if enabled, err := s.Enabled(ctx, user.ID); err != nil {
+		return nil, err
+	} else if enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Begin(ctx, user.ID, secret, time.Now().Unix()); err != nil {
+		return nil, err
+	}
+	return &Enrollment{Secret: secret, URI: provisioningURI(s.issuer, user.Username, secret)}, nil
+}
+
+// Confirm enables a pending enrollment with a code from the user's app and
+// returns their recovery codes. They are shown only this once.
+func (s *TwoFactorService) Confirm(ctx context.Context, userID, code string) ([]string, error) {
+	tf, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+	if tf.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	now := time.Now()
+	step, ok := matchTOTP(tf.Secret, code, now, tf.LastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Enable(ctx, userID, step, now.Unix(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a code from an enabled user's app, or one of their recovery
+// codes, which is used up. Each app code is accepted only once.
+// Returns ErrTwoFactorNotEnrolled if the user has not enabled two-factor
+// authentication, and ErrInvalidTwoFactorCode if the code does not match.
+func (s *TwoFactorService) Verify(ctx context.Context, userID, code string) error {
+	tf, err := s.store.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil || !tf.Enabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	var ok bool
+	if step, matched := matchTOTP(tf.Secret, code, time.Now(), tf.LastStep); matched {
+		// A concurrent request may have used the same code first.
+		ok, err = s.store.UseStep(ctx, userID, step)
+	} else {
+		ok, err = s.store.UseRecoveryCode(ctx, userID, hashCode(code))
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// Disable removes a user's enrollment after checking a code as Verify does.
+func (s *TwoFactorService) Disable(ctx context.Context, userID, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, userID)
+}
@@ -0,0 +1,173 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238 appendix B, truncated to 6 digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ" // base32 of "12345678901234567890"
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := auth.TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode failed: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+type twoFactorTestEnv struct {
+	svc  *auth.TwoFactorService
+	user *model.User
+	ctx  context.Context
+}
+
+func newTwoFactorEnv(t *testing.T) *twoFactorTestEnv {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	user, err := auth.NewService(sqlite.NewUserRepo(db)).Register(context.Background(), "alice", "password123")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	return &twoFactorTestEnv{
+		svc:  auth.NewTwoFactorService(sqlite.NewTwoFactorStore(db), "GatherYourDeals"),
+		user: user,
+		ctx:  context.Background(),
+	}
+}
+
+// enable enrolls the user and confirms the enrollment, returning the secret
+// and recovery codes.
+func (e *twoFactorTestEnv) enable(t *testing.T) (string, []string) {
+	t.Helper()
+	enrollment, err := e.svc.Enroll(e.ctx, e.user)
+	if err != nil {
+		t.Fatalf("Enroll failed: %v", err)
+	}
+	code, _ := auth.TOTPCode(enrollment.Secret, time.Now())
+	codes, err := e.svc.Confirm(e.ctx, e.user.ID, code)
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	return enrollment.Secret, codes
+}
+
+func TestTwoFactor_Enroll(t *testing.T) {
+	env := newTwoFactorEnv(t)
+
+	enrollment, err := env.svc.Enroll(env.ctx, env.user)
+	if err != nil {
+		t.Fatalf("Enroll failed: %v", err)
+	}
+	uri, err := url.Parse(enrollment.URI)
+	if err != nil {
+		t.Fatalf("invalid provisioning URI %q: %v", enrollment.URI, err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/GatherYourDeals:alice" {
+		t.Errorf("unexpected provisioning URI %q", enrollment.URI)
+	}
+	if uri.Query().Get("secret") != enrollment.Secret || uri.Query().Get("issuer") != "GatherYourDeals" {
+		t.Errorf("unexpected provisioning URI query %q", uri.RawQuery)
+	}
+
+	// Pending until confirmed.
+	if enabled, _ := env.svc.Enabled(env.ctx, env.user.ID); enabled {
+		t.Error("expected enrollment to be pending")
+	}
+	if _, err := env.svc.Confirm(env.ctx, env.user.ID, "000000"); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+
+	_, codes := env.enable(t)
+	if len(codes) != 10 {
+		t.Errorf("expected 10 recovery codes, got %d", len(codes))
+	}
+	if enabled, _ := env.svc.Enabled(env.ctx, env.user.ID); !enabled {
+		t.Error("expected two-factor authentication to be enabled")
+	}
+	if _, err := env.svc.Enroll(env.ctx, env.user); !errors.Is(err, auth.ErrTwoFactorEnabled) {
+		t.Errorf("expected ErrTwoFactorEnabled, got %v", err)
+	}
+}
+
+func TestTwoFactor_VerifyRejectsReplay(t *testing.T) {
+	env := newTwoFactorEnv(t)
+	secret, _ := env.enable(t)
+
+	// The code used to confirm cannot be used again.
+	code, _ := auth.TOTPCode(secret, time.Now())
+	if err := env.svc.Verify(env.ctx, env.user.ID, code); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Fatalf("expected replayed code to be rejected, got %v", err)
+	}
+
+	// The next step's code is within the allowed clock skew, once.
+	next, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+	if err := env.svc.Verify(env.ctx, env.user.ID, next); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := env.svc.Verify(env.ctx, env.user.ID, next); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("expected replayed code to be rejected, got %v", err)
+	}
+}
+
+func TestTwoFactor_RecoveryCodes(t *testing.T) {
+	env := newTwoFactorEnv(t)
+	_, codes := env.enable(t)
+
+	// Codes are accepted once, ignoring case and dashes.
+	if err := env.svc.Verify(env.ctx, env.user.ID, strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))); err != nil {
+		t.Fatalf("Verify with recovery code failed: %v", err)
+	}
+	if err := env.svc.Verify(env.ctx, env.user.ID, codes[0]); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("expected used recovery code to be rejected, got %v", err)
+	}
+
+	status, err := env.svc.Status(env.ctx, env.user.ID)
+	if err != nil || status == nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.RecoveryCodesLeft != 9 {
+		t.Errorf("expected 9 recovery codes left, got %d", status.RecoveryCodesLeft)
+	}
+}
+
+func TestTwoFactor_Disable(t *testing.T) {
+	env := newTwoFactorEnv(t)
+	_, codes := env.enable(t)
+
+	if err := env.svc.Disable(env.ctx, env.user.ID, "000000"); !errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+		t.Errorf("expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+	if err := env.svc.Disable(env.ctx, env.user.ID, codes[1]); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if status, _ := env.svc.Status(env.ctx, env.user.ID); status != nil {
+		t.Errorf("expected no enrollment after disabling, got %+v", status)
+	}
+	if err := env.svc.Verify(env.ctx, env.user.ID, codes[2]); !errors.Is(err, auth.ErrTwoFactorNotEnrolled) {
+		t.Errorf("expected ErrTwoFactorNotEnrolled, got %v", err)
+	}
+}
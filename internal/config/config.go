@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"time"
 
@@ -10,15 +11,23 @@ import (
 
 // Config represents the full server configuration.
 type Config struct {
-	Server   ServerConfig `yaml:"server"`
-	Database DBConfig     `yaml:"database"`
-	Auth     AuthConfig   `yaml:"auth"`
-	Log      LogConfig    `yaml:"log"`
+	Server      ServerConfig      `yaml:"server"`
+	Database    DBConfig          `yaml:"database"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Log         LogConfig         `yaml:"log"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Alerts      AlertsConfig      `yaml:"alerts"`
+	Webhooks    WebhooksConfig    `yaml:"webhooks"`
+	Changes     ChangesConfig     `yaml:"changes"`
 }
 
 // ServerConfig holds HTTP server settings.
 type ServerConfig struct {
 	Port string `yaml:"port"`
+	// TrustedProxies lists the IPs or CIDRs of reverse proxies whose
+	// X-Forwarded-For header is believed. Empty trusts none, so the client IP
+	// is always the connection's address.
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
 // DBConfig holds database settings.
@@ -42,12 +51,92 @@ type LogConfig struct {
 	MaxSizeMB int    `yaml:"max_size_mb"`
 }
 
+// IdempotencyConfig holds settings for Idempotency-Key handling on write endpoints.
+type IdempotencyConfig struct {
+	TTL string `yaml:"ttl"` // how long a stored response can be replayed
+}
+
+// GetTTL parses the idempotency window string into a time.Duration.
+func (c *IdempotencyConfig) GetTTL() (time.Duration, error) {
+	return time.ParseDuration(c.TTL)
+}
+
+// AlertsConfig holds settings for delivering price watch alerts.
+type AlertsConfig struct {
+	WebhookURL string `yaml:"webhook_url"` // optional; alerts are POSTed here as they trigger
+}
+
+// WebhooksConfig holds settings for delivering events to webhook subscriptions.
+type WebhooksConfig struct {
+	Timeout     string `yaml:"timeout"`      // per-request timeout
+	MaxAttempts int    `yaml:"max_attempts"` // failed attempts before a delivery is dead-lettered
+}
+
+// GetTimeout parses the webhook request timeout string into a time.Duration.
+func (c *WebhooksConfig) GetTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Timeout)
+}
+
+// ChangesConfig holds settings for the change feed's event log.
+type ChangesConfig struct {
+	Retention string `yaml:"retention"` // how long events are kept before compaction
+}
+
+// GetRetention parses the event retention string into a time.Duration.
+func (c *ChangesConfig) GetRetention() (time.Duration, error) {
+	return time.ParseDuration(c.Retention)
+}
+
 // AuthConfig holds JWT authentication settings.
-// The JWT secret is intentionally NOT stored in the YAML file.
-// Set the GYD_JWT_SECRET environment variable instead.
+// JWT signing keys are intentionally NOT stored in the YAML file. They come
+// from the key file managed with "gatheryourdeals keys" and from the
+// GYD_JWT_SECRET environment variable.
 type AuthConfig struct {
-	AccessTokenExp  string `yaml:"access_token_exp"`
-	RefreshTokenExp string `yaml:"refresh_token_exp"`
+	AccessTokenExp  string          `yaml:"access_token_exp"`
+	RefreshTokenExp string          `yaml:"refresh_token_exp"`
+	KeysFile        string          `yaml:"keys_file"`    // optional path of the JWT key file
+	Registration    string          `yaml:"registration"` // "open" (default), "invite" or "closed"
+	Lockout         LockoutConfig   `yaml:"lockout"`
+	TwoFactor       TwoFactorConfig `yaml:"two_factor"`
+}
+
+// TwoFactorConfig holds TOTP two-factor authentication settings.
+type TwoFactorConfig struct {
+	Issuer           string `yaml:"issuer"`             // server name shown in authenticator apps
+	RequireForAdmins bool   `yaml:"require_for_admins"` // admins act as users until they log in with 2FA
+}
+
+// LockoutConfig holds brute-force protection settings for logins.
+type LockoutConfig struct {
+	MaxAttempts   int    `yaml:"max_attempts"`    // failed logins per username before a lockout
+	IPMaxAttempts int    `yaml:"ip_max_attempts"` // failed logins per client IP before a lockout
+	Duration      string `yaml:"duration"`        // first lockout; doubles with every further failure
+	MaxDuration   string `yaml:"max_duration"`    // longest lockout
+	ResetAfter    string `yaml:"reset_after"`     // failures are forgotten this long after the last one
+}
+
+// GetDuration parses the first lockout duration string into a time.Duration.
+func (c *LockoutConfig) GetDuration() (time.Duration, error) {
+	return time.ParseDuration(c.Duration)
+}
+
+// GetMaxDuration parses the longest lockout duration string into a time.Duration.
+func (c *LockoutConfig) GetMaxDuration() (time.Duration, error) {
+	return time.ParseDuration(c.MaxDuration)
+}
+
+// GetResetAfter parses the failure reset window string into a time.Duration.
+func (c *LockoutConfig) GetResetAfter() (time.Duration, error) {
+	return time.ParseDuration(c.ResetAfter)
+}
+
+// EffectiveKeysFile returns the path of the JWT key file, "" if none.
+// GYD_JWT_KEYS_FILE env var takes precedence over the config file value.
+func (c *AuthConfig) EffectiveKeysFile() string {
+	if path := os.Getenv("GYD_JWT_KEYS_FILE"); path != "" {
+		return path
+	}
+	return c.KeysFile
 }
 
 // Load reads the config from a YAML file at the given path.
@@ -76,11 +165,14 @@ func Load(path string) (*Config, error) {
 }
 
 // JWTSecret reads the JWT signing secret from the GYD_JWT_SECRET environment variable.
-// Returns an error if it is not set or is too short to be secure.
+// Returns nil if it is not set, and an error if it is too short to be secure.
 func (c *Config) JWTSecret() ([]byte, error) {
 	secret := os.Getenv("GYD_JWT_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
 	if len(secret) < 32 {
-		return nil, fmt.Errorf("GYD_JWT_SECRET must be set and at least 32 characters long")
+		return nil, fmt.Errorf("GYD_JWT_SECRET must be at least 32 characters long")
 	}
 	return []byte(secret), nil
 }
@@ -99,6 +191,13 @@ func (c *Config) validate() error {
 	if c.Server.Port == "" {
 		c.Server.Port = "8080"
 	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid trusted proxy: %q (must be an IP or CIDR)", proxy)
+			}
+		}
+	}
 	if c.Database.Driver == "" {
 		c.Database.Driver = "sqlite"
 	}
@@ -117,11 +216,50 @@ func (c *Config) validate() error {
 	if c.Auth.RefreshTokenExp == "" {
 		c.Auth.RefreshTokenExp = "168h"
 	}
+	if c.Auth.Lockout.MaxAttempts <= 0 {
+		c.Auth.Lockout.MaxAttempts = 5
+	}
+	if c.Auth.Lockout.IPMaxAttempts <= 0 {
+		c.Auth.Lockout.IPMaxAttempts = 20
+	}
+	if c.Auth.Lockout.Duration == "" {
+		c.Auth.Lockout.Duration = "1m"
+	}
+	if c.Auth.Lockout.MaxDuration == "" {
+		c.Auth.Lockout.MaxDuration = "1h"
+	}
+	if c.Auth.Lockout.ResetAfter == "" {
+		c.Auth.Lockout.ResetAfter = "24h"
+	}
+	if c.Auth.Registration == "" {
+		c.Auth.Registration = "open"
+	}
+	switch c.Auth.Registration {
+	case "open", "invite", "closed":
+		// valid
+	default:
+		return fmt.Errorf("unsupported registration mode: %q (must be \"open\", \"invite\" or \"closed\")", c.Auth.Registration)
+	}
+	if c.Auth.TwoFactor.Issuer == "" {
+		c.Auth.TwoFactor.Issuer = "GatherYourDeals"
+	}
 	if c.Log.Dir == "" {
 		c.Log.Dir = "logs"
 	}
 	if c.Log.MaxSizeMB <= 0 {
 		c.Log.MaxSizeMB = 10
 	}
+	if c.Idempotency.TTL == "" {
+		c.Idempotency.TTL = "24h"
+	}
+	if c.Webhooks.Timeout == "" {
+		c.Webhooks.Timeout = "10s"
+	}
+	if c.Webhooks.MaxAttempts <= 0 {
+		c.Webhooks.MaxAttempts = 8
+	}
+	if c.Changes.Retention == "" {
+		c.Changes.Retention = "720h"
+	}
 	return nil
 }
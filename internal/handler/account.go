@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gin-gonic/gin"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type updateProfileRequest struct {
+	Username    string `json:"username" binding:"required"`
+	DisplayName string `json:"displayName" binding:"max=100"`
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// ChangePassword handles PUT /api/v1/auth/password
+// Changes the caller's password after checking their current one, and logs
+// them out of every other session, revoking all of their access tokens. The
+// response carries a new token pair for the caller's session. Wrong passwords
+// count as failed logins.
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkLockout(c, user.Username) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.service.ChangePassword(ctx, user.ID, req.CurrentPassword, req.NewPassword)
+	if errors.Is(err, auth.ErrIncorrectPassword) {
+		h.recordFailure(c, user.Username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	if _, err := h.tokens.RevokeOtherSessions(ctx, user.ID, c.GetString(middleware.ContextKeySessionID)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+	h.renewSession(c, gin.H{"message": "password changed"})
+}
+
+// UpdateProfile handles PUT /api/v1/auth/me
+// Replaces the caller's username and display name. An empty display name
+// removes it.
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	var req updateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.UpdateProfile(c.Request.Context(), userID.(string), req.Username, req.DisplayName)
+	switch {
+	case errors.Is(err, auth.ErrUsernameExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+		return
+	case errors.Is(err, model.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteAccount handles DELETE /api/v1/auth/me
+// Deletes the caller's account and all of their receipts after checking
+// their password, and revokes their tokens. Wrong passwords count as failed
+// logins. Admins cannot delete their own account.
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	var req deleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkLockout(c, user.Username) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	err := h.service.DeleteAccount(ctx, user.ID, req.Password)
+	switch {
+	case errors.Is(err, auth.ErrIncorrectPassword):
+		h.recordFailure(c, user.Username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrAdminSelfDelete):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete account"})
+		return
+	}
+
+	// The refresh tokens went with the row; this makes the access tokens
+	// fail at once instead of when the cached token version expires.
+	if err := h.tokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account deleted"})
+}
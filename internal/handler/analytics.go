@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// AnalyticsHandler handles HTTP requests for spending reports over the
+// caller's own receipts.
+type AnalyticsHandler struct {
+	analytics repository.AnalyticsRepository
+	meta      repository.MetaFieldRepository
+}
+
+// NewAnalyticsHandler creates a new analytics handler.
+func NewAnalyticsHandler(analytics repository.AnalyticsRepository, meta repository.MetaFieldRepository) *AnalyticsHandler {
+	return &AnalyticsHandler{analytics: analytics, meta: meta}
+}
+
+// Spending handles GET /api/v1/analytics/spending
+// Returns the caller's spend per currency, in total and grouped by
+// ?group_by=month (default), store, category, or field together with
+// ?field=<name> for a user-defined enum or bool field. ?from= / ?to= (Y.M.D,
+// inclusive) limit the purchase date range. ?currency= converts each receipt
+// into that currency at its purchase-date rate; receipts without a known
+// rate stay in their own currency.
+func (h *AnalyticsHandler) Spending(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	query := model.SpendingQuery{GroupBy: c.DefaultQuery("group_by", model.GroupByMonth)}
+	switch query.GroupBy {
+	case model.GroupByMonth, model.GroupByStore, model.GroupByCategory:
+	case model.GroupByField:
+		if !h.bindGroupField(c, &query) {
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be month, store, category or field"})
+		return
+	}
+
+	var err error
+	if query.From, err = parseDateParam(c, "from"); err != nil {
+		return
+	}
+	if query.To, err = parseDateParam(c, "to"); err != nil {
+		return
+	}
+	if query.Currency, err = parseCurrencyParam(c, "currency"); err != nil {
+		return
+	}
+
+	report, err := h.analytics.Spending(c.Request.Context(), userID.(string), query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to summarize spending"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// bindGroupField reads ?field= and checks that it names a user-defined
+// field of a groupable type. On error it writes the response and returns false.
+func (h *AnalyticsHandler) bindGroupField(c *gin.Context, query *model.SpendingQuery) bool {
+	query.Field = c.Query("field")
+	if query.Field == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by=field requires field"})
+		return false
+	}
+	field, err := h.meta.GetField(c.Request.Context(), query.Field)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get field"})
+		return false
+	}
+	if field == nil || field.Native {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field must be a registered user-defined field"})
+		return false
+	}
+	if !model.IsGroupableFieldType(field.FieldType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "field must be of type enum or bool, got " + field.FieldType})
+		return false
+	}
+	return true
+}
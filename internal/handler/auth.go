@@ -1,33 +1,45 @@
 package handler
 
 import (
+	"errors"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gatheryourdeals/data/internal/auth"
 	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler handles HTTP requests for authentication endpoints.
 type AuthHandler struct {
-	service *auth.Service
-	tokens  *auth.TokenService
+	service      *auth.Service
+	tokens       *auth.TokenService
+	guard        *auth.LoginGuard
+	twoFactor    *auth.TwoFactorService
+	registration *auth.Registration
 }
 
-// NewAuthHandler creates a new authentication handler.
-func NewAuthHandler(service *auth.Service, tokens *auth.TokenService) *AuthHandler {
-	return &AuthHandler{service: service, tokens: tokens}
+// NewAuthHandler creates a new authentication handler. guard locks out
+// usernames and client IPs after repeated failed logins, and registration
+// decides who may register.
+func NewAuthHandler(service *auth.Service, tokens *auth.TokenService, guard *auth.LoginGuard, twoFactor *auth.TwoFactorService, registration *auth.Registration) *AuthHandler {
+	return &AuthHandler{service: service, tokens: tokens, guard: guard, twoFactor: twoFactor, registration: registration}
 }
 
 type registerRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=8"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required,min=8"`
+	InviteCode string `json:"invite_code"` // required when registration is invite-only
 }
 
 type loginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device"` // optional name shown in the session list
 }
 
 type refreshRequest struct {
@@ -35,17 +47,27 @@ type refreshRequest struct {
 }
 
 // Register handles POST /api/v1/users
+// Who may register depends on the registration mode: anyone, only holders
+// of an invite code, or no one. Refused registrations get 403.
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	user, err := h.service.Register(c.Request.Context(), req.Username, req.Password)
+	user, err := h.registration.Register(c.Request.Context(), req.Username, req.Password, req.InviteCode)
 	if err == auth.ErrUsernameExists {
 		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
 		return
 	}
+	if errors.Is(err, auth.ErrPasswordTooLong) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, auth.ErrRegistrationClosed) || errors.Is(err, auth.ErrInviteRequired) || errors.Is(err, auth.ErrInvalidInvite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register user"})
 		return
@@ -58,15 +80,24 @@ func (h *AuthHandler) Register(c *gin.Context) {
 }
 
 // Login handles POST /api/v1/sessions
-// Returns an access token and a refresh token on success.
+// Returns an access token and a refresh token on success. For users with
+// two-factor authentication it instead returns a two-factor token, to be
+// sent with a code to LoginTwoFactor. While the username or client IP is
+// locked out by failed logins, returns 429 with Retry-After without checking
+// the password.
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	user, err := h.service.Login(c.Request.Context(), req.Username, req.Password)
+	if !h.checkLockout(c, req.Username) {
+		return
+	}
+	ctx := c.Request.Context()
+	user, err := h.service.Login(ctx, req.Username, req.Password)
 	if err == auth.ErrInvalidCredential {
+		h.recordFailure(c, req.Username)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 		return
 	}
@@ -74,7 +105,31 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
 		return
 	}
-	access, refresh, err := h.tokens.IssueTokenPair(c.Request.Context(), user)
+
+	// The failed login count is only cleared once the second factor is in
+	// too, so that knowing the password does not allow unlimited code guesses.
+	enabled, err := h.twoFactor.Enabled(ctx, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if enabled {
+		challenge, err := h.tokens.IssueTwoFactorChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"two_factor_token":    challenge,
+		})
+		return
+	}
+
+	if err := h.guard.Succeed(ctx, user.Username); err != nil {
+		slog.Warn("failed to clear failed logins", "error", err)
+	}
+	access, refresh, err := h.tokens.IssueTokenPair(c.Request.Context(), user, clientInfo(c, req.Device))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
 		return
@@ -94,7 +149,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	access, refresh, err := h.tokens.RefreshAccessToken(c.Request.Context(), req.RefreshToken, h.service)
+	access, refresh, err := h.tokens.RefreshAccessToken(c.Request.Context(), req.RefreshToken, h.service, clientInfo(c, ""))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
 		return
@@ -141,3 +196,202 @@ func (h *AuthHandler) Me(c *gin.Context) {
 		"role": claims.Role,
 	})
 }
+
+// JWKS handles GET /.well-known/jwks.json
+// Publishes the public keys of the Ed25519 and RSA signing keys, so other
+// services can verify access tokens by their kid header. The set is empty
+// when tokens are signed with HMAC secrets only.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.tokens.JWKS())
+}
+
+// ListSessions handles GET /api/v1/auth/sessions
+// Lists the caller's active sessions, most recently used first. The session
+// of the access token making the request is marked current.
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	sessions, err := h.tokens.ListSessions(c.Request.Context(), userID.(string), c.GetString(middleware.ContextKeySessionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// RevokeSession handles DELETE /api/v1/auth/sessions/:id
+// Logs one of the caller's sessions out. Its refresh token stops working at
+// once. Unless it is the caller's own session, all of the caller's access
+// tokens are revoked too, and the response carries a new token pair for the
+// caller's session; other sessions get new access tokens when they refresh.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	sessionID, currentID := c.Param("id"), c.GetString(middleware.ContextKeySessionID)
+	if err := h.tokens.RevokeSession(ctx, userID.(string), sessionID); err != nil {
+		if errors.Is(err, model.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	// Revoking the caller's own session is a logout; its access token expires
+	// naturally, as on logout.
+	if sessionID == currentID {
+		c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+		return
+	}
+	h.renewSession(c, gin.H{"message": "session revoked"})
+}
+
+// RevokeOtherSessions handles DELETE /api/v1/auth/sessions
+// Logs the caller out everywhere except the session making the request, and
+// revokes their access tokens at once. The response carries a new token pair
+// for the caller's session.
+func (h *AuthHandler) RevokeOtherSessions(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	n, err := h.tokens.RevokeOtherSessions(c.Request.Context(), userID.(string), c.GetString(middleware.ContextKeySessionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	h.renewSession(c, gin.H{"revoked": n})
+}
+
+// renewSession revokes the caller's access tokens, including those of the
+// sessions just revoked, and responds with resp plus a new token pair for the
+// caller's own session.
+func (h *AuthHandler) renewSession(c *gin.Context, resp gin.H) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	access, refresh, err := h.tokens.RenewSession(c.Request.Context(), user,
+		c.GetString(middleware.ContextKeySessionID), clientInfo(c, ""))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+	resp["access_token"] = access
+	resp["refresh_token"] = refresh
+	resp["token_type"] = "Bearer"
+	c.JSON(http.StatusOK, resp)
+}
+
+// currentUser looks up the authenticated caller. It responds with an error
+// and returns false if that fails.
+func (h *AuthHandler) currentUser(c *gin.Context) (*model.User, bool) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return nil, false
+	}
+	user, err := h.service.GetUserByID(c.Request.Context(), userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return nil, false
+	}
+	return user, true
+}
+
+// RevokeUserSessions handles DELETE /api/v1/users/:id/sessions — admin only.
+// Logs a user out of every session.
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	user, err := h.service.GetUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := h.tokens.RevokeAllForUser(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions revoked"})
+}
+
+// UnlockUser handles DELETE /api/v1/users/:id/lockout (admin only).
+// It clears a user's failed logins, ending any lockout. Lockouts of client
+// IPs are lifted with the "gatheryourdeals admin unlock --ip" command.
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	user, err := h.service.GetUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if err := h.guard.Unlock(c.Request.Context(), model.LoginSubjectUsername, user.Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlock user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+}
+
+// checkLockout responds with 429 and returns false if logins for username or
+// from the client's IP are locked out.
+func (h *AuthHandler) checkLockout(c *gin.Context, username string) bool {
+	wait, err := h.guard.Check(c.Request.Context(), username, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return false
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts, try again later"})
+		return false
+	}
+	return true
+}
+
+// recordFailure counts a failed login for username from the client's IP.
+func (h *AuthHandler) recordFailure(c *gin.Context, username string) {
+	if err := h.guard.Fail(c.Request.Context(), username, c.ClientIP()); err != nil {
+		slog.Warn("failed to record failed login", "error", err)
+	}
+}
+
+// clientInfo describes the client of a login or refresh request.
+func clientInfo(c *gin.Context, device string) model.ClientInfo {
+	return model.ClientInfo{
+		Device:    device,
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// CategoryHandler handles HTTP requests for product category endpoints.
+type CategoryHandler struct {
+	categories repository.CategoryRepository
+}
+
+// NewCategoryHandler creates a new category handler.
+func NewCategoryHandler(categories repository.CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{categories: categories}
+}
+
+type categoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID string `json:"parentId"`
+}
+
+// ListCategories handles GET /api/v1/categories
+// Returns the whole category hierarchy as a tree of top-level categories
+// with nested children. The taxonomy is small, so it is not paginated.
+func (h *CategoryHandler) ListCategories(c *gin.Context) {
+	categories, err := h.categories.ListCategories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list categories"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": model.BuildCategoryTree(categories)})
+}
+
+// GetCategory handles GET /api/v1/categories/:id
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	category, err := h.categories.GetCategory(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get category"})
+		return
+	}
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory handles POST /api/v1/categories — admin only.
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+	category.ID = uuid.New().String()
+
+	if err := h.categories.CreateCategory(c.Request.Context(), category); err != nil {
+		writeCategoryError(c, err, "failed to create category")
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory handles PUT /api/v1/categories/:id — admin only.
+// Renames the category and/or moves it under a different parent.
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	category, ok := bindCategory(c)
+	if !ok {
+		return
+	}
+	category.ID = c.Param("id")
+
+	if err := h.categories.UpdateCategory(c.Request.Context(), category); err != nil {
+		writeCategoryError(c, err, "failed to update category")
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory handles DELETE /api/v1/categories/:id — admin only.
+// Only empty categories (no subcategories, no products) can be deleted.
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	if err := h.categories.DeleteCategory(c.Request.Context(), c.Param("id")); err != nil {
+		writeCategoryError(c, err, "failed to delete category")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "category deleted"})
+}
+
+// writeCategoryError maps category repository errors to HTTP responses.
+func writeCategoryError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, model.ErrParentCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+	case errors.Is(err, model.ErrCategoryCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrCategoryExists), errors.Is(err, model.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// bindCategory parses and validates a category request body.
+// On error it writes a 400 response and returns false.
+func bindCategory(c *gin.Context) (*model.Category, bool) {
+	var req categoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return nil, false
+	}
+	return &model.Category{Name: name, ParentID: strings.TrimSpace(req.ParentID)}, true
+}
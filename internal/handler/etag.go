@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setETag exposes a record version as a strong ETag, e.g. `"3"`.
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// parseIfMatch reads the If-Match header and returns the version the client
+// expects the record to be at. It returns 0 when the header is absent or "*",
+// meaning the write is unconditional.
+//
+// Only a single ETag previously issued by setETag is accepted. On a malformed
+// header this function writes a 400 JSON response and returns ok=false; the
+// caller must return immediately without writing further output.
+func parseIfMatch(c *gin.Context) (version int64, ok bool) {
+	raw := strings.TrimSpace(c.GetHeader("If-Match"))
+	if raw == "" || raw == "*" {
+		return 0, true
+	}
+	unquoted, err := strconv.Unquote(raw)
+	if err == nil {
+		version, err = strconv.ParseInt(unquoted, 10, 64)
+	}
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": `If-Match must be a single ETag such as "3"`})
+		return 0, false
+	}
+	return version, true
+}
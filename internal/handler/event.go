@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+const (
+	// eventBatchSize caps how many events are read from the log per query.
+	eventBatchSize = 100
+	// heartbeatInterval is how often an idle stream sends a comment line so
+	// proxies do not close it.
+	heartbeatInterval = 15 * time.Second
+	// streamResetEvent tells a stream client that events it missed have been
+	// compacted, so it must reload its receipts.
+	streamResetEvent = "reset"
+)
+
+// EventHandler serves the persistent event log: the caller's receipt changes
+// as Server-Sent Events, and the full change feed for admins.
+type EventHandler struct {
+	events repository.EventRepository
+	poll   time.Duration
+}
+
+// NewEventHandler creates a new event handler that checks the event log for
+// new events every poll interval.
+func NewEventHandler(events repository.EventRepository, poll time.Duration) *EventHandler {
+	return &EventHandler{events: events, poll: poll}
+}
+
+// StreamReceiptEvents handles GET /api/v1/receipts/stream
+// Streams receipt.created, receipt.updated and receipt.deleted events for
+// the caller's receipts. Each event's SSE id is its position in the event
+// log; reconnecting with a Last-Event-ID header (or ?last_event_id=) replays
+// everything after it. Without one, the stream starts with the next change.
+// If events after the Last-Event-ID have been compacted, the stream sends a
+// reset event, whose id is the compaction watermark, and goes on from there.
+func (h *EventHandler) StreamReceiptEvents(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	var lastID int64
+	if raw != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(raw, 10, 64); err != nil || lastID < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID must be a non-negative integer"})
+			return
+		}
+	} else {
+		var err error
+		if lastID, err = h.events.LatestEventID(ctx); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read event log"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	c.Writer.Flush()
+
+	poll := time.NewTicker(h.poll)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		events, err := h.events.ListUserEvents(ctx, userID.(string), lastID, eventBatchSize)
+		if errors.Is(err, model.ErrEventsCompacted) {
+			through, err := h.events.CompactedThrough(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Warn("event stream failed", "user", userID, "error", err)
+				}
+				return
+			}
+			_, _ = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: {\"compactedThrough\":%d}\n\n", through, streamResetEvent, through)
+			c.Writer.Flush()
+			lastID = through
+			continue
+		}
+		if err != nil {
+			// The client reconnects with the last ID it received.
+			if ctx.Err() == nil {
+				slog.Warn("event stream failed", "user", userID, "error", err)
+			}
+			return
+		}
+		for _, event := range events {
+			data, err := json.Marshal(event.Data)
+			if err != nil {
+				slog.Warn("event stream failed", "event", event.ID, "error", err)
+				return
+			}
+			_, _ = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			lastID = event.ID
+		}
+		c.Writer.Flush()
+		if len(events) == eventBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			_, _ = fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case <-poll.C:
+		}
+	}
+}
+
+// ListChanges handles GET /api/v1/changes (admin only)
+// Returns up to limit (default and maximum 100) events of every type and
+// user with a sequence number greater than ?since= (default 0), oldest
+// first. Pass the returned next value as since to read on. Responds 410 if
+// events after since have been compacted; the caller must resync and read
+// on from compactedThrough.
+func (h *EventHandler) ListChanges(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "0"), 10, 64)
+	if err != nil || since < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a non-negative integer"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(maxLimit)))
+	if err != nil || limit < 1 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxLimit)})
+		return
+	}
+
+	ctx := c.Request.Context()
+	// Fetch one extra event to learn whether more follow.
+	events, err := h.events.ListEvents(ctx, since, limit+1)
+	if err != nil {
+		if errors.Is(err, model.ErrEventsCompacted) {
+			through, err := h.events.CompactedThrough(ctx)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read event log"})
+				return
+			}
+			c.JSON(http.StatusGone, gin.H{"error": "changes after this sequence number have been compacted", "compactedThrough": through})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list changes"})
+		return
+	}
+
+	hasMore := len(events) > limit
+	if hasMore {
+		events = events[:limit]
+	}
+	next := since
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": events, "next": next, "hasMore": hasMore})
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// maxRatesCSVBytes caps the size of an exchange rate CSV import.
+const maxRatesCSVBytes = 8 << 20
+
+// ExchangeRateHandler handles HTTP requests for managing the exchange rates
+// used to show prices in a display currency. All endpoints are admin only.
+type ExchangeRateHandler struct {
+	rates repository.ExchangeRateRepository
+}
+
+// NewExchangeRateHandler creates a new exchange rate handler.
+func NewExchangeRateHandler(rates repository.ExchangeRateRepository) *ExchangeRateHandler {
+	return &ExchangeRateHandler{rates: rates}
+}
+
+// ListRates handles GET /api/v1/exchange-rates
+// Returns a paginated list of rates, newest first by default, optionally
+// narrowed to one ?base= or ?quote= currency.
+func (h *ExchangeRateHandler) ListRates(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var base, quote string
+	var err error
+	if base, err = parseCurrencyParam(c, "base"); err != nil {
+		return
+	}
+	if quote, err = parseCurrencyParam(c, "quote"); err != nil {
+		return
+	}
+	params, err := parsePaginationParams(c, "rate_date", "DESC", exchangeRateSortFields)
+	if err != nil {
+		return
+	}
+
+	page, err := h.rates.ListRates(c.Request.Context(), base, quote, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// PutRate handles POST /api/v1/exchange-rates
+// Stores one rate, replacing any rate of the same pair on the same date.
+func (h *ExchangeRateHandler) PutRate(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var rate model.ExchangeRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if err := rate.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rates.PutRates(c.Request.Context(), []*model.ExchangeRate{&rate}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rate)
+}
+
+// ImportRates handles POST /api/v1/exchange-rates/import
+// Reads rates from a CSV request body with a header row naming the columns
+// date, base, quote and rate. The import is all or nothing: a bad row
+// rejects the whole file, naming its line.
+func (h *ExchangeRateHandler) ImportRates(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxRatesCSVBytes)
+	rates, err := model.ParseRatesCSV(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("CSV must be at most %d bytes", maxRatesCSVBytes)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rates.PutRates(c.Request.Context(), rates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(rates)})
+}
+
+// DeleteRate handles DELETE /api/v1/exchange-rates/:base/:quote/:date
+func (h *ExchangeRateHandler) DeleteRate(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	rate := model.ExchangeRate{Base: c.Param("base"), Quote: c.Param("quote"), Date: c.Param("date"), Rate: 1}
+	if err := rate.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.rates.DeleteRate(c.Request.Context(), rate.Base, rate.Quote, rate.Date); err != nil {
+		if errors.Is(err, model.ErrRateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "exchange rate not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete exchange rate"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "exchange rate deleted"})
+}
+
+// parseCurrencyParam reads an optional ISO 4217 currency query parameter,
+// upper-cased. A missing parameter yields "". On error it writes a 400
+// response.
+func parseCurrencyParam(c *gin.Context, name string) (string, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return "", nil
+	}
+	currency, ok := model.NormalizeCurrency(raw)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be a 3-letter ISO 4217 currency code"})
+		return "", fmt.Errorf("invalid currency %q", raw)
+	}
+	return currency, nil
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// maxRadiusKm caps radius queries; half the Earth's circumference covers everything.
+const maxRadiusKm = 20038
+
+// hasGeoParams reports whether the request uses any geographic query parameter.
+func hasGeoParams(c *gin.Context) bool {
+	for _, key := range []string{"lat", "lng", "radius_km", "bbox"} {
+		if _, ok := c.GetQuery(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGeoFilter parses the geographic query parameters: a center point
+// (lat, lng), an optional radius_km around it, and an optional bounding box
+// (bbox=south,west,north,east). It returns nil when none are given.
+//
+// On validation error, this function writes a 400 JSON response and returns a
+// non-nil error; the caller must return immediately without writing further output.
+func parseGeoFilter(c *gin.Context) (*model.GeoFilter, error) {
+	if !hasGeoParams(c) {
+		return nil, nil
+	}
+	geo := &model.GeoFilter{}
+
+	rawLat, hasLat := c.GetQuery("lat")
+	rawLng, hasLng := c.GetQuery("lng")
+	if hasLat != hasLng {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng must be given together"})
+		return nil, fmt.Errorf("incomplete center")
+	}
+	if hasLat {
+		lat, errLat := strconv.ParseFloat(rawLat, 64)
+		lng, errLng := strconv.ParseFloat(rawLng, 64)
+		if errLat != nil || errLng != nil || !validLatitude(lat) || !validLongitude(lng) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lat must be within [-90, 90] and lng within [-180, 180]"})
+			return nil, fmt.Errorf("invalid center")
+		}
+		geo.Center = &model.GeoPoint{Lat: lat, Lng: lng}
+	}
+
+	if raw, ok := c.GetQuery("radius_km"); ok {
+		radius, err := strconv.ParseFloat(raw, 64)
+		if err != nil || radius <= 0 || radius > maxRadiusKm {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("radius_km must be a number in (0, %d]", maxRadiusKm)})
+			return nil, fmt.Errorf("invalid radius_km")
+		}
+		if geo.Center == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km requires lat and lng"})
+			return nil, fmt.Errorf("radius without center")
+		}
+		geo.RadiusKm = radius
+	}
+
+	if raw, ok := c.GetQuery("bbox"); ok {
+		box, err := parseBoundingBox(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bbox must be south,west,north,east in degrees with south <= north"})
+			return nil, err
+		}
+		geo.Box = box
+	}
+	return geo, nil
+}
+
+// parseBoundingBox parses "south,west,north,east". West may exceed east for
+// boxes crossing the antimeridian.
+func parseBoundingBox(raw string) (*model.BoundingBox, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox needs 4 values")
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox value %q", p)
+		}
+		v[i] = f
+	}
+	box := &model.BoundingBox{South: v[0], West: v[1], North: v[2], East: v[3]}
+	if !validLatitude(box.South) || !validLatitude(box.North) || box.South > box.North ||
+		!validLongitude(box.West) || !validLongitude(box.East) {
+		return nil, fmt.Errorf("bbox out of range")
+	}
+	return box, nil
+}
+
+func validLatitude(lat float64) bool  { return lat >= -90 && lat <= 90 }
+func validLongitude(lng float64) bool { return lng >= -180 && lng <= 180 }
+
+// withDistanceSort returns a copy of allowedSortFields that also accepts
+// sort_by=distance, for requests that give a center point.
+func withDistanceSort(allowedSortFields map[string]string) map[string]string {
+	fields := make(map[string]string, len(allowedSortFields)+1)
+	for k, v := range allowedSortFields {
+		fields[k] = v
+	}
+	fields["distance"] = model.SortByDistance
+	return fields
+}
+
+// nearestFirst switches a distance sort to ascending unless the client chose
+// an order, since "closest first" is what a distance sort is for.
+func nearestFirst(c *gin.Context, params *model.PaginationParams) {
+	if params.SortBy == model.SortByDistance && c.Query("sort_order") == "" {
+		params.SortOrder = "ASC"
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,37 +29,82 @@ type testEnv struct {
 	userRepo    *sqlite.UserRepo
 	metaRepo    *sqlite.MetaFieldRepo
 	receiptRepo *sqlite.ReceiptRepo
+	storeRepo   *sqlite.StoreRepo
+	productRepo *sqlite.ProductRepo
+	idemRepo    *sqlite.IdempotencyRepo
+	eventRepo   *sqlite.EventRepo
 	authService *auth.Service
 	tokens      *auth.TokenService
 }
 
 func setupEnv(t *testing.T) *testEnv {
+	t.Helper()
+	return setupEnvWithRegistration(t, auth.RegistrationOpen)
+}
+
+// setupEnvWithRegistration is setupEnv with the given registration mode.
+func setupEnvWithRegistration(t *testing.T, mode auth.RegistrationMode) *testEnv {
 	t.Helper()
 	db := testutil.NewTestDB(t)
 	userRepo := sqlite.NewUserRepo(db)
 	refreshStore := sqlite.NewRefreshTokenStore(db)
 	metaRepo := sqlite.NewMetaFieldRepo(db)
 	receiptRepo := sqlite.NewReceiptRepo(db, metaRepo)
+	storeRepo := sqlite.NewStoreRepo(db)
+	productRepo := sqlite.NewProductRepo(db)
+	categoryRepo := sqlite.NewCategoryRepo(db)
+	idemRepo := sqlite.NewIdempotencyRepo(db)
+	eventRepo := sqlite.NewEventRepo(db)
+	rateRepo := sqlite.NewExchangeRateRepo(db)
 
 	authService := auth.NewService(userRepo)
 	tokens := auth.NewTokenService(
-		[]byte("test-secret-that-is-long-enough-32c"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("test-secret-that-is-long-enough-32c")}),
 		time.Hour,
 		7*24*time.Hour,
 		refreshStore,
+		userRepo,
+		false,
 	)
 
-	authHandler := handler.NewAuthHandler(authService, tokens)
-	userHandler := handler.NewUserHandler(userRepo)
+	guard := auth.NewLoginGuard(sqlite.NewLoginAttemptStore(db), auth.LoginPolicy{
+		MaxAttempts:   5,
+		IPMaxAttempts: 20,
+		Lockout:       time.Minute,
+		MaxLockout:    time.Hour,
+		ResetAfter:    24 * time.Hour,
+	})
+
+	authHandler := handler.NewAuthHandler(authService, tokens, guard, auth.NewTwoFactorService(sqlite.NewTwoFactorStore(db), "GatherYourDeals"),
+		auth.NewRegistration(mode, authService, sqlite.NewInviteStore(db)))
+	userHandler := handler.NewUserHandler(userRepo, tokens)
 	metaHandler := handler.NewMetaHandler(metaRepo)
-	receiptHandler := handler.NewReceiptHandler(receiptRepo)
-	r := handler.NewRouter(authHandler, userHandler, metaHandler, receiptHandler, tokens, nil)
+	receiptHandler := handler.NewReceiptHandler(receiptRepo, rateRepo)
+	storeHandler := handler.NewStoreHandler(storeRepo)
+	productHandler := handler.NewProductHandler(productRepo)
+	categoryHandler := handler.NewCategoryHandler(categoryRepo)
+	priceHandler := handler.NewPriceHandler(sqlite.NewPriceRepo(db), productRepo, categoryRepo)
+	analyticsHandler := handler.NewAnalyticsHandler(sqlite.NewAnalyticsRepo(db), metaRepo)
+	watchHandler := handler.NewWatchHandler(sqlite.NewWatchRepo(db), productRepo)
+	webhookHandler := handler.NewWebhookHandler(sqlite.NewWebhookRepo(db))
+	eventHandler := handler.NewEventHandler(eventRepo, 10*time.Millisecond)
+	exchangeRateHandler := handler.NewExchangeRateHandler(rateRepo)
+	r, err := handler.NewRouter(authHandler, userHandler, metaHandler, receiptHandler, storeHandler,
+		productHandler, categoryHandler, priceHandler, analyticsHandler, watchHandler, webhookHandler,
+		eventHandler, exchangeRateHandler, tokens, idemRepo, 24*time.Hour, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create router: %v", err)
+	}
 
 	return &testEnv{
 		router:      r,
 		userRepo:    userRepo,
 		metaRepo:    metaRepo,
 		receiptRepo: receiptRepo,
+		storeRepo:   storeRepo,
+		productRepo: productRepo,
+		idemRepo:    idemRepo,
+		eventRepo:   eventRepo,
 		authService: authService,
 		tokens:      tokens,
 	}
@@ -69,7 +117,7 @@ func (e *testEnv) getAdminToken(t *testing.T) string {
 	if err != nil {
 		t.Fatalf("failed to create admin: %v", err)
 	}
-	access, _, err := e.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := e.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("failed to issue admin token: %v", err)
 	}
@@ -83,7 +131,7 @@ func (e *testEnv) getUserToken(t *testing.T, username, password string) string {
 	if err != nil {
 		t.Fatalf("failed to register user: %v", err)
 	}
-	access, _, err := e.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := e.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("failed to issue user token: %v", err)
 	}
@@ -217,11 +265,11 @@ func TestLogout_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
-	access, _, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, _, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -282,7 +330,7 @@ func TestRefresh_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -333,7 +381,7 @@ func TestRefresh_ConsumedToken(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -400,6 +448,121 @@ func TestLogin_UnknownUser(t *testing.T) {
 	}
 }
 
+// --- Login lockout tests ---
+
+// postLogin attempts a login and returns the response.
+func postLogin(t *testing.T, env *testEnv, username, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := jsonBody(t, map[string]string{"username": username, "password": password})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLogin_LockedOutAfterFailedAttempts(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+
+	for i := 0; i < 5; i++ {
+		if w := postLogin(t, env, "alice", "wrong-password"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d: %s", i+1, w.Code, w.Body.String())
+		}
+	}
+
+	// Locked out: even the right password is refused without being checked.
+	w := postLogin(t, env, "alice", "password123")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry <= 0 || retry > 60 {
+		t.Errorf("expected Retry-After of up to 60 seconds, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestLogin_SuccessClearsFailedAttempts(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+
+	for i := 0; i < 4; i++ {
+		postLogin(t, env, "alice", "wrong-password")
+	}
+	login(t, env, "alice", "password123", "")
+
+	// The count started over, so four more failures do not lock alice out.
+	for i := 0; i < 4; i++ {
+		postLogin(t, env, "alice", "wrong-password")
+	}
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLogin_SpoofedForwardedForIgnored(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+
+	postLoginFrom := func(username, password, forwardedFor string) int {
+		body := jsonBody(t, map[string]string{"username": username, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// No proxy is trusted, so a new X-Forwarded-For on every attempt does not
+	// give each one a fresh per-IP count.
+	for i := 0; i < 20; i++ {
+		postLoginFrom(fmt.Sprintf("nobody%d", i), "wrong-password", fmt.Sprintf("198.51.100.%d", i))
+	}
+	if code := postLoginFrom("alice", "password123", "198.51.100.99"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 for the connection's IP, got %d", code)
+	}
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+	env.getUserToken(t, "alice", "password123")
+	alice, _ := env.userRepo.GetUserByUsername(context.Background(), "alice")
+
+	for i := 0; i < 5; i++ {
+		postLogin(t, env, "alice", "wrong-password")
+	}
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+alice.ID+"/lockout", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 after unlock, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminUnlockUser_NotFound(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/nonexistent/lockout", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 // --- Logout tests (additional) ---
 
 func TestLogout_RefreshTokenRevokedAfterLogout(t *testing.T) {
@@ -408,7 +571,7 @@ func TestLogout_RefreshTokenRevokedAfterLogout(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
-	access, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	access, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -499,7 +662,7 @@ func TestAdminDeleteUser_RevokesRefreshTokens(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Register failed: %v", err)
 	}
-	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user)
+	_, refresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("IssueTokenPair failed: %v", err)
 	}
@@ -521,6 +684,110 @@ func TestAdminDeleteUser_RevokesRefreshTokens(t *testing.T) {
 	}
 }
 
+func TestAdminDeleteUser_RevokesAccessTokens(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+	userToken := env.getUserToken(t, "alice", "password123")
+	user, _ := env.userRepo.GetUserByUsername(context.Background(), "alice")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+user.ID, nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	env.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// The deleted user's access token stops working at once, not at expiry.
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after user deletion, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// updateRole changes a user's role as the given admin.
+func updateRole(t *testing.T, env *testEnv, adminToken, userID string, role model.Role) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/users/"+userID+"/role", jsonBody(t, map[string]string{"role": string(role)}))
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminUpdateRole_RevokesAccessTokens(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+
+	user, err := env.authService.Register(context.Background(), "bob", "password123")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if w := updateRole(t, env, adminToken, user.ID, model.RoleAdmin); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	user, _ = env.userRepo.GetUserByID(context.Background(), user.ID)
+	bobToken, bobRefresh, err := env.tokens.IssueTokenPair(context.Background(), user, model.ClientInfo{})
+	if err != nil {
+		t.Fatalf("IssueTokenPair failed: %v", err)
+	}
+
+	if w := updateRole(t, env, adminToken, user.ID, model.RoleUser); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The demoted admin's access token can no longer reach admin endpoints.
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set("Authorization", "Bearer "+bobToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with a revoked token, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "token has been revoked") {
+		t.Errorf("expected revoked error, got %s", w.Body.String())
+	}
+
+	// Their session survives, and refreshing picks up the new role.
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", jsonBody(t, map[string]string{"refresh_token": bobRefresh}))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 from refresh, got %d: %s", w.Code, w.Body.String())
+	}
+	var tokens map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	claims, err := env.tokens.ValidateAccessToken(tokens["access_token"])
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed: %v", err)
+	}
+	if claims.Role != model.RoleUser {
+		t.Errorf("expected role user after refresh, got %q", claims.Role)
+	}
+}
+
+func TestAdminUpdateRole_Validation(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+	admin, _ := env.userRepo.GetUserByUsername(context.Background(), "admin")
+	env.getUserToken(t, "alice", "password123")
+	alice, _ := env.userRepo.GetUserByUsername(context.Background(), "alice")
+
+	if w := updateRole(t, env, adminToken, alice.ID, "superuser"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid role: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := updateRole(t, env, adminToken, admin.ID, model.RoleUser); w.Code != http.StatusBadRequest {
+		t.Errorf("own role: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := updateRole(t, env, adminToken, "nonexistent-id", model.RoleAdmin); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestAdminEndpoints_ForbiddenForRegularUser(t *testing.T) {
 	env := setupEnv(t)
 	env.getAdminToken(t) // ensure admin exists
@@ -532,6 +799,8 @@ func TestAdminEndpoints_ForbiddenForRegularUser(t *testing.T) {
 	}{
 		{http.MethodGet, "/api/v1/users"},
 		{http.MethodDelete, "/api/v1/users/some-id"},
+		{http.MethodPut, "/api/v1/users/some-id/role"},
+		{http.MethodDelete, "/api/v1/users/some-id/lockout"},
 	}
 
 	for _, ep := range endpoints {
@@ -568,8 +837,8 @@ func TestMeta_ListFields(t *testing.T) {
 		t.Fatalf("failed to unmarshal: %v", err)
 	}
 	data := resp["data"].([]interface{})
-	if len(data) != 7 {
-		t.Errorf("expected 7 native fields, got %d", len(data))
+	if len(data) != 8 {
+		t.Errorf("expected 8 native fields, got %d", len(data))
 	}
 }
 
@@ -1440,7 +1709,7 @@ func TestMeta_Pagination_LimitOffset(t *testing.T) {
 	env := setupEnv(t)
 	token := env.getUserToken(t, "alice", "password123")
 
-	// 7 native fields seeded; request limit=3&offset=3 → 3 items, total=7, total_pages=3
+	// 8 native fields seeded; request limit=3&offset=3 → 3 items, total=8, total_pages=3
 	req := httptest.NewRequest(http.MethodGet, "/api/v1/meta?limit=3&offset=3", nil)
 	req.Header.Set("Authorization", "Bearer "+token)
 	w := httptest.NewRecorder()
@@ -1458,8 +1727,8 @@ func TestMeta_Pagination_LimitOffset(t *testing.T) {
 	if len(data) != 3 {
 		t.Errorf("expected 3 items in page, got %d", len(data))
 	}
-	if resp["total"].(float64) != 7 {
-		t.Errorf("expected total 7, got %v", resp["total"])
+	if resp["total"].(float64) != 8 {
+		t.Errorf("expected total 8, got %v", resp["total"])
 	}
 	if resp["total_pages"].(float64) != 3 {
 		t.Errorf("expected total_pages 3, got %v", resp["total_pages"])
@@ -1507,3 +1776,2075 @@ func TestMeta_Pagination_InvalidSortBy(t *testing.T) {
 		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
 	}
 }
+
+// ===========================================================================
+// Idempotency-Key tests
+// ===========================================================================
+
+func postReceiptWithKey(t *testing.T, env *testEnv, token, key, productName string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := jsonBody(t, map[string]interface{}{
+		"productName":  productName,
+		"purchaseDate": "2025.04.05",
+		"price":        "5.49CAD",
+		"amount":       "1",
+		"storeName":    "Costco",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/receipts", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReceipt_Create_IdempotentRetry(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	first := postReceiptWithKey(t, env, token, "key-1", "Milk")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", first.Code, first.Body.String())
+	}
+	retry := postReceiptWithKey(t, env, token, "key-1", "Milk")
+	if retry.Code != http.StatusCreated {
+		t.Fatalf("expected replayed 201, got %d: %s", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected Idempotent-Replayed header on retry")
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("expected identical body on replay\nfirst: %s\nretry: %s", first.Body.String(), retry.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/receipts", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	var page map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if page["total"].(float64) != 1 {
+		t.Errorf("expected exactly 1 receipt after retry, got %v", page["total"])
+	}
+}
+
+func TestReceipt_Create_IdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	if w := postReceiptWithKey(t, env, token, "key-1", "Milk"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w := postReceiptWithKey(t, env, token, "key-1", "Bread")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReceipt_Create_IdempotencyKeyScopedPerUser(t *testing.T) {
+	env := setupEnv(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	bob := env.getUserToken(t, "bob", "password123")
+
+	if w := postReceiptWithKey(t, env, alice, "shared-key", "Milk"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	w := postReceiptWithKey(t, env, bob, "shared-key", "Milk")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201 for a different user, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Idempotent-Replayed") != "" {
+		t.Error("expected a fresh response for a different user, got a replay")
+	}
+}
+
+func TestIdempotency_CredentialResponsesNotStored(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	ctx := context.Background()
+	adminUser, _ := env.userRepo.GetUserByUsername(ctx, "admin")
+
+	send := func(token, method, url, key string, body interface{}) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest(method, url, jsonBody(t, body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		if w.Code >= http.StatusMultipleChoices {
+			t.Fatalf("%s %s: expected success, got %d: %s", method, url, w.Code, w.Body.String())
+		}
+		var resp map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	send(admin, http.MethodPost, "/api/v1/invites", "invite", map[string]interface{}{})
+	send(admin, http.MethodPost, "/api/v1/webhooks", "webhook", map[string]interface{}{
+		"url": "https://example.com/hook", "events": []string{"receipt.created"},
+	})
+	login(t, env, "admin", "adminpass1", "phone")
+	var other string
+	for _, session := range listSessions(t, env, admin) {
+		if !session.Current {
+			other = session.ID
+		}
+	}
+	token := send(admin, http.MethodDelete, "/api/v1/auth/sessions/"+other, "session", nil)["access_token"].(string)
+	token = send(token, http.MethodDelete, "/api/v1/auth/sessions", "sessions", nil)["access_token"].(string)
+	token = send(token, http.MethodPut, "/api/v1/auth/password", "password", map[string]string{
+		"current_password": "adminpass1", "new_password": "adminpass2",
+	})["access_token"].(string)
+	enrollment := send(token, http.MethodPost, "/api/v1/auth/2fa", "enroll", nil)
+	totp, _ := auth.TOTPCode(enrollment["secret"].(string), time.Now())
+	send(token, http.MethodPost, "/api/v1/auth/2fa/confirm", "confirm", map[string]string{"code": totp})
+
+	for _, key := range []string{"invite", "webhook", "enroll", "confirm", "password", "sessions", "session"} {
+		if record, err := env.idemRepo.Get(ctx, adminUser.ID, key); err != nil || record != nil {
+			t.Errorf("expected no stored response for key %q, got %+v, %v", key, record, err)
+		}
+	}
+
+	// Other writes are still stored.
+	if w := postReceiptWithKey(t, env, token, "receipt", "Milk"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if record, _ := env.idemRepo.Get(ctx, adminUser.ID, "receipt"); record == nil || !record.Completed() {
+		t.Errorf("expected the receipt response to be stored, got %+v", record)
+	}
+}
+
+// ===========================================================================
+// ETag / If-Match tests
+// ===========================================================================
+
+// createReceiptForETag creates a receipt and returns its ID and ETag.
+func createReceiptForETag(t *testing.T, env *testEnv, token string) (string, string) {
+	t.Helper()
+	w := postReceiptWithKey(t, env, token, "", "Milk")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create receipt: %d %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	return resp["id"].(string), w.Header().Get("ETag")
+}
+
+func putReceipt(t *testing.T, env *testEnv, token, id, ifMatch, price string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := jsonBody(t, map[string]interface{}{
+		"productName":  "Milk",
+		"purchaseDate": "2025.04.05",
+		"price":        price,
+		"amount":       "1",
+		"storeName":    "Costco",
+	})
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/receipts/"+id, body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReceipt_Get_ReturnsETag(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	id, _ := createReceiptForETag(t, env, token)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/receipts/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Errorf(`expected ETag "1", got %q`, got)
+	}
+}
+
+func TestReceipt_Update_IfMatch(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	id, etag := createReceiptForETag(t, env, token)
+
+	w := putReceipt(t, env, token, id, etag, "4.99CAD")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Errorf(`expected ETag "2" after update, got %q`, got)
+	}
+
+	// A second writer still holding the old ETag must be rejected.
+	w = putReceipt(t, env, token, id, etag, "3.99CAD")
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReceipt_Update_NotFound(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	w := putReceipt(t, env, token, "nonexistent", "", "4.99CAD")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReceipt_Update_MalformedIfMatch(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	id, _ := createReceiptForETag(t, env, token)
+
+	w := putReceipt(t, env, token, id, "not-an-etag", "4.99CAD")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReceipt_Delete_IfMatchMismatch(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	id, _ := createReceiptForETag(t, env, token)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/receipts/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", `"5"`)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestMeta_UpdateDescription_IfMatch(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/meta/productName", nil)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf(`expected ETag "1", got %q`, etag)
+	}
+
+	update := func(desc string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/meta/productName",
+			jsonBody(t, map[string]string{"description": desc}))
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := update("first"); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := update("second"); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for stale ETag, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// ===========================================================================
+// Cursor pagination tests
+// ===========================================================================
+
+func getJSON(t *testing.T, env *testEnv, token, url string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestReceipt_CursorPagination(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createReceipt(t, env, token, "A", "2025.01.01")
+	createReceipt(t, env, token, "B", "2025.02.01")
+	createReceipt(t, env, token, "C", "2025.03.01")
+
+	code, first := getJSON(t, env, token, "/api/v1/receipts?cursor=&limit=2&sort_by=purchase_date&sort_order=asc")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, first)
+	}
+	if _, ok := first["total"]; ok {
+		t.Error("expected no total in cursor mode by default")
+	}
+	if first["has_more"] != true {
+		t.Fatal("expected has_more on first page")
+	}
+	next, _ := first["next_cursor"].(string)
+
+	// Sort parameters are carried by the cursor and may be omitted.
+	code, second := getJSON(t, env, token, "/api/v1/receipts?cursor="+next+"&limit=2&include_total=true")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, second)
+	}
+	data := second["data"].([]interface{})
+	if len(data) != 1 || data[0].(map[string]interface{})["productName"] != "C" {
+		t.Errorf("expected only receipt C on page 2, got %v", data)
+	}
+	if second["has_more"] != false {
+		t.Error("expected has_more=false on last page")
+	}
+	if second["total"].(float64) != 3 {
+		t.Errorf("expected total 3, got %v", second["total"])
+	}
+}
+
+func TestReceipt_CursorPagination_InvalidCursor(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	code, _ := getJSON(t, env, token, "/api/v1/receipts?cursor=not-a-cursor")
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", code)
+	}
+}
+
+func TestReceipt_CursorPagination_RejectsForeignSortColumn(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	forged := model.Cursor{SortBy: "user_id", SortOrder: "ASC", Value: "x", ID: "x"}.Encode()
+	code, _ := getJSON(t, env, token, "/api/v1/receipts?cursor="+forged)
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a cursor with a non-allowlisted column, got %d", code)
+	}
+}
+
+func TestReceipt_CursorPagination_SortChangeRejected(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createReceipt(t, env, token, "A", "2025.01.01")
+	createReceipt(t, env, token, "B", "2025.02.01")
+
+	_, first := getJSON(t, env, token, "/api/v1/receipts?cursor=&limit=1&sort_by=purchase_date")
+	next, _ := first["next_cursor"].(string)
+
+	code, _ := getJSON(t, env, token, "/api/v1/receipts?cursor="+next+"&sort_by=product_name")
+	if code != http.StatusBadRequest {
+		t.Fatalf("expected 400 when sort_by changes between pages, got %d", code)
+	}
+}
+
+func TestMeta_CursorPagination(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	code, resp := getJSON(t, env, token, "/api/v1/meta?cursor=&limit=5")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if len(resp["data"].([]interface{})) != 5 || resp["has_more"] != true {
+		t.Errorf("expected 5 fields and more to come, got %v", resp)
+	}
+}
+
+func TestAdminListUsers_CursorPagination(t *testing.T) {
+	env := setupEnv(t)
+	adminToken := env.getAdminToken(t)
+
+	code, resp := getJSON(t, env, adminToken, "/api/v1/users?cursor=&include_total=true")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if resp["total"].(float64) != 1 || resp["has_more"] != false {
+		t.Errorf("expected the single admin and no more pages, got %v", resp)
+	}
+}
+
+// ===========================================================================
+// Search tests
+// ===========================================================================
+
+func TestSearchReceipts(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createReceipt(t, env, token, "Oat Milk", "2025.03.01")
+	createReceipt(t, env, token, "Sourdough Bread", "2025.03.02")
+
+	code, resp := getJSON(t, env, token, `/api/v1/receipts/search?q=%22oat+milk%22`)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	data := resp["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("expected 1 hit, got %v", data)
+	}
+	hit := data[0].(map[string]interface{})
+	if hit["receipt"].(map[string]interface{})["productName"] != "Oat Milk" {
+		t.Errorf("unexpected hit: %v", hit)
+	}
+	if _, ok := hit["score"]; !ok {
+		t.Error("expected a score on each hit")
+	}
+
+	code, resp = getJSON(t, env, token, "/api/v1/receipts/search?q=sour*")
+	if code != http.StatusOK || resp["total"].(float64) != 1 {
+		t.Errorf("expected 1 prefix hit, got %d: %v", code, resp)
+	}
+}
+
+func TestSearchReceipts_InvalidQuery(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	for _, url := range []string{"/api/v1/receipts/search", `/api/v1/receipts/search?q=%22oat`} {
+		code, _ := getJSON(t, env, token, url)
+		if code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, code)
+		}
+	}
+}
+
+// ===========================================================================
+// Geographic query tests
+// ===========================================================================
+
+func createReceiptAt(t *testing.T, env *testEnv, token, productName string, lat, lng float64) {
+	t.Helper()
+	body := jsonBody(t, map[string]interface{}{
+		"productName":  productName,
+		"purchaseDate": "2025.04.05",
+		"price":        "5.49CAD",
+		"amount":       "1",
+		"storeName":    "Costco",
+		"latitude":     lat,
+		"longitude":    lng,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/receipts", body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("failed to create receipt: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListReceipts_WithinRadiusByDistance(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createReceiptAt(t, env, token, "Richmond Milk", 49.1666, -123.1336)
+	createReceiptAt(t, env, token, "Burnaby Milk", 49.2488, -122.9805)
+	createReceiptAt(t, env, token, "Seattle Milk", 47.6062, -122.3321)
+
+	code, resp := getJSON(t, env, token, "/api/v1/receipts?lat=49.2827&lng=-123.1207&radius_km=20&sort_by=distance")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	data := resp["data"].([]interface{})
+	if len(data) != 2 {
+		t.Fatalf("expected 2 receipts within 20 km, got %d", len(data))
+	}
+	first := data[0].(map[string]interface{})
+	if first["productName"] != "Burnaby Milk" {
+		t.Errorf("expected the nearest receipt first, got %v", first["productName"])
+	}
+	if d, ok := first["distanceKm"].(float64); !ok || d <= 0 || d > 20 {
+		t.Errorf("expected distanceKm within 20, got %v", first["distanceKm"])
+	}
+}
+
+func TestListReceipts_GeoValidation(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	for _, url := range []string{
+		"/api/v1/receipts?lat=49.2",
+		"/api/v1/receipts?lat=91&lng=0",
+		"/api/v1/receipts?radius_km=5",
+		"/api/v1/receipts?lat=49.2&lng=-123.1&radius_km=-1",
+		"/api/v1/receipts?bbox=1,2,3",
+		"/api/v1/receipts?bbox=50,0,40,10",
+		"/api/v1/receipts?sort_by=distance",
+		"/api/v1/receipts?cursor=&lat=49.2&lng=-123.1",
+		"/api/v1/receipts/search?q=milk&sort_by=distance",
+	} {
+		if code, _ := getJSON(t, env, token, url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, code)
+		}
+	}
+}
+
+func TestSearchReceipts_NearPoint(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createReceiptAt(t, env, token, "Oat Milk", 49.2488, -122.9805)
+	createReceiptAt(t, env, token, "Oat Milk", 47.6062, -122.3321)
+
+	code, resp := getJSON(t, env, token, "/api/v1/receipts/search?q=oat&lat=49.2827&lng=-123.1207&radius_km=50")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if resp["total"].(float64) != 1 {
+		t.Errorf("expected only the nearby receipt, got %v", resp["total"])
+	}
+}
+
+// ===========================================================================
+// Store registry tests
+// ===========================================================================
+
+func sendJSON(t *testing.T, env *testEnv, token, method, url string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, url, jsonBody(t, body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestStores_AdminCRUD(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+
+	code, store := sendJSON(t, env, admin, http.MethodPost, "/api/v1/stores", map[string]interface{}{
+		"name":      "Costco",
+		"chain":     "Costco Wholesale",
+		"latitude":  49.2827,
+		"longitude": -123.1207,
+		"aliases":   []string{"Costco Wholesale"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, store)
+	}
+	id := store["id"].(string)
+
+	code, got := getJSON(t, env, admin, "/api/v1/stores/"+id)
+	if code != http.StatusOK || got["name"] != "Costco" || len(got["aliases"].([]interface{})) != 1 {
+		t.Fatalf("unexpected store: %d %v", code, got)
+	}
+
+	code, updated := sendJSON(t, env, admin, http.MethodPut, "/api/v1/stores/"+id, map[string]interface{}{
+		"name":    "Costco",
+		"aliases": []string{"Price Club"},
+	})
+	if code != http.StatusOK || updated["aliases"].([]interface{})[0] != "Price Club" {
+		t.Fatalf("unexpected update: %d %v", code, updated)
+	}
+
+	code, list := getJSON(t, env, admin, "/api/v1/stores")
+	if code != http.StatusOK || list["total"].(float64) != 1 {
+		t.Fatalf("unexpected list: %d %v", code, list)
+	}
+
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/stores/"+id, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 on delete, got %d", code)
+	}
+	if code, _ := getJSON(t, env, admin, "/api/v1/stores/"+id); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+}
+
+func TestStores_WritesRequireAdmin(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/stores", map[string]string{"name": "Costco"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 on create, got %d", code)
+	}
+	if code, _ := getJSON(t, env, token, "/api/v1/stores"); code != http.StatusOK {
+		t.Errorf("expected users to list stores, got %d", code)
+	}
+}
+
+func TestStores_Validation(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+
+	for _, body := range []map[string]interface{}{
+		{},
+		{"name": "!!!"},
+		{"name": "Costco", "latitude": 49.2},
+		{"name": "Costco", "latitude": 91, "longitude": 0},
+		{"name": "Costco", "aliases": []string{" "}},
+	} {
+		if code, resp := sendJSON(t, env, admin, http.MethodPost, "/api/v1/stores", body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d: %v", body, code, resp)
+		}
+	}
+}
+
+func TestStores_AliasConflict(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+
+	sendJSON(t, env, admin, http.MethodPost, "/api/v1/stores", map[string]interface{}{
+		"name": "Costco", "aliases": []string{"Costco Wholesale"},
+	})
+	code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/stores", map[string]string{"name": "costco wholesale"})
+	if code != http.StatusConflict {
+		t.Errorf("expected 409, got %d", code)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodPut, "/api/v1/stores/nope", map[string]string{"name": "Nope"}); code != http.StatusNotFound {
+		t.Errorf("expected 404 updating a missing store, got %d", code)
+	}
+}
+
+func TestStores_ReceiptResolvesStore(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	_, store := sendJSON(t, env, admin, http.MethodPost, "/api/v1/stores", map[string]interface{}{
+		"name": "Costco", "aliases": []string{"Costco Wholesale"},
+	})
+
+	code, receipt := sendJSON(t, env, token, http.MethodPost, "/api/v1/receipts", map[string]interface{}{
+		"productName":  "Milk",
+		"purchaseDate": "2025.04.05",
+		"price":        "5.49CAD",
+		"amount":       "1",
+		"storeName":    "COSTCO WHOLESALE",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, receipt)
+	}
+	if receipt["storeId"] != store["id"] || receipt["storeName"] != "COSTCO WHOLESALE" {
+		t.Errorf("expected receipt linked to %v with its name kept, got %v / %v", store["id"], receipt["storeId"], receipt["storeName"])
+	}
+}
+
+// ===========================================================================
+// Product catalog tests
+// ===========================================================================
+
+func TestProducts_AdminCRUDWithCategory(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+
+	code, dairy := sendJSON(t, env, admin, http.MethodPost, "/api/v1/categories", map[string]string{"name": "Dairy"})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, dairy)
+	}
+	code, milk := sendJSON(t, env, admin, http.MethodPost, "/api/v1/categories", map[string]string{
+		"name": "Milk", "parentId": dairy["id"].(string),
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, milk)
+	}
+
+	code, product := sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", map[string]interface{}{
+		"name":       "2% Milk 4L",
+		"barcode":    "036000291452",
+		"categoryId": milk["id"],
+		"aliases":    []string{"Milk 2 percent 4 L"},
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, product)
+	}
+	if product["barcode"] != "0036000291452" {
+		t.Errorf("expected UPC-A stored as EAN-13, got %v", product["barcode"])
+	}
+
+	code, list := getJSON(t, env, admin, "/api/v1/products?category="+dairy["id"].(string))
+	if code != http.StatusOK || list["total"].(float64) != 1 {
+		t.Fatalf("expected the product under Dairy, got %d: %v", code, list)
+	}
+
+	code, tree := getJSON(t, env, admin, "/api/v1/categories")
+	roots := tree["data"].([]interface{})
+	if code != http.StatusOK || len(roots) != 1 {
+		t.Fatalf("unexpected category tree: %d %v", code, tree)
+	}
+	if children := roots[0].(map[string]interface{})["children"].([]interface{}); len(children) != 1 {
+		t.Errorf("expected Milk nested under Dairy, got %v", children)
+	}
+
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/categories/"+milk["id"].(string), nil); code != http.StatusConflict {
+		t.Errorf("expected 409 deleting a category with products, got %d", code)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodPut, "/api/v1/categories/"+dairy["id"].(string), map[string]string{
+		"name": "Dairy", "parentId": milk["id"].(string),
+	}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a category cycle, got %d", code)
+	}
+
+	id := product["id"].(string)
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/products/"+id, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 on delete, got %d", code)
+	}
+	if code, _ := getJSON(t, env, admin, "/api/v1/products/"+id); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+}
+
+func TestProducts_Validation(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/products", map[string]string{"name": "Milk"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", code)
+	}
+	for _, body := range []map[string]interface{}{
+		{},
+		{"name": "Milk", "barcode": "036000291453"},
+		{"name": "Milk", "categoryId": "nope"},
+	} {
+		if code, resp := sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d: %v", body, code, resp)
+		}
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/categories", map[string]string{
+		"name": "Milk", "parentId": "nope",
+	}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a missing parent, got %d", code)
+	}
+
+	sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", map[string]string{"name": "Milk", "barcode": "4006381333931"})
+	if code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", map[string]string{
+		"name": "Other", "barcode": "4006381333931",
+	}); code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken barcode, got %d", code)
+	}
+}
+
+func TestProducts_ReceiptResolvesProduct(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	_, product := sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", map[string]interface{}{
+		"name": "2% Milk 4L", "barcode": "0036000291452",
+	})
+
+	receipt := map[string]interface{}{
+		"productName":  "MILK 2PCT",
+		"purchaseDate": "2025.04.05",
+		"price":        "5.49CAD",
+		"amount":       "1",
+		"storeName":    "Costco",
+		"barcode":      "036000291452",
+	}
+	code, created := sendJSON(t, env, token, http.MethodPost, "/api/v1/receipts", receipt)
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %v", code, created)
+	}
+	if created["productId"] != product["id"] || created["barcode"] != "0036000291452" {
+		t.Errorf("expected receipt linked by barcode, got %v / %v", created["productId"], created["barcode"])
+	}
+
+	receipt["barcode"] = "12345"
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/receipts", receipt); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid barcode, got %d", code)
+	}
+}
+
+// ===========================================================================
+// Price history tests
+// ===========================================================================
+
+func createPurchase(t *testing.T, env *testEnv, token, productName, date, price, amount, store string) {
+	t.Helper()
+	code, resp := sendJSON(t, env, token, http.MethodPost, "/api/v1/receipts", map[string]string{
+		"productName":  productName,
+		"purchaseDate": date,
+		"price":        price,
+		"amount":       amount,
+		"storeName":    store,
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("failed to create receipt: %d %v", code, resp)
+	}
+}
+
+func TestPriceHistory_AcrossUsersByProductAndAlias(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	bob := env.getUserToken(t, "bob", "password123")
+
+	_, product := sendJSON(t, env, admin, http.MethodPost, "/api/v1/products", map[string]interface{}{
+		"name": "Milk 4L", "aliases": []string{"Homo Milk 4L"},
+	})
+	createPurchase(t, env, alice, "Milk 4L", "2025.04.01", "5.60CAD", "4L", "Costco")
+	createPurchase(t, env, bob, "Homo Milk 4L", "2025.04.03", "6.00CAD", "4L", "Walmart")
+	createPurchase(t, env, bob, "Milk 4L", "2025.05.02", "4.80CAD", "4L", "Costco")
+
+	for _, url := range []string{
+		"/api/v1/prices/history?bucket=month&product_id=" + product["id"].(string),
+		"/api/v1/prices/history?bucket=month&name=homo+milk+4l",
+	} {
+		code, history := getJSON(t, env, alice, url)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", url, code, history)
+		}
+		if history["productId"] != product["id"] || history["bucket"] != "month" {
+			t.Errorf("%s: unexpected history header: %v", url, history)
+		}
+		series := history["series"].([]interface{})
+		if len(series) != 1 {
+			t.Fatalf("%s: expected 1 series, got %v", url, series)
+		}
+		s := series[0].(map[string]interface{})
+		if s["count"].(float64) != 3 || s["unit"] != "l" || s["currency"] != "CAD" || s["min"].(float64) != 1.2 {
+			t.Errorf("%s: unexpected series: %v", url, s)
+		}
+		if buckets := s["buckets"].([]interface{}); len(buckets) != 2 {
+			t.Errorf("%s: expected April and May buckets, got %v", url, buckets)
+		}
+	}
+}
+
+func TestPriceHistory_UncataloguedNameAndRange(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	createPurchase(t, env, token, "Bananas", "2025.04.01", "1.00CAD", "1lb", "Costco")
+	createPurchase(t, env, token, "bananas", "2025.04.20", "0.90CAD", "1lb", "Costco")
+
+	code, history := getJSON(t, env, token, "/api/v1/prices/history?name=BANANAS&from=2025.04.10")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, history)
+	}
+	if _, ok := history["productId"]; ok {
+		t.Errorf("expected no productId for an uncatalogued name, got %v", history["productId"])
+	}
+	series := history["series"].([]interface{})
+	if len(series) != 1 || series[0].(map[string]interface{})["count"].(float64) != 1 {
+		t.Errorf("expected one price after from, got %v", series)
+	}
+}
+
+func TestPriceHistory_Validation(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	for _, url := range []string{
+		"/api/v1/prices/history",
+		"/api/v1/prices/history?name=Milk&bucket=day",
+		"/api/v1/prices/history?name=Milk&from=April",
+		"/api/v1/prices/history?name=Milk&product_id=x",
+	} {
+		if code, resp := getJSON(t, env, token, url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", url, code, resp)
+		}
+	}
+	if code, _ := getJSON(t, env, token, "/api/v1/prices/history?product_id=nope"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown product, got %d", code)
+	}
+}
+
+func TestDeals_RecentPricesWithinLookback(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	recent := time.Now().AddDate(0, 0, -3).Format("2006.01.02")
+	old := time.Now().AddDate(0, 0, -60).Format("2006.01.02")
+	createPurchase(t, env, token, "Butter", old, "6.00CAD", "1lb", "Costco")
+	createPurchase(t, env, token, "Butter", recent, "4.50CAD", "1lb", "Walmart")
+	createPurchase(t, env, token, "Butter", recent, "5.00CAD", "1lb", "Costco")
+
+	code, report := getJSON(t, env, token, "/api/v1/prices/deals?name=butter")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, report)
+	}
+	groups := report["groups"].([]interface{})
+	if len(groups) != 1 {
+		t.Fatalf("expected 1 group, got %v", groups)
+	}
+	deals := groups[0].(map[string]interface{})["deals"].([]interface{})
+	if len(deals) != 2 || deals[0].(map[string]interface{})["storeName"] != "Walmart" {
+		t.Fatalf("expected Walmart ranked first, got %v", deals)
+	}
+	if costco := deals[1].(map[string]interface{}); costco["count"].(float64) != 1 {
+		t.Errorf("expected the old Costco receipt outside the window, got %v", costco)
+	}
+
+	code, report = getJSON(t, env, token, "/api/v1/prices/deals?name=butter&lookback_days=90")
+	deals = report["groups"].([]interface{})[0].(map[string]interface{})["deals"].([]interface{})
+	if code != http.StatusOK || deals[1].(map[string]interface{})["count"].(float64) != 2 {
+		t.Errorf("expected both Costco receipts in a 90 day window, got %d %v", code, deals)
+	}
+}
+
+func TestDeals_Validation(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	for _, url := range []string{
+		"/api/v1/prices/deals",
+		"/api/v1/prices/deals?name=Milk&category=x",
+		"/api/v1/prices/deals?name=Milk&lookback_days=0",
+		"/api/v1/prices/deals?name=Milk&lat=49.2&lng=-123.1",
+		"/api/v1/prices/deals?name=Milk&radius_km=5",
+	} {
+		if code, resp := getJSON(t, env, token, url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", url, code, resp)
+		}
+	}
+	if code, _ := getJSON(t, env, token, "/api/v1/prices/deals?category=nope"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown category, got %d", code)
+	}
+}
+
+// ===========================================================================
+// Spending analytics tests
+// ===========================================================================
+
+func TestSpending_OwnReceiptsByMonth(t *testing.T) {
+	env := setupEnv(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	bob := env.getUserToken(t, "bob", "password123")
+	createPurchase(t, env, alice, "Milk", "2025.03.30", "5.49CAD", "1", "Costco")
+	createPurchase(t, env, alice, "Eggs", "2025.04.02", "4.20CAD", "1", "Costco")
+	createPurchase(t, env, bob, "Eggs", "2025.04.02", "50.00CAD", "1", "Costco")
+
+	code, report := getJSON(t, env, alice, "/api/v1/analytics/spending?from=2025.04.01")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, report)
+	}
+	groups := report["groups"].([]interface{})
+	if report["groupBy"] != "month" || len(groups) != 1 {
+		t.Fatalf("expected one April group, got %v", report)
+	}
+	april := groups[0].(map[string]interface{})
+	total := april["totals"].([]interface{})[0].(map[string]interface{})
+	if april["key"] != "2025-04" || total["currency"] != "CAD" || total["total"].(float64) != 4.2 {
+		t.Errorf("unexpected April spend: %v", april)
+	}
+}
+
+func TestSpending_GroupByField(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	sendJSON(t, env, token, http.MethodPost, "/api/v1/meta", map[string]string{"fieldName": "payment", "description": "how it was paid", "type": "enum"})
+	sendJSON(t, env, token, http.MethodPost, "/api/v1/meta", map[string]string{"fieldName": "brand", "description": "brand", "type": "string"})
+	code, resp := sendJSON(t, env, token, http.MethodPost, "/api/v1/receipts", map[string]string{
+		"productName": "Milk", "purchaseDate": "2025.04.02", "price": "4.20CAD", "amount": "1",
+		"storeName": "Costco", "payment": "card",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("failed to create receipt: %d %v", code, resp)
+	}
+
+	code, report := getJSON(t, env, token, "/api/v1/analytics/spending?group_by=field&field=payment")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, report)
+	}
+	if groups := report["groups"].([]interface{}); len(groups) != 1 || groups[0].(map[string]interface{})["key"] != "card" {
+		t.Errorf("expected one card group, got %v", groups)
+	}
+
+	for _, url := range []string{
+		"/api/v1/analytics/spending?group_by=week",
+		"/api/v1/analytics/spending?group_by=field",
+		"/api/v1/analytics/spending?group_by=field&field=brand",
+		"/api/v1/analytics/spending?group_by=field&field=price",
+		"/api/v1/analytics/spending?group_by=field&field=unknown",
+		"/api/v1/analytics/spending?to=yesterday",
+	} {
+		if code, resp := getJSON(t, env, token, url); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %v", url, code, resp)
+		}
+	}
+}
+
+// ===========================================================================
+// Price watch and alert tests
+// ===========================================================================
+
+func TestWatch_AlertsWhenPriceDrops(t *testing.T) {
+	env := setupEnv(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	bob := env.getUserToken(t, "bob", "password123")
+
+	code, watch := sendJSON(t, env, alice, http.MethodPost, "/api/v1/watches", map[string]interface{}{
+		"productName": "Butter", "maxPrice": 4.00, "currency": "cad", "unit": "lb",
+	})
+	if code != http.StatusCreated || watch["currency"] != "CAD" || watch["unit"] != "lb" {
+		t.Fatalf("failed to create watch: %d %v", code, watch)
+	}
+
+	createPurchase(t, env, bob, "Butter", "2025.04.01", "5.49CAD", "1lb", "Costco")
+	createPurchase(t, env, bob, "butter", "2025.04.02", "3.59CAD", "1lb", "Walmart")
+
+	code, inbox := getJSON(t, env, alice, "/api/v1/alerts?unread=true")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, inbox)
+	}
+	alerts := inbox["data"].([]interface{})
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert, got %v", alerts)
+	}
+	alert := alerts[0].(map[string]interface{})
+	if alert["storeName"] != "Walmart" || alert["unitPrice"].(float64) != 3.59 || alert["read"] != false {
+		t.Errorf("unexpected alert: %v", alert)
+	}
+
+	id := strconv.FormatInt(int64(alert["id"].(float64)), 10)
+	if code, resp := sendJSON(t, env, bob, http.MethodPost, "/api/v1/alerts/"+id+"/read", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 marking another user's alert, got %d: %v", code, resp)
+	}
+	if code, resp := sendJSON(t, env, alice, http.MethodPost, "/api/v1/alerts/"+id+"/read", nil); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if _, inbox = getJSON(t, env, alice, "/api/v1/alerts?unread=true"); inbox["total"].(float64) != 0 {
+		t.Errorf("expected no unread alerts, got %v", inbox)
+	}
+
+	code, list := getJSON(t, env, alice, "/api/v1/watches")
+	if code != http.StatusOK || len(list["data"].([]interface{})) != 1 {
+		t.Fatalf("expected one watch, got %d: %v", code, list)
+	}
+	if code, _ := sendJSON(t, env, bob, http.MethodDelete, "/api/v1/watches/"+watch["id"].(string), nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting another user's watch, got %d", code)
+	}
+	if code, _ := sendJSON(t, env, alice, http.MethodDelete, "/api/v1/watches/"+watch["id"].(string), nil); code != http.StatusOK {
+		t.Errorf("expected 200 deleting own watch, got %d", code)
+	}
+}
+
+func TestWatch_Validation(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	for _, body := range []map[string]interface{}{
+		{"maxPrice": 4, "currency": "CAD"},
+		{"productName": "Butter", "productId": "p1", "maxPrice": 4, "currency": "CAD"},
+		{"productName": "Butter", "maxPrice": 0, "currency": "CAD"},
+		{"productName": "Butter", "maxPrice": 4, "currency": "$"},
+	} {
+		if code, resp := sendJSON(t, env, token, http.MethodPost, "/api/v1/watches", body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d: %v", body, code, resp)
+		}
+	}
+	code, resp := sendJSON(t, env, token, http.MethodPost, "/api/v1/watches", map[string]interface{}{
+		"productId": "missing", "maxPrice": 4, "currency": "CAD",
+	})
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown product, got %d: %v", code, resp)
+	}
+}
+
+// ===========================================================================
+// Outgoing webhook tests
+// ===========================================================================
+
+func TestWebhook_AdminCRUDAndDeliveryLog(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	user := env.getUserToken(t, "alice", "password123")
+
+	body := map[string]interface{}{"url": "https://hooks.example.com/gyd", "events": []string{"receipt.*"}}
+	if code, _ := sendJSON(t, env, user, http.MethodPost, "/api/v1/webhooks", body); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", code)
+	}
+	code, sub := sendJSON(t, env, admin, http.MethodPost, "/api/v1/webhooks", body)
+	if code != http.StatusCreated || sub["active"] != true || len(sub["secret"].(string)) != 64 {
+		t.Fatalf("failed to create webhook: %d %v", code, sub)
+	}
+	id := sub["id"].(string)
+
+	code, got := getJSON(t, env, admin, "/api/v1/webhooks/"+id)
+	if code != http.StatusOK || got["secret"] != nil {
+		t.Errorf("expected the secret to be hidden after creation, got %d %v", code, got)
+	}
+
+	createPurchase(t, env, user, "Butter", "2025.04.01", "4.99CAD", "1lb", "Costco")
+
+	code, log := getJSON(t, env, admin, "/api/v1/webhooks/"+id+"/deliveries?status=pending")
+	if code != http.StatusOK || log["total"].(float64) != 1 {
+		t.Fatalf("expected one pending delivery, got %d: %v", code, log)
+	}
+	delivery := log["data"].([]interface{})[0].(map[string]interface{})
+	if delivery["eventType"] != "receipt.created" || delivery["attempts"].(float64) != 0 {
+		t.Errorf("unexpected delivery: %v", delivery)
+	}
+	deliveryID := strconv.FormatInt(int64(delivery["id"].(float64)), 10)
+	if code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/webhooks/"+id+"/deliveries/"+deliveryID+"/retry", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 retrying a delivery that is not dead, got %d", code)
+	}
+
+	code, updated := sendJSON(t, env, admin, http.MethodPut, "/api/v1/webhooks/"+id, map[string]interface{}{
+		"url": "https://hooks.example.com/gyd", "events": []string{"meta.updated"}, "active": false,
+	})
+	if code != http.StatusOK || updated["active"] != false || updated["secret"] != nil {
+		t.Fatalf("failed to update webhook: %d %v", code, updated)
+	}
+	createPurchase(t, env, user, "Butter", "2025.04.02", "4.79CAD", "1lb", "Costco")
+	if _, log = getJSON(t, env, admin, "/api/v1/webhooks/"+id+"/deliveries"); log["total"].(float64) != 1 {
+		t.Errorf("expected no new deliveries for an inactive webhook, got %v", log)
+	}
+
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/webhooks/"+id, nil); code != http.StatusOK {
+		t.Errorf("expected 200 deleting webhook, got %d", code)
+	}
+	if code, _ := getJSON(t, env, admin, "/api/v1/webhooks/"+id+"/deliveries"); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted webhook's deliveries, got %d", code)
+	}
+}
+
+func TestWebhook_Validation(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	for _, body := range []map[string]interface{}{
+		{"events": []string{"*"}},
+		{"url": "ftp://example.com", "events": []string{"*"}},
+		{"url": "https://example.com"},
+		{"url": "https://example.com", "events": []string{}},
+		{"url": "https://example.com", "events": []string{"user.created"}},
+	} {
+		if code, resp := sendJSON(t, env, admin, http.MethodPost, "/api/v1/webhooks", body); code != http.StatusBadRequest {
+			t.Errorf("%v: expected 400, got %d: %v", body, code, resp)
+		}
+	}
+	if code, _ := getJSON(t, env, admin, "/api/v1/webhooks/missing/deliveries?status=lost"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown status, got %d", code)
+	}
+}
+
+// ===========================================================================
+// Receipt event stream tests
+// ===========================================================================
+
+// streamEvents opens the receipt event stream for d, then returns the
+// (id, event) pairs received before the stream is cut off.
+func streamEvents(t *testing.T, env *testEnv, token, lastEventID string, d time.Duration) (int, [][2]string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/receipts/stream", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+token)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	var events [][2]string
+	var id string
+	for _, line := range bytes.Split(w.Body.Bytes(), []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(line, []byte("id: ")):
+			id = string(line[4:])
+		case bytes.HasPrefix(line, []byte("event: ")):
+			events = append(events, [2]string{id, string(line[7:])})
+		}
+	}
+	if w.Code == http.StatusOK && w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+	return w.Code, events
+}
+
+func TestReceiptStream_ResumesFromLastEventID(t *testing.T) {
+	env := setupEnv(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	bob := env.getUserToken(t, "bob", "password123")
+
+	code, receipt := sendJSON(t, env, alice, http.MethodPost, "/api/v1/receipts", map[string]string{
+		"productName": "Milk", "purchaseDate": "2025.04.01", "price": "5.49CAD", "amount": "1", "storeName": "Costco",
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("failed to create receipt: %d %v", code, receipt)
+	}
+	createReceipt(t, env, bob, "Bread", "2025.04.01")
+	if code, _ := sendJSON(t, env, alice, http.MethodDelete, "/api/v1/receipts/"+receipt["id"].(string), nil); code != http.StatusOK {
+		t.Fatalf("failed to delete receipt: %d", code)
+	}
+
+	code, events := streamEvents(t, env, alice, "0", 100*time.Millisecond)
+	if code != http.StatusOK || len(events) != 2 ||
+		events[0][1] != "receipt.created" || events[1][1] != "receipt.deleted" {
+		t.Fatalf("expected alice's created and deleted events, got %d %v", code, events)
+	}
+
+	if _, resumed := streamEvents(t, env, alice, events[0][0], 100*time.Millisecond); len(resumed) != 1 || resumed[0] != events[1] {
+		t.Errorf("expected only the delete after resuming from %s, got %v", events[0][0], resumed)
+	}
+	if _, live := streamEvents(t, env, alice, "", 100*time.Millisecond); len(live) != 0 {
+		t.Errorf("expected a fresh stream to start after existing events, got %v", live)
+	}
+	if code, _ := streamEvents(t, env, alice, "abc", 100*time.Millisecond); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed Last-Event-ID, got %d", code)
+	}
+}
+
+func TestReceiptStream_ResetAfterCompaction(t *testing.T) {
+	env := setupEnv(t)
+	alice := env.getUserToken(t, "alice", "password123")
+
+	createReceipt(t, env, alice, "Milk", "2025.04.01")
+	createReceipt(t, env, alice, "Bread", "2025.04.02")
+	if _, err := env.eventRepo.CompactEvents(context.Background(), time.Now().Add(time.Hour).Unix()); err != nil {
+		t.Fatalf("CompactEvents failed: %v", err)
+	}
+	createReceipt(t, env, alice, "Eggs", "2025.04.03")
+	through, _ := env.eventRepo.CompactedThrough(context.Background())
+
+	// A client that last saw the first event missed the compacted second one.
+	code, events := streamEvents(t, env, alice, "1", 100*time.Millisecond)
+	if code != http.StatusOK || len(events) != 2 {
+		t.Fatalf("expected a reset and the new event, got %d %v", code, events)
+	}
+	if events[0] != [2]string{strconv.FormatInt(through, 10), "reset"} || events[1][1] != "receipt.created" {
+		t.Errorf("expected a reset at %d, then receipt.created, got %v", through, events)
+	}
+}
+
+func TestChanges_DeletedUserReceipts(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	createReceipt(t, env, alice, "Milk", "2025.04.01")
+	createReceipt(t, env, alice, "Bread", "2025.04.02")
+	aliceUser, _ := env.userRepo.GetUserByUsername(context.Background(), "alice")
+
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/users/"+aliceUser.ID, nil); code != http.StatusOK {
+		t.Fatalf("failed to delete user: %d", code)
+	}
+
+	_, page := getJSON(t, env, admin, "/api/v1/changes")
+	deleted := 0
+	for _, c := range page["data"].([]interface{}) {
+		change := c.(map[string]interface{})
+		if change["type"] == "receipt.deleted" && change["userId"] == aliceUser.ID {
+			deleted++
+		}
+	}
+	if deleted != 2 {
+		t.Errorf("expected a receipt.deleted change for each of alice's receipts, got %d: %v", deleted, page["data"])
+	}
+}
+
+func TestChanges_ReadSinceSequence(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice := env.getUserToken(t, "alice", "password123")
+
+	createReceipt(t, env, alice, "Milk", "2025.04.01")
+	createReceipt(t, env, alice, "Bread", "2025.04.02")
+	if code, _ := sendJSON(t, env, admin, http.MethodPut, "/api/v1/meta/productName", map[string]string{
+		"description": "What was bought",
+	}); code != http.StatusOK {
+		t.Fatalf("failed to update meta field: %d", code)
+	}
+
+	if code, _ := getJSON(t, env, alice, "/api/v1/changes"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin, got %d", code)
+	}
+	code, page := getJSON(t, env, admin, "/api/v1/changes?limit=2")
+	if code != http.StatusOK || page["hasMore"] != true || len(page["data"].([]interface{})) != 2 {
+		t.Fatalf("expected a full first page, got %d: %v", code, page)
+	}
+	first := page["data"].([]interface{})[0].(map[string]interface{})
+	if first["type"] != "receipt.created" || first["data"].(map[string]interface{})["productName"] != "Milk" {
+		t.Errorf("unexpected first change: %v", first)
+	}
+
+	next := strconv.FormatInt(int64(page["next"].(float64)), 10)
+	code, page = getJSON(t, env, admin, "/api/v1/changes?since="+next)
+	changes := page["data"].([]interface{})
+	if code != http.StatusOK || page["hasMore"] != false || len(changes) != 1 ||
+		changes[0].(map[string]interface{})["type"] != "meta.updated" {
+		t.Fatalf("expected the meta change on the second page, got %d: %v", code, page)
+	}
+
+	if _, err := env.eventRepo.CompactEvents(context.Background(), time.Now().Add(time.Hour).Unix()); err != nil {
+		t.Fatalf("CompactEvents failed: %v", err)
+	}
+	code, gone := getJSON(t, env, admin, "/api/v1/changes?since="+next)
+	if code != http.StatusGone || gone["compactedThrough"] == nil {
+		t.Fatalf("expected 410 after compaction, got %d: %v", code, gone)
+	}
+	last := strconv.FormatInt(int64(gone["compactedThrough"].(float64)), 10)
+	if code, page := getJSON(t, env, admin, "/api/v1/changes?since="+last); code != http.StatusOK || len(page["data"].([]interface{})) != 0 {
+		t.Errorf("expected an empty page from the compaction point, got %d: %v", code, page)
+	}
+}
+
+// ===========================================================================
+// Exchange rate and display currency tests
+// ===========================================================================
+
+func importRates(t *testing.T, env *testEnv, token, csv string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/exchange-rates/import", strings.NewReader(csv))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return w.Code, resp
+}
+
+func TestExchangeRates_AdminManagement(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice := env.getUserToken(t, "alice", "password123")
+
+	if code, _ := importRates(t, env, alice, "date,base,quote,rate\n2025.03.01,USD,CAD,1.4\n"); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a non-admin import, got %d", code)
+	}
+	code, resp := importRates(t, env, admin, "date,base,quote,rate\n2025.03.01,USD,CAD,1.4\n2025.03.01,EUR,CAD,oops\n")
+	if code != http.StatusBadRequest || !strings.Contains(resp["error"].(string), "line 3") {
+		t.Errorf("expected 400 naming line 3, got %d %v", code, resp)
+	}
+	code, resp = importRates(t, env, admin, "date,base,quote,rate\n2025.03.01,USD,CAD,1.4\n2025.03.03,usd,cad,1.5\n")
+	if code != http.StatusOK || resp["imported"] != float64(2) {
+		t.Fatalf("expected 2 rates imported, got %d %v", code, resp)
+	}
+
+	code, resp = sendJSON(t, env, admin, http.MethodPost, "/api/v1/exchange-rates", map[string]interface{}{
+		"base": "cad", "quote": "eur", "date": "2025.3.1", "rate": 0.65,
+	})
+	if code != http.StatusCreated || resp["base"] != "CAD" || resp["date"] != "2025-03-01" {
+		t.Errorf("unexpected created rate: %d %v", code, resp)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/exchange-rates", map[string]interface{}{
+		"base": "CAD", "quote": "CAD", "date": "2025.3.1", "rate": 1,
+	}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a rate between the same currency, got %d", code)
+	}
+
+	code, page := getJSON(t, env, admin, "/api/v1/exchange-rates?quote=cad")
+	data, _ := page["data"].([]interface{})
+	if code != http.StatusOK || page["total"] != float64(2) || data[0].(map[string]interface{})["date"] != "2025-03-03" {
+		t.Errorf("expected the CAD rates newest first, got %d %v", code, page)
+	}
+
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/exchange-rates/USD/CAD/2025-03-03", nil); code != http.StatusOK {
+		t.Errorf("expected 200 deleting a rate, got %d", code)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/exchange-rates/USD/CAD/2025-03-03", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting a missing rate, got %d", code)
+	}
+}
+
+func TestDisplayCurrency_ListAnalyticsAndDeals(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice := env.getUserToken(t, "alice", "password123")
+	if code, resp := importRates(t, env, admin, "date,base,quote,rate\n2025.03.01,USD,CAD,1.4\n2025.03.03,USD,CAD,1.5\n"); code != http.StatusOK {
+		t.Fatalf("failed to import rates: %d %v", code, resp)
+	}
+	createPurchase(t, env, alice, "Milk 4L", "2025.03.02", "4.00USD", "4L", "Target")  // 5.60 CAD
+	createPurchase(t, env, alice, "Milk 4L", "2025.03.04", "4.00USD", "4L", "Target")  // 6.00 CAD
+	createPurchase(t, env, alice, "Milk 4L", "2025.03.04", "5.80CAD", "4L", "Costco")  // as is
+	createPurchase(t, env, alice, "Milk 4L", "2025.02.20", "9.99USD", "4L", "Walmart") // before any rate
+
+	code, page := getJSON(t, env, alice, "/api/v1/receipts?currency=cad&sort_by=purchase_date&sort_order=asc")
+	if code != http.StatusOK {
+		t.Fatalf("failed to list receipts: %d %v", code, page)
+	}
+	var display []interface{}
+	for _, item := range page["data"].([]interface{}) {
+		receipt := item.(map[string]interface{})
+		if p, ok := receipt["displayPrice"].(map[string]interface{}); ok {
+			display = append(display, p["value"])
+		} else {
+			display = append(display, nil)
+		}
+	}
+	if len(display) != 4 || display[0] != nil || display[1] != 5.6 || display[2] != 6.0 || display[3] != 5.8 {
+		t.Errorf("unexpected display prices: %v", display)
+	}
+	if code, _ := getJSON(t, env, alice, "/api/v1/receipts?currency=dollars"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid currency, got %d", code)
+	}
+
+	code, report := getJSON(t, env, alice, "/api/v1/analytics/spending?group_by=store&currency=CAD")
+	if code != http.StatusOK || report["currency"] != "CAD" {
+		t.Fatalf("unexpected spending report: %d %v", code, report)
+	}
+	totals := report["totals"].([]interface{})
+	if len(totals) != 2 || totals[0].(map[string]interface{})["total"] != 17.4 || totals[1].(map[string]interface{})["currency"] != "USD" {
+		t.Errorf("unexpected converted totals: %v", totals)
+	}
+
+	code, deals := getJSON(t, env, alice, "/api/v1/prices/deals?name=Milk+4L&lookback_days=3650&currency=CAD")
+	if code != http.StatusOK || deals["currency"] != "CAD" {
+		t.Fatalf("unexpected deals: %d %v", code, deals)
+	}
+	groups := deals["groups"].([]interface{})
+	first := groups[0].(map[string]interface{})["deals"].([]interface{})[0].(map[string]interface{})
+	if len(groups) != 2 || first["storeName"] != "Costco" {
+		t.Errorf("expected Costco to beat Target once converted, got %v", groups)
+	}
+}
+
+// ===========================================================================
+// JWKS tests
+// ===========================================================================
+
+func TestJWKS_PublicWithoutHMACKeys(t *testing.T) {
+	env := setupEnv(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "" {
+		t.Fatalf("expected a cacheable 200 without authentication, got %d %v", w.Code, w.Header())
+	}
+	// The test server signs with an HMAC secret, which is never published.
+	if body := strings.TrimSpace(w.Body.String()); body != `{"keys":[]}` {
+		t.Errorf("unexpected JWKS: %s", body)
+	}
+}
+
+// ===========================================================================
+// Session tests
+// ===========================================================================
+
+// login logs in over HTTP from a named device and returns the token pair.
+func login(t *testing.T, env *testEnv, username, password, device string) (access, refresh string) {
+	t.Helper()
+	body := jsonBody(t, map[string]string{"username": username, "password": password, "device": device})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gyd-test/1.0")
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login failed: %d %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return resp["access_token"], resp["refresh_token"]
+}
+
+// listSessions returns the caller's sessions.
+func listSessions(t *testing.T, env *testEnv, token string) []model.Session {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list sessions failed: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data []model.Session `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return resp.Data
+}
+
+func TestSessions_ListMarksCurrent(t *testing.T) {
+	env := setupEnv(t)
+	if _, err := env.authService.Register(context.Background(), "alice", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	phone, _ := login(t, env, "alice", "password123", "Phone")
+	login(t, env, "alice", "password123", "Laptop")
+
+	sessions := listSessions(t, env, phone)
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	for _, s := range sessions {
+		if s.Current != (s.Device == "Phone") {
+			t.Errorf("session %q: current = %v", s.Device, s.Current)
+		}
+		if s.UserAgent != "gyd-test/1.0" || s.IPAddress == "" || s.CreatedAt == 0 || s.LastUsedAt == 0 {
+			t.Errorf("expected client details to be recorded, got %+v", s)
+		}
+	}
+}
+
+func TestSessions_RevokeOne(t *testing.T) {
+	env := setupEnv(t)
+	if _, err := env.authService.Register(context.Background(), "alice", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	phone, _ := login(t, env, "alice", "password123", "Phone")
+	laptop, laptopRefresh := login(t, env, "alice", "password123", "Laptop")
+	bob := env.getUserToken(t, "bob", "password123")
+
+	var laptopID string
+	for _, s := range listSessions(t, env, phone) {
+		if s.Device == "Laptop" {
+			laptopID = s.ID
+		}
+	}
+
+	// Another user cannot see or revoke the session.
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/sessions/"+laptopID, nil)
+	req.Header.Set("Authorization", "Bearer "+bob)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 revoking another user's session, got %d", w.Code)
+	}
+
+	code, resp := sendJSON(t, env, phone, http.MethodDelete, "/api/v1/auth/sessions/"+laptopID, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), laptopRefresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the revoked session's refresh token to be rejected")
+	}
+	if code, _ := getJSON(t, env, laptop, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with the revoked session's access token, got %d", code)
+	}
+
+	// The caller's session continues with the new tokens.
+	renewed, _ := resp["access_token"].(string)
+	if sessions := listSessions(t, env, renewed); len(sessions) != 1 || sessions[0].Device != "Phone" || !sessions[0].Current {
+		t.Errorf("expected only the current phone session left, got %+v", sessions)
+	}
+}
+
+func TestSessions_RevokeCurrent(t *testing.T) {
+	env := setupEnv(t)
+	if _, err := env.authService.Register(context.Background(), "alice", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	phone, phoneRefresh := login(t, env, "alice", "password123", "Phone")
+
+	code, resp := sendJSON(t, env, phone, http.MethodDelete, "/api/v1/auth/sessions/"+listSessions(t, env, phone)[0].ID, nil)
+	if code != http.StatusOK || resp["access_token"] != nil {
+		t.Fatalf("expected 200 without new tokens, got %d: %v", code, resp)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), phoneRefresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the revoked session's refresh token to be rejected")
+	}
+}
+
+func TestSessions_RevokeOthers(t *testing.T) {
+	env := setupEnv(t)
+	if _, err := env.authService.Register(context.Background(), "alice", "password123"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	phone, phoneRefresh := login(t, env, "alice", "password123", "Phone")
+	laptop, _ := login(t, env, "alice", "password123", "Laptop")
+	login(t, env, "alice", "password123", "Tablet")
+	var phoneID string
+	for _, s := range listSessions(t, env, phone) {
+		if s.Current {
+			phoneID = s.ID
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+phone)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"revoked":2`) {
+		t.Fatalf("expected 2 sessions revoked, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+
+	// Access tokens of the revoked sessions stop working at once, as do the
+	// caller's old tokens, which the new pair replaces.
+	if code, _ := getJSON(t, env, laptop, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with a revoked session's access token, got %d", code)
+	}
+	if code, _ := getJSON(t, env, phone, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with the caller's old access token, got %d", code)
+	}
+	ctx := context.Background()
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, phoneRefresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the caller's old refresh token to be replaced")
+	}
+
+	// The caller's session continues under the same ID.
+	renewed, _ := resp["access_token"].(string)
+	if sessions := listSessions(t, env, renewed); len(sessions) != 1 || sessions[0].ID != phoneID || !sessions[0].Current {
+		t.Errorf("expected only the current phone session left, got %+v", sessions)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, resp["refresh_token"].(string), env.authService, model.ClientInfo{}); err != nil {
+		t.Errorf("expected the new refresh token to work, got %v", err)
+	}
+}
+
+func TestSessions_AdminRevokesAllOfUser(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+	alice, err := env.authService.Register(context.Background(), "alice", "password123")
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	userToken, refresh := login(t, env, "alice", "password123", "Phone")
+
+	// Regular users cannot force-logout anyone.
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+alice.ID+"/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a regular user, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+alice.ID+"/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the user's refresh token to be revoked")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/users/nonexistent/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown user, got %d", w.Code)
+	}
+}
+
+// ===========================================================================
+// Two-factor authentication tests
+// ===========================================================================
+
+// enableTwoFactor enrolls the token's user in two-factor authentication and
+// returns their TOTP secret and recovery codes.
+func enableTwoFactor(t *testing.T, env *testEnv, token string) (string, []string) {
+	t.Helper()
+	code, enrollment := sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa", nil)
+	if code != http.StatusOK {
+		t.Fatalf("enroll: expected 200, got %d: %v", code, enrollment)
+	}
+	secret := enrollment["secret"].(string)
+	if !strings.HasPrefix(enrollment["uri"].(string), "otpauth://totp/") {
+		t.Errorf("expected an otpauth URI, got %v", enrollment["uri"])
+	}
+
+	totp, _ := auth.TOTPCode(secret, time.Now())
+	code, resp := sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa/confirm", map[string]string{"code": totp})
+	if code != http.StatusOK {
+		t.Fatalf("confirm: expected 200, got %d: %v", code, resp)
+	}
+	var codes []string
+	for _, c := range resp["recoveryCodes"].([]interface{}) {
+		codes = append(codes, c.(string))
+	}
+	return secret, codes
+}
+
+// twoFactorChallenge logs in with a password and returns the two-factor
+// token the login asks to be exchanged.
+func twoFactorChallenge(t *testing.T, env *testEnv, username, password string) string {
+	t.Helper()
+	w := postLogin(t, env, username, password)
+	var resp map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp["two_factor_required"] != true {
+		t.Fatalf("expected a two-factor challenge, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := resp["access_token"]; ok {
+		t.Fatal("expected no tokens before the second factor")
+	}
+	return resp["two_factor_token"].(string)
+}
+
+func TestTwoFactor_Login(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+	token, _ := login(t, env, "alice", "password123", "")
+	secret, _ := enableTwoFactor(t, env, token)
+
+	challenge := twoFactorChallenge(t, env, "alice", "password123")
+	// The confirmation code was used up; the next one is within clock skew.
+	totp, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+	code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+		"two_factor_token": challenge, "code": totp, "device": "Phone",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	access := resp["access_token"].(string)
+	if code, _ := getJSON(t, env, access, "/api/v1/auth/me"); code != http.StatusOK {
+		t.Errorf("expected the new access token to work, got %d", code)
+	}
+
+	sessions := listSessions(t, env, access)
+	for _, s := range sessions {
+		if s.Current != s.TwoFactor {
+			t.Errorf("session %s: expected TwoFactor %v, got %v", s.ID, s.Current, s.TwoFactor)
+		}
+	}
+
+	// Each code works once.
+	code, _ = sendJSON(t, env, "", http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+		"two_factor_token": challenge, "code": totp,
+	})
+	if code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a replayed code, got %d", code)
+	}
+}
+
+func TestTwoFactor_LoginWithRecoveryCode(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	_, codes := enableTwoFactor(t, env, token)
+
+	challenge := twoFactorChallenge(t, env, "alice", "password123")
+	code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+		"two_factor_token": challenge, "code": codes[0],
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+
+	code, status := getJSON(t, env, token, "/api/v1/auth/2fa")
+	if code != http.StatusOK || status["enabled"] != true || status["recoveryCodesLeft"] != float64(9) {
+		t.Errorf("expected 9 recovery codes left, got %d: %v", code, status)
+	}
+}
+
+func TestTwoFactor_LoginRejectsBadInput(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	enableTwoFactor(t, env, token)
+	challenge := twoFactorChallenge(t, env, "alice", "password123")
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"wrong code", challenge, "invalid two-factor code"},
+		{"access token as challenge", token, "invalid or expired two-factor token"},
+		{"garbage token", "not-a-token", "invalid or expired two-factor token"},
+	}
+	for _, tt := range tests {
+		code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+			"two_factor_token": tt.token, "code": "000000",
+		})
+		if code != http.StatusUnauthorized || resp["error"] != tt.want {
+			t.Errorf("%s: expected 401 %q, got %d: %v", tt.name, tt.want, code, resp)
+		}
+	}
+
+	// The challenge token is not an access token either.
+	if code, _ := getJSON(t, env, challenge, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a challenge used as an access token, got %d", code)
+	}
+}
+
+func TestTwoFactor_WrongCodesLockOut(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	enableTwoFactor(t, env, token)
+	challenge := twoFactorChallenge(t, env, "alice", "password123")
+
+	for i := 0; i < 5; i++ {
+		sendJSON(t, env, "", http.MethodPost, "/api/v1/auth/login/2fa", map[string]string{
+			"two_factor_token": challenge, "code": "000000",
+		})
+	}
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after wrong two-factor codes, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTwoFactor_EnrollAndConfirmErrors(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	if code, status := getJSON(t, env, token, "/api/v1/auth/2fa"); code != http.StatusOK || status["enabled"] != false {
+		t.Errorf("expected two-factor authentication to be off, got %d: %v", code, status)
+	}
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa/confirm", map[string]string{"code": "000000"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 confirming without an enrollment, got %d", code)
+	}
+
+	sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa", nil)
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa/confirm", map[string]string{"code": "000000"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a wrong code, got %d", code)
+	}
+
+	enableTwoFactor(t, env, token)
+	if code, _ := sendJSON(t, env, token, http.MethodPost, "/api/v1/auth/2fa", nil); code != http.StatusConflict {
+		t.Errorf("expected 409 enrolling twice, got %d", code)
+	}
+}
+
+func TestTwoFactor_Disable(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+	token, refresh := login(t, env, "alice", "password123", "")
+	_, codes := enableTwoFactor(t, env, token)
+
+	if code, _ := sendJSON(t, env, token, http.MethodDelete, "/api/v1/auth/2fa", map[string]string{"code": "000000"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wrong code, got %d", code)
+	}
+	code, resp := sendJSON(t, env, token, http.MethodDelete, "/api/v1/auth/2fa", map[string]string{"code": codes[0]})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+
+	// Every session is logged out, and logging in takes a password alone.
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected sessions to be revoked")
+	}
+	login(t, env, "alice", "password123", "")
+}
+
+// ===========================================================================
+// Account self-service tests
+// ===========================================================================
+
+func TestChangePassword(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+	token, refresh := login(t, env, "alice", "password123", "Laptop")
+	other, otherRefresh := login(t, env, "alice", "password123", "Phone")
+
+	code, resp := sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/password", map[string]string{
+		"current_password": "wrong-password", "new_password": "newpassword1",
+	})
+	if code != http.StatusBadRequest || resp["error"] != "current password is incorrect" {
+		t.Fatalf("expected 400 for a wrong current password, got %d: %v", code, resp)
+	}
+	if code, _ := sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/password", map[string]string{
+		"current_password": "password123", "new_password": "short",
+	}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a short new password, got %d", code)
+	}
+
+	code, resp = sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/password", map[string]string{
+		"current_password": "password123", "new_password": "newpassword1",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+
+	// The other session is logged out at once, access token included.
+	ctx := context.Background()
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, otherRefresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the other session to be revoked")
+	}
+	if code, _ := getJSON(t, env, other, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with the other session's access token, got %d", code)
+	}
+
+	// The session that changed the password continues with new tokens.
+	if code, _ := getJSON(t, env, token, "/api/v1/auth/me"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with the replaced access token, got %d", code)
+	}
+	if code, _ := getJSON(t, env, resp["access_token"].(string), "/api/v1/auth/me"); code != http.StatusOK {
+		t.Errorf("expected the new access token to work, got %d", code)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, refresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the replaced refresh token to stop working")
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(ctx, resp["refresh_token"].(string), env.authService, model.ClientInfo{}); err != nil {
+		t.Errorf("expected the new refresh token to work, got %v", err)
+	}
+
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the old password, got %d", w.Code)
+	}
+	login(t, env, "alice", "newpassword1", "")
+}
+
+func TestChangePassword_WrongPasswordsLockOut(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+
+	for i := 0; i < 5; i++ {
+		sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/password", map[string]string{
+			"current_password": "wrong-password", "new_password": "newpassword1",
+		})
+	}
+	code, _ := sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/password", map[string]string{
+		"current_password": "password123", "new_password": "newpassword1",
+	})
+	if code != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after wrong passwords, got %d", code)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	env := setupEnv(t)
+	token := env.getUserToken(t, "alice", "password123")
+	env.getUserToken(t, "bob", "password123")
+
+	code, resp := sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/me", map[string]string{
+		"username": "alice2", "displayName": "Alice",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+	if resp["username"] != "alice2" || resp["displayName"] != "Alice" {
+		t.Errorf("expected alice2 / Alice, got %v", resp)
+	}
+	if _, ok := resp["passwordHash"]; ok {
+		t.Error("password hash must not be returned")
+	}
+	login(t, env, "alice2", "password123", "")
+
+	code, resp = sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/me", map[string]string{"username": "bob"})
+	if code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken username, got %d: %v", code, resp)
+	}
+	if code, _ := sendJSON(t, env, token, http.MethodPut, "/api/v1/auth/me", map[string]string{"displayName": "Alice"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without a username, got %d", code)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	env := setupEnv(t)
+	env.getUserToken(t, "alice", "password123")
+	token, refresh := login(t, env, "alice", "password123", "")
+	createReceipt(t, env, token, "Milk", "2025.01.01")
+
+	if code, _ := sendJSON(t, env, token, http.MethodDelete, "/api/v1/auth/me", map[string]string{"password": "wrong-password"}); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wrong password, got %d", code)
+	}
+	code, resp := sendJSON(t, env, token, http.MethodDelete, "/api/v1/auth/me", map[string]string{"password": "password123"})
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", code, resp)
+	}
+
+	if code, _ := getJSON(t, env, token, "/api/v1/receipts"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 with the deleted user's access token, got %d", code)
+	}
+	if _, _, err := env.tokens.RefreshAccessToken(context.Background(), refresh, env.authService, model.ClientInfo{}); err == nil {
+		t.Error("expected the deleted user's refresh token to be revoked")
+	}
+	if w := postLogin(t, env, "alice", "password123"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 logging in to the deleted account, got %d", w.Code)
+	}
+}
+
+func TestDeleteAccount_AdminRefused(t *testing.T) {
+	env := setupEnv(t)
+	admin := env.getAdminToken(t)
+
+	code, resp := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/auth/me", map[string]string{"password": "adminpass1"})
+	if code != http.StatusBadRequest || resp["error"] != "admins cannot delete their own account" {
+		t.Errorf("expected 400 for an admin, got %d: %v", code, resp)
+	}
+}
+
+// ===========================================================================
+// Registration mode and invite tests
+// ===========================================================================
+
+func TestRegister_Closed(t *testing.T) {
+	env := setupEnvWithRegistration(t, auth.RegistrationClosed)
+
+	code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/users", map[string]string{
+		"username": "alice", "password": "password123",
+	})
+	if code != http.StatusForbidden || resp["error"] != "registration is closed" {
+		t.Errorf("expected 403 while registration is closed, got %d: %v", code, resp)
+	}
+}
+
+func TestRegister_InviteOnly(t *testing.T) {
+	env := setupEnvWithRegistration(t, auth.RegistrationInvite)
+	admin := env.getAdminToken(t)
+
+	code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/users", map[string]string{
+		"username": "alice", "password": "password123",
+	})
+	if code != http.StatusForbidden || resp["error"] != "an invite code is required" {
+		t.Fatalf("expected 403 without an invite code, got %d: %v", code, resp)
+	}
+
+	code, invite := sendJSON(t, env, admin, http.MethodPost, "/api/v1/invites", map[string]string{})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201 creating an invite, got %d: %v", code, invite)
+	}
+	if invite["maxUses"] != float64(1) || invite["code"] == nil {
+		t.Fatalf("expected a single-use invite with its code, got %v", invite)
+	}
+
+	code, resp = sendJSON(t, env, "", http.MethodPost, "/api/v1/users", map[string]string{
+		"username": "alice", "password": "password123", "invite_code": invite["code"].(string),
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201 with the invite code, got %d: %v", code, resp)
+	}
+	code, resp = sendJSON(t, env, "", http.MethodPost, "/api/v1/users", map[string]string{
+		"username": "bob", "password": "password123", "invite_code": invite["code"].(string),
+	})
+	if code != http.StatusForbidden || resp["error"] != "invalid or expired invite code" {
+		t.Errorf("expected 403 reusing a single-use invite, got %d: %v", code, resp)
+	}
+}
+
+func TestInvites_AdminManagement(t *testing.T) {
+	env := setupEnvWithRegistration(t, auth.RegistrationInvite)
+	admin := env.getAdminToken(t)
+	user := env.getUserToken(t, "alice", "password123")
+
+	if code, _ := sendJSON(t, env, user, http.MethodPost, "/api/v1/invites", map[string]string{}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a regular user, got %d", code)
+	}
+	if code, _ := getJSON(t, env, user, "/api/v1/invites"); code != http.StatusForbidden {
+		t.Errorf("expected 403 listing as a regular user, got %d", code)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodPost, "/api/v1/invites", map[string]interface{}{"expiresIn": "soon"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad expiresIn, got %d", code)
+	}
+
+	code, invite := sendJSON(t, env, admin, http.MethodPost, "/api/v1/invites", map[string]interface{}{
+		"maxUses": 5, "expiresIn": "72h",
+	})
+	if code != http.StatusCreated || invite["maxUses"] != float64(5) {
+		t.Fatalf("expected 201 with 5 uses, got %d: %v", code, invite)
+	}
+	if invite["expiresAt"].(float64)-invite["createdAt"].(float64) != 72*3600 {
+		t.Errorf("expected the invite to expire in 72h, got %v", invite)
+	}
+
+	code, list := getJSON(t, env, admin, "/api/v1/invites")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	data := list["data"].([]interface{})
+	if len(data) != 1 {
+		t.Fatalf("expected 1 invite, got %d", len(data))
+	}
+	if listed := data[0].(map[string]interface{}); listed["code"] != nil || listed["createdBy"] == nil {
+		t.Errorf("expected the code hidden and the creator set, got %v", listed)
+	}
+
+	id := invite["id"].(string)
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/invites/"+id, nil); code != http.StatusOK {
+		t.Fatalf("expected 200 revoking, got %d", code)
+	}
+	code, resp := sendJSON(t, env, "", http.MethodPost, "/api/v1/users", map[string]string{
+		"username": "bob", "password": "password123", "invite_code": invite["code"].(string),
+	})
+	if code != http.StatusForbidden {
+		t.Errorf("expected 403 with a revoked invite, got %d: %v", code, resp)
+	}
+	if code, _ := sendJSON(t, env, admin, http.MethodDelete, "/api/v1/invites/"+id, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 revoking twice, got %d", code)
+	}
+}
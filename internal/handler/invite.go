@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type createInviteRequest struct {
+	MaxUses   int    `json:"maxUses" binding:"gte=0"` // default 1
+	ExpiresIn string `json:"expiresIn"`               // Go duration, default 168h
+}
+
+// ListInvites handles GET /api/v1/invites — admin only.
+// Lists all invites, newest first. Their codes are not shown.
+func (h *AuthHandler) ListInvites(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	invites, err := h.registration.ListInvites(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invites})
+}
+
+// CreateInvite handles POST /api/v1/invites — admin only.
+// Creates an invite code for invite-only registration. The code is in the
+// response and is not shown again.
+func (h *AuthHandler) CreateInvite(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	// Every field is optional, so an empty body is fine.
+	var req createInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	maxUses, ttl := req.MaxUses, auth.DefaultInviteExpiry
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresIn must be a positive duration such as 72h"})
+			return
+		}
+		ttl = d
+	}
+
+	invite, err := h.registration.CreateInvite(c.Request.Context(), c.GetString(middleware.ContextKeyUserID), maxUses, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// RevokeInvite handles DELETE /api/v1/invites/:id — admin only.
+// Deletes an invite so its code stops working. Accounts already created
+// with it are not affected.
+func (h *AuthHandler) RevokeInvite(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	if err := h.registration.RevokeInvite(c.Request.Context(), c.Param("id")); err != nil {
+		if errors.Is(err, auth.ErrInviteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invite not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "invite revoked"})
+}
@@ -32,7 +32,22 @@ type updateDescriptionRequest struct {
 
 // ListFields handles GET /api/v1/meta
 // Returns a paginated list of all registered fields (native + user-defined).
+// Offset pagination by default; cursor pagination when ?cursor= is given.
 func (h *MetaHandler) ListFields(c *gin.Context) {
+	if isCursorRequest(c) {
+		params, err := parseCursorParams(c, "field_name", "ASC", metaSortFields)
+		if err != nil {
+			return
+		}
+		page, err := h.meta.ListFieldsCursor(c.Request.Context(), params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list fields"})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
 	// Meta fields default to ascending order (alphabetical by name).
 	params, err := parsePaginationParams(c, "field_name", "ASC", metaSortFields)
 	if err != nil {
@@ -48,6 +63,23 @@ func (h *MetaHandler) ListFields(c *gin.Context) {
 	c.JSON(http.StatusOK, page)
 }
 
+// GetField handles GET /api/v1/meta/:fieldName
+// Returns a single field with its version as an ETag.
+func (h *MetaHandler) GetField(c *gin.Context) {
+	field, err := h.meta.GetField(c.Request.Context(), c.Param("fieldName"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get field"})
+		return
+	}
+	if field == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "field not found"})
+		return
+	}
+
+	setETag(c, field.Version)
+	c.JSON(http.StatusOK, field)
+}
+
 // CreateField handles POST /api/v1/meta
 // Registers a new user-defined field.
 func (h *MetaHandler) CreateField(c *gin.Context) {
@@ -70,11 +102,13 @@ func (h *MetaHandler) CreateField(c *gin.Context) {
 		return
 	}
 
+	setETag(c, field.Version)
 	c.JSON(http.StatusCreated, field)
 }
 
 // UpdateDescription handles PUT /api/v1/meta/:fieldName
 // Updates the description of an existing field. Admin only.
+// Honours If-Match: a stale version is rejected with 412.
 func (h *MetaHandler) UpdateDescription(c *gin.Context) {
 	if !requireAdmin(c) {
 		return
@@ -88,14 +122,26 @@ func (h *MetaHandler) UpdateDescription(c *gin.Context) {
 		return
 	}
 
-	if err := h.meta.UpdateDescription(c.Request.Context(), fieldName, req.Description); err != nil {
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := h.meta.UpdateDescription(c.Request.Context(), fieldName, req.Description, expectedVersion); err != nil {
 		if errors.Is(err, model.ErrFieldNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "field not found"})
 			return
 		}
+		if errors.Is(err, model.ErrVersionMismatch) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "field was modified by someone else; reload and retry"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update field"})
 		return
 	}
 
+	if field, err := h.meta.GetField(c.Request.Context(), fieldName); err == nil && field != nil {
+		setETag(c, field.Version)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "description updated"})
 }
@@ -27,6 +27,12 @@ var receiptSortFields = map[string]string{
 	"created_at":    "upload_time",
 }
 
+// searchSortFields lists the sort_by values search accepts besides its
+// default relevance ranking. Only valid together with a center point.
+var searchSortFields = map[string]string{
+	"distance": model.SortByDistance,
+}
+
 // userSortFields maps API sort_by values to user DB column names.
 // Note: "email" is intentionally absent — the users table has no email column.
 var userSortFields = map[string]string{
@@ -41,6 +47,37 @@ var metaSortFields = map[string]string{
 	"name": "field_name",
 }
 
+// storeSortFields maps API sort_by values to stores DB column names.
+var storeSortFields = map[string]string{
+	"name":       "name",
+	"chain":      "chain",
+	"created_at": "created_at",
+}
+
+// productSortFields maps API sort_by values to products DB column names.
+var productSortFields = map[string]string{
+	"name":       "name",
+	"created_at": "created_at",
+}
+
+// alertSortFields maps API sort_by values to alerts DB column names. Alert
+// IDs increase with creation time, so "created_at" sorts by ID.
+var alertSortFields = map[string]string{
+	"created_at": "id",
+}
+
+// deliverySortFields maps API sort_by values to webhook_deliveries DB column
+// names. Delivery IDs increase with creation time, so "created_at" sorts by ID.
+var deliverySortFields = map[string]string{
+	"created_at": "id",
+	"attempts":   "attempts",
+}
+
+// exchangeRateSortFields maps API sort_by values to exchange_rates DB column names.
+var exchangeRateSortFields = map[string]string{
+	"date": "rate_date",
+}
+
 // parsePaginationParams parses and validates the four pagination query parameters
 // (offset, limit, sort_by, sort_order) from the request.
 //
@@ -55,31 +92,147 @@ func parsePaginationParams(
 	defaultSortOrderOverride string,
 	allowedSortFields map[string]string,
 ) (model.PaginationParams, error) {
-	// --- offset ---
+	offset, err := parseOffset(c)
+	if err != nil {
+		return model.PaginationParams{}, err
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		return model.PaginationParams{}, err
+	}
+
+	sortBy, sortOrder, err := parseSort(c, defaultSortBy, defaultSortOrderOverride, allowedSortFields)
+	if err != nil {
+		return model.PaginationParams{}, err
+	}
+
+	return model.PaginationParams{
+		Offset:    offset,
+		Limit:     limit,
+		SortBy:    sortBy,
+		SortOrder: sortOrder,
+	}, nil
+}
+
+// isCursorRequest reports whether the client asked for cursor pagination.
+// Cursor mode is selected by the presence of the cursor query parameter;
+// an empty value (?cursor=) requests the first page.
+func isCursorRequest(c *gin.Context) bool {
+	_, ok := c.GetQuery("cursor")
+	return ok
+}
+
+// parseCursorParams parses and validates the cursor pagination query parameters
+// (cursor, limit, sort_by, sort_order, include_total) from the request.
+//
+// A non-empty cursor carries the ordering it was issued for, so sort_by and
+// sort_order may be omitted on follow-up pages; if given, they must match.
+// The cursor's sort column is checked against allowedSortFields before use,
+// since it ends up interpolated into SQL.
+//
+// On validation error, this function writes a 400 JSON response and returns a
+// non-nil error; the caller must return immediately without writing further output.
+func parseCursorParams(
+	c *gin.Context,
+	defaultSortBy string,
+	defaultSortOrderOverride string,
+	allowedSortFields map[string]string,
+) (model.CursorParams, error) {
+	if _, ok := c.GetQuery("offset"); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset cannot be combined with cursor"})
+		return model.CursorParams{}, fmt.Errorf("offset with cursor")
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		return model.CursorParams{}, err
+	}
+
+	sortBy, sortOrder, err := parseSort(c, defaultSortBy, defaultSortOrderOverride, allowedSortFields)
+	if err != nil {
+		return model.CursorParams{}, err
+	}
+
+	includeTotal := false
+	if raw := c.Query("include_total"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "include_total must be true or false"})
+			return model.CursorParams{}, fmt.Errorf("invalid include_total")
+		}
+		includeTotal = v
+	}
+
+	params := model.CursorParams{
+		Limit:        limit,
+		SortBy:       sortBy,
+		SortOrder:    sortOrder,
+		IncludeTotal: includeTotal,
+	}
+
+	raw := c.Query("cursor")
+	if raw == "" {
+		return params, nil
+	}
+	cursor, err := model.DecodeCursor(raw)
+	if err != nil || !isAllowedColumn(cursor.SortBy, allowedSortFields) ||
+		(cursor.SortOrder != "ASC" && cursor.SortOrder != "DESC") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+		return model.CursorParams{}, fmt.Errorf("invalid cursor")
+	}
+	if (c.Query("sort_by") != "" && cursor.SortBy != sortBy) ||
+		(c.Query("sort_order") != "" && cursor.SortOrder != sortOrder) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort_by and sort_order cannot change between pages"})
+		return model.CursorParams{}, fmt.Errorf("sort changed with cursor")
+	}
+	params.SortBy = cursor.SortBy
+	params.SortOrder = cursor.SortOrder
+	params.After = cursor
+	return params, nil
+}
+
+// parseOffset parses the offset query parameter, defaulting to 0.
+// On validation error it writes a 400 JSON response.
+func parseOffset(c *gin.Context) (int, error) {
 	offset := 0
 	if raw := c.Query("offset"); raw != "" {
 		v, err := strconv.Atoi(raw)
 		if err != nil || v < 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
-			return model.PaginationParams{}, fmt.Errorf("invalid offset")
+			return 0, fmt.Errorf("invalid offset")
 		}
 		offset = v
 	}
+	return offset, nil
+}
 
-	// --- limit ---
+// parseLimit parses the limit query parameter, applying the default and cap.
+// On validation error it writes a 400 JSON response.
+func parseLimit(c *gin.Context) (int, error) {
 	limit := defaultLimit
 	if raw := c.Query("limit"); raw != "" {
 		v, err := strconv.Atoi(raw)
 		if err != nil || v < 1 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
-			return model.PaginationParams{}, fmt.Errorf("invalid limit")
+			return 0, fmt.Errorf("invalid limit")
 		}
 		if v > maxLimit {
 			v = maxLimit
 		}
 		limit = v
 	}
+	return limit, nil
+}
 
+// parseSort parses the sort_by and sort_order query parameters, mapping sort_by
+// through allowedSortFields. On validation error it writes a 400 JSON response.
+func parseSort(
+	c *gin.Context,
+	defaultSortBy string,
+	defaultSortOrderOverride string,
+	allowedSortFields map[string]string,
+) (string, string, error) {
 	// --- sort_order ---
 	sortOrder := defaultSortOrder
 	if defaultSortOrderOverride != "" {
@@ -89,7 +242,7 @@ func parsePaginationParams(
 		upper := strings.ToUpper(raw)
 		if upper != "ASC" && upper != "DESC" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort_order: allowed values are asc, desc"})
-			return model.PaginationParams{}, fmt.Errorf("invalid sort_order")
+			return "", "", fmt.Errorf("invalid sort_order")
 		}
 		sortOrder = upper
 	}
@@ -106,15 +259,19 @@ func parsePaginationParams(
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": fmt.Sprintf("invalid sort_by: allowed values are %s", strings.Join(allowed, ", ")),
 			})
-			return model.PaginationParams{}, fmt.Errorf("invalid sort_by")
+			return "", "", fmt.Errorf("invalid sort_by")
 		}
 		sortBy = col
 	}
+	return sortBy, sortOrder, nil
+}
 
-	return model.PaginationParams{
-		Offset:    offset,
-		Limit:     limit,
-		SortBy:    sortBy,
-		SortOrder: sortOrder,
-	}, nil
+// isAllowedColumn reports whether column is one of the DB columns in allowedSortFields.
+func isAllowedColumn(column string, allowedSortFields map[string]string) bool {
+	for _, col := range allowedSortFields {
+		if col == column {
+			return true
+		}
+	}
+	return false
 }
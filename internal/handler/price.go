@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// Deal lookback window bounds, in days.
+const (
+	defaultLookbackDays = 30
+	maxLookbackDays     = 3650
+)
+
+// PriceHandler handles HTTP requests for price endpoints. Prices are
+// computed from the receipts of all users.
+type PriceHandler struct {
+	prices     repository.PriceRepository
+	products   repository.ProductRepository
+	categories repository.CategoryRepository
+}
+
+// NewPriceHandler creates a new price handler.
+func NewPriceHandler(prices repository.PriceRepository, products repository.ProductRepository, categories repository.CategoryRepository) *PriceHandler {
+	return &PriceHandler{prices: prices, products: products, categories: categories}
+}
+
+// PriceHistory handles GET /api/v1/prices/history
+// Returns the unit price history of one product, selected by ?product_id=
+// or ?name= (see bindPriceProduct). ?bucket=week|month (default week) sets
+// the bucket size and ?from= / ?to= (Y.M.D, inclusive) limit the date range.
+// ?currency= converts prices at purchase-date rates, as for Deals.
+func (h *PriceHandler) PriceHistory(c *gin.Context) {
+	filter, ok := h.bindPriceProduct(c, false)
+	if !ok {
+		return
+	}
+
+	var err error
+	if filter.From, err = parseDateParam(c, "from"); err != nil {
+		return
+	}
+	if filter.To, err = parseDateParam(c, "to"); err != nil {
+		return
+	}
+	if filter.Currency, err = parseCurrencyParam(c, "currency"); err != nil {
+		return
+	}
+
+	bucket := c.DefaultQuery("bucket", model.BucketWeek)
+	if bucket != model.BucketWeek && bucket != model.BucketMonth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be week or month"})
+		return
+	}
+
+	history, err := h.prices.PriceHistory(c.Request.Context(), filter, bucket)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get price history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// Deals handles GET /api/v1/prices/deals
+// Ranks stores by the latest and lowest unit price of a product (?product_id=
+// or ?name=) or of any product in a category (?category=), over the last
+// ?lookback_days= days (default 30). Each deal shows how far its latest
+// price is from the product's historical median. lat, lng with radius_km,
+// or bbox, keep only stores in that area. ?currency= converts unit prices
+// into that currency at their purchase-date rates, so stores pricing in
+// different currencies rank together; prices without a known rate stay in
+// their own currency group.
+func (h *PriceHandler) Deals(c *gin.Context) {
+	filter, ok := h.bindPriceProduct(c, true)
+	if !ok {
+		return
+	}
+
+	days := defaultLookbackDays
+	if raw := c.Query("lookback_days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLookbackDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("lookback_days must be an integer in [1, %d]", maxLookbackDays)})
+			return
+		}
+		days = n
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	filter.From = today.AddDate(0, 0, -days)
+
+	var err error
+	if filter.Currency, err = parseCurrencyParam(c, "currency"); err != nil {
+		return
+	}
+	near, err := parseGeoFilter(c)
+	if err != nil {
+		return
+	}
+	if near != nil && near.RadiusKm == 0 && near.Box == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lng require radius_km"})
+		return
+	}
+
+	report, err := h.prices.Deals(c.Request.Context(), filter, near)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find deals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// bindPriceProduct reads which receipts a price endpoint looks at: ?product_id=
+// for a catalog product, ?category= (if allowCategory) for the products in a
+// category and its subcategories, or ?name=. A name that resolves to a
+// catalog product (by name or alias) covers all receipts linked to that
+// product; otherwise receipts are matched by product name, ignoring case.
+// Products and categories given by ID must exist. On error it writes the
+// response and returns false.
+func (h *PriceHandler) bindPriceProduct(c *gin.Context, allowCategory bool) (model.PriceFilter, bool) {
+	var filter model.PriceFilter
+	ctx := c.Request.Context()
+
+	productID := c.Query("product_id")
+	name := strings.TrimSpace(c.Query("name"))
+	categoryID := ""
+	if allowCategory {
+		categoryID = c.Query("category")
+	}
+
+	given := 0
+	for _, v := range []string{productID, name, categoryID} {
+		if v != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		if allowCategory {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of product_id, name or category is required"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of product_id or name is required"})
+		}
+		return filter, false
+	}
+
+	switch {
+	case productID != "":
+		product, err := h.products.GetProduct(ctx, productID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get product"})
+			return filter, false
+		}
+		if product == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return filter, false
+		}
+		filter.ProductID, filter.ProductName = product.ID, product.Name
+	case categoryID != "":
+		category, err := h.categories.GetCategory(ctx, categoryID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get category"})
+			return filter, false
+		}
+		if category == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "category not found"})
+			return filter, false
+		}
+		filter.CategoryID = category.ID
+	default:
+		product, err := h.products.ResolveProduct(ctx, name, "")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve product"})
+			return filter, false
+		}
+		if product != nil {
+			filter.ProductID, filter.ProductName = product.ID, product.Name
+		} else {
+			filter.ProductName = name
+		}
+	}
+	return filter, true
+}
+
+// parseDateParam parses an optional Y.M.D query parameter. A missing
+// parameter yields the zero time. On error it writes a 400 response.
+func parseDateParam(c *gin.Context, name string) (time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := model.ParsePurchaseDate(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + ": " + err.Error()})
+		return time.Time{}, err
+	}
+	return t, nil
+}
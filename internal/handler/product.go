@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// ProductHandler handles HTTP requests for product catalog endpoints.
+type ProductHandler struct {
+	products repository.ProductRepository
+}
+
+// NewProductHandler creates a new product handler.
+func NewProductHandler(products repository.ProductRepository) *ProductHandler {
+	return &ProductHandler{products: products}
+}
+
+type productRequest struct {
+	Name       string   `json:"name" binding:"required"`
+	Barcode    string   `json:"barcode"`
+	CategoryID string   `json:"categoryId"`
+	Aliases    []string `json:"aliases"`
+}
+
+// ListProducts handles GET /api/v1/products
+// Returns a paginated list of products, alphabetical by name by default.
+// ?category= keeps products in that category or any of its subcategories.
+func (h *ProductHandler) ListProducts(c *gin.Context) {
+	params, err := parsePaginationParams(c, "name", "ASC", productSortFields)
+	if err != nil {
+		return
+	}
+
+	page, err := h.products.ListProducts(c.Request.Context(), c.Query("category"), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetProduct handles GET /api/v1/products/:id
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	product, err := h.products.GetProduct(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get product"})
+		return
+	}
+	if product == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// CreateProduct handles POST /api/v1/products — admin only.
+// Registers a product; receipts whose barcode or product name matches are
+// linked to it.
+func (h *ProductHandler) CreateProduct(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	product, ok := bindProduct(c)
+	if !ok {
+		return
+	}
+	product.ID = uuid.New().String()
+
+	if err := h.products.CreateProduct(c.Request.Context(), product); err != nil {
+		writeProductError(c, err, "failed to create product")
+		return
+	}
+
+	c.JSON(http.StatusCreated, product)
+}
+
+// UpdateProduct handles PUT /api/v1/products/:id — admin only.
+// Replaces the product's details and alias list.
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	product, ok := bindProduct(c)
+	if !ok {
+		return
+	}
+	product.ID = c.Param("id")
+
+	if err := h.products.UpdateProduct(c.Request.Context(), product); err != nil {
+		writeProductError(c, err, "failed to update product")
+		return
+	}
+
+	c.JSON(http.StatusOK, product)
+}
+
+// DeleteProduct handles DELETE /api/v1/products/:id — admin only.
+// Linked receipts are unlinked but keep their product name.
+func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	if err := h.products.DeleteProduct(c.Request.Context(), c.Param("id")); err != nil {
+		writeProductError(c, err, "failed to delete product")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "product deleted"})
+}
+
+// writeProductError maps product repository errors to HTTP responses.
+func writeProductError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, model.ErrProductNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+	case errors.Is(err, model.ErrCategoryNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, model.ErrProductAliasTaken), errors.Is(err, model.ErrBarcodeTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// bindProduct parses and validates a product request body.
+// On error it writes a 400 response and returns false.
+func bindProduct(c *gin.Context) (*model.Product, bool) {
+	var req productRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if model.NormalizeProductName(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must contain letters or digits"})
+		return nil, false
+	}
+
+	var barcode string
+	if strings.TrimSpace(req.Barcode) != "" {
+		var err error
+		if barcode, err = model.NormalizeBarcode(req.Barcode); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+	}
+
+	aliases := []string{}
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		if model.NormalizeProductName(alias) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "aliases must contain letters or digits"})
+			return nil, false
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return &model.Product{
+		Name:       name,
+		Barcode:    barcode,
+		CategoryID: strings.TrimSpace(req.CategoryID),
+		Aliases:    aliases,
+	}, true
+}
@@ -15,11 +15,13 @@ import (
 // ReceiptHandler handles HTTP requests for purchase receipt endpoints.
 type ReceiptHandler struct {
 	receipts repository.ReceiptRepository
+	rates    repository.ExchangeRateRepository
 }
 
-// NewReceiptHandler creates a new receipt handler.
-func NewReceiptHandler(receipts repository.ReceiptRepository) *ReceiptHandler {
-	return &ReceiptHandler{receipts: receipts}
+// NewReceiptHandler creates a new receipt handler. rates converts listed
+// prices into a requested display currency.
+func NewReceiptHandler(receipts repository.ReceiptRepository, rates repository.ExchangeRateRepository) *ReceiptHandler {
+	return &ReceiptHandler{receipts: receipts, rates: rates}
 }
 
 // CreateReceipt handles POST /api/v1/receipts
@@ -45,6 +47,9 @@ func (h *ReceiptHandler) CreateReceipt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "productName, purchaseDate, price, amount, and storeName are required"})
 		return
 	}
+	if !normalizeReceiptBarcode(c, receipt) {
+		return
+	}
 
 	receipt.ID = uuid.New().String()
 	receipt.Extras = extras
@@ -59,11 +64,12 @@ func (h *ReceiptHandler) CreateReceipt(c *gin.Context) {
 		return
 	}
 
+	setETag(c, receipt.Version)
 	c.JSON(http.StatusCreated, receipt)
 }
 
 // GetReceipt handles GET /api/v1/receipts/:id
-// Returns a single receipt by ID.
+// Returns a single receipt by ID, with its version as an ETag.
 func (h *ReceiptHandler) GetReceipt(c *gin.Context) {
 	id := c.Param("id")
 
@@ -77,37 +83,184 @@ func (h *ReceiptHandler) GetReceipt(c *gin.Context) {
 		return
 	}
 
+	setETag(c, receipt.Version)
+	c.JSON(http.StatusOK, receipt)
+}
+
+// UpdateReceipt handles PUT /api/v1/receipts/:id
+// Replaces the native fields and extras of a receipt. Accepts the same flat
+// JSON object as CreateReceipt. Honours If-Match: a stale version is rejected with 412.
+func (h *ReceiptHandler) UpdateReceipt(c *gin.Context) {
+	id := c.Param("id")
+
+	var raw map[string]interface{}
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	receipt, extras := model.ParseReceiptFromMap(raw)
+	if receipt.ProductName == "" || receipt.PurchaseDate == "" ||
+		receipt.Price == "" || receipt.Amount == "" || receipt.StoreName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "productName, purchaseDate, price, amount, and storeName are required"})
+		return
+	}
+	if !normalizeReceiptBarcode(c, receipt) {
+		return
+	}
+
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
+	receipt.ID = id
+	receipt.Extras = extras
+
+	if err := h.receipts.UpdateReceipt(c.Request.Context(), receipt, expectedVersion); err != nil {
+		switch {
+		case errors.Is(err, model.ErrReceiptNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "receipt not found"})
+		case errors.Is(err, model.ErrVersionMismatch):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "receipt was modified by someone else; reload and retry"})
+		case errors.Is(err, model.ErrFieldNotRegistered):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update receipt"})
+		}
+		return
+	}
+
+	setETag(c, receipt.Version)
 	c.JSON(http.StatusOK, receipt)
 }
 
 // ListReceipts handles GET /api/v1/receipts
 // Returns a paginated list of receipts for the authenticated user.
+// Offset pagination by default; cursor pagination when ?cursor= is given.
+// Offset mode also accepts geographic filters (lat, lng, radius_km, bbox);
+// with a center point each receipt carries distanceKm and sort_by=distance is allowed.
+// With ?currency= each receipt whose price can be converted at its purchase
+// date's rate carries displayPrice.
 func (h *ReceiptHandler) ListReceipts(c *gin.Context) {
 	userID, exists := c.Get(middleware.ContextKeyUserID)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
 		return
 	}
+	currency, err := parseCurrencyParam(c, "currency")
+	if err != nil {
+		return
+	}
 
-	params, err := parsePaginationParams(c, "upload_time", "", receiptSortFields)
+	if isCursorRequest(c) {
+		if hasGeoParams(c) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "geographic filters cannot be combined with cursor"})
+			return
+		}
+		params, err := parseCursorParams(c, "upload_time", "", receiptSortFields)
+		if err != nil {
+			return
+		}
+		page, err := h.receipts.ListReceiptsByUserCursor(c.Request.Context(), userID.(string), params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list receipts"})
+			return
+		}
+		if !h.setDisplayPrices(c, page.Data, currency) {
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	geo, err := parseGeoFilter(c)
+	if err != nil {
+		return
+	}
+	sortFields := receiptSortFields
+	if geo != nil && geo.Center != nil {
+		sortFields = withDistanceSort(receiptSortFields)
+	}
+	params, err := parsePaginationParams(c, "upload_time", "", sortFields)
 	if err != nil {
 		return
 	}
+	params.Geo = geo
+	nearestFirst(c, &params)
 
 	page, err := h.receipts.ListReceiptsByUser(c.Request.Context(), userID.(string), params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list receipts"})
 		return
 	}
+	if !h.setDisplayPrices(c, page.Data, currency) {
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SearchReceipts handles GET /api/v1/receipts/search
+// Full-text search over the authenticated user's receipts by product name,
+// store name and string-typed extras. Results are ranked best match first and
+// paginated with offset and limit. Accepts the same geographic filters as
+// ListReceipts; sort_by=distance (with a center point) is the only sort option.
+func (h *ReceiptHandler) SearchReceipts(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	query, err := model.ParseSearchQuery(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	geo, err := parseGeoFilter(c)
+	if err != nil {
+		return
+	}
+	offset, err := parseOffset(c)
+	if err != nil {
+		return
+	}
+	limit, err := parseLimit(c)
+	if err != nil {
+		return
+	}
+	params := model.PaginationParams{Offset: offset, Limit: limit, Geo: geo}
+	if c.Query("sort_by") != "" {
+		if geo == nil || geo.Center == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sort_by: search results are ranked by relevance unless lat and lng are given"})
+			return
+		}
+		if params.SortBy, params.SortOrder, err = parseSort(c, "", "", searchSortFields); err != nil {
+			return
+		}
+		nearestFirst(c, &params)
+	}
+
+	page, err := h.receipts.SearchReceipts(c.Request.Context(), userID.(string), query, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search receipts"})
+		return
+	}
 
 	c.JSON(http.StatusOK, page)
 }
 
 // DeleteReceipt handles DELETE /api/v1/receipts/:id
-// Deletes a receipt by ID.
+// Deletes a receipt by ID. Honours If-Match: a stale version is rejected with 412.
 func (h *ReceiptHandler) DeleteReceipt(c *gin.Context) {
 	id := c.Param("id")
 
+	expectedVersion, ok := parseIfMatch(c)
+	if !ok {
+		return
+	}
+
 	receipt, err := h.receipts.GetReceiptByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up receipt"})
@@ -118,10 +271,54 @@ func (h *ReceiptHandler) DeleteReceipt(c *gin.Context) {
 		return
 	}
 
-	if err := h.receipts.DeleteReceipt(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete receipt"})
+	if err := h.receipts.DeleteReceipt(c.Request.Context(), id, expectedVersion); err != nil {
+		switch {
+		case errors.Is(err, model.ErrReceiptNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "receipt not found"})
+		case errors.Is(err, model.ErrVersionMismatch):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": "receipt was modified by someone else; reload and retry"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete receipt"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "receipt deleted"})
 }
+
+// setDisplayPrices converts the price of each receipt into currency, if
+// given, at the rate in effect on its purchase date. Receipts that cannot be
+// converted are left without a display price. On error it writes the
+// response and returns false.
+func (h *ReceiptHandler) setDisplayPrices(c *gin.Context, receipts []*model.Receipt, currency string) bool {
+	if currency == "" {
+		return true
+	}
+	table, err := h.rates.RateTable(c.Request.Context(), currency)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load exchange rates"})
+		return false
+	}
+	for _, receipt := range receipts {
+		if converted, ok := table.ConvertPrice(receipt.Price, receipt.PurchaseDate); ok {
+			receipt.DisplayPrice = &converted
+		}
+	}
+	return true
+}
+
+// normalizeReceiptBarcode validates the optional barcode of a receipt and
+// rewrites it in normalized form, so receipts and products compare equal.
+// On error it writes a 400 response and returns false.
+func normalizeReceiptBarcode(c *gin.Context, receipt *model.Receipt) bool {
+	if receipt.Barcode == "" {
+		return true
+	}
+	barcode, err := model.NormalizeBarcode(receipt.Barcode)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	receipt.Barcode = barcode
+	return true
+}
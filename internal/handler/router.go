@@ -1,60 +1,166 @@
 package handler
 
 import (
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/gatheryourdeals/data/internal/auth"
 	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/repository"
 )
 
 // NewRouter creates a gin router with all routes registered.
 // The logWriter is used for Gin's own request logging so it goes to
 // the same destination as application logs (stdout + rotating file).
+// Write requests on authenticated routes honour the Idempotency-Key header,
+// with stored responses replayable for idempotencyTTL, except on routes whose
+// responses carry credentials. The client IP, used for
+// login lockouts and sessions, is read from X-Forwarded-For only on requests
+// from trustedProxies; with none, the connection's address is always used.
 func NewRouter(
 	authHandler *AuthHandler,
 	userHandler *UserHandler,
 	metaHandler *MetaHandler,
 	receiptHandler *ReceiptHandler,
+	storeHandler *StoreHandler,
+	productHandler *ProductHandler,
+	categoryHandler *CategoryHandler,
+	priceHandler *PriceHandler,
+	analyticsHandler *AnalyticsHandler,
+	watchHandler *WatchHandler,
+	webhookHandler *WebhookHandler,
+	eventHandler *EventHandler,
+	exchangeRateHandler *ExchangeRateHandler,
 	tokens *auth.TokenService,
+	idempotency repository.IdempotencyRepository,
+	idempotencyTTL time.Duration,
+	trustedProxies []string,
 	logWriter io.Writer,
-) *gin.Engine {
+) (*gin.Engine, error) {
 	if logWriter != nil {
 		gin.DefaultWriter = logWriter
 	}
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, fmt.Errorf("set trusted proxies: %w", err)
+	}
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
 	v1 := r.Group("/api/v1")
 
 	// Public endpoints
 	v1.POST("/users", authHandler.Register)
 	v1.POST("/auth/login", authHandler.Login)
+	v1.POST("/auth/login/2fa", authHandler.LoginTwoFactor)
 	v1.POST("/auth/refresh", authHandler.Refresh)
 
 	// Authenticated endpoints — role checks happen inside each handler
-	protected := v1.Group("")
-	protected.Use(middleware.Auth(tokens))
+	authenticated := v1.Group("")
+	authenticated.Use(middleware.Auth(tokens))
+	{
+		// Responses carrying credentials (tokens, TOTP secrets, recovery,
+		// invite and webhook signing codes) ignore Idempotency-Key, so they
+		// are never stored.
+		authenticated.PUT("/auth/password", authHandler.ChangePassword)
+		authenticated.DELETE("/auth/sessions", authHandler.RevokeOtherSessions)
+		authenticated.DELETE("/auth/sessions/:id", authHandler.RevokeSession)
+		authenticated.POST("/auth/2fa", authHandler.EnrollTwoFactor)
+		authenticated.POST("/auth/2fa/confirm", authHandler.ConfirmTwoFactor)
+		authenticated.POST("/invites", authHandler.CreateInvite)
+		authenticated.POST("/webhooks", webhookHandler.CreateWebhook)
+	}
+
+	protected := authenticated.Group("")
+	protected.Use(middleware.Idempotency(idempotency, idempotencyTTL))
 	{
 		// Auth
 		protected.POST("/auth/logout", authHandler.Logout)
 		protected.GET("/auth/me", authHandler.Me)
+		protected.PUT("/auth/me", authHandler.UpdateProfile)
+		protected.DELETE("/auth/me", authHandler.DeleteAccount)
+		protected.GET("/auth/sessions", authHandler.ListSessions)
+		protected.GET("/auth/2fa", authHandler.TwoFactorStatus)
+		protected.DELETE("/auth/2fa", authHandler.DisableTwoFactor)
 
 		// Users (admin-only checks inside handler)
 		protected.GET("/users", userHandler.ListUsers)
 		protected.DELETE("/users/:id", userHandler.DeleteUser)
+		protected.PUT("/users/:id/role", userHandler.UpdateRole)
+		protected.DELETE("/users/:id/sessions", authHandler.RevokeUserSessions)
+		protected.DELETE("/users/:id/lockout", authHandler.UnlockUser)
+
+		// Invites for invite-only registration (admin check inside handler)
+		protected.GET("/invites", authHandler.ListInvites)
+		protected.DELETE("/invites/:id", authHandler.RevokeInvite)
 
 		// Meta (update description has admin check inside handler)
 		protected.GET("/meta", metaHandler.ListFields)
+		protected.GET("/meta/:fieldName", metaHandler.GetField)
 		protected.POST("/meta", metaHandler.CreateField)
 		protected.PUT("/meta/:fieldName", metaHandler.UpdateDescription)
 
 		// Receipts
 		protected.POST("/receipts", receiptHandler.CreateReceipt)
 		protected.GET("/receipts", receiptHandler.ListReceipts)
+		protected.GET("/receipts/search", receiptHandler.SearchReceipts)
+		protected.GET("/receipts/stream", eventHandler.StreamReceiptEvents)
 		protected.GET("/receipts/:id", receiptHandler.GetReceipt)
+		protected.PUT("/receipts/:id", receiptHandler.UpdateReceipt)
 		protected.DELETE("/receipts/:id", receiptHandler.DeleteReceipt)
+
+		// Stores (writes have admin check inside handler)
+		protected.GET("/stores", storeHandler.ListStores)
+		protected.GET("/stores/:id", storeHandler.GetStore)
+		protected.POST("/stores", storeHandler.CreateStore)
+		protected.PUT("/stores/:id", storeHandler.UpdateStore)
+		protected.DELETE("/stores/:id", storeHandler.DeleteStore)
+
+		// Product catalog (writes have admin check inside handler)
+		protected.GET("/products", productHandler.ListProducts)
+		protected.GET("/products/:id", productHandler.GetProduct)
+		protected.POST("/products", productHandler.CreateProduct)
+		protected.PUT("/products/:id", productHandler.UpdateProduct)
+		protected.DELETE("/products/:id", productHandler.DeleteProduct)
+		protected.GET("/categories", categoryHandler.ListCategories)
+		protected.GET("/categories/:id", categoryHandler.GetCategory)
+		protected.POST("/categories", categoryHandler.CreateCategory)
+		protected.PUT("/categories/:id", categoryHandler.UpdateCategory)
+		protected.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+
+		// Prices
+		protected.GET("/prices/history", priceHandler.PriceHistory)
+		protected.GET("/prices/deals", priceHandler.Deals)
+
+		// Analytics over the caller's own receipts
+		protected.GET("/analytics/spending", analyticsHandler.Spending)
+
+		// Price watches and the caller's alert inbox
+		protected.GET("/watches", watchHandler.ListWatches)
+		protected.POST("/watches", watchHandler.CreateWatch)
+		protected.DELETE("/watches/:id", watchHandler.DeleteWatch)
+		protected.GET("/alerts", watchHandler.ListAlerts)
+		protected.POST("/alerts/:id/read", watchHandler.MarkAlertRead)
+
+		// Change feed (admin check inside handler)
+		protected.GET("/changes", eventHandler.ListChanges)
+
+		// Outgoing webhook subscriptions (admin check inside handler)
+		protected.GET("/webhooks", webhookHandler.ListWebhooks)
+		protected.GET("/webhooks/:id", webhookHandler.GetWebhook)
+		protected.PUT("/webhooks/:id", webhookHandler.UpdateWebhook)
+		protected.DELETE("/webhooks/:id", webhookHandler.DeleteWebhook)
+		protected.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		protected.POST("/webhooks/:id/deliveries/:deliveryId/retry", webhookHandler.RetryDelivery)
+
+		// Exchange rates for display-currency conversion (admin check inside handler)
+		protected.GET("/exchange-rates", exchangeRateHandler.ListRates)
+		protected.POST("/exchange-rates", exchangeRateHandler.PutRate)
+		protected.POST("/exchange-rates/import", exchangeRateHandler.ImportRates)
+		protected.DELETE("/exchange-rates/:base/:quote/:date", exchangeRateHandler.DeleteRate)
 	}
 
-	return r
+	return r, nil
 }
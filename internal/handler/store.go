@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// StoreHandler handles HTTP requests for store registry endpoints.
+type StoreHandler struct {
+	stores repository.StoreRepository
+}
+
+// NewStoreHandler creates a new store handler.
+func NewStoreHandler(stores repository.StoreRepository) *StoreHandler {
+	return &StoreHandler{stores: stores}
+}
+
+type storeRequest struct {
+	Name      string   `json:"name" binding:"required"`
+	Chain     string   `json:"chain"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	Aliases   []string `json:"aliases"`
+}
+
+// ListStores handles GET /api/v1/stores
+// Returns a paginated list of stores, alphabetical by name by default.
+func (h *StoreHandler) ListStores(c *gin.Context) {
+	params, err := parsePaginationParams(c, "name", "ASC", storeSortFields)
+	if err != nil {
+		return
+	}
+
+	page, err := h.stores.ListStores(c.Request.Context(), params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list stores"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetStore handles GET /api/v1/stores/:id
+func (h *StoreHandler) GetStore(c *gin.Context) {
+	store, err := h.stores.GetStore(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get store"})
+		return
+	}
+	if store == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "store not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, store)
+}
+
+// CreateStore handles POST /api/v1/stores — admin only.
+// Registers a store; receipts whose store name matches its name or an alias
+// are linked to it.
+func (h *StoreHandler) CreateStore(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	store, ok := bindStore(c)
+	if !ok {
+		return
+	}
+	store.ID = uuid.New().String()
+
+	if err := h.stores.CreateStore(c.Request.Context(), store); err != nil {
+		if errors.Is(err, model.ErrStoreAliasTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create store"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, store)
+}
+
+// UpdateStore handles PUT /api/v1/stores/:id — admin only.
+// Replaces the store's details and alias list.
+func (h *StoreHandler) UpdateStore(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	store, ok := bindStore(c)
+	if !ok {
+		return
+	}
+	store.ID = c.Param("id")
+
+	if err := h.stores.UpdateStore(c.Request.Context(), store); err != nil {
+		if errors.Is(err, model.ErrStoreNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "store not found"})
+			return
+		}
+		if errors.Is(err, model.ErrStoreAliasTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update store"})
+		return
+	}
+
+	c.JSON(http.StatusOK, store)
+}
+
+// DeleteStore handles DELETE /api/v1/stores/:id — admin only.
+// Linked receipts are unlinked but keep their store name.
+func (h *StoreHandler) DeleteStore(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	if err := h.stores.DeleteStore(c.Request.Context(), c.Param("id")); err != nil {
+		if errors.Is(err, model.ErrStoreNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "store not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete store"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "store deleted"})
+}
+
+// bindStore parses and validates a store request body.
+// On error it writes a 400 response and returns false.
+func bindStore(c *gin.Context) (*model.Store, bool) {
+	var req storeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if model.NormalizeStoreName(name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must contain letters or digits"})
+		return nil, false
+	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude must be given together"})
+		return nil, false
+	}
+	if req.Latitude != nil && (!validLatitude(*req.Latitude) || !validLongitude(*req.Longitude)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude must be in [-90, 90] and longitude in [-180, 180]"})
+		return nil, false
+	}
+
+	aliases := []string{}
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		if model.NormalizeStoreName(alias) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "aliases must contain letters or digits"})
+			return nil, false
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return &model.Store{
+		Name:      name,
+		Chain:     strings.TrimSpace(req.Chain),
+		Address:   strings.TrimSpace(req.Address),
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Aliases:   aliases,
+	}, true
+}
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gatheryourdeals/data/internal/auth"
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type twoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // app code or recovery code
+	Device         string `json:"device"`
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginTwoFactor handles POST /api/v1/auth/login/2fa
+// Completes a login with the two-factor token Login returned and a code
+// from the user's authenticator app, or one of their recovery codes.
+// Wrong codes count as failed logins.
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req twoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx := c.Request.Context()
+	userID, err := h.tokens.ValidateTwoFactorChallenge(req.TwoFactorToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired two-factor token"})
+		return
+	}
+	user, err := h.service.GetUserByID(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired two-factor token"})
+		return
+	}
+	if !h.checkLockout(c, user.Username) {
+		return
+	}
+
+	err = h.twoFactor.Verify(ctx, user.ID, req.Code)
+	if errors.Is(err, auth.ErrInvalidTwoFactorCode) || errors.Is(err, auth.ErrTwoFactorNotEnrolled) {
+		h.recordFailure(c, user.Username)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid two-factor code"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "login failed"})
+		return
+	}
+	if err := h.guard.Succeed(ctx, user.Username); err != nil {
+		slog.Warn("failed to clear failed logins", "error", err)
+	}
+
+	access, refresh, err := h.tokens.IssueTwoFactorTokenPair(ctx, user, clientInfo(c, req.Device))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"token_type":    "Bearer",
+	})
+}
+
+// TwoFactorStatus handles GET /api/v1/auth/2fa
+// Returns whether the caller has two-factor authentication enabled, and how
+// many recovery codes they have left.
+func (h *AuthHandler) TwoFactorStatus(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	status, err := h.twoFactor.Status(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get two-factor status"})
+		return
+	}
+	if status == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// EnrollTwoFactor handles POST /api/v1/auth/2fa
+// Starts enrollment with a new TOTP secret, returned with its otpauth://
+// provisioning URI for the client to show as a QR code. Enrollment takes
+// effect once ConfirmTwoFactor accepts a code.
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	user, err := h.service.GetUserByID(c.Request.Context(), userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return
+	}
+	enrollment, err := h.twoFactor.Enroll(c.Request.Context(), user)
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTwoFactor handles POST /api/v1/auth/2fa/confirm
+// Enables two-factor authentication with a code from the newly enrolled app
+// and returns the caller's recovery codes, which are not shown again.
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.twoFactor.Confirm(c.Request.Context(), userID.(string), req.Code)
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorNotEnrolled), errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// DisableTwoFactor handles DELETE /api/v1/auth/2fa
+// Turns two-factor authentication off after checking a code, and logs the
+// caller out of every session. Wrong codes count as failed logins. Admins
+// cannot turn it off while the server requires it for them.
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := h.service.GetUserByID(ctx, userID.(string))
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up user"})
+		return
+	}
+	if h.tokens.TwoFactorRequired(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is required for admins"})
+		return
+	}
+	if !h.checkLockout(c, user.Username) {
+		return
+	}
+
+	err = h.twoFactor.Disable(ctx, user.ID, req.Code)
+	switch {
+	case errors.Is(err, auth.ErrTwoFactorNotEnrolled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		h.recordFailure(c, user.Username)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+
+	// Sessions started with the second factor must not outlive it.
+	if err := h.tokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/gatheryourdeals/data/internal/auth"
 	"github.com/gatheryourdeals/data/internal/middleware"
 	"github.com/gatheryourdeals/data/internal/model"
 	"github.com/gatheryourdeals/data/internal/repository"
@@ -12,21 +14,42 @@ import (
 
 // UserHandler handles HTTP requests for user management endpoints.
 type UserHandler struct {
-	users repository.UserRepository
+	users  repository.UserRepository
+	tokens *auth.TokenService
 }
 
-// NewUserHandler creates a new user handler.
-func NewUserHandler(users repository.UserRepository) *UserHandler {
-	return &UserHandler{users: users}
+// NewUserHandler creates a new user handler. tokens revokes the tokens of
+// deleted users and of users whose role changes.
+func NewUserHandler(users repository.UserRepository, tokens *auth.TokenService) *UserHandler {
+	return &UserHandler{users: users, tokens: tokens}
+}
+
+type updateRoleRequest struct {
+	Role model.Role `json:"role" binding:"required"`
 }
 
 // ListUsers handles GET /api/v1/users — admin only.
 // Returns a paginated list of all registered users.
+// Offset pagination by default; cursor pagination when ?cursor= is given.
 func (h *UserHandler) ListUsers(c *gin.Context) {
 	if !requireAdmin(c) {
 		return
 	}
 
+	if isCursorRequest(c) {
+		params, err := parseCursorParams(c, "created_at", "", userSortFields)
+		if err != nil {
+			return
+		}
+		page, err := h.users.ListUsersCursor(c.Request.Context(), params)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+			return
+		}
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
 	params, err := parsePaginationParams(c, "created_at", "", userSortFields)
 	if err != nil {
 		return
@@ -63,10 +86,56 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
 		return
 	}
+	// The user's refresh tokens went with the row; this makes their access
+	// tokens fail at once instead of when the cached token version expires.
+	if err := h.tokens.RevokeAllForUser(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
 }
 
+// UpdateRole handles PUT /api/v1/users/:id/role — admin only.
+// Promotes a user to admin or demotes an admin to user. The user's access
+// tokens are revoked, so the next request must refresh and picks up the new
+// role. Admins cannot change their own role, so at least one admin remains.
+func (h *UserHandler) UpdateRole(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	var req updateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Role != model.RoleAdmin && req.Role != model.RoleUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin or user"})
+		return
+	}
+	userID := c.Param("id")
+	if userID == c.GetString(middleware.ContextKeyUserID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot change your own role"})
+		return
+	}
+
+	if err := h.users.UpdateRole(c.Request.Context(), userID, req.Role); err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		return
+	}
+	if err := h.tokens.RevokeAccessTokens(c.Request.Context(), userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": userID, "role": req.Role})
+}
+
 // requireAdmin checks if the current user has admin role.
 // Returns false and sends a 403 response if not.
 func requireAdmin(c *gin.Context) bool {
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// WatchHandler handles HTTP requests for the caller's price watches and the
+// alerts they trigger.
+type WatchHandler struct {
+	watches  repository.WatchRepository
+	products repository.ProductRepository
+}
+
+// NewWatchHandler creates a new watch handler.
+func NewWatchHandler(watches repository.WatchRepository, products repository.ProductRepository) *WatchHandler {
+	return &WatchHandler{watches: watches, products: products}
+}
+
+type watchRequest struct {
+	ProductID   string  `json:"productId"`
+	ProductName string  `json:"productName"`
+	MaxPrice    float64 `json:"maxPrice"`
+	Currency    string  `json:"currency"`
+	Unit        string  `json:"unit"`
+}
+
+// CreateWatch handles POST /api/v1/watches
+// Registers a watch on a product (productId, or productName resolved
+// through the catalog as for price history) that raises an alert whenever a
+// receipt is added at or below maxPrice per unit (default "each") in currency.
+func (h *WatchHandler) CreateWatch(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	watch, ok := h.bindWatch(c)
+	if !ok {
+		return
+	}
+	watch.ID = uuid.New().String()
+	watch.UserID = userID.(string)
+
+	if err := h.watches.CreateWatch(c.Request.Context(), watch); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create watch"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, watch)
+}
+
+// ListWatches handles GET /api/v1/watches
+// Returns all of the caller's watches, newest first.
+func (h *WatchHandler) ListWatches(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	watches, err := h.watches.ListWatches(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list watches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": watches})
+}
+
+// DeleteWatch handles DELETE /api/v1/watches/:id
+// Removes one of the caller's watches together with its alerts.
+func (h *WatchHandler) DeleteWatch(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	if err := h.watches.DeleteWatch(c.Request.Context(), userID.(string), c.Param("id")); err != nil {
+		if errors.Is(err, model.ErrWatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "watch not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete watch"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "watch deleted"})
+}
+
+// ListAlerts handles GET /api/v1/alerts
+// Returns a paginated inbox of the caller's alerts, newest first.
+// ?unread=true leaves out alerts already marked read.
+func (h *WatchHandler) ListAlerts(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	unreadOnly := false
+	if raw := c.Query("unread"); raw != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unread must be true or false"})
+			return
+		}
+	}
+	params, err := parsePaginationParams(c, "id", defaultSortOrder, alertSortFields)
+	if err != nil {
+		return
+	}
+
+	page, err := h.watches.ListAlerts(c.Request.Context(), userID.(string), unreadOnly, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// MarkAlertRead handles POST /api/v1/alerts/:id/read
+func (h *WatchHandler) MarkAlertRead(c *gin.Context) {
+	userID, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		return
+	}
+	if err := h.watches.MarkAlertRead(c.Request.Context(), userID.(string), id); err != nil {
+		if errors.Is(err, model.ErrAlertNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark alert read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "alert marked read"})
+}
+
+// bindWatch parses and validates a watch request body, resolving the product
+// it names. On error it writes the response and returns false.
+func (h *WatchHandler) bindWatch(c *gin.Context) (*model.Watch, bool) {
+	var req watchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	req.ProductName = strings.TrimSpace(req.ProductName)
+	if (req.ProductID == "") == (req.ProductName == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exactly one of productId or productName is required"})
+		return nil, false
+	}
+	if req.MaxPrice <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxPrice must be greater than 0"})
+		return nil, false
+	}
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 || strings.IndexFunc(currency, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "currency must be a 3-letter ISO 4217 code"})
+		return nil, false
+	}
+	unit := strings.ToLower(strings.TrimSpace(req.Unit))
+	if unit == "" {
+		unit = model.UnitEach
+	}
+
+	watch := &model.Watch{MaxPrice: req.MaxPrice, Currency: currency, Unit: unit}
+	ctx := c.Request.Context()
+	if req.ProductID != "" {
+		product, err := h.products.GetProduct(ctx, req.ProductID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get product"})
+			return nil, false
+		}
+		if product == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "product not found"})
+			return nil, false
+		}
+		watch.ProductID, watch.ProductName = product.ID, product.Name
+		return watch, true
+	}
+
+	product, err := h.products.ResolveProduct(ctx, req.ProductName, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve product"})
+		return nil, false
+	}
+	if product != nil {
+		watch.ProductID, watch.ProductName = product.ID, product.Name
+	} else {
+		watch.ProductName = req.ProductName
+	}
+	return watch, true
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// WebhookHandler handles HTTP requests for managing outgoing webhook
+// subscriptions and inspecting their delivery log. All endpoints are admin-only.
+type WebhookHandler struct {
+	webhooks repository.WebhookRepository
+}
+
+// NewWebhookHandler creates a new webhook handler.
+func NewWebhookHandler(webhooks repository.WebhookRepository) *WebhookHandler {
+	return &WebhookHandler{webhooks: webhooks}
+}
+
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+	Secret string   `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// CreateWebhook handles POST /api/v1/webhooks
+// Subscribes a URL to the events matching its filters. If no secret is
+// given one is generated; the response is the only place it is shown.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+	sub := &model.WebhookSubscription{
+		ID:     uuid.New().String(),
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: req.Secret,
+		Active: req.Active == nil || *req.Active,
+	}
+	if sub.Secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+			return
+		}
+		sub.Secret = hex.EncodeToString(buf)
+	}
+
+	if err := h.webhooks.CreateSubscription(c.Request.Context(), sub); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+// ListWebhooks handles GET /api/v1/webhooks
+// Returns all subscriptions, oldest first, without their secrets.
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	subs, err := h.webhooks.ListSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list webhooks"})
+		return
+	}
+	for _, sub := range subs {
+		sub.Secret = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": subs})
+}
+
+// GetWebhook handles GET /api/v1/webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	sub, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	sub.Secret = ""
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// UpdateWebhook handles PUT /api/v1/webhooks/:id
+// Replaces the URL and event filters. active is left unchanged when
+// omitted, and the secret cannot be changed.
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	sub, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	req, ok := bindWebhook(c)
+	if !ok {
+		return
+	}
+	sub.URL, sub.Events = req.URL, req.Events
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+
+	if err := h.webhooks.UpdateSubscription(c.Request.Context(), sub); err != nil {
+		if errors.Is(err, model.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update webhook"})
+		return
+	}
+	sub.Secret = ""
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/:id
+// Removes a subscription together with its delivery log.
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	if err := h.webhooks.DeleteSubscription(c.Request.Context(), c.Param("id")); err != nil {
+		if errors.Is(err, model.ErrWebhookNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// ListDeliveries handles GET /api/v1/webhooks/:id/deliveries
+// Returns a paginated delivery log for a subscription, newest first.
+// ?status=pending|delivered|dead narrows it to one state.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	status := c.Query("status")
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, delivered or dead"})
+		return
+	}
+	params, err := parsePaginationParams(c, "id", defaultSortOrder, deliverySortFields)
+	if err != nil {
+		return
+	}
+	sub, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	page, err := h.webhooks.ListDeliveries(c.Request.Context(), sub.ID, status, params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// RetryDelivery handles POST /api/v1/webhooks/:id/deliveries/:deliveryId/retry
+// Puts a dead-lettered delivery back in the queue with a fresh set of attempts.
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	id, err := strconv.ParseInt(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "dead-lettered delivery not found"})
+		return
+	}
+	if err := h.webhooks.RetryDelivery(c.Request.Context(), c.Param("id"), id, time.Now().Unix()); err != nil {
+		if errors.Is(err, model.ErrDeliveryNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dead-lettered delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retry delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "delivery queued for retry"})
+}
+
+// loadWebhook fetches the subscription named by the :id path parameter.
+// On error it writes the response and returns false.
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (*model.WebhookSubscription, bool) {
+	sub, err := h.webhooks.GetSubscription(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get webhook"})
+		return nil, false
+	}
+	if sub == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil, false
+	}
+	return sub, true
+}
+
+// bindWebhook parses and validates a webhook request body. On error it
+// writes the response and returns false.
+func bindWebhook(c *gin.Context) (*webhookRequest, bool) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	req.URL = strings.TrimSpace(req.URL)
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL"})
+		return nil, false
+	}
+	if len(req.Events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events must list at least one event type"})
+		return nil, false
+	}
+	for _, filter := range req.Events {
+		if !model.ValidEventFilter(filter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type: " + filter})
+			return nil, false
+		}
+	}
+	return &req, true
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -10,13 +11,17 @@ import (
 )
 
 const (
-	ContextKeyUserID = "userID"
-	ContextKeyRole   = "userRole"
+	ContextKeyUserID    = "userID"
+	ContextKeyRole      = "userRole"
+	ContextKeySessionID = "sessionID"
 )
 
 // Auth validates the Bearer access token using the TokenService.
-// On success it sets userID and userRole in the gin context.
-// No DB call needed — the role is embedded in the JWT claims.
+// On success it sets userID, userRole and sessionID in the gin context. The
+// session ID is empty for tokens issued before sessions were recorded.
+// The role is embedded in the JWT claims; the only lookup is the user's
+// token version, cached briefly, which rejects tokens of deleted users and
+// tokens revoked by a role change, password reset or forced logout.
 func Auth(tokens *auth.TokenService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		header := c.GetHeader("Authorization")
@@ -30,14 +35,23 @@ func Auth(tokens *auth.TokenService) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := tokens.ValidateAccessToken(parts[1])
-		if err != nil {
+		claims, err := tokens.Authenticate(c.Request.Context(), parts[1])
+		if errors.Is(err, auth.ErrTokenRevoked) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return
+		}
+		if errors.Is(err, auth.ErrInvalidToken) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
 			return
 		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+			return
+		}
 
 		c.Set(ContextKeyUserID, claims.UserID)
 		c.Set(ContextKeyRole, claims.Role)
+		c.Set(ContextKeySessionID, claims.SessionID)
 		c.Next()
 	}
 }
@@ -25,10 +25,12 @@ func newTokenService(t *testing.T) (*auth.TokenService, *sqlite.UserRepo) {
 	userRepo := sqlite.NewUserRepo(db)
 	refreshStore := sqlite.NewRefreshTokenStore(db)
 	tokens := auth.NewTokenService(
-		[]byte("test-secret-that-is-long-enough-32c"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("test-secret-that-is-long-enough-32c")}),
 		time.Hour,
 		7*24*time.Hour,
 		refreshStore,
+		userRepo,
+		false,
 	)
 	return tokens, userRepo
 }
@@ -46,7 +48,7 @@ func issueToken(t *testing.T, tokens *auth.TokenService, userRepo *sqlite.UserRe
 	if err != nil {
 		t.Fatalf("failed to create user: %v", err)
 	}
-	access, _, err := tokens.IssueTokenPair(t.Context(), user)
+	access, _, err := tokens.IssueTokenPair(t.Context(), user, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("failed to issue token: %v", err)
 	}
@@ -175,10 +177,12 @@ func TestAuth_TamperedClaims(t *testing.T) {
 	attackerDB := testutil.NewTestDB(t)
 	attackerRepo := sqlite.NewUserRepo(attackerDB)
 	attackerTokens := auth.NewTokenService(
-		[]byte("attacker-secret-long-enough-here!!"),
+		auth.NewKeyRing(auth.SigningKey{ID: "test", Secret: []byte("attacker-secret-long-enough-here!!")}),
 		time.Hour,
 		7*24*time.Hour,
 		sqlite.NewRefreshTokenStore(attackerDB),
+		attackerRepo,
+		false,
 	)
 	attackerSvc := auth.NewService(attackerRepo)
 	attackerUser, err := attackerSvc.Register(context.Background(), "attacker", "password123")
@@ -187,7 +191,7 @@ func TestAuth_TamperedClaims(t *testing.T) {
 	}
 	// Manually set role to admin to simulate a privilege escalation attempt
 	attackerUser.Role = model.RoleAdmin
-	attackerToken, _, err := attackerTokens.IssueTokenPair(context.Background(), attackerUser)
+	attackerToken, _, err := attackerTokens.IssueTokenPair(context.Background(), attackerUser, model.ClientInfo{})
 	if err != nil {
 		t.Fatalf("failed to issue attacker token: %v", err)
 	}
@@ -229,3 +233,29 @@ func TestRequireAdmin_NoRole(t *testing.T) {
 		t.Errorf("expected 500, got %d", w.Code)
 	}
 }
+
+func TestAuth_RevokedToken(t *testing.T) {
+	tokens, userRepo := newTokenService(t)
+	token := issueToken(t, tokens, userRepo, model.RoleUser)
+	user, err := userRepo.GetUserByUsername(t.Context(), "testuser")
+	if err != nil || user == nil {
+		t.Fatalf("GetUserByUsername: %v", err)
+	}
+	if err := tokens.RevokeAccessTokens(t.Context(), user.ID); err != nil {
+		t.Fatalf("RevokeAccessTokens: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/test", middleware.Auth(tokens), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
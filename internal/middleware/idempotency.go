@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+const (
+	// HeaderIdempotencyKey is the request header clients use to make a write retry-safe.
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed is set on responses that were replayed from storage.
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentBodyBytes bounds the request body read into memory to be
+	// fingerprinted. It matches the largest body a protected route accepts,
+	// the exchange rate CSV import.
+	maxIdempotentBodyBytes = 8 << 20
+)
+
+// Idempotency makes POST, PUT and DELETE requests carrying an Idempotency-Key
+// header safe to retry. The first request with a given key is executed and its
+// response stored for ttl; a retry with the same key and body gets the stored
+// response back, while reusing the key for a different request is rejected.
+// Keys are scoped to the authenticated user, so this must run after Auth.
+// Server errors (5xx) are not stored, letting the client retry with the same key.
+// The response is stored, or the key released, even if the client disconnects
+// or the handler panics, so a retry never finds the key stuck in progress.
+func Idempotency(store repository.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" || !isIdempotentMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("request body must be at most %d bytes", maxIdempotentBodyBytes)})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := c.GetString(ContextKeyUserID)
+		ctx := c.Request.Context()
+		now := time.Now()
+		record := &model.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: hashRequest(c.Request.Method, c.Request.URL.Path, c.GetHeader("If-Match"), body),
+			CreatedAt:   now.Unix(),
+			ExpiresAt:   now.Add(ttl).Unix(),
+		}
+
+		reserved, err := store.Reserve(ctx, record)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+			return
+		}
+		if !reserved {
+			replayStored(c, store, record)
+			return
+		}
+
+		// The request context is cancelled when the client goes away, but the
+		// outcome must still be recorded.
+		ctx = context.WithoutCancel(ctx)
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// The handler panicked; release the key and let Recovery answer.
+			if err := store.Delete(ctx, userID, key); err != nil {
+				slog.Warn("failed to release idempotency key", "error", err)
+			}
+		}()
+
+		capture := &responseCapture{ResponseWriter: c.Writer}
+		c.Writer = capture
+		c.Next()
+		finished = true
+
+		if capture.Status() >= http.StatusInternalServerError {
+			if err := store.Delete(ctx, userID, key); err != nil {
+				slog.Warn("failed to release idempotency key", "error", err)
+			}
+			return
+		}
+		headers := capture.Header().Clone()
+		headers.Del("Content-Length")
+		if err := store.Complete(ctx, userID, key, capture.Status(), headers, capture.body.Bytes()); err != nil {
+			slog.Warn("failed to store idempotent response", "error", err)
+		}
+	}
+}
+
+// replayStored answers a request whose key is already taken: with the stored
+// response if it matches, or with an error explaining why it cannot be replayed.
+func replayStored(c *gin.Context, store repository.IdempotencyRepository, record *model.IdempotencyRecord) {
+	existing, err := store.Get(c.Request.Context(), record.UserID, record.Key)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check idempotency key"})
+		return
+	}
+	if existing != nil && existing.RequestHash != record.RequestHash {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key has already been used with a different request"})
+		return
+	}
+	if existing == nil || !existing.Completed() {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+		return
+	}
+	for name, values := range existing.ResponseHeaders {
+		c.Writer.Header()[name] = values
+	}
+	contentType := c.Writer.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json; charset=utf-8"
+	}
+	c.Header(HeaderIdempotentReplayed, "true")
+	c.Data(existing.StatusCode, contentType, existing.ResponseBody)
+	c.Abort()
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// hashRequest fingerprints a request so a reused key can be matched against
+// it. The If-Match precondition is part of the request: a retry with another
+// one is a different request.
+func hashRequest(method, path, ifMatch string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n" + ifMatch + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCapture tees everything the handler writes so it can be stored.
+type responseCapture struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseCapture) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseCapture) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
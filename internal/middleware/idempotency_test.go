@@ -0,0 +1,208 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/middleware"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+// newIdempotentRouter returns a router whose POST /test handler counts its
+// calls and answers with the given status.
+func newIdempotentRouter(t *testing.T, status int, calls *int) *gin.Engine {
+	t.Helper()
+	return newIdempotentRouterWith(t, func(c *gin.Context) {
+		*calls++
+		c.JSON(status, gin.H{"call": *calls})
+	})
+}
+
+// newIdempotentRouterWith returns a router serving POST /test with handler
+// behind the Idempotency middleware.
+func newIdempotentRouterWith(t *testing.T, handler gin.HandlerFunc) *gin.Engine {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	if err := sqlite.NewUserRepo(db).CreateUser(t.Context(), &model.User{
+		ID: "user-1", Username: "user-1", PasswordHash: "hash", Role: model.RoleUser,
+	}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.POST("/test",
+		func(c *gin.Context) { c.Set(middleware.ContextKeyUserID, "user-1") },
+		middleware.Idempotency(sqlite.NewIdempotencyRepo(db), time.Hour),
+		handler,
+	)
+	return r
+}
+
+func doIdempotentPost(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusCreated, &calls)
+
+	first := doIdempotentPost(r, "k", `{"a":1}`)
+	second := doIdempotentPost(r, "k", `{"a":1}`)
+
+	if calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("expected replay of %d %s, got %d %s", first.Code, first.Body.String(), second.Code, second.Body.String())
+	}
+}
+
+func TestIdempotency_DifferentBodyRejected(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusCreated, &calls)
+
+	doIdempotentPost(r, "k", `{"a":1}`)
+	w := doIdempotentPost(r, "k", `{"a":2}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_DifferentIfMatchRejected(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusCreated, &calls)
+
+	for i, etag := range []string{`"1"`, `"2"`} {
+		req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"a":1}`))
+		req.Header.Set(middleware.HeaderIdempotencyKey, "k")
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if i == 1 && w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected 422 for a retry with another If-Match, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusCreated, &calls)
+
+	w := doIdempotentPost(r, "k", strings.Repeat("x", 8<<20+1))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if calls != 0 {
+		t.Errorf("expected handler not to run, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_NoHeaderPassesThrough(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusCreated, &calls)
+
+	doIdempotentPost(r, "", `{"a":1}`)
+	doIdempotentPost(r, "", `{"a":1}`)
+
+	if calls != 2 {
+		t.Errorf("expected handler to run twice without a key, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_ServerErrorNotStored(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouter(t, http.StatusInternalServerError, &calls)
+
+	doIdempotentPost(r, "k", `{"a":1}`)
+	doIdempotentPost(r, "k", `{"a":1}`)
+
+	if calls != 2 {
+		t.Errorf("expected a 5xx response to release the key, handler ran %d times", calls)
+	}
+}
+
+func TestIdempotency_ReplaysHeaders(t *testing.T) {
+	r := newIdempotentRouterWith(t, func(c *gin.Context) {
+		c.Header("ETag", `"1"`)
+		c.Header("Location", "/api/v1/receipts/r-1")
+		c.JSON(http.StatusCreated, gin.H{"id": "r-1"})
+	})
+
+	doIdempotentPost(r, "k", `{"a":1}`)
+	w := doIdempotentPost(r, "k", `{"a":1}`)
+
+	if w.Header().Get(middleware.HeaderIdempotentReplayed) != "true" {
+		t.Fatalf("expected a replay, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") != `"1"` || w.Header().Get("Location") != "/api/v1/receipts/r-1" {
+		t.Errorf("expected the original headers replayed, got %v", w.Header())
+	}
+}
+
+func TestIdempotency_ClientDisconnectStillStored(t *testing.T) {
+	calls := 0
+	var cancel context.CancelFunc
+	r := newIdempotentRouterWith(t, func(c *gin.Context) {
+		calls++
+		// The client goes away while the handler is running.
+		cancel()
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancel = cancelFn
+	req := httptest.NewRequest(http.MethodPost, "/test", strings.NewReader(`{"a":1}`)).WithContext(ctx)
+	req.Header.Set(middleware.HeaderIdempotencyKey, "k")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := doIdempotentPost(r, "k", `{"a":1}`)
+	if w.Code != http.StatusCreated || w.Header().Get(middleware.HeaderIdempotentReplayed) != "true" {
+		t.Errorf("expected the stored response replayed, got %d %s", w.Code, w.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	calls := 0
+	r := newIdempotentRouterWith(t, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	if w := doIdempotentPost(r, "k", `{"a":1}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 from the panic, got %d", w.Code)
+	}
+	if w := doIdempotentPost(r, "k", `{"a":1}`); w.Code != http.StatusCreated {
+		t.Errorf("expected the retry to run, got %d %s", w.Code, w.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("expected handler to run twice, ran %d times", calls)
+	}
+}
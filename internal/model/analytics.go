@@ -0,0 +1,124 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Spending report groupings. GroupByField groups by the value of a
+// user-defined field whose type is groupable (see IsGroupableFieldType).
+const (
+	GroupByMonth    = "month"
+	GroupByStore    = "store"
+	GroupByCategory = "category"
+	GroupByField    = "field"
+)
+
+// groupableFieldTypes are the meta field types with a small, fixed set of
+// values, which makes them useful to group spending by.
+var groupableFieldTypes = map[string]bool{"enum": true, "bool": true, "boolean": true}
+
+// IsGroupableFieldType reports whether spending can be grouped by fields of the given type.
+func IsGroupableFieldType(fieldType string) bool {
+	return groupableFieldTypes[fieldType]
+}
+
+// SpendingQuery selects and groups a user's receipts for a spending report.
+// Field names the extras field when GroupBy is GroupByField. Zero From/To
+// leave that end of the purchase date range open. A non-empty Currency
+// converts amounts into it at purchase-date rates where a rate is known.
+type SpendingQuery struct {
+	GroupBy  string
+	Field    string
+	From     time.Time
+	To       time.Time
+	Currency string
+}
+
+// SpendTotal is the spend in one currency. Amounts in different currencies
+// are never added together.
+type SpendTotal struct {
+	Currency string  `json:"currency"` // ISO 4217 code, "" for prices without one
+	Total    float64 `json:"total"`
+	Count    int     `json:"count"` // number of receipts
+}
+
+// SpendGroup is the spend of one month, store, category or field value.
+// Key is the month (YYYY-MM), store ID, category ID or field value; it is
+// empty for receipts without a readable purchase date, store link, category
+// or field value.
+type SpendGroup struct {
+	Key    string        `json:"key"`
+	Label  string        `json:"label"`
+	Totals []*SpendTotal `json:"totals"`
+}
+
+// SpendingReport is a user's spend grouped by one dimension.
+type SpendingReport struct {
+	GroupBy string `json:"groupBy"`
+	Field   string `json:"field,omitempty"`
+	// Currency is the requested display currency. Amounts that could not be
+	// converted into it stay in their own currency.
+	Currency string        `json:"currency,omitempty"`
+	Totals   []*SpendTotal `json:"totals"`
+	Groups   []*SpendGroup `json:"groups"`
+	// Unpriced counts the receipts in the date range whose price could not
+	// be read as a number, and which are therefore not in any total.
+	Unpriced int `json:"unpriced"`
+}
+
+// SpendRow is the spend of one group in one currency on one purchase day
+// (YYYY-MM-DD, "" if unreadable), as summed in SQL before conversion.
+type SpendRow struct {
+	Key, Label, Currency, Day string
+	Total                     float64
+	Count                     int
+}
+
+// ConvertSpend converts rows into the table's currency at each day's rate
+// and sums them into overall totals and groups. Groups keep the order of
+// their first row; rows that cannot be converted keep their currency.
+func ConvertSpend(rows []SpendRow, table *RateTable) ([]*SpendTotal, []*SpendGroup) {
+	type groupKey struct{ key, label string }
+	overall := map[string]*SpendTotal{}
+	groups := []*SpendGroup{}
+	byGroup := map[groupKey]map[string]*SpendTotal{}
+	for _, row := range rows {
+		currency, total := row.Currency, row.Total
+		if day, err := time.Parse("2006-01-02", row.Day); err == nil {
+			if converted, ok := table.Convert(row.Total, row.Currency, day); ok {
+				currency, total = converted.Currency, converted.Value
+			}
+		}
+		k := groupKey{row.Key, row.Label}
+		if byGroup[k] == nil {
+			byGroup[k] = map[string]*SpendTotal{}
+			groups = append(groups, &SpendGroup{Key: row.Key, Label: row.Label})
+		}
+		for _, totals := range []map[string]*SpendTotal{overall, byGroup[k]} {
+			t := totals[currency]
+			if t == nil {
+				t = &SpendTotal{Currency: currency}
+				totals[currency] = t
+			}
+			t.Total += total
+			t.Count += row.Count
+		}
+	}
+	for _, g := range groups {
+		g.Totals = sortedSpendTotals(byGroup[groupKey{g.Key, g.Label}])
+	}
+	return sortedSpendTotals(overall), groups
+}
+
+// sortedSpendTotals rounds totals to cents and orders them by currency.
+func sortedSpendTotals(totals map[string]*SpendTotal) []*SpendTotal {
+	result := make([]*SpendTotal, 0, len(totals))
+	for _, t := range totals {
+		t.Total = math.Round(t.Total*100) / 100
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
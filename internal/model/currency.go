@@ -0,0 +1,213 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateNotFound is returned when an exchange rate does not exist.
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// ExchangeRate says that on Date one unit of Base was worth Rate units of
+// Quote. A rate applies from its date until the next rate of the same pair.
+// Date is YYYY-MM-DD.
+type ExchangeRate struct {
+	Base  string  `json:"base"`
+	Quote string  `json:"quote"`
+	Date  string  `json:"date"`
+	Rate  float64 `json:"rate"`
+}
+
+// ConvertedPrice is a receipt price converted into a display currency at the
+// rate in effect on its purchase date.
+type ConvertedPrice struct {
+	Value    float64 `json:"value"`
+	Currency string  `json:"currency"`
+	Rate     float64 `json:"rate"`     // display currency units per unit of the receipt's currency
+	RateDate string  `json:"rateDate"` // date of the rate used, YYYY-MM-DD; "" when no conversion was needed
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and reports whether it is
+// three letters.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 || strings.IndexFunc(code, func(r rune) bool { return r < 'A' || r > 'Z' }) >= 0 {
+		return code, false
+	}
+	return code, true
+}
+
+// Validate normalizes the rate's currencies and date and checks its value.
+func (r *ExchangeRate) Validate() error {
+	var ok bool
+	if r.Base, ok = NormalizeCurrency(r.Base); !ok {
+		return fmt.Errorf("base must be a 3-letter ISO 4217 code, got %q", r.Base)
+	}
+	if r.Quote, ok = NormalizeCurrency(r.Quote); !ok {
+		return fmt.Errorf("quote must be a 3-letter ISO 4217 code, got %q", r.Quote)
+	}
+	if r.Base == r.Quote {
+		return fmt.Errorf("base and quote must differ")
+	}
+	date, err := ParsePurchaseDate(r.Date)
+	if err != nil {
+		return fmt.Errorf("date: %w", err)
+	}
+	r.Date = date.Format("2006-01-02")
+	if math.IsNaN(r.Rate) || math.IsInf(r.Rate, 0) {
+		return fmt.Errorf("rate must be a finite number")
+	}
+	if r.Rate <= 0 {
+		return fmt.Errorf("rate must be greater than 0")
+	}
+	return nil
+}
+
+// ParseRatesCSV reads exchange rates from CSV with a header row naming the
+// columns date, base, quote and rate, in any order. Every row is validated;
+// the first bad row fails the whole import.
+func ParseRatesCSV(r io.Reader) ([]*ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty CSV")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"date", "base", "quote", "rate"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("header is missing column %q", name)
+		}
+	}
+
+	rates := []*ExchangeRate{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: rate must be a number", line)
+		}
+		rate := &ExchangeRate{
+			Base:  record[columns["base"]],
+			Quote: record[columns["quote"]],
+			Date:  record[columns["date"]],
+			Rate:  value,
+		}
+		if err := rate.Validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+}
+
+// ratePoint is the factor converting one currency into a RateTable's
+// target currency from a date on.
+type ratePoint struct {
+	date   string
+	factor float64
+}
+
+// RateTable converts amounts into one target currency at the rate in effect
+// on a given date: the latest rate on or before it. A rate quoted either way
+// round is used, so a USD→CAD rate also converts CAD into USD.
+type RateTable struct {
+	target string
+	points map[string][]ratePoint // by source currency, oldest first
+}
+
+// NewRateTable builds a table converting into target from the given rates.
+// Rates not involving target are ignored. If a pair is quoted both ways on
+// the same date, the rate whose quote is target wins.
+func NewRateTable(target string, rates []*ExchangeRate) *RateTable {
+	type entry struct {
+		factor float64
+		direct bool
+	}
+	byDate := map[string]map[string]entry{}
+	for _, r := range rates {
+		var currency string
+		var e entry
+		switch target {
+		case r.Quote:
+			currency, e = r.Base, entry{r.Rate, true}
+		case r.Base:
+			currency, e = r.Quote, entry{1 / r.Rate, false}
+		default:
+			continue
+		}
+		if byDate[currency] == nil {
+			byDate[currency] = map[string]entry{}
+		}
+		if old, ok := byDate[currency][r.Date]; !ok || !old.direct {
+			byDate[currency][r.Date] = e
+		}
+	}
+
+	t := &RateTable{target: target, points: map[string][]ratePoint{}}
+	for currency, dates := range byDate {
+		points := make([]ratePoint, 0, len(dates))
+		for date, e := range dates {
+			points = append(points, ratePoint{date, e.factor})
+		}
+		sort.Slice(points, func(i, j int) bool { return points[i].date < points[j].date })
+		t.points[currency] = points
+	}
+	return t
+}
+
+// Currency returns the table's target currency.
+func (t *RateTable) Currency() string {
+	return t.target
+}
+
+// Convert converts value in currency into the target currency at the rate in
+// effect on date. ok is false if the currency is unknown ("") or has no rate
+// on or before date.
+func (t *RateTable) Convert(value float64, currency string, date time.Time) (ConvertedPrice, bool) {
+	if currency == t.target {
+		return ConvertedPrice{Value: value, Currency: t.target, Rate: 1}, true
+	}
+	points := t.points[currency]
+	day := date.Format("2006-01-02")
+	i := sort.Search(len(points), func(i int) bool { return points[i].date > day })
+	if i == 0 {
+		return ConvertedPrice{}, false
+	}
+	p := points[i-1]
+	return ConvertedPrice{Value: value * p.factor, Currency: t.target, Rate: p.factor, RateDate: p.date}, true
+}
+
+// ConvertPrice converts a receipt's free-text price at its purchase date.
+// ok is false if either cannot be parsed or no rate applies.
+func (t *RateTable) ConvertPrice(price, purchaseDate string) (ConvertedPrice, bool) {
+	value, currency, ok := ParsePrice(price)
+	if !ok {
+		return ConvertedPrice{}, false
+	}
+	date, err := ParsePurchaseDate(purchaseDate)
+	if err != nil {
+		return ConvertedPrice{}, false
+	}
+	converted, ok := t.Convert(value, currency, date)
+	converted.Value = math.Round(converted.Value*100) / 100
+	return converted, ok
+}
@@ -0,0 +1,138 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Deal is the recent price of one product at one store.
+type Deal struct {
+	ProductID   string  `json:"productId,omitempty"`
+	ProductName string  `json:"productName"`
+	StoreName   string  `json:"storeName"`
+	StoreID     string  `json:"storeId,omitempty"`
+	LatestPrice float64 `json:"latestPrice"` // unit price on the most recent receipt in the window
+	LatestDate  string  `json:"latestDate"`  // YYYY-MM-DD
+	LowestPrice float64 `json:"lowestPrice"` // lowest unit price in the window
+	Count       int     `json:"count"`       // receipts in the window
+	// HistoricalMedian is the median unit price of the product across all
+	// stores and all time, and VsMedianPercent how far LatestPrice is from
+	// it: -10 means 10% below the median.
+	HistoricalMedian float64  `json:"historicalMedian"`
+	VsMedianPercent  float64  `json:"vsMedianPercent"`
+	DistanceKm       *float64 `json:"distanceKm,omitempty"` // from the query point, when one is given
+
+	latest time.Time
+}
+
+// DealGroup ranks the deals that share a currency and unit, best first.
+type DealGroup struct {
+	Currency string  `json:"currency"`
+	Unit     string  `json:"unit"`
+	Deals    []*Deal `json:"deals"`
+}
+
+// DealReport is the answer to "where is this cheapest right now?".
+type DealReport struct {
+	ProductID   string       `json:"productId,omitempty"`
+	ProductName string       `json:"productName,omitempty"`
+	CategoryID  string       `json:"categoryId,omitempty"`
+	Currency    string       `json:"currency,omitempty"` // requested display currency
+	From        string       `json:"from,omitempty"`     // start of the lookback window, YYYY-MM-DD
+	Groups      []*DealGroup `json:"groups"`
+}
+
+// BuildDeals ranks stores by the unit prices of points, the receipts within
+// the lookback window, and near, if given. history must cover the product's
+// whole history: medians are computed from it. Within a group deals are
+// ordered by latest price, lowest first, then by recency.
+func BuildDeals(points, history []PricePoint, near *GeoFilter) []*DealGroup {
+	type medianKey struct{ product, currency, unit string }
+	byKey := map[medianKey][]float64{}
+	for _, p := range history {
+		k := medianKey{pointProductKey(p), p.Currency, p.Unit}
+		byKey[k] = append(byKey[k], p.Value)
+	}
+	medians := make(map[medianKey]float64, len(byKey))
+	for k, values := range byKey {
+		sort.Float64s(values)
+		medians[k] = Median(values)
+	}
+
+	type dealKey struct{ product, store, currency, unit string }
+	deals := map[dealKey]*Deal{}
+	var order []dealKey
+	for _, p := range points {
+		if near != nil && (p.Location == nil || !near.Contains(*p.Location)) {
+			continue
+		}
+		k := dealKey{pointProductKey(p), pointStoreKey(p), p.Currency, p.Unit}
+		d := deals[k]
+		if d == nil {
+			d = &Deal{ProductID: p.ProductID, ProductName: p.ProductName, StoreName: p.StoreName, StoreID: p.StoreID, LowestPrice: p.Value}
+			deals[k] = d
+			order = append(order, k)
+		}
+		d.Count++
+		if p.Value < d.LowestPrice {
+			d.LowestPrice = p.Value
+		}
+		if d.Count == 1 || p.Date.After(d.latest) || (p.Date.Equal(d.latest) && p.Value < d.LatestPrice) {
+			d.latest, d.LatestPrice = p.Date, p.Value
+			if near != nil && near.Center != nil && p.Location != nil {
+				distance := HaversineKm(*near.Center, *p.Location)
+				d.DistanceKm = &distance
+			}
+		}
+	}
+
+	type groupKey struct{ currency, unit string }
+	groups := map[groupKey]*DealGroup{}
+	result := []*DealGroup{}
+	for _, k := range order {
+		d := deals[k]
+		d.LatestDate = d.latest.Format("2006-01-02")
+		d.HistoricalMedian = medians[medianKey{k.product, k.currency, k.unit}]
+		if d.HistoricalMedian > 0 {
+			d.VsMedianPercent = math.Round((d.LatestPrice-d.HistoricalMedian)/d.HistoricalMedian*1000) / 10
+		}
+
+		g := groups[groupKey{k.currency, k.unit}]
+		if g == nil {
+			g = &DealGroup{Currency: k.currency, Unit: k.unit}
+			groups[groupKey{k.currency, k.unit}] = g
+			result = append(result, g)
+		}
+		g.Deals = append(g.Deals, d)
+	}
+	for _, g := range result {
+		sort.SliceStable(g.Deals, func(i, j int) bool {
+			a, b := g.Deals[i], g.Deals[j]
+			if a.LatestPrice != b.LatestPrice {
+				return a.LatestPrice < b.LatestPrice
+			}
+			return a.latest.After(b.latest)
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i].Deals) > len(result[j].Deals) })
+	return result
+}
+
+// pointProductKey identifies the product of a price point: its catalog
+// product, or its normalized name when the receipt is not linked.
+func pointProductKey(p PricePoint) string {
+	if p.ProductID != "" {
+		return "id:" + p.ProductID
+	}
+	return "name:" + NormalizeProductName(p.ProductName)
+}
+
+// pointStoreKey identifies the store of a price point: its registered
+// store, or its normalized name when the receipt is not linked.
+func pointStoreKey(p PricePoint) string {
+	if p.StoreID != "" {
+		return "id:" + p.StoreID
+	}
+	return "name:" + NormalizeStoreName(p.StoreName)
+}
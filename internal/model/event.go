@@ -0,0 +1,41 @@
+package model
+
+import "errors"
+
+// ErrEventsCompacted is returned when reading the change feed from a
+// sequence number whose following events have already been compacted away.
+var ErrEventsCompacted = errors.New("events have been compacted")
+
+// Types of change events published when receipts and meta fields are written.
+const (
+	EventReceiptCreated = "receipt.created"
+	EventReceiptUpdated = "receipt.updated"
+	EventReceiptDeleted = "receipt.deleted"
+	EventMetaCreated    = "meta.created"
+	EventMetaUpdated    = "meta.updated"
+)
+
+// EventTypes lists every event type, in the order they are documented.
+var EventTypes = []string{
+	EventReceiptCreated, EventReceiptUpdated, EventReceiptDeleted,
+	EventMetaCreated, EventMetaUpdated,
+}
+
+// Event is a change to a receipt or meta field. Repositories publish events
+// in the same transaction as the write they describe, appending them to a
+// persistent event log. ID and CreatedAt are assigned by the log; IDs
+// increase in commit order within a backend.
+// Timestamps are Unix epoch seconds (UTC).
+type Event struct {
+	ID        int64       `json:"id"`
+	Type      string      `json:"type"`
+	UserID    string      `json:"userId,omitempty"` // owner of the receipt; "" for meta field events
+	Data      interface{} `json:"data"`             // the receipt or field as written, or a DeletedReceipt
+	CreatedAt int64       `json:"createdAt"`
+}
+
+// DeletedReceipt is the data of a receipt.deleted event.
+type DeletedReceipt struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+}
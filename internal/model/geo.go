@@ -0,0 +1,69 @@
+package model
+
+import "math"
+
+// EarthRadiusKm is the mean Earth radius used for haversine distances.
+const EarthRadiusKm = 6371.0088
+
+// SortByDistance is the PaginationParams.SortBy value that orders receipts by
+// distance from GeoFilter.Center instead of by a column.
+const SortByDistance = "distance"
+
+// GeoPoint is a WGS84 coordinate in decimal degrees.
+type GeoPoint struct {
+	Lat float64
+	Lng float64
+}
+
+// BoundingBox selects coordinates with South <= latitude <= North and
+// West <= longitude <= East. A box with West > East crosses the antimeridian.
+type BoundingBox struct {
+	South float64
+	West  float64
+	North float64
+	East  float64
+}
+
+// GeoFilter restricts a receipt listing to a location. Receipts without
+// coordinates never match a radius or bounding box.
+type GeoFilter struct {
+	Center   *GeoPoint    // reference point for distances; required for RadiusKm and distance sorting
+	RadiusKm float64      // > 0 keeps receipts within this many km of Center
+	Box      *BoundingBox // keeps receipts inside the box
+}
+
+// LatitudeSpan returns the latitude range that can contain points within
+// RadiusKm of Center. Repositories use it to narrow a radius query with an
+// index range scan before computing exact distances.
+func (g *GeoFilter) LatitudeSpan() (min, max float64) {
+	delta := g.RadiusKm / EarthRadiusKm * 180 / math.Pi
+	return math.Max(g.Center.Lat-delta, -90), math.Min(g.Center.Lat+delta, 90)
+}
+
+// Contains reports whether a point lies within the filter's radius and
+// bounding box.
+func (g *GeoFilter) Contains(p GeoPoint) bool {
+	if g.RadiusKm > 0 && HaversineKm(*g.Center, p) > g.RadiusKm {
+		return false
+	}
+	if box := g.Box; box != nil {
+		if p.Lat < box.South || p.Lat > box.North {
+			return false
+		}
+		if box.West <= box.East {
+			return p.Lng >= box.West && p.Lng <= box.East
+		}
+		return p.Lng >= box.West || p.Lng <= box.East
+	}
+	return true
+}
+
+// HaversineKm returns the great-circle distance between two points in km.
+func HaversineKm(a, b GeoPoint) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
@@ -0,0 +1,25 @@
+package model
+
+// IdempotencyRecord stores the outcome of a write request made with an
+// Idempotency-Key header so that a retry can be answered with the same
+// response instead of being executed twice.
+// A StatusCode of 0 means the original request is still in progress.
+type IdempotencyRecord struct {
+	UserID       string
+	Key          string
+	Method       string
+	Path         string
+	RequestHash  string
+	StatusCode   int
+	ResponseBody []byte
+	// ResponseHeaders holds the headers the handler set, such as ETag and Location.
+	ResponseHeaders map[string][]string
+	CreatedAt       int64
+	ExpiresAt       int64
+}
+
+// Completed reports whether the original request has finished and its
+// response has been stored.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -0,0 +1,24 @@
+package model
+
+import "errors"
+
+// ErrInviteNotFound is returned when an invite does not exist.
+var ErrInviteNotFound = errors.New("invite not found")
+
+// Invite lets people register while registration is invite-only. Only a
+// hash of its code is stored, so the code is shown once, when the invite is
+// created. Timestamps are Unix epoch seconds (UTC).
+type Invite struct {
+	ID        string `json:"id"`
+	Code      string `json:"code,omitempty"`      // set only on the invite returned at creation
+	CreatedBy string `json:"createdBy,omitempty"` // ID of the admin who created it; empty if created from the CLI
+	MaxUses   int    `json:"maxUses"`
+	Uses      int    `json:"uses"`
+	ExpiresAt int64  `json:"expiresAt"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// Usable reports whether the invite can still be used at the unix time now.
+func (i *Invite) Usable(now int64) bool {
+	return i.Uses < i.MaxUses && now < i.ExpiresAt
+}
@@ -0,0 +1,18 @@
+package model
+
+// LoginSubject is what failed logins are counted against.
+type LoginSubject string
+
+const (
+	LoginSubjectUsername LoginSubject = "username" // the username a login was attempted for
+	LoginSubjectIP       LoginSubject = "ip"       // the client IP address a login came from
+)
+
+// LoginAttempts counts the consecutive failed logins of one username or
+// client IP. A successful login or an admin unlock clears a username's count.
+type LoginAttempts struct {
+	Subject       LoginSubject `json:"subject"`
+	Key           string       `json:"key"` // the username or IP address
+	Failures      int          `json:"failures"`
+	LastFailureAt int64        `json:"lastFailureAt"` // unix seconds
+}
@@ -7,4 +7,5 @@ type MetaField struct {
 	Description string `json:"description"`
 	FieldType   string `json:"type"`
 	Native      bool   `json:"native"`
+	Version     int64  `json:"version"`
 }
@@ -1,13 +1,21 @@
 package model
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
 // PaginationParams carries validated pagination input from the handler layer
 // to the repository layer. All fields are set by the handler after validation;
 // the repository must not re-validate them.
 type PaginationParams struct {
-	Offset    int    // >= 0
-	Limit     int    // 1–100 (silently capped by handler)
-	SortBy    string // DB column name (mapped from API param by handler allowlist)
-	SortOrder string // "ASC" or "DESC" (normalised to uppercase by handler)
+	Offset    int        // >= 0
+	Limit     int        // 1–100 (silently capped by handler)
+	SortBy    string     // DB column name (mapped from API param by handler allowlist), or SortByDistance
+	SortOrder string     // "ASC" or "DESC" (normalised to uppercase by handler)
+	Geo       *GeoFilter // optional location filter; only receipt listings and search use it
 }
 
 // Page is a generic paginated response envelope returned by all list endpoints.
@@ -17,4 +25,74 @@ type Page[T any] struct {
 	Offset     int `json:"offset"`
 	Limit      int `json:"limit"`
 	TotalPages int `json:"total_pages"`
+	// Truncated is set when only part of the matches could be returned; Total
+	// then counts that part. Only SQLite search without FTS5 sets it.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// CursorParams carries validated keyset pagination input from the handler layer
+// to the repository layer. Rows are ordered by SortBy and then by the table's
+// unique ID so that every row has a stable position even when sort values tie.
+type CursorParams struct {
+	Limit        int     // 1–100 (silently capped by handler)
+	SortBy       string  // DB column name (allowlisted by handler, also when taken from a cursor)
+	SortOrder    string  // "ASC" or "DESC"
+	After        *Cursor // position of the last row of the previous page; nil for the first page
+	IncludeTotal bool    // run the COUNT(*) query and fill CursorPage.Total
+}
+
+// CursorPage is the response envelope for cursor-paginated list endpoints.
+// Total is only present when requested, since counting is the cost that
+// cursor mode exists to avoid.
+type CursorPage[T any] struct {
+	Data       []T    `json:"data"`
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int   `json:"total,omitempty"`
+}
+
+// Cursor identifies a position in a keyset-paginated list: the sort value and
+// ID of the last row returned, plus the ordering it was taken from. Clients
+// only ever see it in its encoded, opaque form.
+type Cursor struct {
+	SortBy    string      `json:"s"`
+	SortOrder string      `json:"o"`
+	Value     interface{} `json:"v"`
+	ID        string      `json:"id"`
+}
+
+// Encode returns the opaque, URL-safe form of the cursor.
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c) // only strings and numbers; cannot fail
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode. Integer sort values
+// come back as int64 so they compare correctly against integer columns.
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	var raw struct {
+		SortBy    string          `json:"s"`
+		SortOrder string          `json:"o"`
+		Value     json.RawMessage `json:"v"`
+		ID        string          `json:"id"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("decode cursor: %w", err)
+	}
+	c := &Cursor{SortBy: raw.SortBy, SortOrder: raw.SortOrder, ID: raw.ID}
+	if n, err := strconv.ParseInt(string(raw.Value), 10, 64); err == nil {
+		c.Value = n
+	} else {
+		var s string
+		if err := json.Unmarshal(raw.Value, &s); err != nil {
+			return nil, fmt.Errorf("decode cursor: unsupported sort value")
+		}
+		c.Value = s
+	}
+	return c, nil
 }
@@ -0,0 +1,342 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalidDate is returned when a date cannot be parsed.
+var ErrInvalidDate = errors.New("invalid date")
+
+// Units that unit prices are expressed in. Weights are converted to
+// kilograms and volumes to litres so that different package sizes compare;
+// counted items and a bare number are priced per item.
+const (
+	UnitKilogram = "kg"
+	UnitLitre    = "l"
+	UnitEach     = "each"
+)
+
+// unitFactors converts a quantity in the given unit into UnitKilogram,
+// UnitLitre or UnitEach.
+var unitFactors = map[string]struct {
+	base   string
+	factor float64
+}{
+	"mg": {UnitKilogram, 0.000001}, "g": {UnitKilogram, 0.001}, "kg": {UnitKilogram, 1},
+	"oz": {UnitKilogram, 0.028349523125}, "lb": {UnitKilogram, 0.45359237}, "lbs": {UnitKilogram, 0.45359237},
+	"ml": {UnitLitre, 0.001}, "cl": {UnitLitre, 0.01}, "dl": {UnitLitre, 0.1}, "l": {UnitLitre, 1},
+	"": {UnitEach, 1}, "x": {UnitEach, 1}, "ea": {UnitEach, 1}, "each": {UnitEach, 1},
+	"pc": {UnitEach, 1}, "pcs": {UnitEach, 1}, "pk": {UnitEach, 1}, "pack": {UnitEach, 1},
+	"ct": {UnitEach, 1}, "count": {UnitEach, 1},
+}
+
+// currencySymbols maps leading currency symbols to ISO 4217 codes, for
+// prices written as "$4.99" instead of "4.99CAD". "$" is ambiguous and
+// left without a code.
+var currencySymbols = map[rune]string{'€': "EUR", '£': "GBP", '¥': "JPY", '$': ""}
+
+// UnitPrice is a receipt price divided by its amount: the price of one
+// kilogram, one litre or one item.
+type UnitPrice struct {
+	Value    float64
+	Currency string // ISO 4217 code from the price, "" if it has none
+	Unit     string // UnitKilogram, UnitLitre, UnitEach, or an unrecognized unit as written
+}
+
+// ParseUnitPrice derives the unit price from a receipt's price ("5.49CAD",
+// "$5.49", "5.49") and amount ("1", "2lb", "2(lb)", "500 g"). ok is false if
+// either cannot be read as a number or the amount is zero.
+func ParseUnitPrice(price, amount string) (UnitPrice, bool) {
+	value, currency, ok := ParsePrice(price)
+	if !ok {
+		return UnitPrice{}, false
+	}
+	quantity, unit, ok := parseAmount(amount)
+	if !ok {
+		return UnitPrice{}, false
+	}
+	return UnitPrice{Value: value / quantity, Currency: currency, Unit: unit}, true
+}
+
+// ParsePrice splits a receipt price such as "5.49CAD" or "$5.49" into its
+// value and ISO 4217 currency code, "" if it names none.
+func ParsePrice(price string) (float64, string, bool) {
+	s := strings.TrimSpace(price)
+	currency := ""
+	if r, size := utf8.DecodeRuneInString(s); size > 0 {
+		if code, ok := currencySymbols[r]; ok {
+			currency = code
+			s = strings.TrimSpace(s[size:])
+		}
+	}
+	number, rest := splitNumber(s)
+	value, ok := parseDecimal(number)
+	if !ok || value < 0 {
+		return 0, "", false
+	}
+	if rest = strings.TrimSpace(rest); rest != "" {
+		if len(rest) != 3 || strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			return 0, "", false
+		}
+		currency = strings.ToUpper(rest)
+	}
+	return value, currency, true
+}
+
+// parseAmount splits an amount into a quantity in its base unit and that unit.
+func parseAmount(amount string) (float64, string, bool) {
+	number, rest := splitNumber(strings.TrimSpace(amount))
+	quantity, ok := parseDecimal(number)
+	if !ok || quantity <= 0 {
+		return 0, "", false
+	}
+	unit := strings.TrimSpace(strings.ToLower(strings.Trim(strings.TrimSpace(rest), "()")))
+	if f, ok := unitFactors[unit]; ok {
+		return quantity * f.factor, f.base, true
+	}
+	return quantity, unit, true
+}
+
+// splitNumber splits s after its leading run of digits, '.' and ','.
+func splitNumber(s string) (string, string) {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.' && r != ','
+	})
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// parseDecimal parses a number that uses either '.' or ',' as the decimal
+// separator. Commas are read as thousands separators when a '.' is present.
+func parseDecimal(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if strings.Contains(s, ".") {
+		s = strings.ReplaceAll(s, ",", "")
+	} else {
+		s = strings.ReplaceAll(s, ",", ".")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// purchaseDateLayouts are the accepted spellings of a purchase date. The
+// documented format is Y.M.D; dashes and slashes are accepted as well.
+var purchaseDateLayouts = []string{"2006.1.2", "2006-1-2", "2006/1/2"}
+
+// ParsePurchaseDate parses a receipt purchase date such as "2025.04.05".
+func ParsePurchaseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range purchaseDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q is not in Y.M.D format", ErrInvalidDate, s)
+}
+
+// Price history bucket sizes.
+const (
+	BucketWeek  = "week"  // ISO weeks, starting on Monday
+	BucketMonth = "month" // calendar months
+)
+
+// PricePoint is one observed unit price, taken from a receipt.
+type PricePoint struct {
+	Date        time.Time
+	ProductID   string // catalog product, "" for unlinked receipts
+	ProductName string // catalog product name, else the receipt's product name
+	StoreName   string
+	StoreID     string
+	Location    *GeoPoint // the registered store's location, else the receipt's; nil if neither is known
+	UnitPrice
+}
+
+// NewPricePoint builds a price point from a receipt's purchase date, price
+// and amount; the caller fills in product and store. ok is false if the
+// date, price or amount cannot be parsed.
+func NewPricePoint(purchaseDate, price, amount string) (PricePoint, bool) {
+	date, err := ParsePurchaseDate(purchaseDate)
+	if err != nil {
+		return PricePoint{}, false
+	}
+	unitPrice, ok := ParseUnitPrice(price, amount)
+	if !ok {
+		return PricePoint{}, false
+	}
+	return PricePoint{Date: date, UnitPrice: unitPrice}, true
+}
+
+// ConvertPoints converts price points into the table's currency at each
+// point's date, in place. Points without a known rate are left as they are.
+func ConvertPoints(points []PricePoint, table *RateTable) {
+	for i, p := range points {
+		if converted, ok := table.Convert(p.Value, p.Currency, p.Date); ok {
+			points[i].Value, points[i].Currency = converted.Value, converted.Currency
+		}
+	}
+}
+
+// PriceFilter selects the receipts a price query looks at. One of ProductID,
+// CategoryID or ProductName is set: CategoryID covers the products in that
+// category and its subcategories, and ProductName matches receipt product
+// names case-insensitively. Zero From/To leave that end of the range open.
+type PriceFilter struct {
+	ProductID   string
+	CategoryID  string
+	ProductName string
+	From        time.Time
+	To          time.Time
+	// Currency, if set, converts unit prices into it at purchase-date rates;
+	// prices without a known rate stay in their own currency.
+	Currency string
+}
+
+// PriceStats summarizes a set of unit prices.
+type PriceStats struct {
+	Count  int     `json:"count"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Median float64 `json:"median"`
+}
+
+// StorePrice is the lowest unit price seen at one store within a bucket.
+type StorePrice struct {
+	StoreName string  `json:"storeName"`
+	StoreID   string  `json:"storeId,omitempty"`
+	Min       float64 `json:"min"`
+	Count     int     `json:"count"`
+}
+
+// PriceBucket is the price summary of one week or month.
+type PriceBucket struct {
+	Start  string        `json:"start"` // first day of the bucket, YYYY-MM-DD
+	Stores []*StorePrice `json:"stores"`
+	PriceStats
+}
+
+// PriceSeries is the history of the unit prices that share a currency and
+// unit. Prices in different currencies or units are never mixed.
+type PriceSeries struct {
+	Currency string         `json:"currency"`
+	Unit     string         `json:"unit"`
+	Buckets  []*PriceBucket `json:"buckets"`
+	PriceStats
+}
+
+// PriceHistory is the price history of one product.
+type PriceHistory struct {
+	ProductID   string         `json:"productId,omitempty"`
+	ProductName string         `json:"productName,omitempty"`
+	Bucket      string         `json:"bucket"`
+	Currency    string         `json:"currency,omitempty"` // requested display currency
+	Series      []*PriceSeries `json:"series"`
+}
+
+// BucketStart returns the first day of the bucket containing date.
+func BucketStart(date time.Time, bucket string) time.Time {
+	if bucket == BucketMonth {
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	daysSinceMonday := (int(date.Weekday()) + 6) % 7
+	return time.Date(date.Year(), date.Month(), date.Day()-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+}
+
+// BuildPriceHistory groups price points into one series per currency and
+// unit, each split into buckets of the given size in chronological order.
+// Series are ordered by number of points, most first.
+func BuildPriceHistory(points []PricePoint, bucket string) []*PriceSeries {
+	type seriesKey struct{ currency, unit string }
+	seriesPoints := map[seriesKey][]PricePoint{}
+	var keys []seriesKey
+	for _, p := range points {
+		k := seriesKey{p.Currency, p.Unit}
+		if _, ok := seriesPoints[k]; !ok {
+			keys = append(keys, k)
+		}
+		seriesPoints[k] = append(seriesPoints[k], p)
+	}
+
+	series := make([]*PriceSeries, 0, len(keys))
+	for _, k := range keys {
+		pts := seriesPoints[k]
+		s := &PriceSeries{Currency: k.currency, Unit: k.unit, Buckets: []*PriceBucket{}, PriceStats: priceStats(pts)}
+
+		byBucket := map[string][]PricePoint{}
+		var starts []string
+		for _, p := range pts {
+			start := BucketStart(p.Date, bucket).Format("2006-01-02")
+			if _, ok := byBucket[start]; !ok {
+				starts = append(starts, start)
+			}
+			byBucket[start] = append(byBucket[start], p)
+		}
+		sort.Strings(starts)
+		for _, start := range starts {
+			s.Buckets = append(s.Buckets, &PriceBucket{
+				Start:      start,
+				Stores:     storePrices(byBucket[start]),
+				PriceStats: priceStats(byBucket[start]),
+			})
+		}
+		series = append(series, s)
+	}
+	sort.SliceStable(series, func(i, j int) bool { return series[i].Count > series[j].Count })
+	return series
+}
+
+// priceStats computes count, min, max and median of a non-empty set of points.
+func priceStats(points []PricePoint) PriceStats {
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Value
+	}
+	sort.Float64s(values)
+	return PriceStats{
+		Count:  len(values),
+		Min:    values[0],
+		Max:    values[len(values)-1],
+		Median: Median(values),
+	}
+}
+
+// Median returns the median of sorted, non-empty values.
+func Median(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// storePrices returns the lowest price per store, cheapest first. Receipts
+// are grouped by linked store when they have one, else by store name.
+func storePrices(points []PricePoint) []*StorePrice {
+	byStore := map[string]*StorePrice{}
+	var stores []*StorePrice
+	for _, p := range points {
+		key := pointStoreKey(p)
+		sp := byStore[key]
+		if sp == nil {
+			sp = &StorePrice{StoreName: p.StoreName, StoreID: p.StoreID, Min: p.Value}
+			byStore[key] = sp
+			stores = append(stores, sp)
+		}
+		if p.Value < sp.Min {
+			sp.Min = p.Value
+		}
+		sp.Count++
+	}
+	sort.SliceStable(stores, func(i, j int) bool { return stores[i].Min < stores[j].Min })
+	return stores
+}
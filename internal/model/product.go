@@ -0,0 +1,196 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrProductNotFound is returned when updating or deleting a product that does not exist.
+var ErrProductNotFound = errors.New("product not found")
+
+// ErrProductAliasTaken is returned when a product name or alias already
+// resolves to a different product.
+var ErrProductAliasTaken = errors.New("alias already belongs to another product")
+
+// ErrBarcodeTaken is returned when a barcode is already assigned to a different product.
+var ErrBarcodeTaken = errors.New("barcode already belongs to another product")
+
+// ErrInvalidBarcode is returned for a barcode that is not a valid
+// EAN-8, UPC-A, EAN-13 or GTIN-14 code.
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// ErrCategoryNotFound is returned when a category, or the category a product
+// is assigned to, does not exist.
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrParentCategoryNotFound is returned when the parent named for a category does not exist.
+var ErrParentCategoryNotFound = errors.New("parent category not found")
+
+// ErrCategoryExists is returned when a category already has a sibling with the same name.
+var ErrCategoryExists = errors.New("category already exists")
+
+// ErrCategoryCycle is returned when moving a category under itself or one of
+// its descendants.
+var ErrCategoryCycle = errors.New("category cannot be its own ancestor")
+
+// ErrCategoryInUse is returned when deleting a category that still has
+// subcategories or products.
+var ErrCategoryInUse = errors.New("category has subcategories or products")
+
+// Product is a canonical product in the catalog. Receipts keep their
+// free-text product name and are linked to a product when their barcode
+// matches the product's barcode, or their product name matches the product's
+// name or one of its aliases (see NormalizeProductName).
+// Timestamps are Unix epoch seconds (UTC).
+type Product struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Barcode    string   `json:"barcode,omitempty"` // normalized, see NormalizeBarcode
+	CategoryID string   `json:"categoryId,omitempty"`
+	Aliases    []string `json:"aliases"`
+	CreatedAt  int64    `json:"createdAt"`
+}
+
+// Category is a node in the product category hierarchy. Top-level
+// categories have no ParentID. Children is only filled in by BuildCategoryTree.
+type Category struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	ParentID  string      `json:"parentId,omitempty"`
+	CreatedAt int64       `json:"createdAt"`
+	Children  []*Category `json:"children,omitempty"`
+}
+
+// BuildCategoryTree nests a flat list of categories under their parents and
+// returns the top-level categories. Siblings are sorted by name.
+func BuildCategoryTree(categories []*Category) []*Category {
+	byID := make(map[string]*Category, len(categories))
+	for _, c := range categories {
+		c.Children = nil
+		byID[c.ID] = c
+	}
+	roots := []*Category{}
+	for _, c := range categories {
+		if parent := byID[c.ParentID]; parent != nil {
+			parent.Children = append(parent.Children, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+	var sortByName func([]*Category)
+	sortByName = func(nodes []*Category) {
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		for _, n := range nodes {
+			sortByName(n.Children)
+		}
+	}
+	sortByName(roots)
+	return roots
+}
+
+// NormalizeProductName reduces a product name to the key used for alias
+// matching: lowercase words separated by single spaces, with "%" spelled out
+// as "percent" and numbers split from units. "2% Milk 4L" and
+// "2 percent milk 4 l" both become "2 percent milk 4 l". Word order matters;
+// register an alias for reordered names.
+func NormalizeProductName(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r == '%':
+			flush()
+			words = append(words, "percent")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			// A switch between digits and letters starts a new word.
+			if len(word) > 0 && unicode.IsDigit(word[len(word)-1]) != unicode.IsDigit(r) {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return strings.Join(words, " ")
+}
+
+// NormalizeBarcode validates a UPC/EAN barcode and returns it in the form
+// used for matching. Spaces and hyphens are ignored. A 12-digit UPC-A code is
+// returned as its 13-digit EAN-13 equivalent, and a GTIN-14 with a leading
+// zero is shortened to EAN-13, so the same item matches whichever form was
+// scanned. EAN-8 codes are kept as they are.
+func NormalizeBarcode(code string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%w: %q must contain digits only", ErrInvalidBarcode, code)
+		}
+	}
+	switch len(digits) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("%w: %q must have 8, 12, 13 or 14 digits", ErrInvalidBarcode, code)
+	}
+	if !validCheckDigit(digits) {
+		return "", fmt.Errorf("%w: %q has a wrong check digit", ErrInvalidBarcode, code)
+	}
+	if len(digits) == 12 {
+		digits = "0" + digits
+	}
+	if len(digits) == 14 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+	return digits, nil
+}
+
+// BarcodeFromValue normalizes a barcode kept in a user-defined receipt field
+// from before barcode became a native field. Strings are normalized like any
+// barcode. A JSON number has lost the leading zeros of its code, so 9 to 12
+// digits are padded back to EAN-13 first. Other values are rejected.
+func BarcodeFromValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return NormalizeBarcode(v)
+	case float64:
+		if v < 0 || v >= 1e14 || v != math.Trunc(v) {
+			return "", fmt.Errorf("%w: %v is not a whole number of at most 14 digits", ErrInvalidBarcode, v)
+		}
+		digits := strconv.FormatFloat(v, 'f', 0, 64)
+		if len(digits) > 8 && len(digits) < 13 {
+			digits = strings.Repeat("0", 13-len(digits)) + digits
+		}
+		return NormalizeBarcode(digits)
+	}
+	return "", fmt.Errorf("%w: %v is not a string or number", ErrInvalidBarcode, v)
+}
+
+// validCheckDigit verifies the GS1 check digit: digits are weighted 3 and 1
+// alternately from the right, starting next to the check digit.
+func validCheckDigit(digits string) bool {
+	sum := 0
+	for i := len(digits) - 2; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if (len(digits)-2-i)%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+	return (10-sum%10)%10 == int(digits[len(digits)-1]-'0')
+}
@@ -16,6 +16,13 @@ var ErrMetaFieldExists = errors.New("field already exists")
 // ErrFieldNotFound is returned when updating a field that does not exist.
 var ErrFieldNotFound = errors.New("field not found")
 
+// ErrReceiptNotFound is returned when updating or deleting a receipt that does not exist.
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// ErrVersionMismatch is returned when a conditional write names a version
+// that no longer matches the stored one, i.e. someone else changed the record first.
+var ErrVersionMismatch = errors.New("record was modified by another request")
+
 // nativeFieldSet is the set of field names that are stored as dedicated columns.
 var nativeFieldSet = map[string]bool{
 	"productName":  true,
@@ -25,6 +32,7 @@ var nativeFieldSet = map[string]bool{
 	"storeName":    true,
 	"latitude":     true,
 	"longitude":    true,
+	"barcode":      true,
 }
 
 // IsNativeField returns true if the field name is a native (built-in) column.
@@ -44,9 +52,19 @@ type Receipt struct {
 	StoreName    string                 `json:"-"`
 	Latitude     *float64               `json:"-"`
 	Longitude    *float64               `json:"-"`
+	Barcode      string                 `json:"-"` // optional UPC/EAN code, normalized by the handler
 	Extras       map[string]interface{} `json:"-"`
 	UploadTime   int64                  `json:"-"`
 	UserID       string                 `json:"-"`
+	Version      int64                  `json:"-"`
+	StoreID      string                 `json:"-"` // set by the repository from StoreName; "" when unresolved
+	ProductID    string                 `json:"-"` // set by the repository from Barcode or ProductName; "" when unresolved
+	// DistanceKm is the distance from the query point of a geographic
+	// listing. It is computed per query and never stored.
+	DistanceKm *float64 `json:"-"`
+	// DisplayPrice is the price converted into a requested display currency.
+	// It is computed per query and never stored.
+	DisplayPrice *ConvertedPrice `json:"-"`
 }
 
 // MarshalJSON produces a flat JSON object merging native fields and extras.
@@ -60,6 +78,7 @@ func (r *Receipt) MarshalJSON() ([]byte, error) {
 		"storeName":    r.StoreName,
 		"uploadTime":   r.UploadTime,
 		"userId":       r.UserID,
+		"version":      r.Version,
 	}
 	if r.Latitude != nil {
 		m["latitude"] = *r.Latitude
@@ -67,6 +86,21 @@ func (r *Receipt) MarshalJSON() ([]byte, error) {
 	if r.Longitude != nil {
 		m["longitude"] = *r.Longitude
 	}
+	if r.Barcode != "" {
+		m["barcode"] = r.Barcode
+	}
+	if r.StoreID != "" {
+		m["storeId"] = r.StoreID
+	}
+	if r.ProductID != "" {
+		m["productId"] = r.ProductID
+	}
+	if r.DistanceKm != nil {
+		m["distanceKm"] = *r.DistanceKm
+	}
+	if r.DisplayPrice != nil {
+		m["displayPrice"] = r.DisplayPrice
+	}
 	for k, v := range r.Extras {
 		m[k] = v
 	}
@@ -104,11 +138,14 @@ func ParseReceiptFromMap(m map[string]interface{}) (*Receipt, map[string]interfa
 	if v, ok := m["longitude"].(float64); ok {
 		r.Longitude = &v
 	}
+	if v, ok := m["barcode"].(string); ok {
+		r.Barcode = v
+	}
 
 	// Track server-managed fields so we skip them.
 	// prevents injection
 	skip := map[string]bool{
-		"id": true, "uploadTime": true, "userId": true,
+		"id": true, "uploadTime": true, "userId": true, "version": true, "storeId": true, "productId": true, "distanceKm": true,
 	}
 
 	extras := make(map[string]interface{})
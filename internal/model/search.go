@@ -0,0 +1,132 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInvalidSearchQuery is returned when a search string cannot be parsed
+// or contains no searchable words.
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// maxSearchTerms caps the number of terms in a query to keep index lookups cheap.
+const maxSearchTerms = 16
+
+// SearchTerm is one clause of a search query. Words are lowercased letters
+// and digits only, so every backend can render them into its own query
+// syntax without escaping.
+type SearchTerm struct {
+	Words  []string
+	Phrase bool // words must appear next to each other, in order
+	Prefix bool // the last word matches any word starting with it
+}
+
+// SearchQuery is a parsed search string. All terms must match.
+type SearchQuery struct {
+	Terms []SearchTerm
+}
+
+// SearchHit is one ranked search result. Higher scores are better matches.
+type SearchHit struct {
+	Receipt *Receipt `json:"receipt"`
+	Score   float64  `json:"score"`
+}
+
+// ParseSearchQuery parses a user-supplied search string.
+//
+// Terms are separated by whitespace and must all match. A term ending in *
+// is a prefix match ("oat*" matches "oats" and "oatmeal"). Text in double
+// quotes is a phrase ("oat milk"), which may also end in * ("oat mil"*).
+// Punctuation inside a term splits it into words, so "2%" and "o'clock"
+// behave the same on every backend.
+func ParseSearchQuery(q string) (*SearchQuery, error) {
+	query := &SearchQuery{}
+	runes := []rune(strings.TrimSpace(q))
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var raw string
+		phrase := false
+		if runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidSearchQuery)
+			}
+			raw = string(runes[i+1 : end])
+			phrase = true
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		prefix := false
+		if phrase && i < len(runes) && runes[i] == '*' {
+			prefix = true
+			i++
+		} else if !phrase && strings.HasSuffix(raw, "*") {
+			prefix = true
+		}
+
+		words := searchWords(raw)
+		if len(words) == 0 {
+			continue
+		}
+		query.Terms = append(query.Terms, SearchTerm{
+			Words:  words,
+			Phrase: phrase || len(words) > 1,
+			Prefix: prefix,
+		})
+	}
+
+	if len(query.Terms) == 0 {
+		return nil, fmt.Errorf("%w: no searchable words", ErrInvalidSearchQuery)
+	}
+	if len(query.Terms) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: at most %d terms are allowed", ErrInvalidSearchQuery, maxSearchTerms)
+	}
+	return query, nil
+}
+
+// searchWords splits text into lowercase runs of letters and digits.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// SearchableExtras returns the string-typed extras of a receipt joined into
+// a single string for the search index. fields maps extra keys to their meta
+// field definitions; keys without a string-typed definition are skipped.
+func SearchableExtras(extras map[string]interface{}, fields map[string]*MetaField) string {
+	keys := make([]string, 0, len(extras))
+	for key := range extras {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		field := fields[key]
+		if field == nil || field.FieldType != "string" {
+			continue
+		}
+		if s, ok := extras[key].(string); ok && s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " ")
+}
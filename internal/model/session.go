@@ -0,0 +1,64 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrSessionNotFound is returned when revoking a session that does not exist
+// or belongs to another user.
+var ErrSessionNotFound = errors.New("session not found")
+
+// Maximum lengths of the client details recorded with a session. Longer
+// values are truncated.
+const (
+	MaxDeviceLength    = 100
+	MaxUserAgentLength = 256
+)
+
+// ClientInfo describes the client a session is started or refreshed from.
+// Device is a name the client chose at login, e.g. "Pixel 8"; UserAgent and
+// IPAddress are taken from the request.
+type ClientInfo struct {
+	Device    string
+	UserAgent string
+	IPAddress string
+}
+
+// Session is one login of a user on one client. It lasts as long as the
+// refresh token family rotated from that login, and its ID is the family ID.
+// Device is kept from the login; UserAgent and IPAddress are those of the
+// most recent refresh.
+// Timestamps are Unix epoch seconds (UTC).
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userId"`
+	Device     string `json:"device"`
+	UserAgent  string `json:"userAgent"`
+	IPAddress  string `json:"ipAddress"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastUsedAt int64  `json:"lastUsedAt"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	TwoFactor  bool   `json:"twoFactor"` // the login was verified with a second factor
+	Current    bool   `json:"current"`   // the session of the access token that listed it
+}
+
+// Normalize trims the device name and truncates the client details to their
+// maximum lengths.
+func (c ClientInfo) Normalize() ClientInfo {
+	c.Device = truncate(strings.TrimSpace(c.Device), MaxDeviceLength)
+	c.UserAgent = truncate(c.UserAgent, MaxUserAgentLength)
+	return c
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
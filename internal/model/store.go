@@ -0,0 +1,38 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrStoreNotFound is returned when updating or deleting a store that does not exist.
+var ErrStoreNotFound = errors.New("store not found")
+
+// ErrStoreAliasTaken is returned when a store name or alias already resolves
+// to a different store.
+var ErrStoreAliasTaken = errors.New("alias already belongs to another store")
+
+// Store is a normalized store entity. Receipts keep their free-text store
+// name and are linked to a store when that name matches the store's name or
+// one of its aliases (see NormalizeStoreName).
+// Timestamps are Unix epoch seconds (UTC).
+type Store struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Chain     string   `json:"chain"`
+	Address   string   `json:"address"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Aliases   []string `json:"aliases"`
+	CreatedAt int64    `json:"createdAt"`
+}
+
+// NormalizeStoreName reduces a store name to the key used for alias matching:
+// lowercase letters and digits separated by single spaces. "COSTCO  #552"
+// and "Costco 552" both become "costco 552".
+func NormalizeStoreName(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
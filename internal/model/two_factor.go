@@ -0,0 +1,20 @@
+package model
+
+import "errors"
+
+// ErrTwoFactorNotEnrolled is returned when a user has no two-factor
+// enrollment in the state an operation needs.
+var ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enrolled")
+
+// TwoFactor is a user's TOTP (RFC 6238) enrollment. It is pending until the
+// user proves their authenticator app works by entering a code; from then on
+// logins need a code or one of the user's recovery codes.
+type TwoFactor struct {
+	UserID            string `json:"userId"`
+	Secret            string `json:"-"`       // base32 shared secret
+	Enabled           bool   `json:"enabled"` // confirmed with a code
+	LastStep          int64  `json:"-"`       // time step of the last accepted code, to stop replays
+	RecoveryCodesLeft int    `json:"recoveryCodesLeft"`
+	CreatedAt         int64  `json:"createdAt"`           // unix seconds
+	EnabledAt         int64  `json:"enabledAt,omitempty"` // unix seconds, 0 while pending
+}
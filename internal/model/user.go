@@ -5,6 +5,13 @@ import "errors"
 // ErrInvalidToken is returned when a refresh token is missing, expired, or revoked.
 var ErrInvalidToken = errors.New("invalid or expired token")
 
+// ErrUserNotFound is returned when a user does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrRefreshTokenReused is returned when a refresh token that was already
+// rotated is presented again. Its whole token family has been revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
 // Role represents the authorization level of a user.
 type Role string
 
@@ -19,8 +26,10 @@ const (
 type User struct {
 	ID           string `json:"id"`
 	Username     string `json:"username"`
+	DisplayName  string `json:"displayName"` // optional; empty means none
 	PasswordHash string `json:"-"`
 	Role         Role   `json:"role"`
 	CreatedAt    int64  `json:"createdAt"`
 	UpdatedAt    int64  `json:"updatedAt"`
+	TokenVersion int64  `json:"-"` // stamped into access tokens; bumped to revoke them
 }
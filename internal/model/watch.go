@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrWatchNotFound is returned when deleting a watch that does not exist or
+// belongs to another user.
+var ErrWatchNotFound = errors.New("watch not found")
+
+// ErrAlertNotFound is returned when marking an alert that does not exist or
+// belongs to another user.
+var ErrAlertNotFound = errors.New("alert not found")
+
+// priceTolerance absorbs rounding from unit conversion when comparing a unit
+// price against a watch's maximum, so 4.00/lb is not missed as 4.0000001.
+const priceTolerance = 1e-9
+
+// Watch is a user's standing request to be alerted when a product is bought
+// at or below a unit price, e.g. butter at 4.00 CAD per lb. A watch on a
+// catalog product matches receipts linked to it; otherwise receipts are
+// matched by product name, ignoring case.
+// Timestamps are Unix epoch seconds (UTC).
+type Watch struct {
+	ID          string  `json:"id"`
+	UserID      string  `json:"userId"`
+	ProductID   string  `json:"productId,omitempty"`
+	ProductName string  `json:"productName"`
+	MaxPrice    float64 `json:"maxPrice"`
+	Currency    string  `json:"currency"`
+	Unit        string  `json:"unit"` // as given, e.g. "lb", "kg", "l" or "each"
+	CreatedAt   int64   `json:"createdAt"`
+}
+
+// Alert records that a receipt triggered a watch. UnitPrice is the receipt's
+// price per the watch's unit, in the watch's currency.
+type Alert struct {
+	ID           int64   `json:"id"`
+	WatchID      string  `json:"watchId"`
+	UserID       string  `json:"userId"`
+	ReceiptID    string  `json:"receiptId"`
+	ProductName  string  `json:"productName"`
+	StoreName    string  `json:"storeName"`
+	PurchaseDate string  `json:"purchaseDate"`
+	UnitPrice    float64 `json:"unitPrice"`
+	MaxPrice     float64 `json:"maxPrice"`
+	Currency     string  `json:"currency"`
+	Unit         string  `json:"unit"`
+	Read         bool    `json:"read"`
+	CreatedAt    int64   `json:"createdAt"`
+}
+
+// BaseUnit returns the unit prices in the given unit are compared in
+// (UnitKilogram, UnitLitre or UnitEach) and how many of it one unit holds.
+// An unrecognized unit is its own base.
+func BaseUnit(unit string) (string, float64) {
+	unit = strings.TrimSpace(strings.ToLower(unit))
+	if f, ok := unitFactors[unit]; ok {
+		return f.base, f.factor
+	}
+	return unit, 1
+}
+
+// Check derives a receipt's unit price from its price and amount and
+// converts it to the watch's unit. triggered is true if it is in the watch's
+// currency and unit family and does not exceed MaxPrice. ok is false if the
+// price cannot be compared at all.
+func (w *Watch) Check(price, amount string) (unitPrice float64, triggered, ok bool) {
+	up, ok := ParseUnitPrice(price, amount)
+	if !ok || up.Currency != w.Currency {
+		return 0, false, false
+	}
+	base, factor := BaseUnit(w.Unit)
+	if up.Unit != base {
+		return 0, false, false
+	}
+	unitPrice = up.Value * factor
+	return unitPrice, unitPrice <= w.MaxPrice+priceTolerance, true
+}
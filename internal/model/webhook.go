@@ -0,0 +1,80 @@
+package model
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrWebhookNotFound is returned when a webhook subscription does not exist.
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrDeliveryNotFound is returned when retrying a webhook delivery that does
+// not exist or is not dead-lettered.
+var ErrDeliveryNotFound = errors.New("dead-lettered delivery not found")
+
+// Webhook delivery states. A pending delivery is retried with exponential
+// backoff until it succeeds (delivered) or runs out of attempts (dead).
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// WebhookSubscription sends the events matching any of its filters to a URL.
+// A filter is an event type ("receipt.created"), a prefix wildcard
+// ("receipt.*"), or "*" for every event. Secret signs each payload and is
+// only returned when the subscription is created.
+// Timestamps are Unix epoch seconds (UTC).
+type WebhookSubscription struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedAt int64    `json:"createdAt"`
+}
+
+// WebhookDelivery is one event queued for one subscription, together with
+// the outcome of its latest attempt. Payload is the event data as JSON.
+type WebhookDelivery struct {
+	ID             int64  `json:"id"`
+	SubscriptionID string `json:"subscriptionId"`
+	EventType      string `json:"eventType"`
+	Payload        []byte `json:"-"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	NextAttemptAt  int64  `json:"nextAttemptAt,omitempty"`
+	LastStatusCode int    `json:"lastStatusCode,omitempty"`
+	LastError      string `json:"lastError,omitempty"`
+	CreatedAt      int64  `json:"createdAt"`
+	DeliveredAt    int64  `json:"deliveredAt,omitempty"`
+
+	// Filled in by WebhookRepository.DueDeliveries for the dispatcher.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// ValidEventFilter reports whether filter is "*", a known event type, or a
+// known event type's prefix followed by ".*".
+func ValidEventFilter(filter string) bool {
+	if filter == "*" {
+		return true
+	}
+	for _, t := range EventTypes {
+		if filter == t || filter == t[:strings.IndexByte(t, '.')]+".*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Wants reports whether any of the subscription's filters matches an event type.
+func (s *WebhookSubscription) Wants(eventType string) bool {
+	for _, f := range s.Events {
+		if f == "*" || f == eventType ||
+			(strings.HasSuffix(f, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// AnalyticsRepo implements repository.AnalyticsRepository backed by PostgreSQL.
+type AnalyticsRepo struct {
+	db *DB
+}
+
+// NewAnalyticsRepo creates a new PostgreSQL-backed analytics repository.
+func NewAnalyticsRepo(db *DB) *AnalyticsRepo {
+	return &AnalyticsRepo{db: db}
+}
+
+func (r *AnalyticsRepo) Spending(ctx context.Context, userID string, query model.SpendingQuery) (*model.SpendingReport, error) {
+	where := "r.user_id = $1"
+	whereArgs := []interface{}{userID}
+	if !query.From.IsZero() {
+		whereArgs = append(whereArgs, query.From.Format("2006-01-02"))
+		where += fmt.Sprintf(" AND r.purchased_on >= $%d", len(whereArgs))
+	}
+	if !query.To.IsZero() {
+		whereArgs = append(whereArgs, query.To.Format("2006-01-02"))
+		where += fmt.Sprintf(" AND r.purchased_on <= $%d", len(whereArgs))
+	}
+
+	report := &model.SpendingReport{GroupBy: query.GroupBy, Field: query.Field, Groups: []*model.SpendGroup{}}
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM receipts r WHERE `+where+` AND r.price_value IS NULL`, whereArgs...,
+	).Scan(&report.Unpriced); err != nil {
+		return nil, fmt.Errorf("count unpriced receipts: %w", err)
+	}
+	where += " AND r.price_value IS NOT NULL"
+	if query.Currency != "" {
+		return r.convertedSpending(ctx, report, query, where, whereArgs)
+	}
+
+	totals, err := r.db.conn.QueryContext(ctx,
+		`SELECT '', '', r.currency, SUM(r.price_value), COUNT(*) FROM receipts r
+		WHERE `+where+` GROUP BY r.currency ORDER BY r.currency`, whereArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("sum spending: %w", err)
+	}
+	overall, err := scanSpendGroups(totals)
+	if err != nil {
+		return nil, err
+	}
+	report.Totals = []*model.SpendTotal{}
+	for _, g := range overall {
+		report.Totals = append(report.Totals, g.Totals...)
+	}
+
+	key, label, joins, args := spendingDimension(query, len(whereArgs)+1)
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT `+key+` AS group_key, `+label+` AS group_label, r.currency, SUM(r.price_value), COUNT(*)
+		FROM receipts r `+joins+`
+		WHERE `+where+`
+		GROUP BY group_key, group_label, r.currency
+		ORDER BY group_label, group_key, r.currency`,
+		append(whereArgs, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("group spending: %w", err)
+	}
+	if report.Groups, err = scanSpendGroups(rows); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// convertedSpending completes report with spending converted into
+// query.Currency. Sums are split by purchase day in SQL so that each can be
+// converted at that day's rate.
+func (r *AnalyticsRepo) convertedSpending(ctx context.Context, report *model.SpendingReport, query model.SpendingQuery, where string, whereArgs []interface{}) (*model.SpendingReport, error) {
+	table, err := loadRateTable(ctx, r.db.conn, query.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	key, label, joins, args := spendingDimension(query, len(whereArgs)+1)
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT `+key+` AS group_key, `+label+` AS group_label, r.currency,
+			COALESCE(r.purchased_on, '') AS day, SUM(r.price_value), COUNT(*)
+		FROM receipts r `+joins+`
+		WHERE `+where+`
+		GROUP BY group_key, group_label, r.currency, day
+		ORDER BY group_label, group_key, r.currency, day`,
+		append(whereArgs, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("group spending: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var spend []model.SpendRow
+	for rows.Next() {
+		var row model.SpendRow
+		if err := rows.Scan(&row.Key, &row.Label, &row.Currency, &row.Day, &row.Total, &row.Count); err != nil {
+			return nil, fmt.Errorf("scan spending: %w", err)
+		}
+		spend = append(spend, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	report.Currency = query.Currency
+	report.Totals, report.Groups = model.ConvertSpend(spend, table)
+	return report, nil
+}
+
+// spendingDimension returns the SQL expressions for the group key and label
+// of a spending query, the joins they need, and their arguments, numbered
+// from placeholder n.
+func spendingDimension(query model.SpendingQuery, n int) (key, label, joins string, args []interface{}) {
+	switch query.GroupBy {
+	case model.GroupByStore:
+		return "COALESCE(r.store_id, '')", "COALESCE(s.name, r.store_name)",
+			"LEFT JOIN stores s ON s.id = r.store_id", nil
+	case model.GroupByCategory:
+		return "COALESCE(c.id, '')", "COALESCE(c.name, '')",
+			"LEFT JOIN products p ON p.id = r.product_id LEFT JOIN categories c ON c.id = p.category_id", nil
+	case model.GroupByField:
+		value := fmt.Sprintf("COALESCE(r.extras::jsonb ->> $%d::text, '')", n)
+		return value, value, "", []interface{}{query.Field}
+	default: // model.GroupByMonth
+		return "COALESCE(substr(r.purchased_on, 1, 7), '')", "COALESCE(substr(r.purchased_on, 1, 7), '')", "", nil
+	}
+}
+
+// scanSpendGroups reads (key, label, currency, total, count) rows into
+// groups, merging consecutive rows with the same key and label.
+func scanSpendGroups(rows *sql.Rows) ([]*model.SpendGroup, error) {
+	defer func() { _ = rows.Close() }()
+
+	groups := []*model.SpendGroup{}
+	var last *model.SpendGroup
+	for rows.Next() {
+		var key, label string
+		var total model.SpendTotal
+		if err := rows.Scan(&key, &label, &total.Currency, &total.Total, &total.Count); err != nil {
+			return nil, fmt.Errorf("scan spending: %w", err)
+		}
+		total.Total = math.Round(total.Total*100) / 100
+		if last == nil || last.Key != key || last.Label != label {
+			last = &model.SpendGroup{Key: key, Label: label}
+			groups = append(groups, last)
+		}
+		last.Totals = append(last.Totals, &total)
+	}
+	return groups, rows.Err()
+}
+
+// spendValues returns the price_value, currency and purchased_on values
+// stored with a receipt so that spending can be aggregated in SQL. A price
+// that cannot be parsed is stored as a NULL value with currency "", which
+// marks the row as processed (see backfillSpendColumns).
+func spendValues(price, purchaseDate string) (sql.NullFloat64, string, sql.NullString) {
+	var value sql.NullFloat64
+	var purchasedOn sql.NullString
+	currency := ""
+	if v, c, ok := model.ParsePrice(price); ok {
+		value, currency = sql.NullFloat64{Float64: v, Valid: true}, c
+	}
+	if date, err := model.ParsePurchaseDate(purchaseDate); err == nil {
+		purchasedOn = sql.NullString{String: date.Format("2006-01-02"), Valid: true}
+	}
+	return value, currency, purchasedOn
+}
+
+// backfillSpendColumns derives the spend columns of receipts stored before
+// they existed. Prices and dates are parsed in Go, so this cannot be a SQL
+// migration; rows still to do have a NULL currency.
+func (db *DB) backfillSpendColumns() error {
+	rows, err := db.conn.Query(`SELECT id, price, purchase_date FROM receipts WHERE currency IS NULL`)
+	if err != nil {
+		return fmt.Errorf("find receipts to backfill: %w", err)
+	}
+	type pending struct{ id, price, date string }
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.price, &p.date); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt: %w", err)
+		}
+		todo = append(todo, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, p := range todo {
+		value, currency, purchasedOn := spendValues(p.price, p.date)
+		if _, err := tx.Exec(
+			`UPDATE receipts SET price_value = $1, currency = $2, purchased_on = $3 WHERE id = $4`,
+			value, currency, purchasedOn, p.id,
+		); err != nil {
+			return fmt.Errorf("backfill receipt: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit backfill: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const categoryColumns = "id, name, parent_id, created_at"
+
+// CategoryRepo implements repository.CategoryRepository backed by PostgreSQL.
+type CategoryRepo struct {
+	db *DB
+}
+
+// NewCategoryRepo creates a new PostgreSQL-backed category repository.
+func NewCategoryRepo(db *DB) *CategoryRepo {
+	return &CategoryRepo{db: db}
+}
+
+func (r *CategoryRepo) CreateCategory(ctx context.Context, category *model.Category) error {
+	category.CreatedAt = time.Now().Unix()
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkCategoryPlacement(ctx, tx, category); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO categories (`+categoryColumns+`) VALUES ($1, $2, $3, $4)`,
+		category.ID, category.Name, nullString(category.ParentID), category.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create category: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit category: %w", err)
+	}
+	return nil
+}
+
+func (r *CategoryRepo) GetCategory(ctx context.Context, id string) (*model.Category, error) {
+	row := r.db.conn.QueryRowContext(ctx, `SELECT `+categoryColumns+` FROM categories WHERE id = $1`, id)
+	category, err := scanCategory(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get category: %w", err)
+	}
+	return category, nil
+}
+
+func (r *CategoryRepo) ListCategories(ctx context.Context) ([]*model.Category, error) {
+	rows, err := r.db.conn.QueryContext(ctx, `SELECT `+categoryColumns+` FROM categories ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("list categories: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	categories := []*model.Category{}
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepo) UpdateCategory(ctx context.Context, category *model.Category) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var createdAt int64
+	err = tx.QueryRowContext(ctx, `SELECT created_at FROM categories WHERE id = $1`, category.ID).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: %q", model.ErrCategoryNotFound, category.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("get category: %w", err)
+	}
+	if err := checkCategoryPlacement(ctx, tx, category); err != nil {
+		return err
+	}
+
+	// Walk up from the new parent; meeting the category itself means the
+	// move would make it its own ancestor.
+	for ancestor := category.ParentID; ancestor != ""; {
+		if ancestor == category.ID {
+			return model.ErrCategoryCycle
+		}
+		var parent sql.NullString
+		if err := tx.QueryRowContext(ctx, `SELECT parent_id FROM categories WHERE id = $1`, ancestor).Scan(&parent); err != nil {
+			return fmt.Errorf("walk category ancestors: %w", err)
+		}
+		ancestor = parent.String
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE categories SET name = $1, parent_id = $2 WHERE id = $3`,
+		category.Name, nullString(category.ParentID), category.ID,
+	); err != nil {
+		return fmt.Errorf("update category: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit category: %w", err)
+	}
+	category.CreatedAt = createdAt
+	return nil
+}
+
+func (r *CategoryRepo) DeleteCategory(ctx context.Context, id string) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var inUse bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories WHERE parent_id = $1)
+			OR EXISTS (SELECT 1 FROM products WHERE category_id = $1)`, id,
+	).Scan(&inUse); err != nil {
+		return fmt.Errorf("check category usage: %w", err)
+	}
+	if inUse {
+		return fmt.Errorf("%w: %q", model.ErrCategoryInUse, id)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete category: %w", err)
+	}
+	if err := expectRow(result, model.ErrCategoryNotFound, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit category: %w", err)
+	}
+	return nil
+}
+
+// checkCategoryPlacement verifies that the category's parent exists and that
+// no sibling already has the same name (compared case-insensitively).
+func checkCategoryPlacement(ctx context.Context, tx *sql.Tx, category *model.Category) error {
+	if category.ParentID != "" {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, category.ParentID,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("check parent category: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %q", model.ErrParentCategoryNotFound, category.ParentID)
+		}
+	}
+
+	var taken bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM categories
+			WHERE COALESCE(parent_id, '') = $1 AND lower(name) = lower($2::text) AND id <> $3)`,
+		category.ParentID, category.Name, category.ID,
+	).Scan(&taken); err != nil {
+		return fmt.Errorf("check category name: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("%w: %q", model.ErrCategoryExists, category.Name)
+	}
+	return nil
+}
+
+// categorySubtreeSQL returns a subquery selecting the ID of the category
+// bound to the given placeholder and the IDs of all its descendants.
+func categorySubtreeSQL(placeholder string) string {
+	return `WITH RECURSIVE subtree(id) AS (
+			SELECT ` + placeholder + `::text
+			UNION SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		) SELECT id FROM subtree`
+}
+
+// scanCategory scans a category row from either *sql.Row or *sql.Rows.
+func scanCategory(row interface{ Scan(...interface{}) error }) (*model.Category, error) {
+	var c model.Category
+	var parentID sql.NullString
+	if err := row.Scan(&c.ID, &c.Name, &parentID, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	c.ParentID = parentID.String
+	return &c, nil
+}
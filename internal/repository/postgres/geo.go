@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// haversineSQL returns the SQL expression for the distance in km between a
+// receipt's coordinates and the point in placeholders lat and lng. It is NULL
+// when the receipt has no coordinates.
+func haversineSQL(lat, lng string) string {
+	lat += "::double precision"
+	lng += "::double precision"
+	return fmt.Sprintf(
+		"(2 * %f * asin(sqrt(least(1, power(sin(radians(latitude - %s) / 2), 2) + "+
+			"cos(radians(%s)) * cos(radians(latitude)) * power(sin(radians(longitude - %s) / 2), 2)))))",
+		model.EarthRadiusKm, lat, lat, lng,
+	)
+}
+
+// distanceColumn returns the select expression for a receipt's distance from
+// the query point, aliased distance_km, and its arguments. Placeholders are
+// numbered from next. It selects NULL when there is no query point so scans
+// stay uniform.
+func distanceColumn(geo *model.GeoFilter, next int) (string, []interface{}) {
+	if geo == nil || geo.Center == nil {
+		return "NULL::double precision AS distance_km", nil
+	}
+	expr := haversineSQL(fmt.Sprintf("$%d", next), fmt.Sprintf("$%d", next+1))
+	return expr + " AS distance_km", []interface{}{geo.Center.Lat, geo.Center.Lng}
+}
+
+// geoCondition returns the WHERE fragment for a radius and/or bounding box
+// filter, with its arguments. Placeholders are numbered from next. It returns
+// an empty fragment when geo filters nothing. Radius queries are narrowed by
+// a latitude range first so the location index can be used.
+func geoCondition(geo *model.GeoFilter, next int) (string, []interface{}) {
+	if geo == nil {
+		return "", nil
+	}
+	var conds []string
+	var args []interface{}
+	placeholder := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", next+len(args)-1)
+	}
+	if geo.RadiusKm > 0 {
+		minLat, maxLat := geo.LatitudeSpan()
+		conds = append(conds, "latitude BETWEEN "+placeholder(minLat)+" AND "+placeholder(maxLat))
+		distance := haversineSQL(placeholder(geo.Center.Lat), placeholder(geo.Center.Lng))
+		conds = append(conds, distance+" <= "+placeholder(geo.RadiusKm))
+	}
+	if box := geo.Box; box != nil {
+		conds = append(conds, "latitude BETWEEN "+placeholder(box.South)+" AND "+placeholder(box.North))
+		if box.West <= box.East {
+			conds = append(conds, "longitude BETWEEN "+placeholder(box.West)+" AND "+placeholder(box.East))
+		} else {
+			conds = append(conds, "(longitude >= "+placeholder(box.West)+" OR longitude <= "+placeholder(box.East)+")")
+		}
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// receiptOrder returns the ORDER BY clause for an offset-paginated receipt
+// listing. Receipts without coordinates sort last by distance.
+func receiptOrder(params model.PaginationParams) string {
+	if params.SortBy == model.SortByDistance {
+		return "ORDER BY distance_km " + params.SortOrder + " NULLS LAST"
+	}
+	return fmt.Sprintf("ORDER BY %s %s", params.SortBy, params.SortOrder)
+}
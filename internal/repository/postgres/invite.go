@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// InviteStore is a PostgreSQL-backed implementation of auth.InviteStore.
+// Invite codes are stored as SHA-256 hashes.
+type InviteStore struct {
+	db *DB
+}
+
+// NewInviteStore creates a new PostgreSQL-backed invite store.
+func NewInviteStore(db *DB) *InviteStore {
+	return &InviteStore{db: db}
+}
+
+func (s *InviteStore) Create(ctx context.Context, invite *model.Invite, codeHash string) error {
+	_, err := s.db.conn.ExecContext(ctx,
+		`INSERT INTO invites (id, code_hash, created_by, max_uses, uses, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		invite.ID, codeHash, nullString(invite.CreatedBy), invite.MaxUses, invite.Uses, invite.ExpiresAt, invite.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create invite: %w", err)
+	}
+	return nil
+}
+
+func (s *InviteStore) List(ctx context.Context) ([]*model.Invite, error) {
+	rows, err := s.db.conn.QueryContext(ctx,
+		`SELECT id, created_by, max_uses, uses, expires_at, created_at FROM invites ORDER BY created_at DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("list invites: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	invites := []*model.Invite{}
+	for rows.Next() {
+		var inv model.Invite
+		var createdBy sql.NullString
+		if err := rows.Scan(&inv.ID, &createdBy, &inv.MaxUses, &inv.Uses, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan invite: %w", err)
+		}
+		inv.CreatedBy = createdBy.String
+		invites = append(invites, &inv)
+	}
+	return invites, rows.Err()
+}
+
+func (s *InviteStore) Redeem(ctx context.Context, codeHash string, at int64) (bool, error) {
+	result, err := s.db.conn.ExecContext(ctx,
+		`UPDATE invites SET uses = uses + 1 WHERE code_hash = $1 AND uses < max_uses AND expires_at > $2`,
+		codeHash, at)
+	if err != nil {
+		return false, fmt.Errorf("redeem invite: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (s *InviteStore) Release(ctx context.Context, codeHash string) error {
+	if _, err := s.db.conn.ExecContext(ctx,
+		`UPDATE invites SET uses = uses - 1 WHERE code_hash = $1 AND uses > 0`, codeHash,
+	); err != nil {
+		return fmt.Errorf("release invite: %w", err)
+	}
+	return nil
+}
+
+func (s *InviteStore) Delete(ctx context.Context, id string) error {
+	result, err := s.db.conn.ExecContext(ctx, `DELETE FROM invites WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete invite: %w", err)
+	}
+	return expectRow(result, model.ErrInviteNotFound, id)
+}
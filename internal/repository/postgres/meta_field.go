@@ -8,7 +8,7 @@ import (
 	"github.com/gatheryourdeals/data/internal/model"
 )
 
-const metaColumns = "field_name, description, field_type, native"
+const metaColumns = "field_name, description, field_type, native, version"
 
 // MetaFieldRepo implements repository.MetaFieldRepository backed by PostgreSQL.
 type MetaFieldRepo struct {
@@ -21,28 +21,31 @@ func NewMetaFieldRepo(db *DB) *MetaFieldRepo {
 }
 
 func (r *MetaFieldRepo) CreateField(ctx context.Context, field *model.MetaField) error {
-	query := `INSERT INTO meta_fields (` + metaColumns + `) VALUES ($1, $2, $3, $4)`
-	_, err := r.db.conn.ExecContext(ctx, query,
-		field.FieldName, field.Description, field.FieldType, field.Native)
+	query := `INSERT INTO meta_fields (` + metaColumns + `) VALUES ($1, $2, $3, $4, $5)`
+	field.Version = 1
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, query,
+		field.FieldName, field.Description, field.FieldType, field.Native, field.Version)
 	if err != nil {
 		return fmt.Errorf("create meta field: %w", err)
 	}
+	if err := publishEvent(ctx, tx, model.Event{Type: model.EventMetaCreated, Data: field}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit meta field: %w", err)
+	}
 	return nil
 }
 
 func (r *MetaFieldRepo) GetField(ctx context.Context, fieldName string) (*model.MetaField, error) {
-	query := `SELECT ` + metaColumns + ` FROM meta_fields WHERE field_name = $1`
-	row := r.db.conn.QueryRowContext(ctx, query, fieldName)
-
-	var f model.MetaField
-	err := row.Scan(&f.FieldName, &f.Description, &f.FieldType, &f.Native)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, fmt.Errorf("get meta field: %w", err)
-	}
-	return &f, nil
+	return getField(ctx, r.db.conn, fieldName)
 }
 
 func (r *MetaFieldRepo) ListFields(ctx context.Context, params model.PaginationParams) (*model.Page[*model.MetaField], error) {
@@ -81,7 +84,7 @@ func (r *MetaFieldRepo) ListFields(ctx context.Context, params model.PaginationP
 	var fields []*model.MetaField
 	for rows.Next() {
 		var f model.MetaField
-		if err := rows.Scan(&f.FieldName, &f.Description, &f.FieldType, &f.Native); err != nil {
+		if err := rows.Scan(&f.FieldName, &f.Description, &f.FieldType, &f.Native, &f.Version); err != nil {
 			return nil, fmt.Errorf("scan meta field: %w", err)
 		}
 		fields = append(fields, &f)
@@ -96,10 +99,66 @@ func (r *MetaFieldRepo) ListFields(ctx context.Context, params model.PaginationP
 	return page, nil
 }
 
-func (r *MetaFieldRepo) UpdateDescription(ctx context.Context, fieldName string, description string) error {
-	result, err := r.db.conn.ExecContext(ctx,
-		`UPDATE meta_fields SET description = $1 WHERE field_name = $2`,
-		description, fieldName)
+func (r *MetaFieldRepo) ListFieldsCursor(ctx context.Context, params model.CursorParams) (*model.CursorPage[*model.MetaField], error) {
+	page := &model.CursorPage[*model.MetaField]{Data: []*model.MetaField{}, Limit: params.Limit}
+	if params.IncludeTotal {
+		var total int
+		if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM meta_fields`).Scan(&total); err != nil {
+			return nil, fmt.Errorf("count meta fields: %w", err)
+		}
+		page.Total = &total
+	}
+
+	// field_name is both the only sort column and the primary key.
+	query := `SELECT ` + metaColumns + ` FROM meta_fields`
+	cond, args := keysetCondition(params, "field_name", 1)
+	if cond != "" {
+		query += ` WHERE ` + cond
+	}
+	// Fetch one extra row to learn whether another page follows.
+	query += ` ` + keysetOrder(params, "field_name") + fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list meta fields: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var fields []*model.MetaField
+	for rows.Next() {
+		var f model.MetaField
+		if err := rows.Scan(&f.FieldName, &f.Description, &f.FieldType, &f.Native, &f.Version); err != nil {
+			return nil, fmt.Errorf("scan meta field: %w", err)
+		}
+		fields = append(fields, &f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		fields = []*model.MetaField{}
+	}
+	trimCursorPage(page, fields, params, func(f *model.MetaField) (interface{}, string) {
+		return f.FieldName, f.FieldName
+	})
+	return page, nil
+}
+
+func (r *MetaFieldRepo) UpdateDescription(ctx context.Context, fieldName string, description string, expectedVersion int64) error {
+	query := `UPDATE meta_fields SET description = $1, version = version + 1 WHERE field_name = $2`
+	args := []interface{}{description, fieldName}
+	if expectedVersion != 0 {
+		query += ` AND version = $3`
+		args = append(args, expectedVersion)
+	}
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("update meta field description: %w", err)
 	}
@@ -108,7 +167,44 @@ func (r *MetaFieldRepo) UpdateDescription(ctx context.Context, fieldName string,
 		return fmt.Errorf("rows affected: %w", err)
 	}
 	if rows == 0 {
+		// Tell a missing field apart from a stale version.
+		if expectedVersion != 0 {
+			field, err := getField(ctx, tx, fieldName)
+			if err != nil {
+				return err
+			}
+			if field != nil {
+				return fmt.Errorf("%w: field %q is at version %d", model.ErrVersionMismatch, fieldName, field.Version)
+			}
+		}
 		return fmt.Errorf("%w: %q", model.ErrFieldNotFound, fieldName)
 	}
+
+	field, err := getField(ctx, tx, fieldName)
+	if err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, tx, model.Event{Type: model.EventMetaUpdated, Data: field}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit meta field: %w", err)
+	}
 	return nil
 }
+
+// getField reads a meta field through q, returning nil if it does not exist.
+func getField(ctx context.Context, q rowQuerier, fieldName string) (*model.MetaField, error) {
+	query := `SELECT ` + metaColumns + ` FROM meta_fields WHERE field_name = $1`
+	row := q.QueryRowContext(ctx, query, fieldName)
+
+	var f model.MetaField
+	err := row.Scan(&f.FieldName, &f.Description, &f.FieldType, &f.Native, &f.Version)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get meta field: %w", err)
+	}
+	return &f, nil
+}
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE idempotency_keys (
+    user_id          TEXT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    idem_key         TEXT    NOT NULL,
+    method           TEXT    NOT NULL,
+    path             TEXT    NOT NULL,
+    request_hash     TEXT    NOT NULL,
+    status_code      INTEGER NOT NULL DEFAULT 0,
+    response_body    BYTEA   NOT NULL DEFAULT '',
+    response_headers TEXT    NOT NULL DEFAULT '{}',
+    created_at       BIGINT  NOT NULL,
+    expires_at       BIGINT  NOT NULL,
+    PRIMARY KEY (user_id, idem_key)
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS idempotency_keys;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE receipts ADD COLUMN search_vector TSVECTOR NOT NULL DEFAULT ''::tsvector;
+
+-- Only string values of string-typed extras are searchable, matching model.SearchableExtras.
+UPDATE receipts r SET search_vector =
+    setweight(to_tsvector('simple', r.product_name), 'A') ||
+    setweight(to_tsvector('simple', r.store_name), 'B') ||
+    setweight(to_tsvector('simple', COALESCE((
+        SELECT string_agg(j.value #>> '{}', ' ' ORDER BY j.key)
+        FROM jsonb_each(r.extras::jsonb) j
+        JOIN meta_fields m ON m.field_name = j.key AND m.field_type = 'string'
+        WHERE jsonb_typeof(j.value) = 'string'
+    ), '')), 'C');
+
+CREATE INDEX idx_receipts_search_vector ON receipts USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_search_vector;
+ALTER TABLE receipts DROP COLUMN search_vector;
@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_receipts_location ON receipts (latitude, longitude);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_location;
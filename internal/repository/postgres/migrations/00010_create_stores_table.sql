@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE stores (
+    id         TEXT    PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    chain      TEXT    NOT NULL DEFAULT '',
+    address    TEXT    NOT NULL DEFAULT '',
+    latitude   REAL,
+    longitude  REAL,
+    created_at BIGINT  NOT NULL
+);
+
+-- alias_key is model.NormalizeStoreName(alias). Every store also has its own
+-- name registered here, so resolving a receipt's store name is one lookup.
+CREATE TABLE store_aliases (
+    alias_key TEXT PRIMARY KEY,
+    alias     TEXT NOT NULL,
+    store_id  TEXT NOT NULL REFERENCES stores(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_store_aliases_store_id ON store_aliases (store_id);
+
+ALTER TABLE receipts ADD COLUMN store_id TEXT REFERENCES stores(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_receipts_store_id ON receipts (store_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_store_id;
+ALTER TABLE receipts DROP COLUMN store_id;
+DROP TABLE IF EXISTS store_aliases;
+DROP TABLE IF EXISTS stores;
@@ -0,0 +1,55 @@
+-- +goose Up
+CREATE TABLE categories (
+    id         TEXT    PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    parent_id  TEXT    REFERENCES categories(id),
+    created_at BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_categories_parent_id ON categories (parent_id);
+
+-- barcode is model.NormalizeBarcode(code); NULL when the product has none.
+CREATE TABLE products (
+    id          TEXT    PRIMARY KEY,
+    name        TEXT    NOT NULL,
+    barcode     TEXT    UNIQUE,
+    category_id TEXT    REFERENCES categories(id),
+    created_at  BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_products_category_id ON products (category_id);
+
+-- alias_key is model.NormalizeProductName(alias). Every product also has its
+-- own name registered here, so resolving a receipt's product name is one lookup.
+CREATE TABLE product_aliases (
+    alias_key  TEXT PRIMARY KEY,
+    alias      TEXT NOT NULL,
+    product_id TEXT NOT NULL REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_aliases_product_id ON product_aliases (product_id);
+
+ALTER TABLE receipts ADD COLUMN barcode TEXT;
+ALTER TABLE receipts ADD COLUMN product_id TEXT REFERENCES products(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_receipts_product_id ON receipts (product_id);
+
+-- barcode becomes a native field. A user-defined field of the same name is
+-- taken over; its values are normalized and moved from extras into the new
+-- column at startup, in Go (see backfillBarcodes).
+DELETE FROM meta_fields WHERE field_name = 'barcode';
+INSERT INTO meta_fields (field_name, description, field_type, native) VALUES
+    ('barcode', 'UPC/EAN barcode of the product, this field is optional', 'string', 1);
+
+-- +goose Down
+-- Barcodes go back into extras, under a user-defined field if any receipt has one.
+UPDATE receipts SET extras = jsonb_set(extras::jsonb, '{barcode}', to_jsonb(barcode))::text WHERE barcode IS NOT NULL;
+UPDATE meta_fields SET native = 0
+    WHERE field_name = 'barcode' AND EXISTS (SELECT 1 FROM receipts WHERE barcode IS NOT NULL);
+DELETE FROM meta_fields WHERE field_name = 'barcode' AND native = 1;
+DROP INDEX IF EXISTS idx_receipts_product_id;
+ALTER TABLE receipts DROP COLUMN product_id;
+ALTER TABLE receipts DROP COLUMN barcode;
+DROP TABLE IF EXISTS product_aliases;
+DROP TABLE IF EXISTS products;
+DROP TABLE IF EXISTS categories;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Numeric price, currency code and ISO purchase date, derived from the
+-- free-text price and purchase_date on every write so that spending can be
+-- summed in SQL. Existing rows are filled in at startup; a NULL currency
+-- marks a row that has not been processed yet.
+ALTER TABLE receipts ADD COLUMN price_value DOUBLE PRECISION;
+ALTER TABLE receipts ADD COLUMN currency TEXT;
+ALTER TABLE receipts ADD COLUMN purchased_on TEXT;
+CREATE INDEX idx_receipts_user_purchased_on ON receipts (user_id, purchased_on);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_user_purchased_on;
+ALTER TABLE receipts DROP COLUMN purchased_on;
+ALTER TABLE receipts DROP COLUMN currency;
+ALTER TABLE receipts DROP COLUMN price_value;
@@ -0,0 +1,42 @@
+-- +goose Up
+-- A watch on a catalog product keeps its product_name so that it falls back
+-- to matching by name if the product is deleted.
+CREATE TABLE watches (
+    id           TEXT    PRIMARY KEY,
+    user_id      TEXT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id   TEXT    REFERENCES products(id) ON DELETE SET NULL,
+    product_name TEXT    NOT NULL,
+    max_price    DOUBLE PRECISION NOT NULL,
+    currency     TEXT    NOT NULL,
+    unit         TEXT    NOT NULL,
+    created_at   BIGINT  NOT NULL
+);
+
+CREATE INDEX idx_watches_user_id ON watches (user_id);
+CREATE INDEX idx_watches_product_id ON watches (product_id);
+CREATE INDEX idx_watches_product_name ON watches (lower(product_name));
+
+-- delivered_at is set once the alert has been sent to the configured webhook.
+CREATE TABLE alerts (
+    id            BIGSERIAL PRIMARY KEY,
+    watch_id      TEXT    NOT NULL REFERENCES watches(id) ON DELETE CASCADE,
+    user_id       TEXT    NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    receipt_id    TEXT    NOT NULL REFERENCES receipts(id) ON DELETE CASCADE,
+    product_name  TEXT    NOT NULL,
+    store_name    TEXT    NOT NULL,
+    purchase_date TEXT    NOT NULL,
+    unit_price    DOUBLE PRECISION NOT NULL,
+    max_price     DOUBLE PRECISION NOT NULL,
+    currency      TEXT    NOT NULL,
+    unit          TEXT    NOT NULL,
+    read          BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at    BIGINT  NOT NULL,
+    delivered_at  BIGINT
+);
+
+CREATE INDEX idx_alerts_user_id ON alerts (user_id, created_at);
+CREATE INDEX idx_alerts_undelivered ON alerts (id) WHERE delivered_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS alerts;
+DROP TABLE IF EXISTS watches;
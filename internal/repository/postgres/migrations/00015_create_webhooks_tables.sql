@@ -0,0 +1,33 @@
+-- +goose Up
+-- events is a JSON array of event filters, see model.WebhookSubscription.
+CREATE TABLE webhook_subscriptions (
+    id         TEXT    PRIMARY KEY,
+    url        TEXT    NOT NULL,
+    events     TEXT    NOT NULL,
+    secret     TEXT    NOT NULL,
+    active     BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at BIGINT  NOT NULL
+);
+
+-- One row per event and subscription. Rows are written in the same
+-- transaction as the change they describe and double as the delivery log.
+CREATE TABLE webhook_deliveries (
+    id               BIGSERIAL PRIMARY KEY,
+    subscription_id  TEXT    NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_type       TEXT    NOT NULL,
+    payload          TEXT    NOT NULL,
+    status           TEXT    NOT NULL,
+    attempts         INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at  BIGINT  NOT NULL,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error       TEXT    NOT NULL DEFAULT '',
+    created_at       BIGINT  NOT NULL,
+    delivered_at     BIGINT
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Single row recording the newest event ID removed by compaction, so change
+-- feed readers asking for older sequence numbers can be told to resync.
+CREATE TABLE event_compaction (
+    id                INTEGER PRIMARY KEY CHECK (id = 1),
+    compacted_through BIGINT NOT NULL
+);
+
+INSERT INTO event_compaction (id, compacted_through) VALUES (1, 0);
+
+-- +goose Down
+DROP TABLE IF EXISTS event_compaction;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Refresh tokens are stored as hex SHA-256 hashes, so a leaked database
+-- holds no usable sessions. Family IDs of tokens issued before families
+-- existed were the token itself, so they are hashed too.
+UPDATE refresh_tokens SET
+    token = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    family_id = CASE WHEN family_id = token THEN encode(sha256(convert_to(token, 'UTF8')), 'hex') ELSE family_id END;
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+CREATE INDEX idx_refresh_tokens_expires_at ON refresh_tokens (expires_at);
+
+-- +goose Down
+-- Hashes cannot be reversed: every session is logged out.
+DROP INDEX IF EXISTS idx_refresh_tokens_expires_at;
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
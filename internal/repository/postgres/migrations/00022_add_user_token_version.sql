@@ -0,0 +1,7 @@
+-- +goose Up
+-- Access tokens carry the version current when they were issued. Bumping a
+-- user's version revokes all of their access tokens at once.
+ALTER TABLE users ADD COLUMN token_version BIGINT NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN token_version;
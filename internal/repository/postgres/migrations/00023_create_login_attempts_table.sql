@@ -0,0 +1,15 @@
+-- +goose Up
+-- Consecutive failed logins per username and per client IP, for lockout.
+-- subject is "username" or "ip"; subject_key is the username or IP address.
+CREATE TABLE login_attempts (
+    subject         TEXT    NOT NULL,
+    subject_key     TEXT    NOT NULL,
+    failures        BIGINT  NOT NULL DEFAULT 0,
+    last_failure_at BIGINT  NOT NULL,
+    PRIMARY KEY (subject, subject_key)
+);
+
+CREATE INDEX idx_login_attempts_last_failure_at ON login_attempts (last_failure_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS login_attempts;
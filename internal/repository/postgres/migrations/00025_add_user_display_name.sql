@@ -0,0 +1,6 @@
+-- +goose Up
+-- An optional name shown instead of the username. Empty means none.
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN display_name;
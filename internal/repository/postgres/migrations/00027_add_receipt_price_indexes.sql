@@ -0,0 +1,13 @@
+-- +goose Up
+-- Price queries select a product's receipts across all users within a
+-- purchase date range, so purchased_on joins the product indexes.
+DROP INDEX IF EXISTS idx_receipts_product_id;
+DROP INDEX IF EXISTS idx_receipts_product_name;
+CREATE INDEX idx_receipts_product_purchased_on ON receipts (product_id, purchased_on);
+CREATE INDEX idx_receipts_product_name_purchased_on ON receipts (lower(product_name), purchased_on);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_product_name_purchased_on;
+DROP INDEX IF EXISTS idx_receipts_product_purchased_on;
+CREATE INDEX idx_receipts_product_name ON receipts (lower(product_name));
+CREATE INDEX idx_receipts_product_id ON receipts (product_id);
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// keysetCondition returns the WHERE fragment selecting rows that come after
+// params.After in the (SortBy, idColumn) ordering, together with its arguments.
+// Placeholders are numbered from next. It returns an empty fragment for the first page.
+func keysetCondition(params model.CursorParams, idColumn string, next int) (string, []interface{}) {
+	if params.After == nil {
+		return "", nil
+	}
+	op := ">"
+	if params.SortOrder == "DESC" {
+		op = "<"
+	}
+	cond := fmt.Sprintf("(%s %s $%d OR (%s = $%d AND %s %s $%d))",
+		params.SortBy, op, next, params.SortBy, next, idColumn, op, next+1)
+	return cond, []interface{}{params.After.Value, params.After.ID}
+}
+
+// keysetOrder returns the ORDER BY clause matching keysetCondition.
+func keysetOrder(params model.CursorParams, idColumn string) string {
+	return fmt.Sprintf("ORDER BY %s %s, %s %s", params.SortBy, params.SortOrder, idColumn, params.SortOrder)
+}
+
+// trimCursorPage cuts a result fetched with LIMIT n+1 down to n rows and, if
+// there were more, fills in HasMore and the cursor pointing after the last row.
+func trimCursorPage[T any](page *model.CursorPage[T], rows []T, params model.CursorParams, position func(T) (interface{}, string)) {
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		value, id := position(rows[len(rows)-1])
+		page.HasMore = true
+		page.NextCursor = model.Cursor{
+			SortBy:    params.SortBy,
+			SortOrder: params.SortOrder,
+			Value:     value,
+			ID:        id,
+		}.Encode()
+	}
+	page.Data = rows
+}
@@ -31,6 +31,12 @@ func New(dsn string) (*DB, error) {
 	if err := db.migrate(); err != nil {
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
+	if err := db.backfillSpendColumns(); err != nil {
+		return nil, fmt.Errorf("spend columns: %w", err)
+	}
+	if err := db.backfillBarcodes(); err != nil {
+		return nil, fmt.Errorf("barcodes: %w", err)
+	}
 	return db, nil
 }
 
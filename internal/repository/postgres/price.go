@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// PriceRepo implements repository.PriceRepository backed by PostgreSQL.
+type PriceRepo struct {
+	db *DB
+}
+
+// NewPriceRepo creates a new PostgreSQL-backed price repository.
+func NewPriceRepo(db *DB) *PriceRepo {
+	return &PriceRepo{db: db}
+}
+
+func (r *PriceRepo) PriceHistory(ctx context.Context, filter model.PriceFilter, bucket string) (*model.PriceHistory, error) {
+	points, err := r.pricePoints(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &model.PriceHistory{
+		ProductID:   filter.ProductID,
+		ProductName: filter.ProductName,
+		Bucket:      bucket,
+		Currency:    filter.Currency,
+		Series:      model.BuildPriceHistory(points, bucket),
+	}, nil
+}
+
+func (r *PriceRepo) Deals(ctx context.Context, filter model.PriceFilter, near *model.GeoFilter) (*model.DealReport, error) {
+	points, err := r.pricePoints(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	// Medians cover the product's whole history, so the receipts before the
+	// window are loaded as well.
+	history := points
+	if !filter.From.IsZero() {
+		before := filter
+		before.From, before.To = time.Time{}, filter.From.AddDate(0, 0, -1)
+		earlier, err := r.pricePoints(ctx, before)
+		if err != nil {
+			return nil, err
+		}
+		history = append(earlier, points...)
+	}
+	report := &model.DealReport{
+		ProductID:   filter.ProductID,
+		ProductName: filter.ProductName,
+		CategoryID:  filter.CategoryID,
+		Currency:    filter.Currency,
+		Groups:      model.BuildDeals(points, history, near),
+	}
+	if !filter.From.IsZero() {
+		report.From = filter.From.Format("2006-01-02")
+	}
+	return report, nil
+}
+
+// pricePoints loads the unit prices of the receipts of the filter's product,
+// category or product name within its date range, converted into the
+// filter's currency if it has one. The range is applied to the purchased_on
+// column; prices are free text, so they are parsed here rather than in SQL.
+func (r *PriceRepo) pricePoints(ctx context.Context, filter model.PriceFilter) ([]model.PricePoint, error) {
+	var where, arg string
+	switch {
+	case filter.ProductID != "":
+		where, arg = "r.product_id = $1", filter.ProductID
+	case filter.CategoryID != "":
+		where, arg = "p.category_id IN ("+categorySubtreeSQL("$1")+")", filter.CategoryID
+	default:
+		where, arg = "lower(r.product_name) = lower($1)", filter.ProductName
+	}
+	args := []interface{}{arg}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.Format("2006-01-02"))
+		where += fmt.Sprintf(" AND r.purchased_on >= $%d", len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.Format("2006-01-02"))
+		where += fmt.Sprintf(" AND r.purchased_on <= $%d", len(args))
+	}
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT r.purchase_date, r.price, r.amount, r.store_name, r.store_id, r.product_id,
+			COALESCE(p.name, r.product_name), s.latitude, s.longitude, r.latitude, r.longitude
+		FROM receipts r
+		LEFT JOIN products p ON p.id = r.product_id
+		LEFT JOIN stores s ON s.id = r.store_id
+		WHERE `+where, args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var points []model.PricePoint
+	for rows.Next() {
+		var date, price, amount, storeName, productName string
+		var storeID, productID sql.NullString
+		var storeLat, storeLng, receiptLat, receiptLng sql.NullFloat64
+		if err := rows.Scan(&date, &price, &amount, &storeName, &storeID, &productID,
+			&productName, &storeLat, &storeLng, &receiptLat, &receiptLng); err != nil {
+			return nil, fmt.Errorf("scan price: %w", err)
+		}
+		point, ok := model.NewPricePoint(date, price, amount)
+		if !ok {
+			continue
+		}
+		point.ProductID, point.ProductName = productID.String, productName
+		point.StoreID, point.StoreName = storeID.String, storeName
+		point.Location = pointLocation(storeLat, storeLng, receiptLat, receiptLng)
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if filter.Currency != "" {
+		table, err := loadRateTable(ctx, r.db.conn, filter.Currency)
+		if err != nil {
+			return nil, err
+		}
+		model.ConvertPoints(points, table)
+	}
+	return points, nil
+}
+
+// pointLocation prefers the registered store's coordinates over the
+// receipt's, and returns nil when neither pair is complete.
+func pointLocation(storeLat, storeLng, receiptLat, receiptLng sql.NullFloat64) *model.GeoPoint {
+	if storeLat.Valid && storeLng.Valid {
+		return &model.GeoPoint{Lat: storeLat.Float64, Lng: storeLng.Float64}
+	}
+	if receiptLat.Valid && receiptLng.Valid {
+		return &model.GeoPoint{Lat: receiptLat.Float64, Lng: receiptLng.Float64}
+	}
+	return nil
+}
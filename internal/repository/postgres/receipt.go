@@ -11,7 +11,7 @@ import (
 	"github.com/gatheryourdeals/data/internal/model"
 )
 
-const receiptColumns = "id, product_name, purchase_date, price, amount, store_name, latitude, longitude, extras, upload_time, user_id"
+const receiptColumns = "id, product_name, purchase_date, price, amount, store_name, latitude, longitude, extras, upload_time, user_id, version, store_id, barcode, product_id"
 
 // ReceiptRepo implements repository.ReceiptRepository backed by PostgreSQL.
 type ReceiptRepo struct {
@@ -26,7 +26,8 @@ func NewReceiptRepo(db *DB, meta *MetaFieldRepo) *ReceiptRepo {
 }
 
 func (r *ReceiptRepo) CreateReceipt(ctx context.Context, receipt *model.Receipt) error {
-	if err := r.validateExtras(ctx, receipt.Extras); err != nil {
+	fields, err := r.validateExtras(ctx, receipt.Extras)
+	if err != nil {
 		return err
 	}
 
@@ -39,9 +40,26 @@ func (r *ReceiptRepo) CreateReceipt(ctx context.Context, receipt *model.Receipt)
 	}
 
 	receipt.UploadTime = time.Now().Unix()
+	receipt.Version = 1
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
 
-	query := `INSERT INTO receipts (` + receiptColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
-	_, err = r.db.conn.ExecContext(ctx, query,
+	if receipt.StoreID, err = resolveStoreID(ctx, tx, receipt.StoreName); err != nil {
+		return err
+	}
+	if receipt.ProductID, err = resolveProductID(ctx, tx, receipt.ProductName, receipt.Barcode); err != nil {
+		return err
+	}
+
+	priceValue, currency, purchasedOn := spendValues(receipt.Price, receipt.PurchaseDate)
+	query := `INSERT INTO receipts (` + receiptColumns + `, price_value, currency, purchased_on, search_vector)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $17, $18, $19, ` +
+		searchVectorSQL("$2", "$6", "$16") + `)`
+	_, err = tx.ExecContext(ctx, query,
 		receipt.ID,
 		receipt.ProductName,
 		receipt.PurchaseDate,
@@ -53,10 +71,27 @@ func (r *ReceiptRepo) CreateReceipt(ctx context.Context, receipt *model.Receipt)
 		string(extrasJSON),
 		receipt.UploadTime,
 		receipt.UserID,
+		receipt.Version,
+		nullString(receipt.StoreID),
+		nullString(receipt.Barcode),
+		nullString(receipt.ProductID),
+		model.SearchableExtras(receipt.Extras, fields),
+		priceValue,
+		currency,
+		purchasedOn,
 	)
 	if err != nil {
 		return fmt.Errorf("create receipt: %w", err)
 	}
+	if err := triggerWatches(ctx, tx, receipt); err != nil {
+		return err
+	}
+	if err := publishEvent(ctx, tx, model.Event{Type: model.EventReceiptCreated, UserID: receipt.UserID, Data: receipt}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit receipt: %w", err)
+	}
 	return nil
 }
 
@@ -67,10 +102,17 @@ func (r *ReceiptRepo) GetReceiptByID(ctx context.Context, id string) (*model.Rec
 }
 
 func (r *ReceiptRepo) ListReceiptsByUser(ctx context.Context, userID string, params model.PaginationParams) (*model.Page[*model.Receipt], error) {
+	where := "user_id = $1"
+	args := []interface{}{userID}
+	if cond, condArgs := geoCondition(params.Geo, 2); cond != "" {
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
 	// Count total matching records.
 	var total int
 	if err := r.db.conn.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM receipts WHERE user_id = $1`, userID,
+		`SELECT COUNT(*) FROM receipts WHERE `+where, args...,
 	).Scan(&total); err != nil {
 		return nil, fmt.Errorf("count receipts: %w", err)
 	}
@@ -91,11 +133,14 @@ func (r *ReceiptRepo) ListReceiptsByUser(ctx context.Context, userID string, par
 	}
 
 	// Fetch paginated data. SortBy and SortOrder are validated by the handler.
+	distance, distanceArgs := distanceColumn(params.Geo, len(args)+1)
+	args = append(args, distanceArgs...)
 	query := fmt.Sprintf(
-		`SELECT `+receiptColumns+` FROM receipts WHERE user_id = $1 ORDER BY %s %s LIMIT $2 OFFSET $3`,
-		params.SortBy, params.SortOrder,
+		`SELECT `+receiptColumns+`, `+distance+` FROM receipts WHERE `+where+` `+receiptOrder(params)+` LIMIT $%d OFFSET $%d`,
+		len(args)+1, len(args)+2,
 	)
-	rows, err := r.db.conn.QueryContext(ctx, query, userID, params.Limit, params.Offset)
+	args = append(args, params.Limit, params.Offset)
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list receipts: %w", err)
 	}
@@ -103,10 +148,14 @@ func (r *ReceiptRepo) ListReceiptsByUser(ctx context.Context, userID string, par
 
 	var receipts []*model.Receipt
 	for rows.Next() {
-		rec, err := r.scanReceiptRow(rows)
+		var distanceKm sql.NullFloat64
+		rec, err := r.scanReceiptRow(rows, &distanceKm)
 		if err != nil {
 			return nil, err
 		}
+		if distanceKm.Valid {
+			rec.DistanceKm = &distanceKm.Float64
+		}
 		receipts = append(receipts, rec)
 	}
 	if err := rows.Err(); err != nil {
@@ -119,45 +168,234 @@ func (r *ReceiptRepo) ListReceiptsByUser(ctx context.Context, userID string, par
 	return page, nil
 }
 
-func (r *ReceiptRepo) DeleteReceipt(ctx context.Context, id string) error {
-	_, err := r.db.conn.ExecContext(ctx, `DELETE FROM receipts WHERE id = $1`, id)
+func (r *ReceiptRepo) ListReceiptsByUserCursor(ctx context.Context, userID string, params model.CursorParams) (*model.CursorPage[*model.Receipt], error) {
+	page := &model.CursorPage[*model.Receipt]{Data: []*model.Receipt{}, Limit: params.Limit}
+	if params.IncludeTotal {
+		var total int
+		if err := r.db.conn.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM receipts WHERE user_id = $1`, userID,
+		).Scan(&total); err != nil {
+			return nil, fmt.Errorf("count receipts: %w", err)
+		}
+		page.Total = &total
+	}
+
+	where := "user_id = $1"
+	args := []interface{}{userID}
+	if cond, condArgs := keysetCondition(params, "id", 2); cond != "" {
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	// Fetch one extra row to learn whether another page follows.
+	query := `SELECT ` + receiptColumns + ` FROM receipts WHERE ` + where + ` ` + keysetOrder(params, "id") + fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list receipts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var receipts []*model.Receipt
+	for rows.Next() {
+		rec, err := r.scanReceiptRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if receipts == nil {
+		receipts = []*model.Receipt{}
+	}
+	trimCursorPage(page, receipts, params, func(rec *model.Receipt) (interface{}, string) {
+		return receiptSortValue(rec, params.SortBy), rec.ID
+	})
+	return page, nil
+}
+
+func (r *ReceiptRepo) UpdateReceipt(ctx context.Context, receipt *model.Receipt, expectedVersion int64) error {
+	fields, err := r.validateExtras(ctx, receipt.Extras)
+	if err != nil {
+		return err
+	}
+
+	extrasJSON, err := json.Marshal(receipt.Extras)
+	if err != nil {
+		return fmt.Errorf("marshal extras: %w", err)
+	}
+	if receipt.Extras == nil {
+		extrasJSON = []byte("{}")
+	}
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	storeID, err := resolveStoreID(ctx, tx, receipt.StoreName)
+	if err != nil {
+		return err
+	}
+	productID, err := resolveProductID(ctx, tx, receipt.ProductName, receipt.Barcode)
+	if err != nil {
+		return err
+	}
+
+	priceValue, currency, purchasedOn := spendValues(receipt.Price, receipt.PurchaseDate)
+	query := `UPDATE receipts SET product_name = $1, purchase_date = $2, price = $3, amount = $4,
+		store_name = $5, latitude = $6, longitude = $7, extras = $8, store_id = $11,
+		barcode = $12, product_id = $13, price_value = $14, currency = $15, purchased_on = $16,
+		version = version + 1,
+		search_vector = ` + searchVectorSQL("$1", "$5", "$10") + `
+		WHERE id = $9`
+	args := []interface{}{
+		receipt.ProductName,
+		receipt.PurchaseDate,
+		receipt.Price,
+		receipt.Amount,
+		receipt.StoreName,
+		receipt.Latitude,
+		receipt.Longitude,
+		string(extrasJSON),
+		receipt.ID,
+		model.SearchableExtras(receipt.Extras, fields),
+		nullString(storeID),
+		nullString(receipt.Barcode),
+		nullString(productID),
+		priceValue,
+		currency,
+		purchasedOn,
+	}
+	if expectedVersion != 0 {
+		query += ` AND version = $17`
+		args = append(args, expectedVersion)
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("update receipt: %w", err)
+	}
+	if err := r.checkVersionedWrite(ctx, result, receipt.ID, expectedVersion); err != nil {
+		return err
+	}
+
+	updated, err := r.scanReceipt(tx.QueryRowContext(ctx, `SELECT `+receiptColumns+` FROM receipts WHERE id = $1`, receipt.ID))
+	if err != nil {
+		return err
+	}
+	if updated == nil {
+		return fmt.Errorf("%w: %q", model.ErrReceiptNotFound, receipt.ID)
+	}
+	if err := publishEvent(ctx, tx, model.Event{Type: model.EventReceiptUpdated, UserID: updated.UserID, Data: updated}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit receipt: %w", err)
+	}
+	*receipt = *updated
+	return nil
+}
+
+func (r *ReceiptRepo) DeleteReceipt(ctx context.Context, id string, expectedVersion int64) error {
+	query := `DELETE FROM receipts WHERE id = $1`
+	args := []interface{}{id}
+	if expectedVersion != 0 {
+		query += ` AND version = $2`
+		args = append(args, expectedVersion)
+	}
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var userID string
+	err = tx.QueryRowContext(ctx, `SELECT user_id FROM receipts WHERE id = $1`, id).Scan(&userID)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("get receipt owner: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("delete receipt: %w", err)
 	}
+	if expectedVersion != 0 {
+		if err := r.checkVersionedWrite(ctx, result, id, expectedVersion); err != nil {
+			return err
+		}
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	} else if n > 0 {
+		event := model.Event{Type: model.EventReceiptDeleted, UserID: userID, Data: model.DeletedReceipt{ID: id, UserID: userID}}
+		if err := publishEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit receipt: %w", err)
+	}
 	return nil
 }
 
-// validateExtras checks that every key in the extras map is registered in the meta table.
-func (r *ReceiptRepo) validateExtras(ctx context.Context, extras map[string]interface{}) error {
-	if len(extras) == 0 {
+// checkVersionedWrite turns a write that matched no rows into the right error:
+// ErrReceiptNotFound when the receipt is gone, ErrVersionMismatch when it exists
+// at a different version than the caller expected.
+func (r *ReceiptRepo) checkVersionedWrite(ctx context.Context, result sql.Result, id string, expectedVersion int64) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if rows > 0 {
 		return nil
 	}
+	if expectedVersion != 0 {
+		current, err := r.GetReceiptByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			return fmt.Errorf("%w: receipt %q is at version %d", model.ErrVersionMismatch, id, current.Version)
+		}
+	}
+	return fmt.Errorf("%w: %q", model.ErrReceiptNotFound, id)
+}
+
+// validateExtras checks that every key in the extras map is registered in the meta table.
+// It returns the meta field definitions of the extras, keyed by field name.
+func (r *ReceiptRepo) validateExtras(ctx context.Context, extras map[string]interface{}) (map[string]*model.MetaField, error) {
+	fields := make(map[string]*model.MetaField, len(extras))
 	for key := range extras {
 		field, err := r.meta.GetField(ctx, key)
 		if err != nil {
-			return fmt.Errorf("validate extras: %w", err)
+			return nil, fmt.Errorf("validate extras: %w", err)
 		}
 		if field == nil {
 			slog.Warn("receipt rejected: unregistered field in extras", "field", key)
-			return fmt.Errorf("%w: %q", model.ErrFieldNotRegistered, key)
+			return nil, fmt.Errorf("%w: %q", model.ErrFieldNotRegistered, key)
 		}
 		if field.Native {
 			slog.Warn("receipt rejected: native field used in extras", "field", key)
-			return fmt.Errorf("%w: %q is a native field and cannot be used in extras", model.ErrFieldNotRegistered, key)
+			return nil, fmt.Errorf("%w: %q is a native field and cannot be used in extras", model.ErrFieldNotRegistered, key)
 		}
+		fields[key] = field
 	}
-	return nil
+	return fields, nil
 }
 
 // scanReceipt scans a single receipt from a QueryRow result.
 func (r *ReceiptRepo) scanReceipt(row *sql.Row) (*model.Receipt, error) {
 	var rec model.Receipt
 	var extrasStr string
+	var storeID, barcode, productID sql.NullString
 	err := row.Scan(
 		&rec.ID, &rec.ProductName, &rec.PurchaseDate,
 		&rec.Price, &rec.Amount, &rec.StoreName,
 		&rec.Latitude, &rec.Longitude, &extrasStr,
-		&rec.UploadTime, &rec.UserID,
+		&rec.UploadTime, &rec.UserID, &rec.Version, &storeID, &barcode, &productID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -168,24 +406,45 @@ func (r *ReceiptRepo) scanReceipt(row *sql.Row) (*model.Receipt, error) {
 	if err := json.Unmarshal([]byte(extrasStr), &rec.Extras); err != nil {
 		return nil, fmt.Errorf("unmarshal extras: %w", err)
 	}
+	rec.StoreID, rec.Barcode, rec.ProductID = storeID.String, barcode.String, productID.String
 	return &rec, nil
 }
 
 // scanReceiptRow scans a single receipt from a multi-row result set.
-func (r *ReceiptRepo) scanReceiptRow(rows *sql.Rows) (*model.Receipt, error) {
+// Any columns selected after the receipt columns are scanned into extra.
+func (r *ReceiptRepo) scanReceiptRow(rows *sql.Rows, extra ...interface{}) (*model.Receipt, error) {
 	var rec model.Receipt
 	var extrasStr string
-	err := rows.Scan(
+	var storeID, barcode, productID sql.NullString
+	dest := []interface{}{
 		&rec.ID, &rec.ProductName, &rec.PurchaseDate,
 		&rec.Price, &rec.Amount, &rec.StoreName,
 		&rec.Latitude, &rec.Longitude, &extrasStr,
-		&rec.UploadTime, &rec.UserID,
-	)
+		&rec.UploadTime, &rec.UserID, &rec.Version, &storeID, &barcode, &productID,
+	}
+	err := rows.Scan(append(dest, extra...)...)
 	if err != nil {
 		return nil, fmt.Errorf("scan receipt row: %w", err)
 	}
 	if err := json.Unmarshal([]byte(extrasStr), &rec.Extras); err != nil {
 		return nil, fmt.Errorf("unmarshal extras: %w", err)
 	}
+	rec.StoreID, rec.Barcode, rec.ProductID = storeID.String, barcode.String, productID.String
 	return &rec, nil
 }
+
+// receiptSortValue returns the value of the given sort column for a receipt.
+func receiptSortValue(rec *model.Receipt, column string) interface{} {
+	switch column {
+	case "purchase_date":
+		return rec.PurchaseDate
+	case "price":
+		return rec.Price
+	case "store_name":
+		return rec.StoreName
+	case "product_name":
+		return rec.ProductName
+	default: // "upload_time"
+		return rec.UploadTime
+	}
+}
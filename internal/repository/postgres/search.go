@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// searchVectorSQL returns the SQL expression that builds receipts.search_vector
+// from placeholders holding the product name, store name and searchable extras.
+// Product names weigh most, then store names, then extras. The 'simple'
+// configuration lowercases without stemming, mirroring the SQLite index.
+func searchVectorSQL(product, store, extras string) string {
+	return `setweight(to_tsvector('simple', ` + product + `::text), 'A') || ` +
+		`setweight(to_tsvector('simple', ` + store + `::text), 'B') || ` +
+		`setweight(to_tsvector('simple', ` + extras + `::text), 'C')`
+}
+
+func (r *ReceiptRepo) SearchReceipts(ctx context.Context, userID string, query *model.SearchQuery, params model.PaginationParams) (*model.Page[*model.SearchHit], error) {
+	where := "user_id = $1 AND search_vector @@ to_tsquery('simple', $2)"
+	args := []interface{}{userID, tsqueryExpression(query)}
+	if cond, condArgs := geoCondition(params.Geo, 3); cond != "" {
+		where += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM receipts WHERE `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count search results: %w", err)
+	}
+
+	page := &model.Page[*model.SearchHit]{
+		Data:   []*model.SearchHit{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	order := "score DESC, upload_time DESC"
+	if params.SortBy == model.SortByDistance {
+		order = "distance_km " + params.SortOrder + " NULLS LAST, " + order
+	}
+	distance, distanceArgs := distanceColumn(params.Geo, len(args)+1)
+	args = append(args, distanceArgs...)
+	rows, err := r.db.conn.QueryContext(ctx, fmt.Sprintf(
+		`SELECT `+receiptColumns+`, `+distance+`, ts_rank_cd(search_vector, to_tsquery('simple', $2)) AS score
+		FROM receipts
+		WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2),
+		append(args, params.Limit, params.Offset)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search receipts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var distanceKm sql.NullFloat64
+		var score float64
+		rec, err := r.scanReceiptRow(rows, &distanceKm, &score)
+		if err != nil {
+			return nil, err
+		}
+		if distanceKm.Valid {
+			rec.DistanceKm = &distanceKm.Float64
+		}
+		page.Data = append(page.Data, &model.SearchHit{Receipt: rec, Score: score})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// tsqueryExpression renders a parsed query for to_tsquery. Words are letters
+// and digits only (see model.ParseSearchQuery), so they need no quoting.
+// Phrase words are joined with <-> (followed by), prefixes get :*, and terms
+// are ANDed.
+func tsqueryExpression(query *model.SearchQuery) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		expr := strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			expr += ":*"
+		}
+		if len(term.Words) > 1 {
+			expr = "(" + expr + ")"
+		}
+		terms[i] = expr
+	}
+	return strings.Join(terms, " & ")
+}
@@ -0,0 +1,363 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const storeColumns = "id, name, chain, address, latitude, longitude, created_at"
+
+// StoreRepo implements repository.StoreRepository backed by PostgreSQL.
+type StoreRepo struct {
+	db *DB
+}
+
+// NewStoreRepo creates a new PostgreSQL-backed store repository.
+func NewStoreRepo(db *DB) *StoreRepo {
+	return &StoreRepo{db: db}
+}
+
+func (r *StoreRepo) CreateStore(ctx context.Context, store *model.Store) error {
+	store.CreatedAt = time.Now().Unix()
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO stores (`+storeColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		store.ID, store.Name, store.Chain, store.Address, store.Latitude, store.Longitude, store.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create store: %w", err)
+	}
+	if err := r.saveAliases(ctx, tx, store); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit store: %w", err)
+	}
+	return nil
+}
+
+func (r *StoreRepo) GetStore(ctx context.Context, id string) (*model.Store, error) {
+	row := r.db.conn.QueryRowContext(ctx, `SELECT `+storeColumns+` FROM stores WHERE id = $1`, id)
+	store, err := scanStore(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get store: %w", err)
+	}
+	if err := r.loadAliases(ctx, []*model.Store{store}); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (r *StoreRepo) ResolveStore(ctx context.Context, name string) (*model.Store, error) {
+	id, err := resolveStoreID(ctx, r.db.conn, name)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return r.GetStore(ctx, id)
+}
+
+func (r *StoreRepo) ListStores(ctx context.Context, params model.PaginationParams) (*model.Page[*model.Store], error) {
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM stores`).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count stores: %w", err)
+	}
+
+	page := &model.Page[*model.Store]{
+		Data:   []*model.Store{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	// Fetch paginated data. SortBy and SortOrder are validated by the handler.
+	query := fmt.Sprintf(
+		`SELECT `+storeColumns+` FROM stores ORDER BY %s %s LIMIT $1 OFFSET $2`,
+		params.SortBy, params.SortOrder,
+	)
+	rows, err := r.db.conn.QueryContext(ctx, query, params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("list stores: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var stores []*model.Store
+	for rows.Next() {
+		store, err := scanStore(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan store: %w", err)
+		}
+		stores = append(stores, store)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadAliases(ctx, stores); err != nil {
+		return nil, err
+	}
+	if stores != nil {
+		page.Data = stores
+	}
+	return page, nil
+}
+
+func (r *StoreRepo) UpdateStore(ctx context.Context, store *model.Store) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE stores SET name = $1, chain = $2, address = $3, latitude = $4, longitude = $5 WHERE id = $6`,
+		store.Name, store.Chain, store.Address, store.Latitude, store.Longitude, store.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update store: %w", err)
+	}
+	if err := expectRow(result, model.ErrStoreNotFound, store.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM store_aliases WHERE store_id = $1`, store.ID); err != nil {
+		return fmt.Errorf("clear store aliases: %w", err)
+	}
+	if err := r.saveAliases(ctx, tx, store); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT created_at FROM stores WHERE id = $1`, store.ID).Scan(&store.CreatedAt); err != nil {
+		return fmt.Errorf("reload store: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit store: %w", err)
+	}
+	return nil
+}
+
+func (r *StoreRepo) DeleteStore(ctx context.Context, id string) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Receipts keep their free-text store name; only the link goes. The
+	// foreign key would do this too, but SQLite has none, so both backends
+	// unlink explicitly.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM store_aliases WHERE store_id = $1`, id); err != nil {
+		return fmt.Errorf("delete store aliases: %w", err)
+	}
+	if err := r.relinkReceipts(ctx, tx, "store_id = $1", id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM stores WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete store: %w", err)
+	}
+	if err := expectRow(result, model.ErrStoreNotFound, id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit store: %w", err)
+	}
+	return nil
+}
+
+// saveAliases registers the store's name and aliases, then links unlinked
+// receipts whose store name matches one of them and unlinks the store's
+// receipts whose alias is gone. Receipts linked to another store keep that
+// link.
+func (r *StoreRepo) saveAliases(ctx context.Context, tx *sql.Tx, store *model.Store) error {
+	keys := map[string]bool{}
+	for _, alias := range append([]string{store.Name}, store.Aliases...) {
+		key := model.NormalizeStoreName(alias)
+		if key == "" || keys[key] {
+			continue
+		}
+		keys[key] = true
+
+		var owner string
+		err := tx.QueryRowContext(ctx, `SELECT store_id FROM store_aliases WHERE alias_key = $1`, key).Scan(&owner)
+		if err == nil {
+			return fmt.Errorf("%w: %q", model.ErrStoreAliasTaken, alias)
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("check store alias: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO store_aliases (alias_key, alias, store_id) VALUES ($1, $2, $3)`, key, alias, store.ID,
+		); err != nil {
+			return fmt.Errorf("save store alias: %w", err)
+		}
+	}
+
+	// Only receipts the alias change can affect are relinked: unlinked ones
+	// matching a saved alias, and this store's ones matching none any more.
+	placeholders := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys)+1)
+	for key := range keys {
+		args = append(args, key)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	args = append(args, store.ID)
+	in := storeKeySQL("store_name") + " IN (" + strings.Join(placeholders, ", ") + ")"
+	return r.relinkReceipts(ctx, tx,
+		fmt.Sprintf("(store_id IS NULL AND %s) OR (store_id = $%d AND NOT %s)", in, len(args), in), args...,
+	)
+}
+
+// storeKeySQL returns the SQL expression for model.NormalizeStoreName of the
+// given column.
+func storeKeySQL(column string) string {
+	return "btrim(regexp_replace(lower(" + column + "), '[^[:alnum:]]+', ' ', 'g'))"
+}
+
+// relinkReceipts resolves the store of the receipts matching cond again
+// after the alias table changed. A receipt whose link changes gets a version
+// bump and a receipt.updated event, like any other edit.
+func (r *StoreRepo) relinkReceipts(ctx context.Context, tx *sql.Tx, cond string, args ...interface{}) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, store_name, COALESCE(store_id, '') FROM receipts WHERE `+cond, args...)
+	if err != nil {
+		return fmt.Errorf("find receipts to relink: %w", err)
+	}
+	type link struct{ id, name, storeID string }
+	var links []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.id, &l.name, &l.storeID); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt link: %w", err)
+		}
+		links = append(links, l)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	receipts := &ReceiptRepo{db: r.db}
+	for _, l := range links {
+		storeID, err := resolveStoreID(ctx, tx, l.name)
+		if err != nil {
+			return err
+		}
+		if storeID == l.storeID {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE receipts SET store_id = $1, version = version + 1 WHERE id = $2`, nullString(storeID), l.id,
+		); err != nil {
+			return fmt.Errorf("link receipt: %w", err)
+		}
+		updated, err := receipts.scanReceipt(tx.QueryRowContext(ctx, `SELECT `+receiptColumns+` FROM receipts WHERE id = $1`, l.id))
+		if err != nil {
+			return err
+		}
+		if err := publishEvent(ctx, tx, model.Event{Type: model.EventReceiptUpdated, UserID: updated.UserID, Data: updated}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAliases fills in the aliases of the given stores, leaving out each
+// store's own name.
+func (r *StoreRepo) loadAliases(ctx context.Context, stores []*model.Store) error {
+	if len(stores) == 0 {
+		return nil
+	}
+	byID := make(map[string]*model.Store, len(stores))
+	placeholders := make([]string, len(stores))
+	args := make([]interface{}, len(stores))
+	for i, s := range stores {
+		s.Aliases = []string{}
+		byID[s.ID] = s
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = s.ID
+	}
+
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT store_id, alias_key, alias FROM store_aliases WHERE store_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY alias`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("load store aliases: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var storeID, key, alias string
+		if err := rows.Scan(&storeID, &key, &alias); err != nil {
+			return fmt.Errorf("scan store alias: %w", err)
+		}
+		if s := byID[storeID]; s != nil && key != model.NormalizeStoreName(s.Name) {
+			s.Aliases = append(s.Aliases, alias)
+		}
+	}
+	return rows.Err()
+}
+
+// expectRow returns notFound when a write matched no row.
+func expectRow(result sql.Result, notFound error, id string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: %q", notFound, id)
+	}
+	return nil
+}
+
+// scanStore scans a store row from either *sql.Row or *sql.Rows.
+func scanStore(row interface{ Scan(...interface{}) error }) (*model.Store, error) {
+	var s model.Store
+	if err := row.Scan(&s.ID, &s.Name, &s.Chain, &s.Address, &s.Latitude, &s.Longitude, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// resolveStoreID returns the ID of the store a free-text store name resolves
+// to through the alias table, or "" if it matches no store.
+func resolveStoreID(ctx context.Context, q rowQuerier, storeName string) (string, error) {
+	var id string
+	err := q.QueryRowContext(ctx,
+		`SELECT store_id FROM store_aliases WHERE alias_key = $1`, model.NormalizeStoreName(storeName),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve store: %w", err)
+	}
+	return id, nil
+}
+
+// nullString maps "" to SQL NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
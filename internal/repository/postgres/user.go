@@ -9,7 +9,7 @@ import (
 	"github.com/gatheryourdeals/data/internal/model"
 )
 
-const userColumns = "id, username, password_hash, role, created_at, updated_at"
+const userColumns = "id, username, password_hash, role, created_at, updated_at, token_version, display_name"
 
 // UserRepo implements repository.UserRepository backed by PostgreSQL.
 type UserRepo struct {
@@ -22,12 +22,12 @@ func NewUserRepo(db *DB) *UserRepo {
 }
 
 func (r *UserRepo) CreateUser(ctx context.Context, user *model.User) error {
-	query := `INSERT INTO users (` + userColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+	query := `INSERT INTO users (` + userColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
 	now := time.Now().Unix()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 	_, err := r.db.conn.ExecContext(ctx, query,
-		user.ID, user.Username, user.PasswordHash, string(user.Role), now, now)
+		user.ID, user.Username, user.PasswordHash, string(user.Role), now, now, user.TokenVersion, user.DisplayName)
 	if err != nil {
 		return fmt.Errorf("create user: %w", err)
 	}
@@ -51,6 +51,44 @@ func (r *UserRepo) UpdatePassword(ctx context.Context, id string, passwordHash s
 	return nil
 }
 
+func (r *UserRepo) UpdateRole(ctx context.Context, id string, role model.Role) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE users SET role = $1, updated_at = $2 WHERE id = $3`, string(role), time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("update role: %w", err)
+	}
+	return expectRow(result, model.ErrUserNotFound, id)
+}
+
+func (r *UserRepo) UpdateProfile(ctx context.Context, id, username, displayName string) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE users SET username = $1, display_name = $2, updated_at = $3 WHERE id = $4`, username, displayName, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("update profile: %w", err)
+	}
+	return expectRow(result, model.ErrUserNotFound, id)
+}
+
+func (r *UserRepo) TokenVersion(ctx context.Context, id string) (int64, error) {
+	var version int64
+	err := r.db.conn.QueryRowContext(ctx, `SELECT token_version FROM users WHERE id = $1`, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %q", model.ErrUserNotFound, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get token version: %w", err)
+	}
+	return version, nil
+}
+
+func (r *UserRepo) BumpTokenVersion(ctx context.Context, id string) error {
+	_, err := r.db.conn.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("bump token version: %w", err)
+	}
+	return nil
+}
+
 func (r *UserRepo) ListUsers(ctx context.Context, params model.PaginationParams) (*model.Page[*model.User], error) {
 	// Count total users.
 	var total int
@@ -102,11 +140,99 @@ func (r *UserRepo) ListUsers(ctx context.Context, params model.PaginationParams)
 	return page, nil
 }
 
+func (r *UserRepo) ListUsersCursor(ctx context.Context, params model.CursorParams) (*model.CursorPage[*model.User], error) {
+	page := &model.CursorPage[*model.User]{Data: []*model.User{}, Limit: params.Limit}
+	if params.IncludeTotal {
+		var total int
+		if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+			return nil, fmt.Errorf("count users: %w", err)
+		}
+		page.Total = &total
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	cond, args := keysetCondition(params, "id", 1)
+	if cond != "" {
+		query += ` WHERE ` + cond
+	}
+	// Fetch one extra row to learn whether another page follows.
+	query += ` ` + keysetOrder(params, "id") + fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+	args = append(args, params.Limit+1)
+
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []*model.User
+	for rows.Next() {
+		u, err := scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*model.User{}
+	}
+	trimCursorPage(page, users, params, func(u *model.User) (interface{}, string) {
+		return userSortValue(u, params.SortBy), u.ID
+	})
+	return page, nil
+}
+
 func (r *UserRepo) DeleteUser(ctx context.Context, id string) error {
-	_, err := r.db.conn.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	tx, err := r.db.conn.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The user's receipts go with ON DELETE CASCADE, but the change feed and
+	// webhooks must still hear of each one.
+	if err := publishReceiptDeletions(ctx, tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id); err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete user: %w", err)
+	}
+	return nil
+}
+
+// publishReceiptDeletions publishes a receipt.deleted event for each of a
+// user's receipts, before they are removed together with the user.
+func publishReceiptDeletions(ctx context.Context, tx *sql.Tx, userID string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM receipts WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return fmt.Errorf("list user receipts: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list user receipts: %w", err)
+	}
+
+	for _, id := range ids {
+		event := model.Event{Type: model.EventReceiptDeleted, UserID: userID, Data: model.DeletedReceipt{ID: id, UserID: userID}}
+		if err := publishEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -124,7 +250,7 @@ func (r *UserRepo) scanUser(ctx context.Context, query string, args ...interface
 	row := r.db.conn.QueryRowContext(ctx, query, args...)
 	var u model.User
 	var role string
-	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt)
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt, &u.TokenVersion, &u.DisplayName)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -139,10 +265,22 @@ func (r *UserRepo) scanUser(ctx context.Context, query string, args ...interface
 func scanRow(rows *sql.Rows) (*model.User, error) {
 	var u model.User
 	var role string
-	err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt)
+	err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt, &u.TokenVersion, &u.DisplayName)
 	if err != nil {
 		return nil, fmt.Errorf("scan row: %w", err)
 	}
 	u.Role = model.Role(role)
 	return &u, nil
 }
+
+// userSortValue returns the value of the given sort column for a user.
+func userSortValue(u *model.User, column string) interface{} {
+	switch column {
+	case "username":
+		return u.Username
+	case "role":
+		return string(u.Role)
+	default: // "created_at"
+		return u.CreatedAt
+	}
+}
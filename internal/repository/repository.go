@@ -17,13 +17,19 @@ type MetaFieldRepository interface {
 	// ListFields returns a paginated list of registered fields (native + user-defined).
 	ListFields(ctx context.Context, params model.PaginationParams) (*model.Page[*model.MetaField], error)
 
-	// UpdateDescription updates the description of an existing field.
-	UpdateDescription(ctx context.Context, fieldName string, description string) error
+	// ListFieldsCursor returns a page of registered fields using keyset pagination.
+	ListFieldsCursor(ctx context.Context, params model.CursorParams) (*model.CursorPage[*model.MetaField], error)
+
+	// UpdateDescription updates the description of an existing field and bumps its version.
+	// If expectedVersion is non-zero the update only applies when it matches the
+	// stored version; otherwise model.ErrVersionMismatch is returned.
+	UpdateDescription(ctx context.Context, fieldName string, description string, expectedVersion int64) error
 }
 
 // ReceiptRepository defines the storage operations for purchase records.
 type ReceiptRepository interface {
-	// CreateReceipt inserts a new purchase record.
+	// CreateReceipt inserts a new purchase record and records an alert for
+	// every price watch it triggers.
 	CreateReceipt(ctx context.Context, receipt *model.Receipt) error
 
 	// GetReceiptByID returns a single receipt by its ID.
@@ -32,8 +38,22 @@ type ReceiptRepository interface {
 	// ListReceiptsByUser returns a paginated list of receipts for a given user.
 	ListReceiptsByUser(ctx context.Context, userID string, params model.PaginationParams) (*model.Page[*model.Receipt], error)
 
-	// DeleteReceipt removes a receipt by its ID.
-	DeleteReceipt(ctx context.Context, id string) error
+	// ListReceiptsByUserCursor returns a page of a user's receipts using keyset pagination.
+	ListReceiptsByUserCursor(ctx context.Context, userID string, params model.CursorParams) (*model.CursorPage[*model.Receipt], error)
+
+	// UpdateReceipt replaces the native fields and extras of an existing receipt
+	// and bumps its version. ID, owner and upload time are kept. If expectedVersion
+	// is non-zero the update only applies when it matches the stored version;
+	// otherwise model.ErrVersionMismatch is returned.
+	UpdateReceipt(ctx context.Context, receipt *model.Receipt, expectedVersion int64) error
+
+	// DeleteReceipt removes a receipt by its ID. If expectedVersion is non-zero
+	// the delete only applies when it matches the stored version.
+	DeleteReceipt(ctx context.Context, id string, expectedVersion int64) error
+
+	// SearchReceipts returns a user's receipts matching a full-text query,
+	// best matches first. Only Offset and Limit of params are used.
+	SearchReceipts(ctx context.Context, userID string, query *model.SearchQuery, params model.PaginationParams) (*model.Page[*model.SearchHit], error)
 }
 
 // UserRepository defines the storage operations for user accounts.
@@ -50,12 +70,281 @@ type UserRepository interface {
 	// UpdatePassword updates the password hash for a user.
 	UpdatePassword(ctx context.Context, id string, passwordHash string) error
 
+	// UpdateRole changes a user's role.
+	// Returns model.ErrUserNotFound if the user does not exist.
+	UpdateRole(ctx context.Context, id string, role model.Role) error
+
+	// UpdateProfile changes a user's username and display name.
+	// Returns model.ErrUserNotFound if the user does not exist.
+	UpdateProfile(ctx context.Context, id, username, displayName string) error
+
+	// TokenVersion returns the version a user's access tokens must carry.
+	// Returns model.ErrUserNotFound if the user does not exist.
+	TokenVersion(ctx context.Context, id string) (int64, error)
+
+	// BumpTokenVersion increments a user's token version, revoking every
+	// access token issued to them so far.
+	BumpTokenVersion(ctx context.Context, id string) error
+
 	// ListUsers returns a paginated list of registered users.
 	ListUsers(ctx context.Context, params model.PaginationParams) (*model.Page[*model.User], error)
 
-	// DeleteUser removes a user by their ID.
+	// ListUsersCursor returns a page of registered users using keyset pagination.
+	ListUsersCursor(ctx context.Context, params model.CursorParams) (*model.CursorPage[*model.User], error)
+
+	// DeleteUser removes a user by their ID, along with their receipts, and
+	// publishes a receipt.deleted event for each receipt.
 	DeleteUser(ctx context.Context, id string) error
 
 	// HasAdmin returns true if at least one admin account exists.
 	HasAdmin(ctx context.Context) (bool, error)
 }
+
+// IdempotencyRepository defines the storage operations for idempotency keys.
+// Keys are scoped per user, so two users may pick the same key independently.
+type IdempotencyRepository interface {
+	// Reserve inserts a pending record for the user and key. It returns false
+	// if an unexpired record for the same user and key already exists.
+	Reserve(ctx context.Context, record *model.IdempotencyRecord) (bool, error)
+
+	// Get returns the unexpired record for a user and key, or nil if none exists.
+	Get(ctx context.Context, userID, key string) (*model.IdempotencyRecord, error)
+
+	// Complete stores the response of a reserved request.
+	Complete(ctx context.Context, userID, key string, statusCode int, headers map[string][]string, body []byte) error
+
+	// Delete removes a record, allowing the key to be used again.
+	Delete(ctx context.Context, userID, key string) error
+
+	// DeleteExpired removes all records past their expiry and returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// StoreRepository defines the storage operations for normalized stores and
+// the aliases that map free-text receipt store names onto them.
+type StoreRepository interface {
+	// CreateStore inserts a store and registers its name and aliases. Receipts
+	// not yet linked to a store are linked if their store name matches.
+	// Returns model.ErrStoreAliasTaken if the name or an alias already
+	// belongs to another store.
+	CreateStore(ctx context.Context, store *model.Store) error
+
+	// GetStore returns a store by ID, or nil if not found.
+	GetStore(ctx context.Context, id string) (*model.Store, error)
+
+	// ResolveStore returns the store a free-text store name resolves to,
+	// or nil if it matches no store name or alias.
+	ResolveStore(ctx context.Context, name string) (*model.Store, error)
+
+	// ListStores returns a paginated list of stores.
+	ListStores(ctx context.Context, params model.PaginationParams) (*model.Page[*model.Store], error)
+
+	// UpdateStore replaces a store's details and aliases, linking newly
+	// matching receipts as CreateStore does. Returns model.ErrStoreNotFound
+	// or model.ErrStoreAliasTaken.
+	UpdateStore(ctx context.Context, store *model.Store) error
+
+	// DeleteStore removes a store and unlinks its receipts, which keep their
+	// store name. Returns model.ErrStoreNotFound if it does not exist.
+	DeleteStore(ctx context.Context, id string) error
+}
+
+// ProductRepository defines the storage operations for the product catalog:
+// canonical products, their aliases and barcodes.
+type ProductRepository interface {
+	// CreateProduct inserts a product and registers its name and aliases.
+	// Receipts not yet linked to a product are linked if their barcode or
+	// product name matches. Returns model.ErrProductAliasTaken,
+	// model.ErrBarcodeTaken or model.ErrCategoryNotFound.
+	CreateProduct(ctx context.Context, product *model.Product) error
+
+	// GetProduct returns a product by ID, or nil if not found.
+	GetProduct(ctx context.Context, id string) (*model.Product, error)
+
+	// ResolveProduct returns the product a receipt line with the given
+	// product name and normalized barcode resolves to, or nil if none.
+	// A barcode match takes precedence over a name match.
+	ResolveProduct(ctx context.Context, name, barcode string) (*model.Product, error)
+
+	// ListProducts returns a paginated list of products. A non-empty
+	// categoryID keeps products in that category or any of its descendants.
+	ListProducts(ctx context.Context, categoryID string, params model.PaginationParams) (*model.Page[*model.Product], error)
+
+	// UpdateProduct replaces a product's details and aliases, linking newly
+	// matching receipts as CreateProduct does. Returns the same errors as
+	// CreateProduct, or model.ErrProductNotFound.
+	UpdateProduct(ctx context.Context, product *model.Product) error
+
+	// DeleteProduct removes a product and unlinks its receipts, which keep
+	// their product name. Returns model.ErrProductNotFound if it does not exist.
+	DeleteProduct(ctx context.Context, id string) error
+}
+
+// CategoryRepository defines the storage operations for the product category hierarchy.
+type CategoryRepository interface {
+	// CreateCategory inserts a category. Returns model.ErrParentCategoryNotFound
+	// if the parent does not exist, or model.ErrCategoryExists if a sibling has
+	// the same name.
+	CreateCategory(ctx context.Context, category *model.Category) error
+
+	// GetCategory returns a category by ID, or nil if not found.
+	GetCategory(ctx context.Context, id string) (*model.Category, error)
+
+	// ListCategories returns every category as a flat list ordered by name.
+	ListCategories(ctx context.Context) ([]*model.Category, error)
+
+	// UpdateCategory renames or moves a category. Returns the same errors as
+	// CreateCategory, model.ErrCategoryNotFound if the category does not
+	// exist, or model.ErrCategoryCycle if it would become its own ancestor.
+	UpdateCategory(ctx context.Context, category *model.Category) error
+
+	// DeleteCategory removes a category. Returns model.ErrCategoryInUse if it
+	// still has subcategories or products, or model.ErrCategoryNotFound.
+	DeleteCategory(ctx context.Context, id string) error
+}
+
+// PriceRepository answers price questions across the receipts of all users.
+// Unit prices are derived from each receipt's price and amount (see
+// model.ParseUnitPrice); receipts whose price, amount or purchase date cannot
+// be parsed are left out.
+type PriceRepository interface {
+	// PriceHistory returns the unit prices of the receipts matching the
+	// filter, grouped into series by currency and unit and bucketed by
+	// model.BucketWeek or model.BucketMonth.
+	PriceHistory(ctx context.Context, filter model.PriceFilter, bucket string) (*model.PriceHistory, error)
+
+	// Deals ranks stores by the unit prices of the filter's product or
+	// category seen within its date range, comparing each to the product's
+	// median over all time. A non-nil near keeps receipts from stores inside
+	// it, located by the registered store or else the receipt coordinates.
+	Deals(ctx context.Context, filter model.PriceFilter, near *model.GeoFilter) (*model.DealReport, error)
+}
+
+// AnalyticsRepository aggregates a user's spending in SQL. Spend is summed
+// from the numeric price stored with each receipt, per currency; receipts
+// whose price cannot be parsed are only counted.
+type AnalyticsRepository interface {
+	// Spending returns the user's spend within the query's purchase date
+	// range, in total and grouped by month, store, category or the value of
+	// a user-defined field.
+	Spending(ctx context.Context, userID string, query model.SpendingQuery) (*model.SpendingReport, error)
+}
+
+// WatchRepository defines the storage operations for price watches and the
+// alerts they trigger. Watches are evaluated by ReceiptRepository.CreateReceipt
+// in the same transaction as the insert, so every new receipt is checked.
+type WatchRepository interface {
+	// CreateWatch inserts a watch.
+	CreateWatch(ctx context.Context, watch *model.Watch) error
+
+	// ListWatches returns the user's watches, newest first.
+	ListWatches(ctx context.Context, userID string) ([]*model.Watch, error)
+
+	// DeleteWatch removes one of the user's watches and its alerts. Returns
+	// model.ErrWatchNotFound if the user has no such watch.
+	DeleteWatch(ctx context.Context, userID, id string) error
+
+	// ListAlerts returns a page of the user's alerts, newest first. If
+	// unreadOnly is set, alerts already marked read are left out.
+	ListAlerts(ctx context.Context, userID string, unreadOnly bool, params model.PaginationParams) (*model.Page[*model.Alert], error)
+
+	// MarkAlertRead marks one of the user's alerts as read. Returns
+	// model.ErrAlertNotFound if the user has no such alert.
+	MarkAlertRead(ctx context.Context, userID string, id int64) error
+
+	// UndeliveredAlerts returns up to limit alerts not yet sent to the
+	// webhook, oldest first.
+	UndeliveredAlerts(ctx context.Context, limit int) ([]*model.Alert, error)
+
+	// MarkAlertDelivered records that an alert was sent to the webhook.
+	MarkAlertDelivered(ctx context.Context, id int64) error
+}
+
+// WebhookRepository defines the storage operations for outgoing webhook
+// subscriptions and their delivery queue. Receipt and meta field
+// repositories queue a delivery for every active subscription that wants an
+// event, in the same transaction as the write.
+type WebhookRepository interface {
+	// CreateSubscription inserts a webhook subscription.
+	CreateSubscription(ctx context.Context, sub *model.WebhookSubscription) error
+
+	// GetSubscription returns a subscription by ID, or nil if not found.
+	GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error)
+
+	// ListSubscriptions returns every subscription, oldest first.
+	ListSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error)
+
+	// UpdateSubscription replaces a subscription's URL, event filters and
+	// active flag; its secret is kept. Returns model.ErrWebhookNotFound.
+	UpdateSubscription(ctx context.Context, sub *model.WebhookSubscription) error
+
+	// DeleteSubscription removes a subscription and its deliveries. Returns
+	// model.ErrWebhookNotFound if it does not exist.
+	DeleteSubscription(ctx context.Context, id string) error
+
+	// ListDeliveries returns a page of a subscription's deliveries, newest
+	// first. A non-empty status keeps deliveries in that state.
+	ListDeliveries(ctx context.Context, subscriptionID, status string, params model.PaginationParams) (*model.Page[*model.WebhookDelivery], error)
+
+	// DueDeliveries returns up to limit pending deliveries of active
+	// subscriptions whose next attempt is due at now, oldest first, with
+	// their subscription's URL and secret.
+	DueDeliveries(ctx context.Context, now int64, limit int) ([]*model.WebhookDelivery, error)
+
+	// SaveAttempt stores the status, attempt count, next attempt time and
+	// outcome of a delivery after an attempt.
+	SaveAttempt(ctx context.Context, delivery *model.WebhookDelivery) error
+
+	// RetryDelivery moves a dead-lettered delivery of the subscription back
+	// to pending, due at now with a fresh attempt count. Returns
+	// model.ErrDeliveryNotFound if there is no such dead delivery.
+	RetryDelivery(ctx context.Context, subscriptionID string, id int64, now int64) error
+}
+
+// EventRepository reads the persistent log of change events that receipt and
+// meta field writes append to. The log is the change feed: event IDs are
+// sequence numbers that increase in commit order.
+type EventRepository interface {
+	// ListEvents returns up to limit events of any type or user with an ID
+	// greater than since, oldest first. Returns model.ErrEventsCompacted if
+	// events after since have been removed by CompactEvents.
+	ListEvents(ctx context.Context, since int64, limit int) ([]*model.Event, error)
+
+	// ListUserEvents returns up to limit receipt events of the user with an
+	// ID greater than afterID, oldest first. Returns model.ErrEventsCompacted
+	// if events after afterID have been removed by CompactEvents.
+	ListUserEvents(ctx context.Context, userID string, afterID int64, limit int) ([]*model.Event, error)
+
+	// LatestEventID returns the ID of the newest event, or 0 if the log is
+	// empty.
+	LatestEventID(ctx context.Context) (int64, error)
+
+	// CompactEvents removes events created before the cutoff (Unix seconds)
+	// and returns how many were removed. Readers of the change feed from
+	// before the removed events get model.ErrEventsCompacted.
+	CompactEvents(ctx context.Context, before int64) (int64, error)
+
+	// CompactedThrough returns the ID of the newest event removed by
+	// compaction, or 0 if none has been.
+	CompactedThrough(ctx context.Context) (int64, error)
+}
+
+// ExchangeRateRepository defines the storage operations for the locally
+// maintained table of exchange rates used to convert prices.
+type ExchangeRateRepository interface {
+	// PutRates inserts the rates, replacing any with the same base, quote
+	// and date, all in one transaction.
+	PutRates(ctx context.Context, rates []*model.ExchangeRate) error
+
+	// ListRates returns a page of rates, optionally narrowed to one base or
+	// quote currency when those are non-empty.
+	ListRates(ctx context.Context, base, quote string, params model.PaginationParams) (*model.Page[*model.ExchangeRate], error)
+
+	// DeleteRate removes one rate. Returns model.ErrRateNotFound if it does
+	// not exist.
+	DeleteRate(ctx context.Context, base, quote, date string) error
+
+	// RateTable returns a table converting into currency from every rate
+	// quoted against it.
+	RateTable(ctx context.Context, currency string) (*model.RateTable, error)
+}
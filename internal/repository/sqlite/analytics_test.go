@@ -0,0 +1,177 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+type analyticsEnv struct {
+	*productEnv
+	analytics *sqlite.AnalyticsRepo
+}
+
+func newAnalyticsEnv(t *testing.T) *analyticsEnv {
+	t.Helper()
+	env := newProductEnv(t)
+	return &analyticsEnv{productEnv: env, analytics: sqlite.NewAnalyticsRepo(env.db)}
+}
+
+func (e *analyticsEnv) spend(t *testing.T, id, userID, productName, date, price, store string, extras map[string]interface{}) {
+	t.Helper()
+	rec := e.sampleReceipt(id, userID)
+	rec.ProductName, rec.PurchaseDate, rec.Price, rec.StoreName, rec.Extras = productName, date, price, store, extras
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+}
+
+func (e *analyticsEnv) spending(t *testing.T, query model.SpendingQuery) *model.SpendingReport {
+	t.Helper()
+	report, err := e.analytics.Spending(e.ctx, "user-1", query)
+	if err != nil {
+		t.Fatalf("Spending failed: %v", err)
+	}
+	return report
+}
+
+// groupTotals maps each group label to its per-currency totals.
+func groupTotals(report *model.SpendingReport) map[string]map[string]float64 {
+	m := map[string]map[string]float64{}
+	for _, g := range report.Groups {
+		m[g.Label] = map[string]float64{}
+		for _, total := range g.Totals {
+			m[g.Label][total.Currency] = total.Total
+		}
+	}
+	return m
+}
+
+func TestSpending_ByMonthPerCurrency(t *testing.T) {
+	env := newAnalyticsEnv(t)
+	env.seedUser(t, "user-2")
+	env.spend(t, "r1", "user-1", "Milk", "2025.03.30", "5.49CAD", "Costco", nil)
+	env.spend(t, "r2", "user-1", "Eggs", "2025.04.01", "4.20CAD", "Costco", nil)
+	env.spend(t, "r3", "user-1", "Bread", "2025.4.15", "3.10CAD", "Walmart", nil)
+	env.spend(t, "r4", "user-1", "Souvenir", "2025.04.20", "12.00USD", "Target", nil)
+	env.spend(t, "r5", "user-1", "Mystery", "2025.04.21", "free", "Costco", nil)
+	env.spend(t, "r6", "user-2", "Milk", "2025.04.02", "99.00CAD", "Costco", nil) // another user
+
+	report := env.spending(t, model.SpendingQuery{GroupBy: model.GroupByMonth})
+	got := groupTotals(report)
+	if len(got) != 2 || got["2025-03"]["CAD"] != 5.49 || got["2025-04"]["CAD"] != 7.3 || got["2025-04"]["USD"] != 12 {
+		t.Errorf("unexpected monthly totals: %v", got)
+	}
+	if len(report.Totals) != 2 || report.Totals[0].Currency != "CAD" || report.Totals[0].Total != 12.79 || report.Totals[0].Count != 3 {
+		t.Errorf("unexpected overall totals: %+v", report.Totals)
+	}
+	if report.Unpriced != 1 {
+		t.Errorf("unpriced = %d, want 1", report.Unpriced)
+	}
+
+	report = env.spending(t, model.SpendingQuery{
+		GroupBy: model.GroupByMonth,
+		From:    time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2025, 4, 15, 0, 0, 0, 0, time.UTC),
+	})
+	if got := groupTotals(report); len(got) != 1 || got["2025-04"]["CAD"] != 7.3 || len(got["2025-04"]) != 1 {
+		t.Errorf("unexpected totals for April 1-15: %v", got)
+	}
+}
+
+func TestSpending_ByStoreAndCategory(t *testing.T) {
+	env := newAnalyticsEnv(t)
+	if err := sqlite.NewStoreRepo(env.db).CreateStore(env.ctx, &model.Store{
+		ID: "s-costco", Name: "Costco Richmond", Aliases: []string{"Costco"},
+	}); err != nil {
+		t.Fatalf("CreateStore failed: %v", err)
+	}
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createProduct(t, &model.Product{ID: "p-milk", Name: "Milk", CategoryID: "c-dairy"})
+	env.createProduct(t, &model.Product{ID: "p-cheese", Name: "Cheese", CategoryID: "c-dairy"})
+
+	env.spend(t, "r1", "user-1", "Milk", "2025.04.01", "5.00CAD", "Costco", nil)
+	env.spend(t, "r2", "user-1", "Cheese", "2025.04.02", "7.50CAD", "COSTCO", nil)
+	env.spend(t, "r3", "user-1", "Bread", "2025.04.03", "3.00CAD", "Walmart", nil)
+
+	stores := env.spending(t, model.SpendingQuery{GroupBy: model.GroupByStore})
+	if got := groupTotals(stores); len(got) != 2 || got["Costco Richmond"]["CAD"] != 12.5 || got["Walmart"]["CAD"] != 3 {
+		t.Errorf("unexpected store totals: %v", got)
+	}
+	if stores.Groups[0].Key != "s-costco" || stores.Groups[1].Key != "" {
+		t.Errorf("expected the registered store keyed by ID and the unlinked one by \"\", got %q, %q",
+			stores.Groups[0].Key, stores.Groups[1].Key)
+	}
+
+	categories := env.spending(t, model.SpendingQuery{GroupBy: model.GroupByCategory})
+	if got := groupTotals(categories); len(got) != 2 || got["Dairy"]["CAD"] != 12.5 || got[""]["CAD"] != 3 {
+		t.Errorf("unexpected category totals: %v", got)
+	}
+}
+
+func TestSpending_ByExtrasField(t *testing.T) {
+	env := newAnalyticsEnv(t)
+	for name, typ := range map[string]string{"payment": "enum", "onSale": "bool"} {
+		if err := env.meta.CreateField(env.ctx, &model.MetaField{FieldName: name, FieldType: typ}); err != nil {
+			t.Fatalf("CreateField failed: %v", err)
+		}
+	}
+	env.spend(t, "r1", "user-1", "Milk", "2025.04.01", "5.00CAD", "Costco", map[string]interface{}{"payment": "card", "onSale": true})
+	env.spend(t, "r2", "user-1", "Eggs", "2025.04.02", "4.00CAD", "Costco", map[string]interface{}{"payment": "cash", "onSale": false})
+	env.spend(t, "r3", "user-1", "Bread", "2025.04.03", "3.00CAD", "Costco", map[string]interface{}{"payment": "card"})
+
+	payment := groupTotals(env.spending(t, model.SpendingQuery{GroupBy: model.GroupByField, Field: "payment"}))
+	if len(payment) != 2 || payment["card"]["CAD"] != 8 || payment["cash"]["CAD"] != 4 {
+		t.Errorf("unexpected payment totals: %v", payment)
+	}
+	onSale := groupTotals(env.spending(t, model.SpendingQuery{GroupBy: model.GroupByField, Field: "onSale"}))
+	if len(onSale) != 3 || onSale["true"]["CAD"] != 5 || onSale["false"]["CAD"] != 4 || onSale[""]["CAD"] != 3 {
+		t.Errorf("unexpected onSale totals: %v", onSale)
+	}
+}
+
+func TestSpending_BackfillsExistingReceipts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gyd.db")
+	db, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	env := &receiptEnv{
+		receipts: sqlite.NewReceiptRepo(db, sqlite.NewMetaFieldRepo(db)),
+		users:    sqlite.NewUserRepo(db),
+		ctx:      context.Background(),
+	}
+	env.seedUser(t, "user-1")
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r1", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	_ = db.Close()
+
+	// Simulate a receipt stored before the spend columns existed.
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw: %v", err)
+	}
+	if _, err := raw.Exec(`UPDATE receipts SET price_value = NULL, currency = NULL, purchased_on = NULL`); err != nil {
+		t.Fatalf("clear spend columns: %v", err)
+	}
+	_ = raw.Close()
+
+	db, err = sqlite.New(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	report, err := sqlite.NewAnalyticsRepo(db).Spending(context.Background(), "user-1", model.SpendingQuery{GroupBy: model.GroupByMonth})
+	if err != nil {
+		t.Fatalf("Spending failed: %v", err)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Key != "2025-04" || report.Groups[0].Totals[0].Total != 5.49 {
+		t.Errorf("expected the backfilled receipt in April, got %+v", report.Groups)
+	}
+}
@@ -0,0 +1,100 @@
+package sqlite_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+func TestCategory_CreateAndTree(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createCategory(t, "c-milk", "Milk", "c-dairy")
+	env.createCategory(t, "c-cheese", "Cheese", "c-dairy")
+	env.createCategory(t, "c-bakery", "Bakery", "")
+
+	got, err := env.categories.GetCategory(env.ctx, "c-milk")
+	if err != nil || got == nil || got.ParentID != "c-dairy" {
+		t.Fatalf("unexpected category: %+v, %v", got, err)
+	}
+
+	all, err := env.categories.ListCategories(env.ctx)
+	if err != nil {
+		t.Fatalf("ListCategories failed: %v", err)
+	}
+	tree := model.BuildCategoryTree(all)
+	if len(tree) != 2 || tree[0].Name != "Bakery" || tree[1].Name != "Dairy" {
+		t.Fatalf("unexpected roots: %+v", tree)
+	}
+	if len(tree[1].Children) != 2 || tree[1].Children[0].Name != "Cheese" {
+		t.Errorf("expected Dairy to have Cheese and Milk, got %+v", tree[1].Children)
+	}
+}
+
+func TestCategory_Validation(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createCategory(t, "c-milk", "Milk", "c-dairy")
+
+	err := env.categories.CreateCategory(env.ctx, &model.Category{ID: "c-2", Name: "dairy"})
+	if !errors.Is(err, model.ErrCategoryExists) {
+		t.Errorf("expected ErrCategoryExists, got %v", err)
+	}
+	// The same name under a different parent is fine.
+	env.createCategory(t, "c-dairy-milk", "Dairy", "c-milk")
+
+	err = env.categories.CreateCategory(env.ctx, &model.Category{ID: "c-3", Name: "Eggs", ParentID: "nope"})
+	if !errors.Is(err, model.ErrParentCategoryNotFound) {
+		t.Errorf("expected ErrParentCategoryNotFound, got %v", err)
+	}
+}
+
+func TestCategory_MoveRejectsCycle(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createCategory(t, "c-milk", "Milk", "c-dairy")
+	env.createCategory(t, "c-oat", "Oat Milk", "c-milk")
+
+	for _, parent := range []string{"c-dairy", "c-oat"} {
+		err := env.categories.UpdateCategory(env.ctx, &model.Category{ID: "c-dairy", Name: "Dairy", ParentID: parent})
+		if !errors.Is(err, model.ErrCategoryCycle) {
+			t.Errorf("moving under %s: expected ErrCategoryCycle, got %v", parent, err)
+		}
+	}
+
+	moved := &model.Category{ID: "c-oat", Name: "Oat Milk", ParentID: "c-dairy"}
+	if err := env.categories.UpdateCategory(env.ctx, moved); err != nil {
+		t.Fatalf("UpdateCategory failed: %v", err)
+	}
+	if moved.CreatedAt == 0 {
+		t.Error("expected CreatedAt to be reloaded")
+	}
+	err := env.categories.UpdateCategory(env.ctx, &model.Category{ID: "nope", Name: "Nope"})
+	if !errors.Is(err, model.ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+}
+
+func TestCategory_DeleteOnlyWhenEmpty(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createCategory(t, "c-milk", "Milk", "c-dairy")
+	env.createProduct(t, &model.Product{ID: "p-1", Name: "Oat Milk", CategoryID: "c-milk"})
+
+	for _, id := range []string{"c-dairy", "c-milk"} {
+		if err := env.categories.DeleteCategory(env.ctx, id); !errors.Is(err, model.ErrCategoryInUse) {
+			t.Errorf("deleting %s: expected ErrCategoryInUse, got %v", id, err)
+		}
+	}
+
+	if err := env.products.DeleteProduct(env.ctx, "p-1"); err != nil {
+		t.Fatalf("DeleteProduct failed: %v", err)
+	}
+	if err := env.categories.DeleteCategory(env.ctx, "c-milk"); err != nil {
+		t.Fatalf("DeleteCategory failed: %v", err)
+	}
+	if err := env.categories.DeleteCategory(env.ctx, "c-milk"); !errors.Is(err, model.ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// EventRepo implements repository.EventRepository backed by SQLite.
+type EventRepo struct {
+	db *DB
+}
+
+// NewEventRepo creates a new SQLite-backed event log repository.
+func NewEventRepo(db *DB) *EventRepo {
+	return &EventRepo{db: db}
+}
+
+func (r *EventRepo) ListEvents(ctx context.Context, since int64, limit int) ([]*model.Event, error) {
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT id, type, user_id, payload, created_at FROM events WHERE id > ? ORDER BY id LIMIT ?`,
+		since, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked after reading, so a compaction that ran meanwhile is noticed.
+	through, err := r.CompactedThrough(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if since < through {
+		return nil, fmt.Errorf("%w: sequence %d is older than %d", model.ErrEventsCompacted, since, through)
+	}
+	return events, nil
+}
+
+func (r *EventRepo) ListUserEvents(ctx context.Context, userID string, afterID int64, limit int) ([]*model.Event, error) {
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT id, type, user_id, payload, created_at FROM events
+		WHERE user_id = ? AND id > ? AND type LIKE 'receipt.%'
+		ORDER BY id LIMIT ?`,
+		userID, afterID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	events, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checked after reading, as in ListEvents.
+	through, err := r.CompactedThrough(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if afterID < through {
+		return nil, fmt.Errorf("%w: sequence %d is older than %d", model.ErrEventsCompacted, afterID, through)
+	}
+	return events, nil
+}
+
+func (r *EventRepo) LatestEventID(ctx context.Context) (int64, error) {
+	var id int64
+	if err := r.db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM events`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("latest event: %w", err)
+	}
+	return id, nil
+}
+
+func (r *EventRepo) CompactEvents(ctx context.Context, before int64) (int64, error) {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Remove a prefix of the log so the watermark covers every removed ID.
+	var through sql.NullInt64
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(id) FROM events WHERE created_at < ?`, before).Scan(&through); err != nil {
+		return 0, fmt.Errorf("find compactable events: %w", err)
+	}
+	if !through.Valid {
+		return 0, nil
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM events WHERE id <= ?`, through.Int64)
+	if err != nil {
+		return 0, fmt.Errorf("compact events: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("rows affected: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE event_compaction SET compacted_through = ? WHERE id = 1 AND compacted_through < ?`,
+		through.Int64, through.Int64,
+	); err != nil {
+		return 0, fmt.Errorf("record compaction: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit compaction: %w", err)
+	}
+	return n, nil
+}
+
+func (r *EventRepo) CompactedThrough(ctx context.Context) (int64, error) {
+	var through int64
+	if err := r.db.conn.QueryRowContext(ctx, `SELECT compacted_through FROM event_compaction WHERE id = 1`).Scan(&through); err != nil {
+		return 0, fmt.Errorf("read compaction watermark: %w", err)
+	}
+	return through, nil
+}
+
+// publishEvent appends the event to the event log and queues its webhook
+// deliveries. It runs inside the transaction of the write the event
+// describes, so the event exists exactly when its change does.
+func publishEvent(ctx context.Context, tx *sql.Tx, event model.Event) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	event.CreatedAt = time.Now().Unix()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO events (type, user_id, payload, created_at) VALUES (?, ?, ?, ?)`,
+		event.Type, event.UserID, string(payload), event.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("log event: %w", err)
+	}
+	return queueDeliveries(ctx, tx, event, payload)
+}
+
+// scanEvents reads and closes a result set of event rows.
+func scanEvents(rows *sql.Rows) ([]*model.Event, error) {
+	defer func() { _ = rows.Close() }()
+
+	events := []*model.Event{}
+	for rows.Next() {
+		var e model.Event
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Type, &e.UserID, &payload, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		e.Data = json.RawMessage(payload)
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,88 @@
+package sqlite_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+func TestEventLog_ListUserEvents(t *testing.T) {
+	env := newProductEnv(t)
+	events := sqlite.NewEventRepo(env.db)
+	env.seedUser(t, "user-2")
+
+	if latest, err := events.LatestEventID(env.ctx); err != nil || latest != 0 {
+		t.Fatalf("LatestEventID = %d, %v; want 0 for an empty log", latest, err)
+	}
+
+	rec := env.sampleReceipt("r1", "user-1")
+	if err := env.receipts.CreateReceipt(env.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r2", "user-2")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	if err := env.meta.UpdateDescription(env.ctx, "productName", "What was bought", 0); err != nil {
+		t.Fatalf("UpdateDescription failed: %v", err)
+	}
+	if err := env.receipts.DeleteReceipt(env.ctx, "r1", 0); err != nil {
+		t.Fatalf("DeleteReceipt failed: %v", err)
+	}
+
+	got, err := events.ListUserEvents(env.ctx, "user-1", 0, 10)
+	if err != nil {
+		t.Fatalf("ListUserEvents failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Type != model.EventReceiptCreated || got[1].Type != model.EventReceiptDeleted {
+		t.Fatalf("expected user-1's created and deleted events, got %+v", got)
+	}
+	var deleted model.DeletedReceipt
+	if err := json.Unmarshal(got[1].Data.(json.RawMessage), &deleted); err != nil || deleted.ID != "r1" || deleted.UserID != "user-1" {
+		t.Errorf("unexpected delete payload %s: %v", got[1].Data, err)
+	}
+
+	if resumed, _ := events.ListUserEvents(env.ctx, "user-1", got[0].ID, 10); len(resumed) != 1 || resumed[0].ID != got[1].ID {
+		t.Errorf("expected only the delete after resuming, got %+v", resumed)
+	}
+	if latest, _ := events.LatestEventID(env.ctx); latest != got[1].ID {
+		t.Errorf("LatestEventID = %d, want %d", latest, got[1].ID)
+	}
+}
+
+func TestEventLog_Compaction(t *testing.T) {
+	env := newProductEnv(t)
+	events := sqlite.NewEventRepo(env.db)
+	for _, id := range []string{"r1", "r2", "r3"} {
+		if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt(id, "user-1")); err != nil {
+			t.Fatalf("CreateReceipt failed: %v", err)
+		}
+	}
+	all, err := events.ListEvents(env.ctx, 0, 10)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListEvents = %+v, %v; want 3", all, err)
+	}
+
+	if n, err := events.CompactEvents(env.ctx, all[0].CreatedAt); err != nil || n != 0 {
+		t.Errorf("CompactEvents = %d, %v; want nothing older than the first event removed", n, err)
+	}
+	if n, err := events.CompactEvents(env.ctx, all[2].CreatedAt+1); err != nil || n != 3 {
+		t.Fatalf("CompactEvents = %d, %v; want 3", n, err)
+	}
+	if through, _ := events.CompactedThrough(env.ctx); through != all[2].ID {
+		t.Errorf("CompactedThrough = %d, want %d", through, all[2].ID)
+	}
+	if _, err := events.ListEvents(env.ctx, all[1].ID, 10); !errors.Is(err, model.ErrEventsCompacted) {
+		t.Errorf("expected ErrEventsCompacted reading from a compacted sequence, got %v", err)
+	}
+
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r4", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	got, err := events.ListEvents(env.ctx, all[2].ID, 10)
+	if err != nil || len(got) != 1 || got[0].ID <= all[2].ID {
+		t.Errorf("expected the new event after the compaction point, got %+v, %v", got, err)
+	}
+}
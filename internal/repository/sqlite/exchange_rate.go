@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// ExchangeRateRepo implements repository.ExchangeRateRepository backed by SQLite.
+type ExchangeRateRepo struct {
+	db *DB
+}
+
+// NewExchangeRateRepo creates a new SQLite-backed exchange rate repository.
+func NewExchangeRateRepo(db *DB) *ExchangeRateRepo {
+	return &ExchangeRateRepo{db: db}
+}
+
+func (r *ExchangeRateRepo) PutRates(ctx context.Context, rates []*model.ExchangeRate) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, rate := range rates {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO exchange_rates (base, quote, rate_date, rate) VALUES (?, ?, ?, ?)
+			ON CONFLICT (base, quote, rate_date) DO UPDATE SET rate = excluded.rate`,
+			rate.Base, rate.Quote, rate.Date, rate.Rate,
+		); err != nil {
+			return fmt.Errorf("put exchange rate: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *ExchangeRateRepo) ListRates(ctx context.Context, base, quote string, params model.PaginationParams) (*model.Page[*model.ExchangeRate], error) {
+	where := "1 = 1"
+	var args []interface{}
+	if base != "" {
+		where += " AND base = ?"
+		args = append(args, base)
+	}
+	if quote != "" {
+		where += " AND quote = ?"
+		args = append(args, quote)
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM exchange_rates WHERE `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count exchange rates: %w", err)
+	}
+
+	page := &model.Page[*model.ExchangeRate]{
+		Data:   []*model.ExchangeRate{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	// SortBy and SortOrder are validated by the handler.
+	query := fmt.Sprintf(
+		`SELECT base, quote, rate_date, rate FROM exchange_rates WHERE `+where+`
+		ORDER BY %s %s, base, quote LIMIT ? OFFSET ?`,
+		params.SortBy, params.SortOrder,
+	)
+	rates, err := listRates(ctx, r.db.conn, query, append(args, params.Limit, params.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	page.Data = rates
+	return page, nil
+}
+
+func (r *ExchangeRateRepo) DeleteRate(ctx context.Context, base, quote, date string) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`DELETE FROM exchange_rates WHERE base = ? AND quote = ? AND rate_date = ?`, base, quote, date,
+	)
+	if err != nil {
+		return fmt.Errorf("delete exchange rate: %w", err)
+	}
+	return expectRow(result, model.ErrRateNotFound, base+"/"+quote+"/"+date)
+}
+
+func (r *ExchangeRateRepo) RateTable(ctx context.Context, currency string) (*model.RateTable, error) {
+	return loadRateTable(ctx, r.db.conn, currency)
+}
+
+// loadRateTable builds a table converting into currency from every rate
+// quoted against it.
+func loadRateTable(ctx context.Context, q rowsQuerier, currency string) (*model.RateTable, error) {
+	rates, err := listRates(ctx, q,
+		`SELECT base, quote, rate_date, rate FROM exchange_rates WHERE base = ? OR quote = ?`, currency, currency,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return model.NewRateTable(currency, rates), nil
+}
+
+// listRates runs a query selecting base, quote, rate_date and rate.
+func listRates(ctx context.Context, q rowsQuerier, query string, args ...interface{}) ([]*model.ExchangeRate, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list exchange rates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	rates := []*model.ExchangeRate{}
+	for rows.Next() {
+		var rate model.ExchangeRate
+		if err := rows.Scan(&rate.Base, &rate.Quote, &rate.Date, &rate.Rate); err != nil {
+			return nil, fmt.Errorf("scan exchange rate: %w", err)
+		}
+		rates = append(rates, &rate)
+	}
+	return rates, rows.Err()
+}
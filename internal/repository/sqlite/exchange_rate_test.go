@@ -0,0 +1,176 @@
+package sqlite_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+// putRates stores USD→CAD rates for early March 2025 and a CAD→EUR rate.
+func putRates(t *testing.T, env *productEnv) *sqlite.ExchangeRateRepo {
+	t.Helper()
+	rates := sqlite.NewExchangeRateRepo(env.db)
+	if err := rates.PutRates(env.ctx, []*model.ExchangeRate{
+		{Base: "USD", Quote: "CAD", Date: "2025-03-01", Rate: 1.4},
+		{Base: "USD", Quote: "CAD", Date: "2025-03-03", Rate: 1.5},
+		{Base: "CAD", Quote: "EUR", Date: "2025-03-01", Rate: 0.65},
+	}); err != nil {
+		t.Fatalf("PutRates failed: %v", err)
+	}
+	return rates
+}
+
+func TestParseRatesCSV(t *testing.T) {
+	rates, err := model.ParseRatesCSV(strings.NewReader("rate,date,base,quote\n1.35,2025.3.1,usd,cad\n0.74, 2025-03-02, CAD, USD\n"))
+	if err != nil {
+		t.Fatalf("ParseRatesCSV failed: %v", err)
+	}
+	if len(rates) != 2 || *rates[0] != (model.ExchangeRate{Base: "USD", Quote: "CAD", Date: "2025-03-01", Rate: 1.35}) ||
+		rates[1].Date != "2025-03-02" || rates[1].Base != "CAD" {
+		t.Errorf("unexpected rates: %+v, %+v", rates[0], rates[1])
+	}
+
+	for input, want := range map[string]string{
+		"date,base,quote\n":                           `missing column "rate"`,
+		"date,base,quote,rate\n2025.3.1,USD,CAD,x":    "line 2: rate must be a number",
+		"date,base,quote,rate\n2025.3.1,USD,USD,1":    "line 2: base and quote must differ",
+		"date,base,quote,rate\n2025.3.1,US,CAD,1":     "line 2: base must be",
+		"date,base,quote,rate\n2025.3.1,USD,CAD,0":    "line 2: rate must be greater than 0",
+		"date,base,quote,rate\n2025.3.1,USD,CAD,NaN":  "line 2: rate must be a finite number",
+		"date,base,quote,rate\n2025.3.1,USD,CAD,Inf":  "line 2: rate must be a finite number",
+		"date,base,quote,rate\n2025.3.1,USD,CAD,-inf": "line 2: rate must be a finite number",
+		"date,base,quote,rate\nsoon,USD,CAD,1":        "line 2: date",
+	} {
+		if _, err := model.ParseRatesCSV(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseRatesCSV(%q) error = %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestRateTable_Convert(t *testing.T) {
+	table := model.NewRateTable("CAD", []*model.ExchangeRate{
+		{Base: "USD", Quote: "CAD", Date: "2025-03-01", Rate: 1.4},
+		{Base: "USD", Quote: "CAD", Date: "2025-03-03", Rate: 1.5},
+		{Base: "CAD", Quote: "EUR", Date: "2025-03-01", Rate: 0.5},
+		{Base: "CAD", Quote: "USD", Date: "2025-03-03", Rate: 0.5}, // loses to the direct quote
+	})
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	for _, tc := range []struct {
+		currency string
+		date     time.Time
+		want     float64
+		rateDate string
+	}{
+		{"USD", day(1), 14, "2025-03-01"},
+		{"USD", day(2), 14, "2025-03-01"},
+		{"USD", day(20), 15, "2025-03-03"},
+		{"EUR", day(5), 20, "2025-03-01"},
+		{"CAD", day(5), 10, ""},
+	} {
+		got, ok := table.Convert(10, tc.currency, tc.date)
+		if !ok || !approxEqual(got.Value, tc.want) || got.Currency != "CAD" || got.RateDate != tc.rateDate {
+			t.Errorf("Convert(10 %s, %s) = %+v, %v; want %v from %q", tc.currency, tc.date.Format("2006-01-02"), got, ok, tc.want, tc.rateDate)
+		}
+	}
+	if _, ok := table.Convert(10, "USD", day(1).AddDate(0, 0, -1)); ok {
+		t.Error("expected no conversion before the first rate")
+	}
+	if _, ok := table.Convert(10, "GBP", day(5)); ok {
+		t.Error("expected no conversion without a rate")
+	}
+	if got, ok := table.ConvertPrice("3.33USD", "2025.03.02"); !ok || got.Value != 4.66 {
+		t.Errorf("ConvertPrice = %+v, %v; want 4.66", got, ok)
+	}
+}
+
+func TestExchangeRates_PutListDelete(t *testing.T) {
+	env := newProductEnv(t)
+	rates := putRates(t, env)
+
+	// Putting a rate again replaces it.
+	if err := rates.PutRates(env.ctx, []*model.ExchangeRate{{Base: "USD", Quote: "CAD", Date: "2025-03-03", Rate: 1.45}}); err != nil {
+		t.Fatalf("PutRates failed: %v", err)
+	}
+	params := model.PaginationParams{Limit: 10, SortBy: "rate_date", SortOrder: "DESC"}
+	page, err := rates.ListRates(env.ctx, "USD", "", params)
+	if err != nil {
+		t.Fatalf("ListRates failed: %v", err)
+	}
+	if page.Total != 2 || page.Data[0].Date != "2025-03-03" || page.Data[0].Rate != 1.45 {
+		t.Errorf("unexpected USD rates: %+v", page)
+	}
+	if page, err = rates.ListRates(env.ctx, "", "EUR", params); err != nil || page.Total != 1 {
+		t.Errorf("ListRates by quote = %+v, %v; want 1 rate", page, err)
+	}
+
+	if err := rates.DeleteRate(env.ctx, "USD", "CAD", "2025-03-03"); err != nil {
+		t.Fatalf("DeleteRate failed: %v", err)
+	}
+	if err := rates.DeleteRate(env.ctx, "USD", "CAD", "2025-03-03"); !errors.Is(err, model.ErrRateNotFound) {
+		t.Errorf("second DeleteRate = %v, want ErrRateNotFound", err)
+	}
+	table, err := rates.RateTable(env.ctx, "CAD")
+	if err != nil {
+		t.Fatalf("RateTable failed: %v", err)
+	}
+	if got, ok := table.Convert(10, "USD", time.Date(2025, 3, 9, 0, 0, 0, 0, time.UTC)); !ok || !approxEqual(got.Value, 14) {
+		t.Errorf("expected the deleted rate to fall back to the earlier one, got %+v", got)
+	}
+}
+
+func TestSpending_ConvertsAtPurchaseDateRate(t *testing.T) {
+	env := newAnalyticsEnv(t)
+	putRates(t, env.productEnv)
+	env.spend(t, "r1", "user-1", "Milk", "2025.03.01", "10.00USD", "Target", nil) // 14 CAD
+	env.spend(t, "r2", "user-1", "Eggs", "2025.03.04", "10.00USD", "Target", nil) // 15 CAD
+	env.spend(t, "r3", "user-1", "Bread", "2025.03.04", "3.10CAD", "Costco", nil) // as is
+	env.spend(t, "r4", "user-1", "Tea", "2025.03.04", "2.00GBP", "Harrods", nil)  // no rate
+	env.spend(t, "r5", "user-1", "Cake", "2025.02.28", "1.00USD", "Target", nil)  // before any rate
+
+	report := env.spending(t, model.SpendingQuery{GroupBy: model.GroupByStore, Currency: "CAD"})
+	if report.Currency != "CAD" {
+		t.Errorf("currency = %q, want CAD", report.Currency)
+	}
+	got := groupTotals(report)
+	if got["Target"]["CAD"] != 29 || got["Target"]["USD"] != 1 || got["Costco"]["CAD"] != 3.1 || got["Harrods"]["GBP"] != 2 {
+		t.Errorf("unexpected store totals: %v", got)
+	}
+	if len(report.Totals) != 3 || report.Totals[0].Currency != "CAD" || report.Totals[0].Total != 32.1 || report.Totals[0].Count != 3 {
+		t.Errorf("unexpected overall totals: %+v", report.Totals)
+	}
+}
+
+func TestDeals_ConvertsCurrencies(t *testing.T) {
+	env := newPriceEnv(t)
+	putRates(t, env.productEnv)
+	env.createProduct(t, &model.Product{ID: "p-milk", Name: "Milk 4L"})
+	env.purchase(t, "r1", "Milk 4L", "2025.03.04", "6.00CAD", "4L", "Costco")
+	env.purchase(t, "r2", "Milk 4L", "2025.03.04", "3.00USD", "4L", "Target") // 4.50 CAD
+
+	filter := model.PriceFilter{ProductID: "p-milk", From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), Currency: "CAD"}
+	report, err := env.prices.Deals(env.ctx, filter, nil)
+	if err != nil {
+		t.Fatalf("Deals failed: %v", err)
+	}
+	if report.Currency != "CAD" || len(report.Groups) != 1 || report.Groups[0].Currency != "CAD" {
+		t.Fatalf("expected one CAD group, got %+v", report)
+	}
+	deals := report.Groups[0].Deals
+	if len(deals) != 2 || deals[0].StoreName != "Target" || !approxEqual(deals[0].LatestPrice, 1.125) {
+		t.Errorf("expected Target to rank first at 1.125 CAD/L, got %+v", deals[0])
+	}
+
+	history, err := env.prices.PriceHistory(env.ctx, model.PriceFilter{ProductID: "p-milk", Currency: "EUR"}, model.BucketWeek)
+	if err != nil {
+		t.Fatalf("PriceHistory failed: %v", err)
+	}
+	// Only the CAD receipt has a EUR rate.
+	if history.Currency != "EUR" || len(history.Series) != 2 {
+		t.Errorf("expected EUR and USD series, got %+v", history.Series)
+	}
+}
@@ -0,0 +1,80 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// haversineKm backs the SQL function haversine_km(lat1, lng1, lat2, lng2),
+// registered on every connection by the driver (see driverName). SQLite only
+// has trigonometric functions when built with a tag, so the formula runs in Go.
+// Like built-in SQL functions it returns NULL when any argument is NULL.
+func haversineKm(lat1, lng1, lat2, lng2 interface{}) interface{} {
+	var coords [4]float64
+	for i, v := range []interface{}{lat1, lng1, lat2, lng2} {
+		switch n := v.(type) {
+		case float64:
+			coords[i] = n
+		case int64:
+			coords[i] = float64(n)
+		default:
+			return nil
+		}
+	}
+	return model.HaversineKm(
+		model.GeoPoint{Lat: coords[0], Lng: coords[1]},
+		model.GeoPoint{Lat: coords[2], Lng: coords[3]},
+	)
+}
+
+// distanceColumn returns the select expression for a receipt's distance from
+// the query point, aliased distance_km, and its arguments. It selects NULL
+// when there is no query point so scans stay uniform.
+func distanceColumn(geo *model.GeoFilter) (string, []interface{}) {
+	if geo == nil || geo.Center == nil {
+		return "NULL AS distance_km", nil
+	}
+	return "haversine_km(?, ?, latitude, longitude) AS distance_km", []interface{}{geo.Center.Lat, geo.Center.Lng}
+}
+
+// geoCondition returns the WHERE fragment for a radius and/or bounding box
+// filter, with its arguments. It returns an empty fragment when geo filters
+// nothing. Radius queries are narrowed by a latitude range first so the
+// location index can be used.
+func geoCondition(geo *model.GeoFilter) (string, []interface{}) {
+	if geo == nil {
+		return "", nil
+	}
+	var conds []string
+	var args []interface{}
+	if geo.RadiusKm > 0 {
+		minLat, maxLat := geo.LatitudeSpan()
+		conds = append(conds,
+			"latitude BETWEEN ? AND ?",
+			"haversine_km(?, ?, latitude, longitude) <= ?",
+		)
+		args = append(args, minLat, maxLat, geo.Center.Lat, geo.Center.Lng, geo.RadiusKm)
+	}
+	if box := geo.Box; box != nil {
+		conds = append(conds, "latitude BETWEEN ? AND ?")
+		args = append(args, box.South, box.North)
+		if box.West <= box.East {
+			conds = append(conds, "longitude BETWEEN ? AND ?")
+		} else {
+			conds = append(conds, "(longitude >= ? OR longitude <= ?)")
+		}
+		args = append(args, box.West, box.East)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// receiptOrder returns the ORDER BY clause for an offset-paginated receipt
+// listing. Receipts without coordinates sort last by distance.
+func receiptOrder(params model.PaginationParams) string {
+	if params.SortBy == model.SortByDistance {
+		return "ORDER BY distance_km " + params.SortOrder + " NULLS LAST"
+	}
+	return fmt.Sprintf("ORDER BY %s %s", params.SortBy, params.SortOrder)
+}
@@ -0,0 +1,157 @@
+package sqlite_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// Downtown Vancouver and a few reference points around it.
+var (
+	vancouver = model.GeoPoint{Lat: 49.2827, Lng: -123.1207}
+	burnaby   = model.GeoPoint{Lat: 49.2488, Lng: -122.9805} // ~11 km east
+	richmond  = model.GeoPoint{Lat: 49.1666, Lng: -123.1336} // ~13 km south
+	seattle   = model.GeoPoint{Lat: 47.6062, Lng: -122.3321} // ~195 km south-east
+)
+
+func (e *receiptEnv) createAt(t *testing.T, id, userID string, at *model.GeoPoint) {
+	t.Helper()
+	rec := e.sampleReceipt(id, userID)
+	if at != nil {
+		rec.Latitude, rec.Longitude = &at.Lat, &at.Lng
+	}
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+}
+
+func (e *receiptEnv) listGeo(t *testing.T, geo *model.GeoFilter, sortBy, sortOrder string) []*model.Receipt {
+	t.Helper()
+	params := defaultReceiptParams()
+	params.Geo = geo
+	if sortBy != "" {
+		params.SortBy, params.SortOrder = sortBy, sortOrder
+	}
+	page, err := e.receipts.ListReceiptsByUser(e.ctx, "user-1", params)
+	if err != nil {
+		t.Fatalf("ListReceiptsByUser failed: %v", err)
+	}
+	if page.Total != len(page.Data) {
+		t.Fatalf("expected total %d to match %d receipts", page.Total, len(page.Data))
+	}
+	return page.Data
+}
+
+func newGeoEnv(t *testing.T) *receiptEnv {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.createAt(t, "r-burnaby", "user-1", &burnaby)
+	env.createAt(t, "r-richmond", "user-1", &richmond)
+	env.createAt(t, "r-seattle", "user-1", &seattle)
+	env.createAt(t, "r-nowhere", "user-1", nil)
+	return env
+}
+
+func receiptIDs(receipts []*model.Receipt) []string {
+	ids := make([]string, len(receipts))
+	for i, r := range receipts {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestHaversineKm(t *testing.T) {
+	paris := model.GeoPoint{Lat: 48.8566, Lng: 2.3522}
+	london := model.GeoPoint{Lat: 51.5074, Lng: -0.1278}
+	if d := model.HaversineKm(paris, london); math.Abs(d-343.5) > 1 {
+		t.Errorf("expected Paris-London to be ~343.5 km, got %.1f", d)
+	}
+	if d := model.HaversineKm(vancouver, vancouver); d != 0 {
+		t.Errorf("expected zero distance to self, got %f", d)
+	}
+}
+
+func TestGeo_RadiusFilter(t *testing.T) {
+	env := newGeoEnv(t)
+
+	got := env.listGeo(t, &model.GeoFilter{Center: &vancouver, RadiusKm: 20}, "", "")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 receipts within 20 km, got %v", receiptIDs(got))
+	}
+	for _, rec := range got {
+		if rec.DistanceKm == nil || *rec.DistanceKm > 20 {
+			t.Errorf("receipt %s: expected a distance within 20 km, got %v", rec.ID, rec.DistanceKm)
+		}
+	}
+
+	if got := env.listGeo(t, &model.GeoFilter{Center: &vancouver, RadiusKm: 5}, "", ""); len(got) != 0 {
+		t.Errorf("expected nothing within 5 km, got %v", receiptIDs(got))
+	}
+}
+
+func TestGeo_SortByDistance(t *testing.T) {
+	env := newGeoEnv(t)
+
+	got := env.listGeo(t, &model.GeoFilter{Center: &vancouver}, model.SortByDistance, "ASC")
+	want := []string{"r-burnaby", "r-richmond", "r-seattle", "r-nowhere"}
+	for i, id := range receiptIDs(got) {
+		if id != want[i] {
+			t.Fatalf("expected nearest first with unlocated receipts last, got %v", receiptIDs(got))
+		}
+	}
+	if got[3].DistanceKm != nil {
+		t.Error("expected no distance for a receipt without coordinates")
+	}
+	if d := *got[2].DistanceKm; math.Abs(d-model.HaversineKm(vancouver, seattle)) > 1e-6 {
+		t.Errorf("expected SQL distance to match HaversineKm, got %f", d)
+	}
+}
+
+func TestGeo_BoundingBox(t *testing.T) {
+	env := newGeoEnv(t)
+
+	box := &model.BoundingBox{South: 49.2, West: -123.5, North: 49.4, East: -122.5}
+	got := env.listGeo(t, &model.GeoFilter{Box: box}, "", "")
+	if len(got) != 1 || got[0].ID != "r-burnaby" {
+		t.Errorf("expected only Burnaby inside the box, got %v", receiptIDs(got))
+	}
+	if got[0].DistanceKm != nil {
+		t.Error("expected no distance without a center point")
+	}
+}
+
+func TestGeo_BoundingBoxAcrossAntimeridian(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.createAt(t, "r-fiji", "user-1", &model.GeoPoint{Lat: -17.7, Lng: 178.0})
+	env.createAt(t, "r-samoa", "user-1", &model.GeoPoint{Lat: -13.8, Lng: -172.1})
+	env.createAt(t, "r-sydney", "user-1", &model.GeoPoint{Lat: -33.9, Lng: 151.2})
+
+	box := &model.BoundingBox{South: -20, West: 170, North: -10, East: -170}
+	if got := env.listGeo(t, &model.GeoFilter{Box: box}, "", ""); len(got) != 2 {
+		t.Errorf("expected Fiji and Samoa, got %v", receiptIDs(got))
+	}
+}
+
+func TestGeo_SearchWithinRadius(t *testing.T) {
+	env := newGeoEnv(t)
+
+	query, err := model.ParseSearchQuery("milk")
+	if err != nil {
+		t.Fatalf("ParseSearchQuery failed: %v", err)
+	}
+	params := model.PaginationParams{
+		Limit:     20,
+		SortBy:    model.SortByDistance,
+		SortOrder: "ASC",
+		Geo:       &model.GeoFilter{Center: &vancouver, RadiusKm: 50},
+	}
+	page, err := env.receipts.SearchReceipts(env.ctx, "user-1", query, params)
+	if err != nil {
+		t.Fatalf("SearchReceipts failed: %v", err)
+	}
+	if page.Total != 2 || page.Data[0].Receipt.ID != "r-burnaby" || page.Data[1].Receipt.ID != "r-richmond" {
+		t.Errorf("expected Burnaby then Richmond, got %d hits", page.Total)
+	}
+}
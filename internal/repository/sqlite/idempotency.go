@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const idempotencyColumns = "user_id, idem_key, method, path, request_hash, status_code, response_body, response_headers, created_at, expires_at"
+
+// IdempotencyRepo implements repository.IdempotencyRepository backed by SQLite.
+type IdempotencyRepo struct {
+	db *DB
+}
+
+// NewIdempotencyRepo creates a new SQLite-backed idempotency key repository.
+func NewIdempotencyRepo(db *DB) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db}
+}
+
+func (r *IdempotencyRepo) Reserve(ctx context.Context, record *model.IdempotencyRecord) (bool, error) {
+	// An expired record no longer blocks the key, so clear it out first.
+	if _, err := r.db.conn.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idem_key = ? AND expires_at <= ?`,
+		record.UserID, record.Key, time.Now().Unix(),
+	); err != nil {
+		return false, fmt.Errorf("clear expired idempotency key: %w", err)
+	}
+
+	query := `INSERT INTO idempotency_keys (` + idempotencyColumns + `) VALUES (?, ?, ?, ?, ?, 0, '', '{}', ?, ?)
+		ON CONFLICT (user_id, idem_key) DO NOTHING`
+	result, err := r.db.conn.ExecContext(ctx, query,
+		record.UserID, record.Key, record.Method, record.Path, record.RequestHash,
+		record.CreatedAt, record.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (r *IdempotencyRepo) Get(ctx context.Context, userID, key string) (*model.IdempotencyRecord, error) {
+	query := `SELECT ` + idempotencyColumns + ` FROM idempotency_keys WHERE user_id = ? AND idem_key = ? AND expires_at > ?`
+	row := r.db.conn.QueryRowContext(ctx, query, userID, key, time.Now().Unix())
+
+	var rec model.IdempotencyRecord
+	var headers string
+	err := row.Scan(&rec.UserID, &rec.Key, &rec.Method, &rec.Path, &rec.RequestHash,
+		&rec.StatusCode, &rec.ResponseBody, &headers, &rec.CreatedAt, &rec.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+	if err := json.Unmarshal([]byte(headers), &rec.ResponseHeaders); err != nil {
+		return nil, fmt.Errorf("decode idempotency response headers: %w", err)
+	}
+	return &rec, nil
+}
+
+func (r *IdempotencyRepo) Complete(ctx context.Context, userID, key string, statusCode int, headers map[string][]string, body []byte) error {
+	if body == nil {
+		body = []byte{}
+	}
+	if headers == nil {
+		headers = map[string][]string{}
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return fmt.Errorf("encode idempotency response headers: %w", err)
+	}
+	_, err = r.db.conn.ExecContext(ctx,
+		`UPDATE idempotency_keys SET status_code = ?, response_headers = ?, response_body = ? WHERE user_id = ? AND idem_key = ?`,
+		statusCode, string(encoded), body, userID, key)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) Delete(ctx context.Context, userID, key string) error {
+	_, err := r.db.conn.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idem_key = ?`, userID, key)
+	if err != nil {
+		return fmt.Errorf("delete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.conn.ExecContext(ctx,
+		`DELETE FROM idempotency_keys WHERE expires_at <= ?`, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,139 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+type idempotencyEnv struct {
+	store *sqlite.IdempotencyRepo
+	users *sqlite.UserRepo
+	ctx   context.Context
+}
+
+func newIdempotencyEnv(t *testing.T) *idempotencyEnv {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	env := &idempotencyEnv{
+		store: sqlite.NewIdempotencyRepo(db),
+		users: sqlite.NewUserRepo(db),
+		ctx:   context.Background(),
+	}
+	mustCreateUser(t, env.users, env.ctx, &model.User{
+		ID: "user-1", Username: "user-1", PasswordHash: "hash", Role: model.RoleUser,
+	})
+	return env
+}
+
+func sampleIdempotencyRecord(key string, ttl time.Duration) *model.IdempotencyRecord {
+	now := time.Now()
+	return &model.IdempotencyRecord{
+		UserID:      "user-1",
+		Key:         key,
+		Method:      "POST",
+		Path:        "/api/v1/receipts",
+		RequestHash: "hash-1",
+		CreatedAt:   now.Unix(),
+		ExpiresAt:   now.Add(ttl).Unix(),
+	}
+}
+
+func TestIdempotency_ReserveCompleteAndGet(t *testing.T) {
+	env := newIdempotencyEnv(t)
+
+	ok, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("key-1", time.Hour))
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected first Reserve to succeed")
+	}
+
+	got, err := env.store.Get(env.ctx, "user-1", "key-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got == nil || got.Completed() {
+		t.Fatalf("expected a pending record, got %+v", got)
+	}
+
+	headers := map[string][]string{"Etag": {`"1"`}, "Location": {"/api/v1/receipts/r-1"}}
+	if err := env.store.Complete(env.ctx, "user-1", "key-1", 201, headers, []byte(`{"id":"r-1"}`)); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	got, err = env.store.Get(env.ctx, "user-1", "key-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.StatusCode != 201 || string(got.ResponseBody) != `{"id":"r-1"}` {
+		t.Errorf("unexpected stored response: %d %s", got.StatusCode, got.ResponseBody)
+	}
+	if got.ResponseHeaders["Location"][0] != "/api/v1/receipts/r-1" || got.ResponseHeaders["Etag"][0] != `"1"` {
+		t.Errorf("unexpected stored headers: %v", got.ResponseHeaders)
+	}
+}
+
+func TestIdempotency_ReserveTwice(t *testing.T) {
+	env := newIdempotencyEnv(t)
+
+	if _, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("key-1", time.Hour)); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	ok, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("key-1", time.Hour))
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if ok {
+		t.Error("expected second Reserve of the same key to fail")
+	}
+}
+
+func TestIdempotency_ExpiredKeyCanBeReused(t *testing.T) {
+	env := newIdempotencyEnv(t)
+
+	if _, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("key-1", -time.Second)); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	got, err := env.store.Get(env.ctx, "user-1", "key-1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != nil {
+		t.Error("expected expired record to be hidden")
+	}
+
+	ok, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("key-1", time.Hour))
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if !ok {
+		t.Error("expected Reserve to succeed once the old record expired")
+	}
+}
+
+func TestIdempotency_DeleteExpired(t *testing.T) {
+	env := newIdempotencyEnv(t)
+
+	if _, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("old", -time.Second)); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if _, err := env.store.Reserve(env.ctx, sampleIdempotencyRecord("fresh", time.Hour)); err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+
+	n, err := env.store.DeleteExpired(env.ctx)
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired record deleted, got %d", n)
+	}
+	if got, _ := env.store.Get(env.ctx, "user-1", "fresh"); got == nil {
+		t.Error("expected unexpired record to survive the sweep")
+	}
+}
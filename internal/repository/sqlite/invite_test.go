@@ -0,0 +1,137 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func newInviteStore(t *testing.T) (*sqlite.InviteStore, *sqlite.UserRepo) {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	users := sqlite.NewUserRepo(db)
+	mustCreateUser(t, users, context.Background(), &model.User{
+		ID: "admin-1", Username: "admin", PasswordHash: "hash", Role: model.RoleAdmin,
+	})
+	return sqlite.NewInviteStore(db), users
+}
+
+func TestInvite_CreateAndList(t *testing.T) {
+	store, _ := newInviteStore(t)
+	ctx := context.Background()
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-1", CreatedBy: "admin-1", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-1")
+	if err := store.Create(ctx, &model.Invite{ID: "inv-2", MaxUses: 3, ExpiresAt: 500, CreatedAt: 200}, "hash-2"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Create(ctx, &model.Invite{ID: "inv-3", MaxUses: 1, ExpiresAt: 500, CreatedAt: 300}, "hash-1"); err == nil {
+		t.Error("expected an error for a duplicate code hash")
+	}
+
+	invites, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(invites) != 2 || invites[0].ID != "inv-2" || invites[1].ID != "inv-1" {
+		t.Fatalf("expected inv-2 then inv-1, got %+v", invites)
+	}
+	if invites[0].CreatedBy != "" || invites[1].CreatedBy != "admin-1" || invites[0].MaxUses != 3 {
+		t.Errorf("unexpected invites: %+v, %+v", invites[0], invites[1])
+	}
+}
+
+func TestInvite_Redeem(t *testing.T) {
+	store, _ := newInviteStore(t)
+	ctx := context.Background()
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-1", MaxUses: 2, ExpiresAt: 500, CreatedAt: 100}, "hash-1")
+
+	for i := 0; i < 2; i++ {
+		if ok, err := store.Redeem(ctx, "hash-1", 200); err != nil || !ok {
+			t.Fatalf("redeem %d: expected ok, got %v, %v", i+1, ok, err)
+		}
+	}
+	if ok, _ := store.Redeem(ctx, "hash-1", 200); ok {
+		t.Error("expected a used-up invite to be refused")
+	}
+	if ok, _ := store.Redeem(ctx, "unknown", 200); ok {
+		t.Error("expected an unknown code to be refused")
+	}
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-2", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-2")
+	if ok, _ := store.Redeem(ctx, "hash-2", 500); ok {
+		t.Error("expected an expired invite to be refused")
+	}
+
+	invites, _ := store.List(ctx)
+	for _, inv := range invites {
+		if inv.ID == "inv-1" && (inv.Uses != 2 || inv.Usable(200)) {
+			t.Errorf("expected inv-1 used up, got %+v", inv)
+		}
+		if inv.ID == "inv-2" && inv.Uses != 0 {
+			t.Errorf("expected refused redeems not to count, got %+v", inv)
+		}
+	}
+}
+
+func TestInvite_Release(t *testing.T) {
+	store, _ := newInviteStore(t)
+	ctx := context.Background()
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-1", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-1")
+	_, _ = store.Redeem(ctx, "hash-1", 200)
+	if err := store.Release(ctx, "hash-1"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if ok, _ := store.Redeem(ctx, "hash-1", 200); !ok {
+		t.Error("expected a released use to be redeemable again")
+	}
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-2", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-2")
+	if err := store.Release(ctx, "hash-2"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if err := store.Release(ctx, "unknown"); err != nil {
+		t.Errorf("expected an unknown code to be ignored, got %v", err)
+	}
+	invites, _ := store.List(ctx)
+	for _, inv := range invites {
+		if inv.ID == "inv-2" && inv.Uses != 0 {
+			t.Errorf("expected uses not to go below zero, got %+v", inv)
+		}
+	}
+}
+
+func TestInvite_Delete(t *testing.T) {
+	store, _ := newInviteStore(t)
+	ctx := context.Background()
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-1", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-1")
+	if err := store.Delete(ctx, "inv-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, _ := store.Redeem(ctx, "hash-1", 200); ok {
+		t.Error("expected a deleted invite to be refused")
+	}
+	if err := store.Delete(ctx, "inv-1"); !errors.Is(err, model.ErrInviteNotFound) {
+		t.Errorf("expected ErrInviteNotFound, got %v", err)
+	}
+}
+
+func TestInvite_CreatorDeletedKeepsInvite(t *testing.T) {
+	store, users := newInviteStore(t)
+	ctx := context.Background()
+
+	_ = store.Create(ctx, &model.Invite{ID: "inv-1", CreatedBy: "admin-1", MaxUses: 1, ExpiresAt: 500, CreatedAt: 100}, "hash-1")
+	if err := users.DeleteUser(ctx, "admin-1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	invites, _ := store.List(ctx)
+	if len(invites) != 1 || invites[0].CreatedBy != "" {
+		t.Errorf("expected the invite to remain without a creator, got %+v", invites)
+	}
+}
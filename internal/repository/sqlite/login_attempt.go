@@ -0,0 +1,64 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// LoginAttemptStore is a SQLite-backed implementation of auth.LoginAttemptStore.
+type LoginAttemptStore struct {
+	db *DB
+}
+
+// NewLoginAttemptStore creates a new SQLite-backed failed login store.
+func NewLoginAttemptStore(db *DB) *LoginAttemptStore {
+	return &LoginAttemptStore{db: db}
+}
+
+func (s *LoginAttemptStore) Get(ctx context.Context, subject model.LoginSubject, key string) (*model.LoginAttempts, error) {
+	a := model.LoginAttempts{Subject: subject, Key: key}
+	err := s.db.conn.QueryRowContext(ctx,
+		`SELECT failures, last_failure_at FROM login_attempts WHERE subject = ? AND subject_key = ?`, subject, key,
+	).Scan(&a.Failures, &a.LastFailureAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get login attempts: %w", err)
+	}
+	return &a, nil
+}
+
+func (s *LoginAttemptStore) RecordFailure(ctx context.Context, subject model.LoginSubject, key string, at, resetBefore int64) error {
+	_, err := s.db.conn.ExecContext(ctx,
+		`INSERT INTO login_attempts (subject, subject_key, failures, last_failure_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT (subject, subject_key) DO UPDATE SET
+			failures = CASE WHEN login_attempts.last_failure_at < ? THEN 1 ELSE login_attempts.failures + 1 END,
+			last_failure_at = excluded.last_failure_at`,
+		subject, key, at, resetBefore)
+	if err != nil {
+		return fmt.Errorf("record failed login: %w", err)
+	}
+	return nil
+}
+
+func (s *LoginAttemptStore) Reset(ctx context.Context, subject model.LoginSubject, key string) error {
+	_, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM login_attempts WHERE subject = ? AND subject_key = ?`, subject, key)
+	if err != nil {
+		return fmt.Errorf("reset login attempts: %w", err)
+	}
+	return nil
+}
+
+func (s *LoginAttemptStore) DeleteStale(ctx context.Context, before int64) (int64, error) {
+	result, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM login_attempts WHERE last_failure_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("delete stale login attempts: %w", err)
+	}
+	return result.RowsAffected()
+}
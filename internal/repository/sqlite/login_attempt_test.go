@@ -0,0 +1,85 @@
+package sqlite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func TestLoginAttempts_RecordFailure(t *testing.T) {
+	store := sqlite.NewLoginAttemptStore(testutil.NewTestDB(t))
+	ctx := context.Background()
+
+	got, err := store.Get(ctx, model.LoginSubjectUsername, "alice")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("expected nil before any failure, got %+v", got)
+	}
+
+	for _, at := range []int64{100, 200, 300} {
+		if err := store.RecordFailure(ctx, model.LoginSubjectUsername, "alice", at, 0); err != nil {
+			t.Fatalf("RecordFailure failed: %v", err)
+		}
+	}
+	got, err = store.Get(ctx, model.LoginSubjectUsername, "alice")
+	if err != nil || got == nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Failures != 3 || got.LastFailureAt != 300 {
+		t.Errorf("expected 3 failures, the last at 300, got %d at %d", got.Failures, got.LastFailureAt)
+	}
+
+	// Usernames and IPs are counted apart, even when the keys match.
+	if other, _ := store.Get(ctx, model.LoginSubjectIP, "alice"); other != nil {
+		t.Errorf("expected no IP count, got %+v", other)
+	}
+}
+
+func TestLoginAttempts_RecordFailureStartsOverAfterReset(t *testing.T) {
+	store := sqlite.NewLoginAttemptStore(testutil.NewTestDB(t))
+	ctx := context.Background()
+
+	_ = store.RecordFailure(ctx, model.LoginSubjectIP, "192.0.2.1", 100, 0)
+	_ = store.RecordFailure(ctx, model.LoginSubjectIP, "192.0.2.1", 200, 0)
+	// The last failure, at 200, is before resetBefore, so the count restarts.
+	if err := store.RecordFailure(ctx, model.LoginSubjectIP, "192.0.2.1", 1000, 500); err != nil {
+		t.Fatalf("RecordFailure failed: %v", err)
+	}
+
+	got, _ := store.Get(ctx, model.LoginSubjectIP, "192.0.2.1")
+	if got == nil || got.Failures != 1 || got.LastFailureAt != 1000 {
+		t.Errorf("expected 1 failure at 1000, got %+v", got)
+	}
+}
+
+func TestLoginAttempts_ResetAndDeleteStale(t *testing.T) {
+	store := sqlite.NewLoginAttemptStore(testutil.NewTestDB(t))
+	ctx := context.Background()
+
+	_ = store.RecordFailure(ctx, model.LoginSubjectUsername, "alice", 100, 0)
+	_ = store.RecordFailure(ctx, model.LoginSubjectUsername, "bob", 100, 0)
+	_ = store.RecordFailure(ctx, model.LoginSubjectIP, "192.0.2.1", 900, 0)
+
+	if err := store.Reset(ctx, model.LoginSubjectUsername, "alice"); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, model.LoginSubjectUsername, "alice"); got != nil {
+		t.Errorf("expected alice's count to be cleared, got %+v", got)
+	}
+
+	n, err := store.DeleteStale(ctx, 500)
+	if err != nil {
+		t.Fatalf("DeleteStale failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 stale count deleted, got %d", n)
+	}
+	if got, _ := store.Get(ctx, model.LoginSubjectIP, "192.0.2.1"); got == nil {
+		t.Error("expected the recent IP count to be kept")
+	}
+}
@@ -2,6 +2,7 @@ package sqlite_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/gatheryourdeals/data/internal/model"
@@ -33,6 +34,7 @@ func TestMetaField_NativeFieldsSeeded(t *testing.T) {
 		"storeName":    true,
 		"latitude":     true,
 		"longitude":    true,
+		"barcode":      true,
 	}
 
 	if page.Total != len(expected) {
@@ -150,7 +152,7 @@ func TestMetaField_UpdateDescription(t *testing.T) {
 		t.Fatalf("CreateField failed: %v", err)
 	}
 
-	if err := repo.UpdateDescription(ctx, "brand", "updated description"); err != nil {
+	if err := repo.UpdateDescription(ctx, "brand", "updated description", 0); err != nil {
 		t.Fatalf("UpdateDescription failed: %v", err)
 	}
 
@@ -168,7 +170,7 @@ func TestMetaField_UpdateDescription_NotFound(t *testing.T) {
 	repo := sqlite.NewMetaFieldRepo(db)
 	ctx := context.Background()
 
-	err := repo.UpdateDescription(ctx, "nonexistent", "new desc")
+	err := repo.UpdateDescription(ctx, "nonexistent", "new desc", 0)
 	if err == nil {
 		t.Fatal("expected error for nonexistent field, got nil")
 	}
@@ -193,9 +195,9 @@ func TestMetaField_ListIncludesUserDefined(t *testing.T) {
 		t.Fatalf("ListFields failed: %v", err)
 	}
 
-	// 7 native + 1 user-defined
-	if page.Total != 8 {
-		t.Fatalf("expected total 8, got %d", page.Total)
+	// 8 native + 1 user-defined
+	if page.Total != 9 {
+		t.Fatalf("expected total 9, got %d", page.Total)
 	}
 
 	found := false
@@ -217,7 +219,7 @@ func TestMetaField_Pagination_LimitOffset(t *testing.T) {
 	repo := sqlite.NewMetaFieldRepo(db)
 	ctx := context.Background()
 
-	// 7 native fields already seeded; add 3 more to get 10 total.
+	// 8 native fields already seeded; add 3 more to get 11 total.
 	for _, name := range []string{"brand", "color", "size"} {
 		if err := repo.CreateField(ctx, &model.MetaField{
 			FieldName: name, Description: name, FieldType: "string",
@@ -231,14 +233,14 @@ func TestMetaField_Pagination_LimitOffset(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ListFields failed: %v", err)
 	}
-	if page.Total != 10 {
-		t.Errorf("expected total 10, got %d", page.Total)
+	if page.Total != 11 {
+		t.Errorf("expected total 11, got %d", page.Total)
 	}
 	if len(page.Data) != 3 {
 		t.Errorf("expected 3 items in page, got %d", len(page.Data))
 	}
 	if page.TotalPages != 4 {
-		t.Errorf("expected total_pages 4 (10/3 = ceil 4), got %d", page.TotalPages)
+		t.Errorf("expected total_pages 4 (11/3 = ceil 4), got %d", page.TotalPages)
 	}
 }
 
@@ -252,9 +254,9 @@ func TestMetaField_Pagination_OffsetBeyondTotal(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ListFields failed: %v", err)
 	}
-	// 7 native fields are seeded.
-	if page.Total != 7 {
-		t.Errorf("expected total 7, got %d", page.Total)
+	// 8 native fields are seeded.
+	if page.Total != 8 {
+		t.Errorf("expected total 8, got %d", page.Total)
 	}
 	if len(page.Data) != 0 {
 		t.Errorf("expected empty data when offset > total, got %d items", len(page.Data))
@@ -279,3 +281,84 @@ func TestMetaField_Pagination_SortDesc(t *testing.T) {
 		t.Errorf("expected descending order, got %q then %q", page.Data[0].FieldName, page.Data[1].FieldName)
 	}
 }
+
+func TestMetaField_UpdateDescription_BumpsVersion(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewMetaFieldRepo(db)
+	ctx := context.Background()
+
+	field := &model.MetaField{FieldName: "brand", Description: "original", FieldType: "string"}
+	if err := repo.CreateField(ctx, field); err != nil {
+		t.Fatalf("CreateField failed: %v", err)
+	}
+	if field.Version != 1 {
+		t.Fatalf("expected new field at version 1, got %d", field.Version)
+	}
+
+	if err := repo.UpdateDescription(ctx, "brand", "second", 1); err != nil {
+		t.Fatalf("UpdateDescription failed: %v", err)
+	}
+	got, err := repo.GetField(ctx, "brand")
+	if err != nil {
+		t.Fatalf("GetField failed: %v", err)
+	}
+	if got.Version != 2 {
+		t.Errorf("expected version 2 after update, got %d", got.Version)
+	}
+}
+
+func TestMetaField_UpdateDescription_StaleVersion(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewMetaFieldRepo(db)
+	ctx := context.Background()
+
+	if err := repo.CreateField(ctx, &model.MetaField{FieldName: "brand", Description: "original", FieldType: "string"}); err != nil {
+		t.Fatalf("CreateField failed: %v", err)
+	}
+	if err := repo.UpdateDescription(ctx, "brand", "first writer", 1); err != nil {
+		t.Fatalf("UpdateDescription failed: %v", err)
+	}
+
+	err := repo.UpdateDescription(ctx, "brand", "second writer", 1)
+	if !errors.Is(err, model.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	got, _ := repo.GetField(ctx, "brand")
+	if got.Description != "first writer" {
+		t.Errorf("expected stale write to be rejected, description is %q", got.Description)
+	}
+}
+
+func TestMetaField_Cursor_WalksNativeFields(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewMetaFieldRepo(db)
+	ctx := context.Background()
+
+	params := model.CursorParams{Limit: 3, SortBy: "field_name", SortOrder: "ASC"}
+	var names []string
+	for {
+		page, err := repo.ListFieldsCursor(ctx, params)
+		if err != nil {
+			t.Fatalf("ListFieldsCursor failed: %v", err)
+		}
+		for _, f := range page.Data {
+			names = append(names, f.FieldName)
+		}
+		if !page.HasMore {
+			break
+		}
+		if params.After, err = model.DecodeCursor(page.NextCursor); err != nil {
+			t.Fatalf("DecodeCursor failed: %v", err)
+		}
+	}
+
+	if len(names) != 8 {
+		t.Fatalf("expected 8 native fields, got %d: %v", len(names), names)
+	}
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Errorf("expected ascending order, got %v", names)
+			break
+		}
+	}
+}
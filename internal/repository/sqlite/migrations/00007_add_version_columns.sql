@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE receipts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE meta_fields ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+ALTER TABLE meta_fields DROP COLUMN version;
+ALTER TABLE receipts DROP COLUMN version;
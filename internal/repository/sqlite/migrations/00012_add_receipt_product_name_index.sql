@@ -0,0 +1,7 @@
+-- +goose Up
+-- Price history matches receipts that are not linked to a catalog product
+-- by case-insensitive product name.
+CREATE INDEX idx_receipts_product_name ON receipts (lower(product_name));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_receipts_product_name;
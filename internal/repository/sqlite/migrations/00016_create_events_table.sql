@@ -0,0 +1,15 @@
+-- +goose Up
+-- Append-only log of change events, written in the same transaction as the
+-- change. IDs double as SSE event IDs for resuming a stream.
+CREATE TABLE events (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    type       TEXT    NOT NULL,
+    user_id    TEXT    NOT NULL DEFAULT '',
+    payload    TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_events_user ON events (user_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- One unit of base was worth rate units of quote from rate_date (YYYY-MM-DD)
+-- until the pair's next rate.
+CREATE TABLE exchange_rates (
+    base      TEXT NOT NULL,
+    quote     TEXT NOT NULL,
+    rate_date TEXT NOT NULL,
+    rate      REAL NOT NULL,
+    PRIMARY KEY (base, quote, rate_date)
+);
+
+CREATE INDEX idx_exchange_rates_quote ON exchange_rates (quote, base, rate_date);
+
+-- +goose Down
+DROP TABLE IF EXISTS exchange_rates;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- A family is the chain of refresh tokens rotated from one login. Rotated
+-- tokens are kept with used_at set, so that replaying one is detected and
+-- revokes the whole family. Existing tokens each start their own family.
+ALTER TABLE refresh_tokens ADD COLUMN family_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN used_at INTEGER;
+UPDATE refresh_tokens SET family_id = token;
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+DELETE FROM refresh_tokens WHERE used_at IS NOT NULL;
+ALTER TABLE refresh_tokens DROP COLUMN used_at;
+ALTER TABLE refresh_tokens DROP COLUMN family_id;
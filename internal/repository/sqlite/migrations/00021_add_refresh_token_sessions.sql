@@ -0,0 +1,20 @@
+-- +goose Up
+-- Each token records the session (token family) it belongs to: the device
+-- named at login, the user agent and IP address of the latest refresh, when
+-- the session started and when it was last used. Tokens issued before this
+-- migration count as started and used now.
+ALTER TABLE refresh_tokens ADD COLUMN device TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE refresh_tokens ADD COLUMN last_used_at INTEGER NOT NULL DEFAULT 0;
+UPDATE refresh_tokens SET created_at = CAST(strftime('%s', 'now') AS INTEGER), last_used_at = CAST(strftime('%s', 'now') AS INTEGER);
+CREATE INDEX idx_refresh_tokens_user ON refresh_tokens (user_id, last_used_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_refresh_tokens_user;
+ALTER TABLE refresh_tokens DROP COLUMN last_used_at;
+ALTER TABLE refresh_tokens DROP COLUMN created_at;
+ALTER TABLE refresh_tokens DROP COLUMN ip_address;
+ALTER TABLE refresh_tokens DROP COLUMN user_agent;
+ALTER TABLE refresh_tokens DROP COLUMN device;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- A user's TOTP secret. enabled_at is NULL until the user confirms the
+-- enrollment with a code. last_step is the time step of the last accepted
+-- code, so a code cannot be replayed.
+CREATE TABLE two_factor (
+    user_id    TEXT    PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    secret     TEXT    NOT NULL,
+    last_step  INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL,
+    enabled_at INTEGER
+);
+
+-- One-time recovery codes, stored as SHA-256 hashes. A code is deleted
+-- when it is used.
+CREATE TABLE recovery_codes (
+    user_id   TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+-- Whether the session was started with a second factor.
+ALTER TABLE refresh_tokens ADD COLUMN two_factor INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE refresh_tokens DROP COLUMN two_factor;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS two_factor;
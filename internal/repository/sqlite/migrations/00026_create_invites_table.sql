@@ -0,0 +1,16 @@
+-- +goose Up
+-- Invite codes for invite-only registration, stored as SHA-256 hashes.
+-- Each registration with a code uses it once; it stops working at max_uses
+-- or at expires_at. created_by is NULL for invites created from the CLI.
+CREATE TABLE invites (
+    id         TEXT    PRIMARY KEY,
+    code_hash  TEXT    NOT NULL UNIQUE,
+    created_by TEXT    REFERENCES users(id) ON DELETE SET NULL,
+    max_uses   INTEGER NOT NULL,
+    uses       INTEGER NOT NULL DEFAULT 0,
+    expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS invites;
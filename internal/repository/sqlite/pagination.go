@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// keysetCondition returns the WHERE fragment selecting rows that come after
+// params.After in the (SortBy, idColumn) ordering, together with its arguments.
+// It returns an empty fragment for the first page.
+func keysetCondition(params model.CursorParams, idColumn string) (string, []interface{}) {
+	if params.After == nil {
+		return "", nil
+	}
+	op := ">"
+	if params.SortOrder == "DESC" {
+		op = "<"
+	}
+	cond := fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))",
+		params.SortBy, op, params.SortBy, idColumn, op)
+	return cond, []interface{}{params.After.Value, params.After.Value, params.After.ID}
+}
+
+// keysetOrder returns the ORDER BY clause matching keysetCondition.
+func keysetOrder(params model.CursorParams, idColumn string) string {
+	return fmt.Sprintf("ORDER BY %s %s, %s %s", params.SortBy, params.SortOrder, idColumn, params.SortOrder)
+}
+
+// trimCursorPage cuts a result fetched with LIMIT n+1 down to n rows and, if
+// there were more, fills in HasMore and the cursor pointing after the last row.
+func trimCursorPage[T any](page *model.CursorPage[T], rows []T, params model.CursorParams, position func(T) (interface{}, string)) {
+	if len(rows) > params.Limit {
+		rows = rows[:params.Limit]
+		value, id := position(rows[len(rows)-1])
+		page.HasMore = true
+		page.NextCursor = model.Cursor{
+			SortBy:    params.SortBy,
+			SortOrder: params.SortOrder,
+			Value:     value,
+			ID:        id,
+		}.Encode()
+	}
+	page.Data = rows
+}
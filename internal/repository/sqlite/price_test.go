@@ -0,0 +1,214 @@
+package sqlite_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+type priceEnv struct {
+	*productEnv
+	prices *sqlite.PriceRepo
+}
+
+func newPriceEnv(t *testing.T) *priceEnv {
+	t.Helper()
+	env := newProductEnv(t)
+	return &priceEnv{productEnv: env, prices: sqlite.NewPriceRepo(env.db)}
+}
+
+func (e *priceEnv) purchase(t *testing.T, id, productName, date, price, amount, store string) {
+	t.Helper()
+	rec := e.sampleReceipt(id, "user-1")
+	rec.ProductName, rec.PurchaseDate, rec.Price, rec.Amount, rec.StoreName = productName, date, price, amount, store
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseUnitPrice(t *testing.T) {
+	cases := []struct {
+		price, amount string
+		want          model.UnitPrice
+	}{
+		{"5.49CAD", "1", model.UnitPrice{Value: 5.49, Currency: "CAD", Unit: model.UnitEach}},
+		{"10.00 usd", "2lb", model.UnitPrice{Value: 10 / (2 * 0.45359237), Currency: "USD", Unit: model.UnitKilogram}},
+		{"$3", "500 g", model.UnitPrice{Value: 6, Unit: model.UnitKilogram}},
+		{"€4,50", "750ml", model.UnitPrice{Value: 6, Currency: "EUR", Unit: model.UnitLitre}},
+		{"6.00CAD", "2(L)", model.UnitPrice{Value: 3, Currency: "CAD", Unit: model.UnitLitre}},
+		{"4.00CAD", "2 bunch", model.UnitPrice{Value: 2, Currency: "CAD", Unit: "bunch"}},
+	}
+	for _, c := range cases {
+		got, ok := model.ParseUnitPrice(c.price, c.amount)
+		if !ok || !approxEqual(got.Value, c.want.Value) || got.Currency != c.want.Currency || got.Unit != c.want.Unit {
+			t.Errorf("ParseUnitPrice(%q, %q) = %+v, %v; want %+v", c.price, c.amount, got, ok, c.want)
+		}
+	}
+	for _, in := range [][2]string{{"free", "1"}, {"5.49CAD", "0"}, {"5.49CAD", "some"}, {"5.49 dollars", "1"}, {"", "1"}} {
+		if got, ok := model.ParseUnitPrice(in[0], in[1]); ok {
+			t.Errorf("ParseUnitPrice(%q, %q) = %+v, expected failure", in[0], in[1], got)
+		}
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	date := time.Date(2025, 4, 5, 0, 0, 0, 0, time.UTC) // a Saturday
+	if got := model.BucketStart(date, model.BucketWeek).Format("2006-01-02"); got != "2025-03-31" {
+		t.Errorf("week bucket = %s, want 2025-03-31", got)
+	}
+	if got := model.BucketStart(date, model.BucketMonth).Format("2006-01-02"); got != "2025-04-01" {
+		t.Errorf("month bucket = %s, want 2025-04-01", got)
+	}
+}
+
+func TestPriceHistory_ByProductAcrossStores(t *testing.T) {
+	env := newPriceEnv(t)
+	env.createProduct(t, &model.Product{ID: "p-milk", Name: "Milk 4L", Aliases: []string{"Homo Milk 4L"}})
+
+	env.purchase(t, "r1", "Milk 4L", "2025.04.01", "5.60CAD", "4L", "Costco")
+	env.purchase(t, "r2", "Homo Milk 4L", "2025.04.03", "6.00CAD", "4L", "Walmart")
+	env.purchase(t, "r3", "milk 4l", "2025.04.04", "4.80CAD", "4L", "Costco")
+	env.purchase(t, "r4", "Milk 4L", "2025.04.10", "6.40CAD", "4L", "Walmart")
+	env.purchase(t, "r5", "Milk 4L", "2025.04.11", "4.99USD", "4L", "Target")
+	env.purchase(t, "r6", "Milk 4L", "sometime", "5.00CAD", "4L", "Costco") // unparseable date
+	env.purchase(t, "r7", "Bread", "2025.04.02", "3.00CAD", "1", "Costco")
+
+	history, err := env.prices.PriceHistory(env.ctx, model.PriceFilter{ProductID: "p-milk"}, model.BucketWeek)
+	if err != nil {
+		t.Fatalf("PriceHistory failed: %v", err)
+	}
+	if len(history.Series) != 2 {
+		t.Fatalf("expected CAD and USD series, got %d", len(history.Series))
+	}
+
+	cad := history.Series[0]
+	if cad.Currency != "CAD" || cad.Unit != model.UnitLitre || cad.Count != 4 {
+		t.Fatalf("unexpected first series: %+v", cad)
+	}
+	if !approxEqual(cad.Min, 1.2) || !approxEqual(cad.Max, 1.6) || !approxEqual(cad.Median, 1.45) {
+		t.Errorf("series stats = %+v, want min 1.2 max 1.6 median 1.45", cad.PriceStats)
+	}
+	if len(cad.Buckets) != 2 || cad.Buckets[0].Start != "2025-03-31" || cad.Buckets[1].Start != "2025-04-07" {
+		t.Fatalf("unexpected buckets: %+v", cad.Buckets)
+	}
+	week1 := cad.Buckets[0]
+	if week1.Count != 3 || !approxEqual(week1.Median, 1.4) {
+		t.Errorf("week 1 stats = %+v, want count 3 median 1.4", week1.PriceStats)
+	}
+	if len(week1.Stores) != 2 || week1.Stores[0].StoreName != "Costco" || !approxEqual(week1.Stores[0].Min, 1.2) || week1.Stores[0].Count != 2 {
+		t.Errorf("week 1 stores = %+v, want Costco cheapest at 1.2 over 2 receipts", week1.Stores)
+	}
+
+	if usd := history.Series[1]; usd.Currency != "USD" || usd.Count != 1 {
+		t.Errorf("unexpected second series: %+v", usd)
+	}
+}
+
+func TestPriceHistory_ByNameAndDateRange(t *testing.T) {
+	env := newPriceEnv(t)
+	env.purchase(t, "r1", "Bananas", "2025.01.15", "1.00CAD", "1lb", "Costco")
+	env.purchase(t, "r2", "bananas", "2025.02.20", "0.90CAD", "1lb", "Costco")
+	env.purchase(t, "r3", "BANANAS", "2025.02.25", "1.10CAD", "1lb", "Walmart")
+	env.purchase(t, "r4", "Bananas", "2025.03.01", "0.80CAD", "1lb", "Costco")
+	env.purchase(t, "r5", "Bananas", "2025.02.28", "0.95CAD", "1lb", "Costco") // on the last day
+
+	filter := model.PriceFilter{
+		ProductName: "Bananas",
+		From:        time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2025, 2, 28, 0, 0, 0, 0, time.UTC),
+	}
+	history, err := env.prices.PriceHistory(env.ctx, filter, model.BucketMonth)
+	if err != nil {
+		t.Fatalf("PriceHistory failed: %v", err)
+	}
+	if len(history.Series) != 1 || history.Series[0].Count != 3 {
+		t.Fatalf("expected one series of 3 prices, got %+v", history.Series)
+	}
+	buckets := history.Series[0].Buckets
+	if len(buckets) != 1 || buckets[0].Start != "2025-02-01" {
+		t.Errorf("expected a single February bucket, got %+v", buckets)
+	}
+	if got := history.Series[0].Unit; got != model.UnitKilogram {
+		t.Errorf("unit = %q, want kg", got)
+	}
+}
+
+func TestPriceHistory_NoReceipts(t *testing.T) {
+	env := newPriceEnv(t)
+	history, err := env.prices.PriceHistory(env.ctx, model.PriceFilter{ProductName: "Nothing"}, model.BucketWeek)
+	if err != nil {
+		t.Fatalf("PriceHistory failed: %v", err)
+	}
+	if history.Series == nil || len(history.Series) != 0 {
+		t.Errorf("expected an empty series list, got %+v", history.Series)
+	}
+}
+
+func TestDeals_RanksStoresAgainstMedian(t *testing.T) {
+	env := newPriceEnv(t)
+	lat, lng := 49.1666, -123.1336
+	if err := sqlite.NewStoreRepo(env.db).CreateStore(env.ctx, &model.Store{
+		ID: "s-costco", Name: "Costco Richmond", Latitude: &lat, Longitude: &lng, Aliases: []string{"Costco"},
+	}); err != nil {
+		t.Fatalf("CreateStore failed: %v", err)
+	}
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createProduct(t, &model.Product{ID: "p-milk", Name: "Milk 4L", CategoryID: "c-dairy"})
+	env.createProduct(t, &model.Product{ID: "p-cheese", Name: "Cheddar", CategoryID: "c-dairy"})
+
+	env.purchase(t, "r1", "Milk 4L", "2025.01.10", "6.00CAD", "4L", "Costco") // before the window
+	env.purchase(t, "r2", "Milk 4L", "2025.03.02", "5.20CAD", "4L", "Costco")
+	env.purchase(t, "r3", "Milk 4L", "2025.03.05", "4.80CAD", "4L", "Costco")
+	env.purchase(t, "r4", "Milk 4L", "2025.03.04", "5.60CAD", "4L", "Walmart")
+	env.purchase(t, "r5", "Cheddar", "2025.03.04", "9.00CAD", "500g", "Walmart")
+
+	filter := model.PriceFilter{ProductID: "p-milk", From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)}
+	report, err := env.prices.Deals(env.ctx, filter, nil)
+	if err != nil {
+		t.Fatalf("Deals failed: %v", err)
+	}
+	if report.From != "2025-03-01" || len(report.Groups) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	deals := report.Groups[0].Deals
+	if len(deals) != 2 {
+		t.Fatalf("expected 2 stores, got %d", len(deals))
+	}
+	costco, walmart := deals[0], deals[1]
+	if costco.StoreID != "s-costco" || !approxEqual(costco.LatestPrice, 1.2) || costco.LatestDate != "2025-03-05" || costco.Count != 2 {
+		t.Errorf("unexpected first deal: %+v", costco)
+	}
+	// Median over all four milk receipts: 1.2, 1.3, 1.4, 1.5.
+	if !approxEqual(costco.HistoricalMedian, 1.35) || costco.VsMedianPercent != -11.1 {
+		t.Errorf("median = %v (%v%%), want 1.35 (-11.1%%)", costco.HistoricalMedian, costco.VsMedianPercent)
+	}
+	if walmart.StoreName != "Walmart" || !approxEqual(walmart.LatestPrice, 1.4) || walmart.StoreID != "" {
+		t.Errorf("unexpected second deal: %+v", walmart)
+	}
+
+	// Only Costco has a known location near Richmond.
+	near := &model.GeoFilter{Center: &model.GeoPoint{Lat: 49.17, Lng: -123.14}, RadiusKm: 5}
+	report, err = env.prices.Deals(env.ctx, filter, near)
+	if err != nil {
+		t.Fatalf("Deals near failed: %v", err)
+	}
+	if len(report.Groups) != 1 || len(report.Groups[0].Deals) != 1 || report.Groups[0].Deals[0].DistanceKm == nil {
+		t.Fatalf("expected only Costco with a distance, got %+v", report.Groups)
+	}
+
+	// A category covers every product in it; milk and cheese land in separate unit groups.
+	report, err = env.prices.Deals(env.ctx, model.PriceFilter{CategoryID: "c-dairy", From: filter.From}, nil)
+	if err != nil {
+		t.Fatalf("Deals by category failed: %v", err)
+	}
+	if len(report.Groups) != 2 || report.Groups[1].Unit != model.UnitKilogram || report.Groups[1].Deals[0].ProductName != "Cheddar" {
+		t.Errorf("unexpected category groups: %+v", report.Groups)
+	}
+}
@@ -0,0 +1,463 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const productColumns = "id, name, barcode, category_id, created_at"
+
+// ProductRepo implements repository.ProductRepository backed by SQLite.
+type ProductRepo struct {
+	db *DB
+}
+
+// NewProductRepo creates a new SQLite-backed product repository.
+func NewProductRepo(db *DB) *ProductRepo {
+	return &ProductRepo{db: db}
+}
+
+func (r *ProductRepo) CreateProduct(ctx context.Context, product *model.Product) error {
+	product.CreatedAt = time.Now().Unix()
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkProductRefs(ctx, tx, product); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO products (`+productColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		product.ID, product.Name, nullString(product.Barcode), nullString(product.CategoryID), product.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create product: %w", err)
+	}
+	if err := r.saveAliases(ctx, tx, product); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit product: %w", err)
+	}
+	return nil
+}
+
+func (r *ProductRepo) GetProduct(ctx context.Context, id string) (*model.Product, error) {
+	row := r.db.conn.QueryRowContext(ctx, `SELECT `+productColumns+` FROM products WHERE id = ?`, id)
+	product, err := scanProduct(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get product: %w", err)
+	}
+	if err := r.loadAliases(ctx, []*model.Product{product}); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *ProductRepo) ResolveProduct(ctx context.Context, name, barcode string) (*model.Product, error) {
+	id, err := resolveProductID(ctx, r.db.conn, name, barcode)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	return r.GetProduct(ctx, id)
+}
+
+func (r *ProductRepo) ListProducts(ctx context.Context, categoryID string, params model.PaginationParams) (*model.Page[*model.Product], error) {
+	where := "1 = 1"
+	var whereArgs []interface{}
+	if categoryID != "" {
+		where = "category_id IN (" + categorySubtreeSQL() + ")"
+		whereArgs = append(whereArgs, categoryID)
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM products WHERE `+where, whereArgs...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count products: %w", err)
+	}
+
+	page := &model.Page[*model.Product]{
+		Data:   []*model.Product{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	// Fetch paginated data. SortBy and SortOrder are validated by the handler.
+	query := fmt.Sprintf(
+		`SELECT `+productColumns+` FROM products WHERE %s ORDER BY %s %s LIMIT ? OFFSET ?`,
+		where, params.SortBy, params.SortOrder,
+	)
+	rows, err := r.db.conn.QueryContext(ctx, query, append(whereArgs, params.Limit, params.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list products: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var products []*model.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan product: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadAliases(ctx, products); err != nil {
+		return nil, err
+	}
+	if products != nil {
+		page.Data = products
+	}
+	return page, nil
+}
+
+func (r *ProductRepo) UpdateProduct(ctx context.Context, product *model.Product) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkProductRefs(ctx, tx, product); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE products SET name = ?, barcode = ?, category_id = ? WHERE id = ?`,
+		product.Name, nullString(product.Barcode), nullString(product.CategoryID), product.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update product: %w", err)
+	}
+	if err := expectRow(result, model.ErrProductNotFound, product.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_aliases WHERE product_id = ?`, product.ID); err != nil {
+		return fmt.Errorf("clear product aliases: %w", err)
+	}
+	if err := r.saveAliases(ctx, tx, product); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT created_at FROM products WHERE id = ?`, product.ID).Scan(&product.CreatedAt); err != nil {
+		return fmt.Errorf("reload product: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit product: %w", err)
+	}
+	return nil
+}
+
+func (r *ProductRepo) DeleteProduct(ctx context.Context, id string) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Receipts keep their free-text product name; only the link goes, or
+	// moves to another product that matches. The barcode and aliases go
+	// first so that the receipts no longer resolve to this product.
+	result, err := tx.ExecContext(ctx, `UPDATE products SET barcode = NULL WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("clear product barcode: %w", err)
+	}
+	if err := expectRow(result, model.ErrProductNotFound, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM product_aliases WHERE product_id = ?`, id); err != nil {
+		return fmt.Errorf("delete product aliases: %w", err)
+	}
+	if err := r.relinkReceipts(ctx, tx, nil, "product_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM products WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("delete product: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit product: %w", err)
+	}
+	return nil
+}
+
+// saveAliases registers the product's name and aliases, then links unlinked
+// receipts whose barcode or product name matches and unlinks the product's
+// receipts whose alias or barcode is gone. Receipts linked to another
+// product keep that link.
+func (r *ProductRepo) saveAliases(ctx context.Context, tx *sql.Tx, product *model.Product) error {
+	keys := map[string]bool{}
+	for _, alias := range append([]string{product.Name}, product.Aliases...) {
+		key := model.NormalizeProductName(alias)
+		if key == "" || keys[key] {
+			continue
+		}
+		keys[key] = true
+
+		var owner string
+		err := tx.QueryRowContext(ctx, `SELECT product_id FROM product_aliases WHERE alias_key = ?`, key).Scan(&owner)
+		if err == nil {
+			return fmt.Errorf("%w: %q", model.ErrProductAliasTaken, alias)
+		}
+		if err != sql.ErrNoRows {
+			return fmt.Errorf("check product alias: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO product_aliases (alias_key, alias, product_id) VALUES (?, ?, ?)`, key, alias, product.ID,
+		); err != nil {
+			return fmt.Errorf("save product alias: %w", err)
+		}
+	}
+
+	// Product names are normalized in Go, so unlinked receipts are matched
+	// here rather than in SQL. Only receipts the change can affect are
+	// relinked: unlinked ones matching the barcode or a saved alias, and this
+	// product's ones, which may match neither any more.
+	affected := func(productID, name, barcode string) bool {
+		return productID != "" || (product.Barcode != "" && barcode == product.Barcode) || keys[model.NormalizeProductName(name)]
+	}
+	return r.relinkReceipts(ctx, tx, affected, "product_id IS NULL OR product_id = ?", product.ID)
+}
+
+// relinkReceipts resolves the product of the receipts matching cond again
+// after the alias table or a barcode changed, skipping those affected
+// rejects; a nil affected checks them all. A receipt whose link changes gets
+// a version bump and a receipt.updated event, like any other edit.
+func (r *ProductRepo) relinkReceipts(
+	ctx context.Context, tx *sql.Tx, affected func(productID, name, barcode string) bool, cond string, args ...interface{},
+) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, product_name, COALESCE(barcode, ''), COALESCE(product_id, '') FROM receipts WHERE `+cond, args...,
+	)
+	if err != nil {
+		return fmt.Errorf("find receipts to relink: %w", err)
+	}
+	type link struct{ id, name, barcode, productID string }
+	var links []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.id, &l.name, &l.barcode, &l.productID); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt link: %w", err)
+		}
+		if affected == nil || affected(l.productID, l.name, l.barcode) {
+			links = append(links, l)
+		}
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	receipts := &ReceiptRepo{db: r.db}
+	for _, l := range links {
+		productID, err := resolveProductID(ctx, tx, l.name, l.barcode)
+		if err != nil {
+			return err
+		}
+		if productID == l.productID {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE receipts SET product_id = ?, version = version + 1 WHERE id = ?`, nullString(productID), l.id,
+		); err != nil {
+			return fmt.Errorf("link receipt: %w", err)
+		}
+		updated, err := receipts.scanReceipt(tx.QueryRowContext(ctx, `SELECT `+receiptColumns+` FROM receipts WHERE id = ?`, l.id))
+		if err != nil {
+			return err
+		}
+		if err := publishEvent(ctx, tx, model.Event{Type: model.EventReceiptUpdated, UserID: updated.UserID, Data: updated}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAliases fills in the aliases of the given products, leaving out each
+// product's own name.
+func (r *ProductRepo) loadAliases(ctx context.Context, products []*model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	byID := make(map[string]*model.Product, len(products))
+	placeholders := make([]string, len(products))
+	args := make([]interface{}, len(products))
+	for i, p := range products {
+		p.Aliases = []string{}
+		byID[p.ID] = p
+		placeholders[i] = "?"
+		args[i] = p.ID
+	}
+
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT product_id, alias_key, alias FROM product_aliases WHERE product_id IN (`+strings.Join(placeholders, ", ")+`) ORDER BY alias`,
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("load product aliases: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var productID, key, alias string
+		if err := rows.Scan(&productID, &key, &alias); err != nil {
+			return fmt.Errorf("scan product alias: %w", err)
+		}
+		if p := byID[productID]; p != nil && key != model.NormalizeProductName(p.Name) {
+			p.Aliases = append(p.Aliases, alias)
+		}
+	}
+	return rows.Err()
+}
+
+// checkProductRefs verifies that the product's category exists and that its
+// barcode is not assigned to another product.
+func checkProductRefs(ctx context.Context, tx *sql.Tx, product *model.Product) error {
+	if product.CategoryID != "" {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM categories WHERE id = ?)`, product.CategoryID,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("check product category: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("%w: %q", model.ErrCategoryNotFound, product.CategoryID)
+		}
+	}
+	if product.Barcode != "" {
+		var taken bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM products WHERE barcode = ? AND id <> ?)`, product.Barcode, product.ID,
+		).Scan(&taken); err != nil {
+			return fmt.Errorf("check product barcode: %w", err)
+		}
+		if taken {
+			return fmt.Errorf("%w: %q", model.ErrBarcodeTaken, product.Barcode)
+		}
+	}
+	return nil
+}
+
+// scanProduct scans a product row from either *sql.Row or *sql.Rows.
+func scanProduct(row interface{ Scan(...interface{}) error }) (*model.Product, error) {
+	var p model.Product
+	var barcode, categoryID sql.NullString
+	if err := row.Scan(&p.ID, &p.Name, &barcode, &categoryID, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	p.Barcode, p.CategoryID = barcode.String, categoryID.String
+	return &p, nil
+}
+
+// resolveProductID returns the ID of the product a receipt line resolves to,
+// or "" if it matches none. A barcode match wins over a name match.
+func resolveProductID(ctx context.Context, q rowQuerier, productName, barcode string) (string, error) {
+	var id string
+	if barcode != "" {
+		err := q.QueryRowContext(ctx, `SELECT id FROM products WHERE barcode = ?`, barcode).Scan(&id)
+		if err == nil {
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("resolve product barcode: %w", err)
+		}
+	}
+	err := q.QueryRowContext(ctx,
+		`SELECT product_id FROM product_aliases WHERE alias_key = ?`, model.NormalizeProductName(productName),
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolve product: %w", err)
+	}
+	return id, nil
+}
+
+// backfillBarcodes moves barcodes kept in a user-defined "barcode" field,
+// from before barcode became a native field, into the barcode column and
+// links their receipts to the catalog. Values are normalized in Go, so this
+// cannot be a SQL migration. A value that is not a valid barcode stays in
+// extras, where the receipt still shows it, and is logged on every start
+// until an update of the receipt corrects or drops it.
+func (db *DB) backfillBarcodes() error {
+	rows, err := db.conn.Query(
+		`SELECT id, product_name, extras FROM receipts WHERE barcode IS NULL AND json_type(extras, '$.barcode') IS NOT NULL`,
+	)
+	if err != nil {
+		return fmt.Errorf("find receipts to backfill: %w", err)
+	}
+	type pending struct{ id, name, extras string }
+	var todo []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.name, &p.extras); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt: %w", err)
+		}
+		todo = append(todo, p)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, p := range todo {
+		var extras map[string]interface{}
+		if err := json.Unmarshal([]byte(p.extras), &extras); err != nil {
+			return fmt.Errorf("unmarshal extras of receipt %s: %w", p.id, err)
+		}
+		barcode, err := model.BarcodeFromValue(extras["barcode"])
+		if err != nil {
+			slog.Warn("receipt keeps an invalid barcode in its extras", "receipt", p.id, "error", err)
+			continue
+		}
+		delete(extras, "barcode")
+		extrasJSON, err := json.Marshal(extras)
+		if err != nil {
+			return fmt.Errorf("marshal extras: %w", err)
+		}
+		productID, err := resolveProductID(ctx, tx, p.name, barcode)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE receipts SET barcode = ?, product_id = ?, extras = ? WHERE id = ?`,
+			barcode, nullString(productID), string(extrasJSON), p.id,
+		); err != nil {
+			return fmt.Errorf("backfill receipt: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit backfill: %w", err)
+	}
+	return nil
+}
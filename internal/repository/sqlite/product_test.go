@@ -0,0 +1,391 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+type productEnv struct {
+	*receiptEnv
+	db         *sqlite.DB
+	products   *sqlite.ProductRepo
+	categories *sqlite.CategoryRepo
+	events     *sqlite.EventRepo
+}
+
+func newProductEnv(t *testing.T) *productEnv {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	meta := sqlite.NewMetaFieldRepo(db)
+	env := &productEnv{
+		receiptEnv: &receiptEnv{
+			receipts: sqlite.NewReceiptRepo(db, meta),
+			meta:     meta,
+			users:    sqlite.NewUserRepo(db),
+			ctx:      context.Background(),
+		},
+		db:         db,
+		products:   sqlite.NewProductRepo(db),
+		categories: sqlite.NewCategoryRepo(db),
+		events:     sqlite.NewEventRepo(db),
+	}
+	env.seedUser(t, "user-1")
+	return env
+}
+
+func (e *productEnv) createProduct(t *testing.T, product *model.Product) *model.Product {
+	t.Helper()
+	if err := e.products.CreateProduct(e.ctx, product); err != nil {
+		t.Fatalf("CreateProduct failed: %v", err)
+	}
+	return product
+}
+
+func (e *productEnv) createCategory(t *testing.T, id, name, parentID string) {
+	t.Helper()
+	if err := e.categories.CreateCategory(e.ctx, &model.Category{ID: id, Name: name, ParentID: parentID}); err != nil {
+		t.Fatalf("CreateCategory failed: %v", err)
+	}
+}
+
+func (e *productEnv) receiptFor(t *testing.T, id, productName, barcode string) *model.Receipt {
+	t.Helper()
+	rec := e.sampleReceipt(id, "user-1")
+	rec.ProductName, rec.Barcode = productName, barcode
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	got, err := e.receipts.GetReceiptByID(e.ctx, id)
+	if err != nil {
+		t.Fatalf("GetReceiptByID failed: %v", err)
+	}
+	return got
+}
+
+func TestNormalizeProductName(t *testing.T) {
+	cases := map[string]string{
+		"2% Milk 4L":         "2 percent milk 4 l",
+		"2 percent milk 4 l": "2 percent milk 4 l",
+		"  OAT-MILK 1.89L ":  "oat milk 1 89 l",
+		"Eggs (12 pk)":       "eggs 12 pk",
+		"---":                "",
+	}
+	for in, want := range cases {
+		if got := model.NormalizeProductName(in); got != want {
+			t.Errorf("NormalizeProductName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeBarcode(t *testing.T) {
+	valid := map[string]string{
+		"036000291452":     "0036000291452", // UPC-A becomes EAN-13
+		"0036000291452":    "0036000291452",
+		"4006381333931":    "4006381333931",
+		"400-6381-33393-1": "4006381333931",
+		"96385074":         "96385074",      // EAN-8
+		"00036000291452":   "0036000291452", // GTIN-14 with leading zero
+	}
+	for in, want := range valid {
+		got, err := model.NormalizeBarcode(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeBarcode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"036000291453", "12345", "abcdefghijkl", ""} {
+		if _, err := model.NormalizeBarcode(in); !errors.Is(err, model.ErrInvalidBarcode) {
+			t.Errorf("NormalizeBarcode(%q): expected ErrInvalidBarcode, got %v", in, err)
+		}
+	}
+}
+
+func TestBarcodeFromValue(t *testing.T) {
+	for in, want := range map[interface{}]string{
+		"0-68700-01102-3":      "0068700011023",
+		float64(68700011023):   "0068700011023",
+		float64(4006381333931): "4006381333931",
+		float64(96385074):      "96385074",
+		float64(36000291452):   "0036000291452",
+	} {
+		got, err := model.BarcodeFromValue(in)
+		if err != nil || got != want {
+			t.Errorf("BarcodeFromValue(%v) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []interface{}{"12345", float64(12345), 6.87e-3, float64(-96385074), true, nil, map[string]interface{}{}} {
+		if _, err := model.BarcodeFromValue(in); !errors.Is(err, model.ErrInvalidBarcode) {
+			t.Errorf("BarcodeFromValue(%v): expected ErrInvalidBarcode, got %v", in, err)
+		}
+	}
+}
+
+func TestProduct_CreateGetAndResolve(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createProduct(t, &model.Product{
+		ID:         "p-milk",
+		Name:       "2% Milk 4L",
+		Barcode:    "0068700011023",
+		CategoryID: "c-dairy",
+		Aliases:    []string{"Milk 2 percent 4 L"},
+	})
+
+	got, err := env.products.GetProduct(env.ctx, "p-milk")
+	if err != nil {
+		t.Fatalf("GetProduct failed: %v", err)
+	}
+	if got == nil || got.Barcode != "0068700011023" || got.CategoryID != "c-dairy" {
+		t.Fatalf("unexpected product: %+v", got)
+	}
+	if len(got.Aliases) != 1 || got.Aliases[0] != "Milk 2 percent 4 L" {
+		t.Errorf("expected the alias without the name, got %v", got.Aliases)
+	}
+
+	for _, tc := range []struct{ name, barcode string }{
+		{"2 percent milk 4 l", ""},
+		{"milk 2 percent 4L", ""},
+		{"Something Else", "0068700011023"},
+	} {
+		resolved, err := env.products.ResolveProduct(env.ctx, tc.name, tc.barcode)
+		if err != nil {
+			t.Fatalf("ResolveProduct failed: %v", err)
+		}
+		if resolved == nil || resolved.ID != "p-milk" {
+			t.Errorf("ResolveProduct(%q, %q) = %+v, want p-milk", tc.name, tc.barcode, resolved)
+		}
+	}
+	if resolved, _ := env.products.ResolveProduct(env.ctx, "Bread", ""); resolved != nil {
+		t.Errorf("expected no product for Bread, got %+v", resolved)
+	}
+}
+
+func TestProduct_Conflicts(t *testing.T) {
+	env := newProductEnv(t)
+	env.createProduct(t, &model.Product{ID: "p-1", Name: "Oat Milk", Barcode: "4006381333931"})
+
+	err := env.products.CreateProduct(env.ctx, &model.Product{ID: "p-2", Name: "OAT-MILK"})
+	if !errors.Is(err, model.ErrProductAliasTaken) {
+		t.Errorf("expected ErrProductAliasTaken, got %v", err)
+	}
+	err = env.products.CreateProduct(env.ctx, &model.Product{ID: "p-3", Name: "Soy Milk", Barcode: "4006381333931"})
+	if !errors.Is(err, model.ErrBarcodeTaken) {
+		t.Errorf("expected ErrBarcodeTaken, got %v", err)
+	}
+	err = env.products.CreateProduct(env.ctx, &model.Product{ID: "p-4", Name: "Rice Milk", CategoryID: "nope"})
+	if !errors.Is(err, model.ErrCategoryNotFound) {
+		t.Errorf("expected ErrCategoryNotFound, got %v", err)
+	}
+}
+
+func TestProduct_ReceiptLinkedOnCreate(t *testing.T) {
+	env := newProductEnv(t)
+	env.createProduct(t, &model.Product{ID: "p-milk", Name: "2% Milk 4L", Barcode: "0068700011023"})
+
+	byName := env.receiptFor(t, "r-1", "2 PERCENT MILK 4 L", "")
+	if byName.ProductID != "p-milk" {
+		t.Errorf("expected name match to link p-milk, got %q", byName.ProductID)
+	}
+	byBarcode := env.receiptFor(t, "r-2", "MLK 2% 4L", "0068700011023")
+	if byBarcode.ProductID != "p-milk" || byBarcode.Barcode != "0068700011023" {
+		t.Errorf("expected barcode match to link p-milk, got %q / %q", byBarcode.ProductID, byBarcode.Barcode)
+	}
+	unknown := env.receiptFor(t, "r-3", "Bread", "")
+	if unknown.ProductID != "" {
+		t.Errorf("expected unknown product to stay unlinked, got %q", unknown.ProductID)
+	}
+}
+
+func TestProduct_ExistingReceiptsLinkedOnCreate(t *testing.T) {
+	env := newProductEnv(t)
+	env.receiptFor(t, "r-1", "milk 2 percent 4 L", "")
+	env.receiptFor(t, "r-2", "MLK", "0068700011023")
+	env.receiptFor(t, "r-3", "Bread", "")
+
+	env.createProduct(t, &model.Product{
+		ID: "p-milk", Name: "2% Milk 4L", Barcode: "0068700011023", Aliases: []string{"Milk 2 percent 4L"},
+	})
+
+	for id, want := range map[string]string{"r-1": "p-milk", "r-2": "p-milk", "r-3": ""} {
+		got, _ := env.receipts.GetReceiptByID(env.ctx, id)
+		if got.ProductID != want {
+			t.Errorf("receipt %s: expected product %q, got %q", id, want, got.ProductID)
+		}
+	}
+}
+
+func TestProduct_UpdateAndDelete(t *testing.T) {
+	env := newProductEnv(t)
+	product := env.createProduct(t, &model.Product{ID: "p-1", Name: "Oat Milk", Aliases: []string{"Oatly"}})
+	env.receiptFor(t, "r-1", "Oat Milk", "")
+
+	product.Aliases = []string{"Oat Beverage"}
+	if err := env.products.UpdateProduct(env.ctx, product); err != nil {
+		t.Fatalf("UpdateProduct failed: %v", err)
+	}
+	if got, _ := env.products.ResolveProduct(env.ctx, "Oatly", ""); got != nil {
+		t.Errorf("expected removed alias to stop resolving, got %+v", got)
+	}
+	if got, _ := env.products.ResolveProduct(env.ctx, "oat beverage", ""); got == nil {
+		t.Error("expected new alias to resolve")
+	}
+
+	if err := env.products.DeleteProduct(env.ctx, "p-1"); err != nil {
+		t.Fatalf("DeleteProduct failed: %v", err)
+	}
+	got, _ := env.receipts.GetReceiptByID(env.ctx, "r-1")
+	if got.ProductID != "" || got.ProductName != "Oat Milk" || got.Version != 2 {
+		t.Errorf("expected receipt unlinked at version 2 with name kept, got %q / %q / %d", got.ProductID, got.ProductName, got.Version)
+	}
+	if err := env.products.DeleteProduct(env.ctx, "p-1"); !errors.Is(err, model.ErrProductNotFound) {
+		t.Errorf("expected ErrProductNotFound, got %v", err)
+	}
+}
+
+func TestProduct_RelinkBumpsVersionAndPublishes(t *testing.T) {
+	env := newProductEnv(t)
+	product := env.createProduct(t, &model.Product{
+		ID: "p-1", Name: "Oat Milk", Barcode: "0068700011023", Aliases: []string{"Oatly"},
+	})
+	env.createProduct(t, &model.Product{ID: "p-2", Name: "Oat Drink"})
+	env.receiptFor(t, "r-1", "Oatly", "")
+	env.receiptFor(t, "r-2", "Oat Milk", "")
+	env.receiptFor(t, "r-3", "Mystery Carton", "0068700011023")
+	env.receiptFor(t, "r-4", "Oat Beverage", "")
+	env.receiptFor(t, "r-5", "Oat Drink", "0068700011023")
+
+	product.Aliases, product.Barcode = []string{"Oat Beverage"}, ""
+	if err := env.products.UpdateProduct(env.ctx, product); err != nil {
+		t.Fatalf("UpdateProduct failed: %v", err)
+	}
+
+	for id, want := range map[string]struct {
+		productID string
+		version   int64
+	}{
+		"r-1": {"", 2}, "r-2": {"p-1", 1}, "r-3": {"", 2}, "r-4": {"p-1", 2}, "r-5": {"p-2", 2},
+	} {
+		got, _ := env.receipts.GetReceiptByID(env.ctx, id)
+		if got.ProductID != want.productID || got.Version != want.version {
+			t.Errorf("receipt %s: expected product %q at version %d, got %q at %d", id, want.productID, want.version, got.ProductID, got.Version)
+		}
+	}
+
+	events, err := env.events.ListUserEvents(env.ctx, "user-1", 0, 100)
+	if err != nil {
+		t.Fatalf("ListUserEvents failed: %v", err)
+	}
+	updated := 0
+	for _, e := range events {
+		if e.Type == model.EventReceiptUpdated {
+			updated++
+		}
+	}
+	if updated != 4 {
+		t.Errorf("expected 4 receipt.updated events for the relinked receipts, got %d", updated)
+	}
+}
+
+func TestProduct_BackfillsBarcodesFromExtras(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gyd.db")
+	db, err := sqlite.New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	env := &receiptEnv{
+		receipts: sqlite.NewReceiptRepo(db, sqlite.NewMetaFieldRepo(db)),
+		users:    sqlite.NewUserRepo(db),
+		ctx:      context.Background(),
+	}
+	env.seedUser(t, "user-1")
+	if err := sqlite.NewProductRepo(db).CreateProduct(env.ctx, &model.Product{
+		ID: "p-milk", Name: "2% Milk 4L", Barcode: "0068700011023",
+	}); err != nil {
+		t.Fatalf("CreateProduct failed: %v", err)
+	}
+	for _, id := range []string{"r-1", "r-2", "r-3", "r-4"} {
+		if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt(id, "user-1")); err != nil {
+			t.Fatalf("CreateReceipt failed: %v", err)
+		}
+	}
+	_ = db.Close()
+
+	// Simulate receipts stored while barcode was a user-defined field.
+	raw, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open raw: %v", err)
+	}
+	for id, extras := range map[string]string{
+		"r-1": `{"barcode": "0-68700-01102-3", "onSale": true}`,
+		"r-2": `{"barcode": 68700011023}`,
+		"r-3": `{"barcode": "12345"}`,
+		"r-4": `{"barcode": true}`,
+	} {
+		if _, err := raw.Exec(`UPDATE receipts SET barcode = NULL, product_id = NULL, extras = ? WHERE id = ?`, extras, id); err != nil {
+			t.Fatalf("set extras: %v", err)
+		}
+	}
+	_ = raw.Close()
+
+	db, err = sqlite.New(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	receipts := sqlite.NewReceiptRepo(db, sqlite.NewMetaFieldRepo(db))
+	for id, want := range map[string]struct {
+		barcode, productID string
+		extra              interface{}
+	}{
+		"r-1": {"0068700011023", "p-milk", nil},
+		"r-2": {"0068700011023", "p-milk", nil},
+		"r-3": {"", "", "12345"},
+		"r-4": {"", "", true},
+	} {
+		got, err := receipts.GetReceiptByID(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetReceiptByID failed: %v", err)
+		}
+		if got.Barcode != want.barcode || got.ProductID != want.productID || got.Extras["barcode"] != want.extra {
+			t.Errorf("receipt %s: expected barcode %q, product %q, extra %v; got %q, %q, %v",
+				id, want.barcode, want.productID, want.extra, got.Barcode, got.ProductID, got.Extras["barcode"])
+		}
+	}
+	if got, _ := receipts.GetReceiptByID(context.Background(), "r-1"); got.Extras["onSale"] != true {
+		t.Errorf("expected other extras kept, got %v", got.Extras)
+	}
+}
+
+func TestProduct_ListByCategoryIncludesSubcategories(t *testing.T) {
+	env := newProductEnv(t)
+	env.createCategory(t, "c-dairy", "Dairy", "")
+	env.createCategory(t, "c-milk", "Milk", "c-dairy")
+	env.createCategory(t, "c-bakery", "Bakery", "")
+	env.createProduct(t, &model.Product{ID: "p-1", Name: "Cheddar", CategoryID: "c-dairy"})
+	env.createProduct(t, &model.Product{ID: "p-2", Name: "Oat Milk", CategoryID: "c-milk"})
+	env.createProduct(t, &model.Product{ID: "p-3", Name: "Bagel", CategoryID: "c-bakery"})
+	env.createProduct(t, &model.Product{ID: "p-4", Name: "Batteries"})
+
+	params := model.PaginationParams{Limit: 10, SortBy: "name", SortOrder: "ASC"}
+	page, err := env.products.ListProducts(env.ctx, "c-dairy", params)
+	if err != nil {
+		t.Fatalf("ListProducts failed: %v", err)
+	}
+	if page.Total != 2 || page.Data[0].Name != "Cheddar" || page.Data[1].Name != "Oat Milk" {
+		t.Errorf("expected Cheddar and Oat Milk, got total %d", page.Total)
+	}
+
+	all, err := env.products.ListProducts(env.ctx, "", params)
+	if err != nil {
+		t.Fatalf("ListProducts failed: %v", err)
+	}
+	if all.Total != 4 {
+		t.Errorf("expected 4 products without a category filter, got %d", all.Total)
+	}
+}
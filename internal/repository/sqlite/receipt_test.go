@@ -2,6 +2,7 @@ package sqlite_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -372,7 +373,7 @@ func TestReceipt_Delete(t *testing.T) {
 		t.Fatalf("CreateReceipt failed: %v", err)
 	}
 
-	if err := env.receipts.DeleteReceipt(env.ctx, "r-1"); err != nil {
+	if err := env.receipts.DeleteReceipt(env.ctx, "r-1", 0); err != nil {
 		t.Fatalf("DeleteReceipt failed: %v", err)
 	}
 
@@ -388,7 +389,7 @@ func TestReceipt_Delete(t *testing.T) {
 func TestReceipt_DeleteNonexistent(t *testing.T) {
 	env := newReceiptEnv(t)
 
-	if err := env.receipts.DeleteReceipt(env.ctx, "nonexistent"); err != nil {
+	if err := env.receipts.DeleteReceipt(env.ctx, "nonexistent", 0); err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 }
@@ -415,3 +416,185 @@ func TestReceipt_CascadeDeleteUser(t *testing.T) {
 		t.Fatal("expected receipt to be cascade deleted with user")
 	}
 }
+
+func TestReceipt_Update(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+
+	rec := env.sampleReceipt("r-1", "user-1")
+	if err := env.receipts.CreateReceipt(env.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+
+	update := env.sampleReceipt("r-1", "")
+	update.Price = "4.99CAD"
+	if err := env.receipts.UpdateReceipt(env.ctx, update, 1); err != nil {
+		t.Fatalf("UpdateReceipt failed: %v", err)
+	}
+	if update.Version != 2 {
+		t.Errorf("expected version 2 after update, got %d", update.Version)
+	}
+	if update.UserID != "user-1" || update.UploadTime != rec.UploadTime {
+		t.Errorf("expected owner and upload time to be kept, got %q %d", update.UserID, update.UploadTime)
+	}
+
+	got, err := env.receipts.GetReceiptByID(env.ctx, "r-1")
+	if err != nil {
+		t.Fatalf("GetReceiptByID failed: %v", err)
+	}
+	if got.Price != "4.99CAD" {
+		t.Errorf("expected price 4.99CAD, got %q", got.Price)
+	}
+}
+
+func TestReceipt_Update_StaleVersion(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r-1", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	if err := env.receipts.UpdateReceipt(env.ctx, env.sampleReceipt("r-1", ""), 1); err != nil {
+		t.Fatalf("UpdateReceipt failed: %v", err)
+	}
+
+	err := env.receipts.UpdateReceipt(env.ctx, env.sampleReceipt("r-1", ""), 1)
+	if !errors.Is(err, model.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+}
+
+func TestReceipt_Update_NotFound(t *testing.T) {
+	env := newReceiptEnv(t)
+
+	err := env.receipts.UpdateReceipt(env.ctx, env.sampleReceipt("missing", ""), 0)
+	if !errors.Is(err, model.ErrReceiptNotFound) {
+		t.Fatalf("expected ErrReceiptNotFound, got %v", err)
+	}
+}
+
+func TestReceipt_Delete_StaleVersion(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r-1", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+
+	err := env.receipts.DeleteReceipt(env.ctx, "r-1", 7)
+	if !errors.Is(err, model.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch, got %v", err)
+	}
+	if got, _ := env.receipts.GetReceiptByID(env.ctx, "r-1"); got == nil {
+		t.Error("expected receipt to survive a delete with a stale version")
+	}
+}
+
+func TestReceipt_Cursor_WalksAllPages(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	// All receipts share the same upload_time second, so ordering relies on the id tiebreak.
+	for i := 0; i < 5; i++ {
+		if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt(fmt.Sprintf("r-%d", i), "user-1")); err != nil {
+			t.Fatalf("CreateReceipt failed: %v", err)
+		}
+	}
+
+	params := model.CursorParams{Limit: 2, SortBy: "upload_time", SortOrder: "DESC"}
+	seen := map[string]bool{}
+	pages := 0
+	for {
+		page, err := env.receipts.ListReceiptsByUserCursor(env.ctx, "user-1", params)
+		if err != nil {
+			t.Fatalf("ListReceiptsByUserCursor failed: %v", err)
+		}
+		pages++
+		for _, rec := range page.Data {
+			if seen[rec.ID] {
+				t.Fatalf("receipt %s returned twice", rec.ID)
+			}
+			seen[rec.ID] = true
+		}
+		if !page.HasMore {
+			break
+		}
+		after, err := model.DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeCursor failed: %v", err)
+		}
+		params.After = after
+	}
+
+	if len(seen) != 5 {
+		t.Errorf("expected to see 5 receipts, saw %d", len(seen))
+	}
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+}
+
+func TestReceipt_Cursor_StableUnderInsert(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	for _, date := range []string{"2025.01.01", "2025.02.01", "2025.03.01"} {
+		rec := env.sampleReceipt("r-"+date, "user-1")
+		rec.PurchaseDate = date
+		if err := env.receipts.CreateReceipt(env.ctx, rec); err != nil {
+			t.Fatalf("CreateReceipt failed: %v", err)
+		}
+	}
+
+	params := model.CursorParams{Limit: 2, SortBy: "purchase_date", SortOrder: "ASC"}
+	first, err := env.receipts.ListReceiptsByUserCursor(env.ctx, "user-1", params)
+	if err != nil {
+		t.Fatalf("ListReceiptsByUserCursor failed: %v", err)
+	}
+
+	// A receipt that sorts before the cursor must not shift the next page.
+	early := env.sampleReceipt("r-early", "user-1")
+	early.PurchaseDate = "2024.12.01"
+	if err := env.receipts.CreateReceipt(env.ctx, early); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+
+	params.After, err = model.DecodeCursor(first.NextCursor)
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	second, err := env.receipts.ListReceiptsByUserCursor(env.ctx, "user-1", params)
+	if err != nil {
+		t.Fatalf("ListReceiptsByUserCursor failed: %v", err)
+	}
+	if len(second.Data) != 1 || second.Data[0].PurchaseDate != "2025.03.01" {
+		t.Fatalf("expected only the 2025.03.01 receipt on page 2, got %d receipts", len(second.Data))
+	}
+	if second.HasMore {
+		t.Error("expected no further pages")
+	}
+}
+
+func TestReceipt_Cursor_IncludeTotal(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r-1", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+
+	params := model.CursorParams{Limit: 10, SortBy: "upload_time", SortOrder: "DESC"}
+	page, err := env.receipts.ListReceiptsByUserCursor(env.ctx, "user-1", params)
+	if err != nil {
+		t.Fatalf("ListReceiptsByUserCursor failed: %v", err)
+	}
+	if page.Total != nil {
+		t.Error("expected no total unless requested")
+	}
+
+	params.IncludeTotal = true
+	page, err = env.receipts.ListReceiptsByUserCursor(env.ctx, "user-1", params)
+	if err != nil {
+		t.Fatalf("ListReceiptsByUserCursor failed: %v", err)
+	}
+	if page.Total == nil || *page.Total != 1 {
+		t.Errorf("expected total 1, got %v", page.Total)
+	}
+}
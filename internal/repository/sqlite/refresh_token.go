@@ -2,13 +2,22 @@ package sqlite
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"time"
 
 	"github.com/gatheryourdeals/data/internal/model"
 )
 
+// sessionColumns are the refresh_tokens columns a model.Session is read from,
+// in scan order.
+const sessionColumns = `family_id, user_id, device, user_agent, ip_address, created_at, last_used_at, expires_at, two_factor`
+
 // RefreshTokenStore is a SQLite-backed implementation of auth.RefreshTokenStore.
+// Tokens are stored as SHA-256 hashes, so a leaked database holds no usable
+// tokens.
 type RefreshTokenStore struct {
 	db *DB
 }
@@ -18,10 +27,11 @@ func NewRefreshTokenStore(db *DB) *RefreshTokenStore {
 	return &RefreshTokenStore{db: db}
 }
 
-func (s *RefreshTokenStore) Save(ctx context.Context, token, userID string, expiresAt time.Time) error {
+func (s *RefreshTokenStore) Save(ctx context.Context, token string, session *model.Session) error {
 	_, err := s.db.conn.ExecContext(ctx,
-		`INSERT INTO refresh_tokens (token, user_id, expires_at) VALUES (?, ?, ?)`,
-		token, userID, expiresAt.Unix(),
+		`INSERT INTO refresh_tokens (token_hash, `+sessionColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		hashToken(token), session.ID, session.UserID, session.Device, session.UserAgent, session.IPAddress,
+		session.CreatedAt, session.LastUsedAt, session.ExpiresAt, session.TwoFactor,
 	)
 	return err
 }
@@ -30,7 +40,7 @@ func (s *RefreshTokenStore) Find(ctx context.Context, token string) (string, err
 	var userID string
 	var expiresAt int64
 	err := s.db.conn.QueryRowContext(ctx,
-		`SELECT user_id, expires_at FROM refresh_tokens WHERE token = ?`, token,
+		`SELECT user_id, expires_at FROM refresh_tokens WHERE token_hash = ? AND used_at IS NULL`, hashToken(token),
 	).Scan(&userID, &expiresAt)
 	if err == sql.ErrNoRows {
 		return "", model.ErrInvalidToken
@@ -39,14 +49,90 @@ func (s *RefreshTokenStore) Find(ctx context.Context, token string) (string, err
 		return "", err
 	}
 	if time.Now().Unix() > expiresAt {
-		_, _ = s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE token = ?`, token)
+		_, _ = s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE token_hash = ?`, hashToken(token))
 		return "", model.ErrInvalidToken
 	}
 	return userID, nil
 }
 
+func (s *RefreshTokenStore) Rotate(ctx context.Context, token string) (*model.Session, error) {
+	hash := hashToken(token)
+	var usedAt sql.NullInt64
+	var session model.Session
+	err := s.db.conn.QueryRowContext(ctx,
+		`SELECT `+sessionColumns+`, used_at FROM refresh_tokens WHERE token_hash = ?`, hash,
+	).Scan(&session.ID, &session.UserID, &session.Device, &session.UserAgent, &session.IPAddress,
+		&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.TwoFactor, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, model.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find refresh token: %w", err)
+	}
+
+	now := time.Now().Unix()
+	if now > session.ExpiresAt {
+		_, _ = s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE token_hash = ?`, hash)
+		return nil, model.ErrInvalidToken
+	}
+	if !usedAt.Valid {
+		// The used_at condition lets only one of two concurrent rotations
+		// win; the other is treated as a replay.
+		result, err := s.db.conn.ExecContext(ctx,
+			`UPDATE refresh_tokens SET used_at = ? WHERE token_hash = ? AND used_at IS NULL`, now, hash)
+		if err != nil {
+			return nil, fmt.Errorf("rotate refresh token: %w", err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("rotate refresh token: %w", err)
+		}
+		if n == 1 {
+			return &session, nil
+		}
+	}
+
+	if _, err := s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE family_id = ?`, session.ID); err != nil {
+		return nil, fmt.Errorf("revoke token family: %w", err)
+	}
+	return &session, model.ErrRefreshTokenReused
+}
+
+func (s *RefreshTokenStore) ListSessions(ctx context.Context, userID string) ([]*model.Session, error) {
+	rows, err := s.db.conn.QueryContext(ctx,
+		`SELECT `+sessionColumns+` FROM refresh_tokens
+		 WHERE user_id = ? AND used_at IS NULL AND expires_at >= ?
+		 ORDER BY last_used_at DESC, family_id`,
+		userID, time.Now().Unix())
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	sessions := []*model.Session{}
+	for rows.Next() {
+		var session model.Session
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Device, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastUsedAt, &session.ExpiresAt, &session.TwoFactor); err != nil {
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *RefreshTokenStore) DeleteSession(ctx context.Context, userID, sessionID string) error {
+	result, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM refresh_tokens WHERE user_id = ? AND family_id = ?`, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return expectRow(result, model.ErrSessionNotFound, sessionID)
+}
+
 func (s *RefreshTokenStore) Delete(ctx context.Context, token string) error {
-	_, err := s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE token = ?`, token)
+	_, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM refresh_tokens WHERE family_id IN (SELECT family_id FROM refresh_tokens WHERE token_hash = ?)`, hashToken(token))
 	return err
 }
 
@@ -54,3 +140,20 @@ func (s *RefreshTokenStore) DeleteAllForUser(ctx context.Context, userID string)
 	_, err := s.db.conn.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE user_id = ?`, userID)
 	return err
 }
+
+func (s *RefreshTokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM refresh_tokens WHERE expires_at < ?`, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("delete expired refresh tokens: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// hashToken returns the hex SHA-256 of a refresh token, the form in which
+// it is stored and looked up. It also backs the SQL function sha256_hex,
+// which migration 00020 hashes existing tokens with.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
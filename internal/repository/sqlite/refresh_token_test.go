@@ -2,6 +2,7 @@ package sqlite_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -40,12 +41,24 @@ func (e *refreshStoreEnv) seedUser(t *testing.T, id string) {
 	}
 }
 
+// session returns a session of userID whose token expires at exp.
+func session(userID, familyID string, exp time.Time) *model.Session {
+	return &model.Session{
+		ID:         familyID,
+		UserID:     userID,
+		Device:     "Pixel 8",
+		CreatedAt:  exp.Add(-time.Hour).Unix(),
+		LastUsedAt: exp.Add(-time.Hour).Unix(),
+		ExpiresAt:  exp.Unix(),
+	}
+}
+
 func TestRefreshTokenStore_SaveAndFind(t *testing.T) {
 	env := newRefreshStoreEnv(t)
 	env.seedUser(t, "user-1")
 
 	exp := time.Now().Add(time.Hour)
-	if err := env.store.Save(env.ctx, "token-abc", "user-1", exp); err != nil {
+	if err := env.store.Save(env.ctx, "token-abc", session("user-1", "family-token-abc", exp)); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
 
@@ -72,7 +85,7 @@ func TestRefreshTokenStore_Find_Expired(t *testing.T) {
 	env.seedUser(t, "user-1")
 
 	exp := time.Now().Add(-time.Second)
-	if err := env.store.Save(env.ctx, "expired-token", "user-1", exp); err != nil {
+	if err := env.store.Save(env.ctx, "expired-token", session("user-1", "family-expired-token", exp)); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
 
@@ -87,7 +100,7 @@ func TestRefreshTokenStore_Find_ExpiredIsDeleted(t *testing.T) {
 	env.seedUser(t, "user-1")
 
 	exp := time.Now().Add(-time.Second)
-	if err := env.store.Save(env.ctx, "expired-token", "user-1", exp); err != nil {
+	if err := env.store.Save(env.ctx, "expired-token", session("user-1", "family-expired-token", exp)); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
 
@@ -106,7 +119,7 @@ func TestRefreshTokenStore_Delete(t *testing.T) {
 	env.seedUser(t, "user-1")
 
 	exp := time.Now().Add(time.Hour)
-	if err := env.store.Save(env.ctx, "token-abc", "user-1", exp); err != nil {
+	if err := env.store.Save(env.ctx, "token-abc", session("user-1", "family-token-abc", exp)); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
 
@@ -136,11 +149,11 @@ func TestRefreshTokenStore_DeleteAllForUser(t *testing.T) {
 	exp := time.Now().Add(time.Hour)
 	tokens := []string{"token-1", "token-2", "token-3"}
 	for _, tok := range tokens {
-		if err := env.store.Save(env.ctx, tok, "user-1", exp); err != nil {
+		if err := env.store.Save(env.ctx, tok, session("user-1", "family-"+tok, exp)); err != nil {
 			t.Fatalf("Save failed: %v", err)
 		}
 	}
-	if err := env.store.Save(env.ctx, "token-other", "user-2", exp); err != nil {
+	if err := env.store.Save(env.ctx, "token-other", session("user-2", "family-token-other", exp)); err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
 
@@ -171,12 +184,162 @@ func TestRefreshTokenStore_Save_DuplicateToken(t *testing.T) {
 	env.seedUser(t, "user-2")
 
 	exp := time.Now().Add(time.Hour)
-	if err := env.store.Save(env.ctx, "token-abc", "user-1", exp); err != nil {
+	if err := env.store.Save(env.ctx, "token-abc", session("user-1", "family-token-abc", exp)); err != nil {
 		t.Fatalf("first Save failed: %v", err)
 	}
 
-	err := env.store.Save(env.ctx, "token-abc", "user-2", exp)
+	err := env.store.Save(env.ctx, "token-abc", session("user-2", "family-other", exp))
 	if err == nil {
 		t.Fatal("expected error on duplicate token, got nil")
 	}
 }
+
+func TestRefreshTokenStore_Rotate(t *testing.T) {
+	env := newRefreshStoreEnv(t)
+	env.seedUser(t, "user-1")
+
+	exp := time.Now().Add(time.Hour)
+	if err := env.store.Save(env.ctx, "token-1", session("user-1", "family-a", exp)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	s, err := env.store.Rotate(env.ctx, "token-1")
+	if err != nil || s.UserID != "user-1" || s.ID != "family-a" || s.Device != "Pixel 8" {
+		t.Fatalf("Rotate = %+v, %v; want the family-a session of user-1", s, err)
+	}
+	// A used token is kept for reuse detection but is no longer valid.
+	if _, err := env.store.Find(env.ctx, "token-1"); err != model.ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for a used token, got %v", err)
+	}
+
+	if _, err := env.store.Rotate(env.ctx, "missing"); err != model.ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken for an unknown token, got %v", err)
+	}
+}
+
+func TestRefreshTokenStore_Rotate_ReuseRevokesFamily(t *testing.T) {
+	env := newRefreshStoreEnv(t)
+	env.seedUser(t, "user-1")
+
+	exp := time.Now().Add(time.Hour)
+	for token, family := range map[string]string{"token-1": "family-a", "token-2": "family-a", "token-3": "family-b"} {
+		if err := env.store.Save(env.ctx, token, session("user-1", family, exp)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if _, err := env.store.Rotate(env.ctx, "token-1"); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+
+	s, err := env.store.Rotate(env.ctx, "token-1")
+	if err != model.ErrRefreshTokenReused || s.UserID != "user-1" || s.ID != "family-a" {
+		t.Fatalf("replayed Rotate = %+v, %v; want ErrRefreshTokenReused", s, err)
+	}
+	if _, err := env.store.Find(env.ctx, "token-2"); err != model.ErrInvalidToken {
+		t.Errorf("expected the rest of the family to be revoked, got %v", err)
+	}
+	if _, err := env.store.Find(env.ctx, "token-3"); err != nil {
+		t.Errorf("expected another family to survive, got %v", err)
+	}
+}
+
+func TestRefreshTokenStore_Delete_RevokesFamily(t *testing.T) {
+	env := newRefreshStoreEnv(t)
+	env.seedUser(t, "user-1")
+
+	exp := time.Now().Add(time.Hour)
+	for token, family := range map[string]string{"token-1": "family-a", "token-2": "family-a", "token-3": "family-b"} {
+		if err := env.store.Save(env.ctx, token, session("user-1", family, exp)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	if err := env.store.Delete(env.ctx, "token-2"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := env.store.Rotate(env.ctx, "token-1"); err != model.ErrInvalidToken {
+		t.Errorf("expected the family to be gone, got %v", err)
+	}
+	if _, err := env.store.Find(env.ctx, "token-3"); err != nil {
+		t.Errorf("expected another family to survive, got %v", err)
+	}
+}
+
+func TestRefreshTokenStore_DeleteExpired(t *testing.T) {
+	env := newRefreshStoreEnv(t)
+	env.seedUser(t, "user-1")
+
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	for token, exp := range map[string]time.Time{"expired-1": past, "expired-2": past, "live": future} {
+		if err := env.store.Save(env.ctx, token, session("user-1", "family-"+token, exp)); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// Used tokens are kept for reuse detection, but only until they expire.
+	if _, err := env.store.Rotate(env.ctx, "live"); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if err := env.store.Save(env.ctx, "expired-3", session("user-1", "family-live", past)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	n, err := env.store.DeleteExpired(env.ctx)
+	if err != nil {
+		t.Fatalf("DeleteExpired failed: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 expired tokens deleted, got %d", n)
+	}
+	// The used token has not expired, so replaying it is still detected.
+	if _, err := env.store.Rotate(env.ctx, "live"); err != model.ErrRefreshTokenReused {
+		t.Errorf("expected ErrRefreshTokenReused for the unexpired used token, got %v", err)
+	}
+}
+
+func TestRefreshTokenStore_ListAndDeleteSessions(t *testing.T) {
+	env := newRefreshStoreEnv(t)
+	env.seedUser(t, "user-1")
+	env.seedUser(t, "user-2")
+
+	exp := time.Now().Add(time.Hour)
+	older := session("user-1", "family-a", exp)
+	older.LastUsedAt -= 60
+	for token, s := range map[string]*model.Session{
+		"token-a":     older,
+		"token-b":     session("user-1", "family-b", exp),
+		"token-old":   session("user-1", "family-c", time.Now().Add(-time.Minute)), // expired
+		"token-other": session("user-2", "family-d", exp),
+	} {
+		if err := env.store.Save(env.ctx, token, s); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	// A rotated token is not a second session.
+	if _, err := env.store.Rotate(env.ctx, "token-b"); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	if err := env.store.Save(env.ctx, "token-b2", session("user-1", "family-b", exp)); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	sessions, err := env.store.ListSessions(env.ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "family-b" || sessions[1].ID != "family-a" || sessions[0].Device != "Pixel 8" {
+		t.Fatalf("expected sessions family-b then family-a, got %+v", sessions)
+	}
+
+	if err := env.store.DeleteSession(env.ctx, "user-2", "family-b"); !errors.Is(err, model.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound deleting another user's session, got %v", err)
+	}
+	if err := env.store.DeleteSession(env.ctx, "user-1", "family-b"); err != nil {
+		t.Fatalf("DeleteSession failed: %v", err)
+	}
+	if _, err := env.store.Find(env.ctx, "token-b2"); err != model.ErrInvalidToken {
+		t.Errorf("expected the session's token to be revoked, got %v", err)
+	}
+	if sessions, _ := env.store.ListSessions(env.ctx, "user-1"); len(sessions) != 1 {
+		t.Errorf("expected one session left, got %d", len(sessions))
+	}
+}
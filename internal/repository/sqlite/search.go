@@ -0,0 +1,329 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// searchColumnWeights weights matches per column of receipts_fts, in column
+// order: receipt_id (not indexed), product_text, store_text, extras_text.
+var searchColumnWeights = []float64{0, 3, 1, 1}
+
+// fts4RankLimit caps how many matches FTS4 search ranks in Go: the newest
+// ones. Matches past it are not returned; the page is marked truncated and
+// its total capped.
+const fts4RankLimit = 1000
+
+// ensureSearchIndex creates the receipts_fts full-text index if it does not
+// exist and fills it from the receipts table.
+//
+// The index is an FTS5 table when the driver is built with the sqlite_fts5
+// tag (go build -tags sqlite_fts5), and an FTS4 table otherwise, since
+// go-sqlite3 only compiles FTS5 in on request. This is why the table is
+// created here instead of in a goose migration. An existing table is kept
+// as it is, so a database keeps working if the build tag changes.
+func (db *DB) ensureSearchIndex() error {
+	var existing string
+	err := db.conn.QueryRow(
+		`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'receipts_fts'`,
+	).Scan(&existing)
+	if err == nil {
+		db.fts5 = strings.Contains(strings.ToLower(existing), "fts5")
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return fmt.Errorf("look up search index: %w", err)
+	}
+
+	var fts5 bool
+	if err := db.conn.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&fts5); err != nil {
+		return fmt.Errorf("check fts5 support: %w", err)
+	}
+	create := `CREATE VIRTUAL TABLE receipts_fts USING fts4(
+		receipt_id, product_text, store_text, extras_text,
+		notindexed=receipt_id, tokenize=unicode61)`
+	if fts5 {
+		create = `CREATE VIRTUAL TABLE receipts_fts USING fts5(
+			receipt_id UNINDEXED, product_text, store_text, extras_text,
+			tokenize='unicode61')`
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(create); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	// Backfill with the same rules as model.SearchableExtras: only string
+	// values of string-typed extras are indexed.
+	if _, err := tx.Exec(`INSERT INTO receipts_fts (receipt_id, product_text, store_text, extras_text)
+		SELECT r.id, r.product_name, r.store_name, COALESCE((
+			SELECT group_concat(j.value, ' ')
+			FROM json_each(r.extras) j
+			JOIN meta_fields m ON m.field_name = j.key AND m.field_type = 'string'
+			WHERE j.type = 'text'
+		), '')
+		FROM receipts r`); err != nil {
+		return fmt.Errorf("backfill search index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit search index: %w", err)
+	}
+	db.fts5 = fts5
+	return nil
+}
+
+// indexReceipt replaces the search index entry of a receipt.
+func indexReceipt(ctx context.Context, tx *sql.Tx, receipt *model.Receipt, extrasText string) error {
+	if err := unindexReceipt(ctx, tx, receipt.ID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO receipts_fts (receipt_id, product_text, store_text, extras_text) VALUES (?, ?, ?, ?)`,
+		receipt.ID, receipt.ProductName, receipt.StoreName, extrasText,
+	)
+	if err != nil {
+		return fmt.Errorf("index receipt: %w", err)
+	}
+	return nil
+}
+
+// unindexReceipt removes a receipt from the search index.
+func unindexReceipt(ctx context.Context, tx *sql.Tx, id string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM receipts_fts WHERE receipt_id = ?`, id); err != nil {
+		return fmt.Errorf("unindex receipt: %w", err)
+	}
+	return nil
+}
+
+func (r *ReceiptRepo) SearchReceipts(ctx context.Context, userID string, query *model.SearchQuery, params model.PaginationParams) (*model.Page[*model.SearchHit], error) {
+	where := "receipts_fts MATCH ? AND receipts.user_id = ?"
+	whereArgs := []interface{}{matchExpression(query, r.db.fts5), userID}
+	if cond, condArgs := geoCondition(params.Geo); cond != "" {
+		where += " AND " + cond
+		whereArgs = append(whereArgs, condArgs...)
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM receipts_fts JOIN receipts ON receipts.id = receipts_fts.receipt_id WHERE `+where,
+		whereArgs...,
+	).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count search results: %w", err)
+	}
+	truncated := !r.db.fts5 && total > fts4RankLimit
+	if truncated {
+		total = fts4RankLimit
+	}
+
+	page := &model.Page[*model.SearchHit]{
+		Data:      []*model.SearchHit{},
+		Total:     total,
+		Offset:    params.Offset,
+		Limit:     params.Limit,
+		Truncated: truncated,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	var hits []*model.SearchHit
+	var err error
+	if r.db.fts5 {
+		hits, err = r.searchFTS5(ctx, where, whereArgs, params)
+	} else {
+		hits, err = r.searchFTS4(ctx, where, whereArgs, params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hits != nil {
+		page.Data = hits
+	}
+	return page, nil
+}
+
+// searchFTS5 ranks in SQL with the built-in bm25 function. bm25 returns
+// lower values for better matches, so it is negated into a score.
+func (r *ReceiptRepo) searchFTS5(ctx context.Context, where string, whereArgs []interface{}, params model.PaginationParams) ([]*model.SearchHit, error) {
+	weights := make([]string, len(searchColumnWeights))
+	for i, w := range searchColumnWeights {
+		weights[i] = fmt.Sprintf("%.1f", w)
+	}
+	order := "score DESC, receipts.upload_time DESC"
+	if params.SortBy == model.SortByDistance {
+		order = "distance_km " + params.SortOrder + " NULLS LAST, " + order
+	}
+	distance, args := distanceColumn(params.Geo)
+	query := `SELECT ` + qualifiedReceiptColumns() + `, ` + distance + `,
+		-bm25(receipts_fts, ` + strings.Join(weights, ", ") + `) AS score
+		FROM receipts_fts JOIN receipts ON receipts.id = receipts_fts.receipt_id
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ? OFFSET ?`
+	args = append(args, whereArgs...)
+	args = append(args, params.Limit, params.Offset)
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search receipts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var hits []*model.SearchHit
+	for rows.Next() {
+		var distanceKm sql.NullFloat64
+		var score float64
+		rec, err := r.scanReceiptRow(rows, &distanceKm, &score)
+		if err != nil {
+			return nil, err
+		}
+		if distanceKm.Valid {
+			rec.DistanceKm = &distanceKm.Float64
+		}
+		hits = append(hits, &model.SearchHit{Receipt: rec, Score: score})
+	}
+	return hits, rows.Err()
+}
+
+// searchFTS4 ranks in Go: FTS4 has no ranking function, only matchinfo
+// statistics. The newest fts4RankLimit matches for the user are scored, then
+// the page is cut out.
+func (r *ReceiptRepo) searchFTS4(ctx context.Context, where string, whereArgs []interface{}, params model.PaginationParams) ([]*model.SearchHit, error) {
+	distance, args := distanceColumn(params.Geo)
+	query := `SELECT ` + qualifiedReceiptColumns() + `, ` + distance + `, matchinfo(receipts_fts, 'pcnx')
+		FROM receipts_fts JOIN receipts ON receipts.id = receipts_fts.receipt_id
+		WHERE ` + where + `
+		ORDER BY receipts.upload_time DESC
+		LIMIT ?`
+	args = append(args, whereArgs...)
+	args = append(args, fts4RankLimit)
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search receipts: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var hits []*model.SearchHit
+	for rows.Next() {
+		var distanceKm sql.NullFloat64
+		var info []byte
+		rec, err := r.scanReceiptRow(rows, &distanceKm, &info)
+		if err != nil {
+			return nil, err
+		}
+		if distanceKm.Valid {
+			rec.DistanceKm = &distanceKm.Float64
+		}
+		hits = append(hits, &model.SearchHit{Receipt: rec, Score: matchinfoScore(info)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if params.SortBy == model.SortByDistance && !sameDistance(a.Receipt.DistanceKm, b.Receipt.DistanceKm) {
+			if a.Receipt.DistanceKm == nil || b.Receipt.DistanceKm == nil {
+				return b.Receipt.DistanceKm == nil
+			}
+			if params.SortOrder == "DESC" {
+				return *a.Receipt.DistanceKm > *b.Receipt.DistanceKm
+			}
+			return *a.Receipt.DistanceKm < *b.Receipt.DistanceKm
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Receipt.UploadTime > b.Receipt.UploadTime
+	})
+	if params.Offset >= len(hits) {
+		return nil, nil
+	}
+	end := params.Offset + params.Limit
+	if end > len(hits) {
+		end = len(hits)
+	}
+	return hits[params.Offset:end], nil
+}
+
+// sameDistance reports whether two optional distances are equal.
+func sameDistance(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// matchinfoScore computes a weighted tf-idf score from an FTS4
+// matchinfo(..., 'pcnx') blob: phrase count, column count, row count, then
+// three values per phrase and column (hits in this row, hits in all rows,
+// rows with hits).
+func matchinfoScore(info []byte) float64 {
+	ints := make([]uint32, len(info)/4)
+	for i := range ints {
+		ints[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(ints) < 3 {
+		return 0
+	}
+	phrases, columns, docs := int(ints[0]), int(ints[1]), float64(ints[2])
+	x := ints[3:]
+	if len(x) < phrases*columns*3 {
+		return 0
+	}
+
+	score := 0.0
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < columns && c < len(searchColumnWeights); c++ {
+			base := (p*columns + c) * 3
+			hits, rowsWithHits := float64(x[base]), float64(x[base+2])
+			if hits == 0 || rowsWithHits == 0 {
+				continue
+			}
+			score += searchColumnWeights[c] * hits * math.Log(1+docs/rowsWithHits)
+		}
+	}
+	return score
+}
+
+// matchExpression renders a parsed query as an FTS MATCH expression. Words
+// are letters and digits only (see model.ParseSearchQuery), so they can be
+// quoted without escaping. Space-separated terms are implicitly ANDed.
+func matchExpression(query *model.SearchQuery, fts5 bool) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		phrase := strings.Join(term.Words, " ")
+		switch {
+		case term.Prefix && fts5:
+			terms[i] = `"` + phrase + `"*`
+		case term.Prefix:
+			terms[i] = `"` + phrase + `*"`
+		default:
+			terms[i] = `"` + phrase + `"`
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// qualifiedReceiptColumns returns receiptColumns prefixed with the receipts
+// table name, for queries that join other tables.
+func qualifiedReceiptColumns() string {
+	cols := strings.Split(receiptColumns, ", ")
+	for i, col := range cols {
+		cols[i] = "receipts." + col
+	}
+	return strings.Join(cols, ", ")
+}
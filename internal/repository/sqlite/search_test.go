@@ -0,0 +1,205 @@
+package sqlite_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+func (e *receiptEnv) search(t *testing.T, userID, q string) []*model.SearchHit {
+	t.Helper()
+	query, err := model.ParseSearchQuery(q)
+	if err != nil {
+		t.Fatalf("ParseSearchQuery(%q) failed: %v", q, err)
+	}
+	page, err := e.receipts.SearchReceipts(e.ctx, userID, query, model.PaginationParams{Limit: 20})
+	if err != nil {
+		t.Fatalf("SearchReceipts(%q) failed: %v", q, err)
+	}
+	if page.Total != len(page.Data) {
+		t.Fatalf("expected total %d to match %d results", page.Total, len(page.Data))
+	}
+	return page.Data
+}
+
+func (e *receiptEnv) createNamed(t *testing.T, id, userID, product, store string, extras map[string]interface{}) {
+	t.Helper()
+	rec := e.sampleReceipt(id, userID)
+	rec.ProductName = product
+	rec.StoreName = store
+	rec.Extras = extras
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+}
+
+func hitIDs(hits []*model.SearchHit) []string {
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.Receipt.ID
+	}
+	return ids
+}
+
+func TestSearch_PrefixAndPhrase(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.createNamed(t, "r-oat", "user-1", "Oat Milk Barista", "Costco", nil)
+	env.createNamed(t, "r-milk", "user-1", "Milk Oat Cookies", "Walmart", nil)
+	env.createNamed(t, "r-bread", "user-1", "Sourdough Bread", "Costco", nil)
+
+	if ids := hitIDs(env.search(t, "user-1", "oat")); len(ids) != 2 {
+		t.Errorf("expected 2 hits for oat, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "sour*")); len(ids) != 1 || ids[0] != "r-bread" {
+		t.Errorf("expected r-bread for sour*, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", `"oat milk"`)); len(ids) != 1 || ids[0] != "r-oat" {
+		t.Errorf("expected only r-oat for the phrase, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", `"oat mil"*`)); len(ids) != 1 || ids[0] != "r-oat" {
+		t.Errorf("expected only r-oat for the prefix phrase, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "costco bread")); len(ids) != 1 || ids[0] != "r-bread" {
+		t.Errorf("expected all terms to be required, got %v", ids)
+	}
+}
+
+func TestSearch_RanksProductAboveStore(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.createNamed(t, "r-store", "user-1", "Eggs", "Fresh Market", nil)
+	env.createNamed(t, "r-product", "user-1", "Fresh Basil", "Loblaws", nil)
+
+	hits := env.search(t, "user-1", "fresh")
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d", len(hits))
+	}
+	if hits[0].Receipt.ID != "r-product" {
+		t.Errorf("expected the product name match first, got %v", hitIDs(hits))
+	}
+	if hits[0].Score <= hits[1].Score {
+		t.Errorf("expected descending scores, got %f then %f", hits[0].Score, hits[1].Score)
+	}
+}
+
+func TestSearch_IndexesOnlyStringExtras(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	for _, f := range []*model.MetaField{
+		{FieldName: "brand", FieldType: "string"},
+		{FieldName: "code", FieldType: "int"},
+	} {
+		if err := env.meta.CreateField(env.ctx, f); err != nil {
+			t.Fatalf("CreateField failed: %v", err)
+		}
+	}
+	env.createNamed(t, "r-1", "user-1", "Milk", "Costco", map[string]interface{}{"brand": "Oatly", "code": "4011"})
+
+	if ids := hitIDs(env.search(t, "user-1", "oatly")); len(ids) != 1 {
+		t.Errorf("expected the string extra to be searchable, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "4011")); len(ids) != 0 {
+		t.Errorf("expected the int-typed extra not to be indexed, got %v", ids)
+	}
+}
+
+func TestSearch_FollowsUpdatesAndDeletes(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.createNamed(t, "r-1", "user-1", "Oat Milk", "Costco", nil)
+
+	rec, err := env.receipts.GetReceiptByID(env.ctx, "r-1")
+	if err != nil {
+		t.Fatalf("GetReceiptByID failed: %v", err)
+	}
+	rec.ProductName = "Almond Milk"
+	if err := env.receipts.UpdateReceipt(env.ctx, rec, 0); err != nil {
+		t.Fatalf("UpdateReceipt failed: %v", err)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "oat")); len(ids) != 0 {
+		t.Errorf("expected the old name to be gone from the index, got %v", ids)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "almond")); len(ids) != 1 {
+		t.Errorf("expected the new name to be indexed, got %v", ids)
+	}
+
+	if err := env.receipts.DeleteReceipt(env.ctx, "r-1", 0); err != nil {
+		t.Fatalf("DeleteReceipt failed: %v", err)
+	}
+	if ids := hitIDs(env.search(t, "user-1", "almond")); len(ids) != 0 {
+		t.Errorf("expected deleted receipt to be gone from the index, got %v", ids)
+	}
+}
+
+func TestSearch_ScopedToUser(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	env.seedUser(t, "user-2")
+	env.createNamed(t, "r-1", "user-1", "Oat Milk", "Costco", nil)
+	env.createNamed(t, "r-2", "user-2", "Oat Milk", "Costco", nil)
+
+	if ids := hitIDs(env.search(t, "user-1", "oat")); len(ids) != 1 || ids[0] != "r-1" {
+		t.Errorf("expected only user-1's receipt, got %v", ids)
+	}
+
+	if err := env.users.DeleteUser(env.ctx, "user-2"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if ids := hitIDs(env.search(t, "user-2", "oat")); len(ids) != 0 {
+		t.Errorf("expected deleted user's receipts to be unsearchable, got %v", ids)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		q       string
+		terms   int
+		wantErr bool
+	}{
+		{q: "oat milk", terms: 2},
+		{q: `"oat milk" costco*`, terms: 2},
+		{q: "2%", terms: 1},
+		{q: `"oat milk`, wantErr: true},
+		{q: "  ", wantErr: true},
+		{q: "* - !", wantErr: true},
+	}
+	for _, tt := range tests {
+		query, err := model.ParseSearchQuery(tt.q)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseSearchQuery(%q): expected error", tt.q)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSearchQuery(%q) failed: %v", tt.q, err)
+			continue
+		}
+		if len(query.Terms) != tt.terms {
+			t.Errorf("ParseSearchQuery(%q): expected %d terms, got %d", tt.q, tt.terms, len(query.Terms))
+		}
+	}
+}
+
+func TestSearch_TruncationReported(t *testing.T) {
+	env := newReceiptEnv(t)
+	env.seedUser(t, "user-1")
+	for i := 0; i <= 1000; i++ {
+		env.createNamed(t, fmt.Sprintf("r-%04d", i), "user-1", "Oat Milk", "Costco", nil)
+	}
+
+	query, _ := model.ParseSearchQuery("oat")
+	page, err := env.receipts.SearchReceipts(env.ctx, "user-1", query, model.PaginationParams{Limit: 20, Offset: 990})
+	if err != nil {
+		t.Fatalf("SearchReceipts failed: %v", err)
+	}
+	switch {
+	case page.Truncated && page.Total == 1000 && len(page.Data) == 10: // FTS4 ranks the newest 1000
+	case !page.Truncated && page.Total == 1001 && len(page.Data) == 11: // FTS5 ranks all
+	default:
+		t.Errorf("expected either a truncated page of 1000 or a full one of 1001, got truncated=%v total=%d with %d results",
+			page.Truncated, page.Total, len(page.Data))
+	}
+}
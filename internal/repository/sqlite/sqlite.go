@@ -4,22 +4,44 @@ import (
 	"database/sql"
 	"embed"
 	"fmt"
+	"log/slog"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/mattn/go-sqlite3"
 	"github.com/pressly/goose/v3"
 )
 
 //go:embed migrations/*.sql
 var migrations embed.FS
 
+// driverName is go-sqlite3 with the application's SQL functions registered
+// on every new connection.
+const driverName = "sqlite3_gyd"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("haversine_km", haversineKm, true); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("store_key", model.NormalizeStoreName, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("sha256_hex", hashToken, true)
+		},
+	})
+}
+
 // DB wraps a sql.DB connection to a SQLite database.
 type DB struct {
 	conn *sql.DB
+	// fts5 reports whether the search index is an FTS5 table; see ensureSearchIndex.
+	fts5 bool
 }
 
 // New opens a SQLite database at the given path and runs migrations.
 func New(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_foreign_keys=on")
+	conn, err := sql.Open(driverName, dbPath+"?_journal_mode=WAL&_foreign_keys=on")
 	if err != nil {
 		return nil, fmt.Errorf("open sqlite: %w", err)
 	}
@@ -30,6 +52,19 @@ func New(dbPath string) (*DB, error) {
 	if err := db.migrate(); err != nil {
 		return nil, fmt.Errorf("migrate: %w", err)
 	}
+	if err := db.ensureSearchIndex(); err != nil {
+		return nil, fmt.Errorf("search index: %w", err)
+	}
+	if !db.fts5 {
+		slog.Warn("search index is FTS4, which ranks only the newest matches of a query; build with -tags sqlite_fts5 for FTS5",
+			"limit", fts4RankLimit)
+	}
+	if err := db.backfillSpendColumns(); err != nil {
+		return nil, fmt.Errorf("spend columns: %w", err)
+	}
+	if err := db.backfillBarcodes(); err != nil {
+		return nil, fmt.Errorf("barcodes: %w", err)
+	}
 	return db, nil
 }
 
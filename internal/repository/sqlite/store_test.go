@@ -0,0 +1,283 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+type storeEnv struct {
+	*receiptEnv
+	stores *sqlite.StoreRepo
+	events *sqlite.EventRepo
+}
+
+func newStoreEnv(t *testing.T) *storeEnv {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	meta := sqlite.NewMetaFieldRepo(db)
+	env := &storeEnv{
+		receiptEnv: &receiptEnv{
+			receipts: sqlite.NewReceiptRepo(db, meta),
+			meta:     meta,
+			users:    sqlite.NewUserRepo(db),
+			ctx:      context.Background(),
+		},
+		stores: sqlite.NewStoreRepo(db),
+		events: sqlite.NewEventRepo(db),
+	}
+	env.seedUser(t, "user-1")
+	return env
+}
+
+func (e *storeEnv) createStore(t *testing.T, id, name string, aliases ...string) *model.Store {
+	t.Helper()
+	store := &model.Store{ID: id, Name: name, Aliases: aliases}
+	if err := e.stores.CreateStore(e.ctx, store); err != nil {
+		t.Fatalf("CreateStore failed: %v", err)
+	}
+	return store
+}
+
+func (e *storeEnv) receiptAt(t *testing.T, id, storeName string) *model.Receipt {
+	t.Helper()
+	rec := e.sampleReceipt(id, "user-1")
+	rec.StoreName = storeName
+	if err := e.receipts.CreateReceipt(e.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	got, err := e.receipts.GetReceiptByID(e.ctx, id)
+	if err != nil {
+		t.Fatalf("GetReceiptByID failed: %v", err)
+	}
+	return got
+}
+
+func TestNormalizeStoreName(t *testing.T) {
+	cases := map[string]string{
+		"Costco":                "costco",
+		"  COSTCO   Wholesale ": "costco wholesale",
+		"T&T Supermarket":       "t t supermarket",
+		"Save-On-Foods #42":     "save on foods 42",
+		"!!!":                   "",
+	}
+	for in, want := range cases {
+		if got := model.NormalizeStoreName(in); got != want {
+			t.Errorf("NormalizeStoreName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestStore_CreateGetAndResolve(t *testing.T) {
+	env := newStoreEnv(t)
+	lat, lng := 49.2827, -123.1207
+	store := &model.Store{
+		ID:        "s-1",
+		Name:      "Costco",
+		Chain:     "Costco Wholesale",
+		Address:   "605 Expo Blvd",
+		Latitude:  &lat,
+		Longitude: &lng,
+		Aliases:   []string{"Costco Wholesale", "COSTCO #54"},
+	}
+	if err := env.stores.CreateStore(env.ctx, store); err != nil {
+		t.Fatalf("CreateStore failed: %v", err)
+	}
+
+	got, err := env.stores.GetStore(env.ctx, "s-1")
+	if err != nil {
+		t.Fatalf("GetStore failed: %v", err)
+	}
+	if got == nil || got.Chain != "Costco Wholesale" || got.Latitude == nil || *got.Latitude != lat {
+		t.Fatalf("unexpected store: %+v", got)
+	}
+	if len(got.Aliases) != 2 || got.Aliases[0] != "COSTCO #54" || got.Aliases[1] != "Costco Wholesale" {
+		t.Errorf("expected both aliases without the name, got %v", got.Aliases)
+	}
+
+	for _, name := range []string{"costco", "  Costco  Wholesale", "Costco #54"} {
+		resolved, err := env.stores.ResolveStore(env.ctx, name)
+		if err != nil {
+			t.Fatalf("ResolveStore(%q) failed: %v", name, err)
+		}
+		if resolved == nil || resolved.ID != "s-1" {
+			t.Errorf("ResolveStore(%q) = %+v, want s-1", name, resolved)
+		}
+	}
+
+	resolved, err := env.stores.ResolveStore(env.ctx, "Walmart")
+	if err != nil || resolved != nil {
+		t.Errorf("expected no store for Walmart, got %+v, %v", resolved, err)
+	}
+	missing, err := env.stores.GetStore(env.ctx, "nope")
+	if err != nil || missing != nil {
+		t.Errorf("expected nil for missing store, got %+v, %v", missing, err)
+	}
+}
+
+func TestStore_AliasTaken(t *testing.T) {
+	env := newStoreEnv(t)
+	env.createStore(t, "s-1", "Costco", "Costco Wholesale")
+
+	err := env.stores.CreateStore(env.ctx, &model.Store{ID: "s-2", Name: "COSTCO wholesale"})
+	if !errors.Is(err, model.ErrStoreAliasTaken) {
+		t.Fatalf("expected ErrStoreAliasTaken, got %v", err)
+	}
+	if got, _ := env.stores.GetStore(env.ctx, "s-2"); got != nil {
+		t.Error("expected the conflicting store not to be created")
+	}
+}
+
+func TestStore_ReceiptLinkedOnCreate(t *testing.T) {
+	env := newStoreEnv(t)
+	env.createStore(t, "s-1", "Costco", "Costco Wholesale")
+
+	linked := env.receiptAt(t, "r-1", "COSTCO WHOLESALE")
+	if linked.StoreID != "s-1" {
+		t.Errorf("expected receipt linked to s-1, got %q", linked.StoreID)
+	}
+	if linked.StoreName != "COSTCO WHOLESALE" {
+		t.Errorf("expected store name kept as entered, got %q", linked.StoreName)
+	}
+
+	unlinked := env.receiptAt(t, "r-2", "Walmart")
+	if unlinked.StoreID != "" {
+		t.Errorf("expected unknown store to stay unlinked, got %q", unlinked.StoreID)
+	}
+
+	unlinked.StoreName = "Costco"
+	if err := env.receipts.UpdateReceipt(env.ctx, unlinked, 0); err != nil {
+		t.Fatalf("UpdateReceipt failed: %v", err)
+	}
+	got, _ := env.receipts.GetReceiptByID(env.ctx, "r-2")
+	if got.StoreID != "s-1" {
+		t.Errorf("expected updated receipt linked to s-1, got %q", got.StoreID)
+	}
+}
+
+func TestStore_ExistingReceiptsLinkedOnCreate(t *testing.T) {
+	env := newStoreEnv(t)
+	env.receiptAt(t, "r-1", "costco")
+	env.receiptAt(t, "r-2", "Costco Wholesale")
+	env.receiptAt(t, "r-3", "Walmart")
+
+	env.createStore(t, "s-1", "Costco", "Costco Wholesale")
+
+	for id, want := range map[string]string{"r-1": "s-1", "r-2": "s-1", "r-3": ""} {
+		got, _ := env.receipts.GetReceiptByID(env.ctx, id)
+		if got.StoreID != want {
+			t.Errorf("receipt %s: expected store %q, got %q", id, want, got.StoreID)
+		}
+	}
+}
+
+func TestStore_UpdateReplacesAliases(t *testing.T) {
+	env := newStoreEnv(t)
+	store := env.createStore(t, "s-1", "Costco", "Costco Wholesale")
+	env.receiptAt(t, "r-1", "Price Club")
+
+	store.Aliases = []string{"Price Club"}
+	if err := env.stores.UpdateStore(env.ctx, store); err != nil {
+		t.Fatalf("UpdateStore failed: %v", err)
+	}
+	if store.CreatedAt == 0 {
+		t.Error("expected CreatedAt to be reloaded")
+	}
+
+	if got, _ := env.stores.ResolveStore(env.ctx, "Costco Wholesale"); got != nil {
+		t.Errorf("expected removed alias to stop resolving, got %+v", got)
+	}
+	if got, _ := env.stores.ResolveStore(env.ctx, "price club"); got == nil || got.ID != "s-1" {
+		t.Errorf("expected new alias to resolve to s-1, got %+v", got)
+	}
+	if got, _ := env.receipts.GetReceiptByID(env.ctx, "r-1"); got.StoreID != "s-1" {
+		t.Errorf("expected receipt matching new alias to be linked, got %q", got.StoreID)
+	}
+
+	err := env.stores.UpdateStore(env.ctx, &model.Store{ID: "nope", Name: "Nope"})
+	if !errors.Is(err, model.ErrStoreNotFound) {
+		t.Errorf("expected ErrStoreNotFound, got %v", err)
+	}
+}
+
+func TestStore_RelinkBumpsVersionAndPublishes(t *testing.T) {
+	env := newStoreEnv(t)
+	store := env.createStore(t, "s-1", "Costco", "Costco Wholesale")
+	env.receiptAt(t, "r-1", "Costco Wholesale")
+	env.receiptAt(t, "r-2", "Costco")
+	env.receiptAt(t, "r-3", "Price Club")
+
+	store.Aliases = []string{"Price Club"}
+	if err := env.stores.UpdateStore(env.ctx, store); err != nil {
+		t.Fatalf("UpdateStore failed: %v", err)
+	}
+
+	for id, want := range map[string]struct {
+		storeID string
+		version int64
+	}{"r-1": {"", 2}, "r-2": {"s-1", 1}, "r-3": {"s-1", 2}} {
+		got, _ := env.receipts.GetReceiptByID(env.ctx, id)
+		if got.StoreID != want.storeID || got.Version != want.version {
+			t.Errorf("receipt %s: expected store %q at version %d, got %q at %d", id, want.storeID, want.version, got.StoreID, got.Version)
+		}
+	}
+
+	events, err := env.events.ListUserEvents(env.ctx, "user-1", 0, 100)
+	if err != nil {
+		t.Fatalf("ListUserEvents failed: %v", err)
+	}
+	updated := 0
+	for _, e := range events {
+		if e.Type == model.EventReceiptUpdated {
+			updated++
+		}
+	}
+	if updated != 2 {
+		t.Errorf("expected 2 receipt.updated events for the relinked receipts, got %d", updated)
+	}
+}
+
+func TestStore_DeleteUnlinksReceipts(t *testing.T) {
+	env := newStoreEnv(t)
+	env.createStore(t, "s-1", "Costco")
+	env.receiptAt(t, "r-1", "Costco")
+
+	if err := env.stores.DeleteStore(env.ctx, "s-1"); err != nil {
+		t.Fatalf("DeleteStore failed: %v", err)
+	}
+	got, _ := env.receipts.GetReceiptByID(env.ctx, "r-1")
+	if got.StoreID != "" || got.StoreName != "Costco" || got.Version != 2 {
+		t.Errorf("expected receipt unlinked at version 2 with name kept, got %q / %q / %d", got.StoreID, got.StoreName, got.Version)
+	}
+	if resolved, _ := env.stores.ResolveStore(env.ctx, "Costco"); resolved != nil {
+		t.Error("expected aliases of a deleted store to stop resolving")
+	}
+	if err := env.stores.DeleteStore(env.ctx, "s-1"); !errors.Is(err, model.ErrStoreNotFound) {
+		t.Errorf("expected ErrStoreNotFound, got %v", err)
+	}
+}
+
+func TestStore_ListSortedByName(t *testing.T) {
+	env := newStoreEnv(t)
+	env.createStore(t, "s-1", "Walmart")
+	env.createStore(t, "s-2", "Costco", "Costco Wholesale")
+	env.createStore(t, "s-3", "Loblaws")
+
+	page, err := env.stores.ListStores(env.ctx, model.PaginationParams{Limit: 2, SortBy: "name", SortOrder: "ASC"})
+	if err != nil {
+		t.Fatalf("ListStores failed: %v", err)
+	}
+	if page.Total != 3 || page.TotalPages != 2 || len(page.Data) != 2 {
+		t.Fatalf("unexpected page: total=%d pages=%d len=%d", page.Total, page.TotalPages, len(page.Data))
+	}
+	if page.Data[0].Name != "Costco" || page.Data[1].Name != "Loblaws" {
+		t.Errorf("expected Costco, Loblaws; got %s, %s", page.Data[0].Name, page.Data[1].Name)
+	}
+	if len(page.Data[0].Aliases) != 1 {
+		t.Errorf("expected aliases loaded in list, got %v", page.Data[0].Aliases)
+	}
+}
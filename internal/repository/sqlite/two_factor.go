@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+// TwoFactorStore is a SQLite-backed implementation of auth.TwoFactorStore.
+// Recovery codes are stored as SHA-256 hashes.
+type TwoFactorStore struct {
+	db *DB
+}
+
+// NewTwoFactorStore creates a new SQLite-backed two-factor store.
+func NewTwoFactorStore(db *DB) *TwoFactorStore {
+	return &TwoFactorStore{db: db}
+}
+
+func (s *TwoFactorStore) Get(ctx context.Context, userID string) (*model.TwoFactor, error) {
+	tf := model.TwoFactor{UserID: userID}
+	var enabledAt sql.NullInt64
+	err := s.db.conn.QueryRowContext(ctx,
+		`SELECT secret, last_step, created_at, enabled_at,
+			(SELECT COUNT(*) FROM recovery_codes WHERE user_id = two_factor.user_id)
+		FROM two_factor WHERE user_id = ?`, userID,
+	).Scan(&tf.Secret, &tf.LastStep, &tf.CreatedAt, &enabledAt, &tf.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get two-factor enrollment: %w", err)
+	}
+	tf.Enabled, tf.EnabledAt = enabledAt.Valid, enabledAt.Int64
+	return &tf, nil
+}
+
+func (s *TwoFactorStore) Begin(ctx context.Context, userID, secret string, at int64) error {
+	_, err := s.db.conn.ExecContext(ctx,
+		`INSERT INTO two_factor (user_id, secret, last_step, created_at) VALUES (?, ?, 0, ?)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, last_step = 0, created_at = excluded.created_at
+		WHERE two_factor.enabled_at IS NULL`,
+		userID, secret, at)
+	if err != nil {
+		return fmt.Errorf("begin two-factor enrollment: %w", err)
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) Enable(ctx context.Context, userID string, step, at int64, codeHashes []string) error {
+	tx, err := s.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE two_factor SET enabled_at = ?, last_step = ? WHERE user_id = ? AND enabled_at IS NULL`,
+		at, step, userID)
+	if err != nil {
+		return fmt.Errorf("enable two-factor: %w", err)
+	}
+	if err := expectRow(result, model.ErrTwoFactorNotEnrolled, userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit two-factor: %w", err)
+	}
+	return nil
+}
+
+func (s *TwoFactorStore) UseStep(ctx context.Context, userID string, step int64) (bool, error) {
+	result, err := s.db.conn.ExecContext(ctx,
+		`UPDATE two_factor SET last_step = ? WHERE user_id = ? AND last_step < ?`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("use two-factor code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (s *TwoFactorStore) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	result, err := s.db.conn.ExecContext(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?`, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rows affected: %w", err)
+	}
+	return rows == 1, nil
+}
+
+func (s *TwoFactorStore) Delete(ctx context.Context, userID string) error {
+	tx, err := s.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM two_factor WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete two-factor enrollment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit two-factor: %w", err)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes replaces a user's recovery codes with the given hashes.
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+	return nil
+}
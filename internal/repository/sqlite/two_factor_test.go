@@ -0,0 +1,126 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+)
+
+func newTwoFactorStore(t *testing.T) (*sqlite.TwoFactorStore, *sqlite.UserRepo) {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	users := sqlite.NewUserRepo(db)
+	mustCreateUser(t, users, context.Background(), &model.User{
+		ID: "user-1", Username: "alice", PasswordHash: "hash", Role: model.RoleUser,
+	})
+	return sqlite.NewTwoFactorStore(db), users
+}
+
+func TestTwoFactor_BeginAndEnable(t *testing.T) {
+	store, _ := newTwoFactorStore(t)
+	ctx := context.Background()
+
+	if got, err := store.Get(ctx, "user-1"); err != nil || got != nil {
+		t.Fatalf("expected no enrollment, got %+v, %v", got, err)
+	}
+	if err := store.Enable(ctx, "user-1", 1, 100, nil); !errors.Is(err, model.ErrTwoFactorNotEnrolled) {
+		t.Fatalf("expected ErrTwoFactorNotEnrolled, got %v", err)
+	}
+
+	_ = store.Begin(ctx, "user-1", "FIRST", 100)
+	// A pending enrollment is replaced.
+	if err := store.Begin(ctx, "user-1", "SECOND", 200); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	got, _ := store.Get(ctx, "user-1")
+	if got == nil || got.Secret != "SECOND" || got.Enabled || got.CreatedAt != 200 {
+		t.Fatalf("expected pending enrollment with the second secret, got %+v", got)
+	}
+
+	if err := store.Enable(ctx, "user-1", 7, 300, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	got, _ = store.Get(ctx, "user-1")
+	if !got.Enabled || got.EnabledAt != 300 || got.LastStep != 7 || got.RecoveryCodesLeft != 2 {
+		t.Errorf("unexpected enrollment after Enable: %+v", got)
+	}
+
+	// An enabled enrollment is left alone.
+	_ = store.Begin(ctx, "user-1", "THIRD", 400)
+	if got, _ = store.Get(ctx, "user-1"); got.Secret != "SECOND" || !got.Enabled {
+		t.Errorf("expected Begin to leave the enabled enrollment alone, got %+v", got)
+	}
+	if err := store.Enable(ctx, "user-1", 8, 500, nil); !errors.Is(err, model.ErrTwoFactorNotEnrolled) {
+		t.Errorf("expected ErrTwoFactorNotEnrolled for an enabled enrollment, got %v", err)
+	}
+}
+
+func TestTwoFactor_UseStep(t *testing.T) {
+	store, _ := newTwoFactorStore(t)
+	ctx := context.Background()
+	_ = store.Begin(ctx, "user-1", "SECRET", 100)
+	_ = store.Enable(ctx, "user-1", 10, 100, nil)
+
+	for _, tt := range []struct {
+		step int64
+		want bool
+	}{{10, false}, {9, false}, {11, true}, {11, false}} {
+		ok, err := store.UseStep(ctx, "user-1", tt.step)
+		if err != nil {
+			t.Fatalf("UseStep failed: %v", err)
+		}
+		if ok != tt.want {
+			t.Errorf("UseStep(%d) = %v, want %v", tt.step, ok, tt.want)
+		}
+	}
+}
+
+func TestTwoFactor_UseRecoveryCode(t *testing.T) {
+	store, _ := newTwoFactorStore(t)
+	ctx := context.Background()
+	_ = store.Begin(ctx, "user-1", "SECRET", 100)
+	_ = store.Enable(ctx, "user-1", 1, 100, []string{"hash-a", "hash-b"})
+
+	if ok, err := store.UseRecoveryCode(ctx, "user-1", "hash-a"); err != nil || !ok {
+		t.Fatalf("expected recovery code to be used, got %v, %v", ok, err)
+	}
+	if ok, _ := store.UseRecoveryCode(ctx, "user-1", "hash-a"); ok {
+		t.Error("expected used recovery code to be gone")
+	}
+	if ok, _ := store.UseRecoveryCode(ctx, "user-1", "hash-c"); ok {
+		t.Error("expected unknown recovery code to be rejected")
+	}
+	if got, _ := store.Get(ctx, "user-1"); got.RecoveryCodesLeft != 1 {
+		t.Errorf("expected 1 recovery code left, got %d", got.RecoveryCodesLeft)
+	}
+}
+
+func TestTwoFactor_DeleteAndCascade(t *testing.T) {
+	store, users := newTwoFactorStore(t)
+	ctx := context.Background()
+	_ = store.Begin(ctx, "user-1", "SECRET", 100)
+	_ = store.Enable(ctx, "user-1", 1, 100, []string{"hash-a"})
+
+	if err := store.Delete(ctx, "user-1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, "user-1"); got != nil {
+		t.Errorf("expected no enrollment after Delete, got %+v", got)
+	}
+	if ok, _ := store.UseRecoveryCode(ctx, "user-1", "hash-a"); ok {
+		t.Error("expected recovery codes to be deleted with the enrollment")
+	}
+
+	// Deleting the user deletes their enrollment too.
+	_ = store.Begin(ctx, "user-1", "SECRET", 100)
+	if err := users.DeleteUser(ctx, "user-1"); err != nil {
+		t.Fatalf("DeleteUser failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, "user-1"); got != nil {
+		t.Errorf("expected enrollment to be deleted with the user, got %+v", got)
+	}
+}
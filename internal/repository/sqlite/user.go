@@ -10,7 +10,7 @@ import (
 )
 
 // return all columns from user, need to change this when changing the schema
-const userColumns = "id, username, password_hash, role, created_at, updated_at"
+const userColumns = "id, username, password_hash, role, created_at, updated_at, token_version, display_name"
 
 // UserRepo implements repository.UserRepository backed by SQLite.
 type UserRepo struct {
@@ -23,12 +23,12 @@ func NewUserRepo(db *DB) *UserRepo {
 }
 
 func (r *UserRepo) CreateUser(ctx context.Context, user *model.User) error {
-	query := `INSERT INTO users (` + userColumns + `) VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO users (` + userColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 	now := time.Now().Unix()
 	user.CreatedAt = now
 	user.UpdatedAt = now
 	_, err := r.db.conn.ExecContext(ctx, query,
-		user.ID, user.Username, user.PasswordHash, string(user.Role), now, now)
+		user.ID, user.Username, user.PasswordHash, string(user.Role), now, now, user.TokenVersion, user.DisplayName)
 	if err != nil {
 		return fmt.Errorf("create user: %w", err)
 	}
@@ -52,6 +52,44 @@ func (r *UserRepo) UpdatePassword(ctx context.Context, id string, passwordHash s
 	return nil
 }
 
+func (r *UserRepo) UpdateRole(ctx context.Context, id string, role model.Role) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE users SET role = ?, updated_at = ? WHERE id = ?`, string(role), time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("update role: %w", err)
+	}
+	return expectRow(result, model.ErrUserNotFound, id)
+}
+
+func (r *UserRepo) UpdateProfile(ctx context.Context, id, username, displayName string) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE users SET username = ?, display_name = ?, updated_at = ? WHERE id = ?`, username, displayName, time.Now().Unix(), id)
+	if err != nil {
+		return fmt.Errorf("update profile: %w", err)
+	}
+	return expectRow(result, model.ErrUserNotFound, id)
+}
+
+func (r *UserRepo) TokenVersion(ctx context.Context, id string) (int64, error) {
+	var version int64
+	err := r.db.conn.QueryRowContext(ctx, `SELECT token_version FROM users WHERE id = ?`, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %q", model.ErrUserNotFound, id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get token version: %w", err)
+	}
+	return version, nil
+}
+
+func (r *UserRepo) BumpTokenVersion(ctx context.Context, id string) error {
+	_, err := r.db.conn.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("bump token version: %w", err)
+	}
+	return nil
+}
+
 func (r *UserRepo) ListUsers(ctx context.Context, params model.PaginationParams) (*model.Page[*model.User], error) {
 	// Count total users.
 	var total int
@@ -103,11 +141,106 @@ func (r *UserRepo) ListUsers(ctx context.Context, params model.PaginationParams)
 	return page, nil
 }
 
+func (r *UserRepo) ListUsersCursor(ctx context.Context, params model.CursorParams) (*model.CursorPage[*model.User], error) {
+	page := &model.CursorPage[*model.User]{Data: []*model.User{}, Limit: params.Limit}
+	if params.IncludeTotal {
+		var total int
+		if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&total); err != nil {
+			return nil, fmt.Errorf("count users: %w", err)
+		}
+		page.Total = &total
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	cond, args := keysetCondition(params, "id")
+	if cond != "" {
+		query += ` WHERE ` + cond
+	}
+	// Fetch one extra row to learn whether another page follows.
+	query += ` ` + keysetOrder(params, "id") + ` LIMIT ?`
+	args = append(args, params.Limit+1)
+
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []*model.User
+	for rows.Next() {
+		u, err := scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []*model.User{}
+	}
+	trimCursorPage(page, users, params, func(u *model.User) (interface{}, string) {
+		return userSortValue(u, params.SortBy), u.ID
+	})
+	return page, nil
+}
+
 func (r *UserRepo) DeleteUser(ctx context.Context, id string) error {
-	_, err := r.db.conn.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	tx, err := r.db.conn.BeginTx(ctx, nil)
 	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The user's receipts go with ON DELETE CASCADE, but the change feed and
+	// webhooks must still hear of each one.
+	if err := publishReceiptDeletions(ctx, tx, id); err != nil {
+		return err
+	}
+	// Their search index entries live in a virtual table and must be removed
+	// explicitly.
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM receipts_fts WHERE receipt_id IN (SELECT id FROM receipts WHERE user_id = ?)", id,
+	); err != nil {
+		return fmt.Errorf("unindex user receipts: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id); err != nil {
 		return fmt.Errorf("delete user: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit delete user: %w", err)
+	}
+	return nil
+}
+
+// publishReceiptDeletions publishes a receipt.deleted event for each of a
+// user's receipts, before they are removed together with the user.
+func publishReceiptDeletions(ctx context.Context, tx *sql.Tx, userID string) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM receipts WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return fmt.Errorf("list user receipts: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("scan receipt id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list user receipts: %w", err)
+	}
+
+	for _, id := range ids {
+		event := model.Event{Type: model.EventReceiptDeleted, UserID: userID, Data: model.DeletedReceipt{ID: id, UserID: userID}}
+		if err := publishEvent(ctx, tx, event); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -125,7 +258,7 @@ func (r *UserRepo) scanUser(ctx context.Context, query string, args ...interface
 	row := r.db.conn.QueryRowContext(ctx, query, args...)
 	var u model.User
 	var role string
-	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt)
+	err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt, &u.TokenVersion, &u.DisplayName)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -140,10 +273,22 @@ func (r *UserRepo) scanUser(ctx context.Context, query string, args ...interface
 func scanRow(rows *sql.Rows) (*model.User, error) {
 	var u model.User
 	var role string
-	err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt)
+	err := rows.Scan(&u.ID, &u.Username, &u.PasswordHash, &role, &u.CreatedAt, &u.UpdatedAt, &u.TokenVersion, &u.DisplayName)
 	if err != nil {
 		return nil, fmt.Errorf("scan row: %w", err)
 	}
 	u.Role = model.Role(role)
 	return &u, nil
 }
+
+// userSortValue returns the value of the given sort column for a user.
+func userSortValue(u *model.User, column string) interface{} {
+	switch column {
+	case "username":
+		return u.Username
+	case "role":
+		return string(u.Role)
+	default: // "created_at"
+		return u.CreatedAt
+	}
+}
@@ -2,6 +2,7 @@ package sqlite_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/gatheryourdeals/data/internal/model"
@@ -276,3 +277,106 @@ func TestHasAdmin(t *testing.T) {
 		t.Fatal("expected admin to exist")
 	}
 }
+
+func TestListUsersCursor_SortByUsername(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewUserRepo(db)
+	ctx := context.Background()
+	for _, name := range []string{"carol", "alice", "bob"} {
+		mustCreateUser(t, repo, ctx, &model.User{ID: "id-" + name, Username: name, PasswordHash: "h", Role: model.RoleUser})
+	}
+
+	params := model.CursorParams{Limit: 2, SortBy: "username", SortOrder: "ASC"}
+	first, err := repo.ListUsersCursor(ctx, params)
+	if err != nil {
+		t.Fatalf("ListUsersCursor failed: %v", err)
+	}
+	if len(first.Data) != 2 || first.Data[0].Username != "alice" || first.Data[1].Username != "bob" {
+		t.Fatalf("unexpected first page: %+v", first.Data)
+	}
+
+	params.After, err = model.DecodeCursor(first.NextCursor)
+	if err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	second, err := repo.ListUsersCursor(ctx, params)
+	if err != nil {
+		t.Fatalf("ListUsersCursor failed: %v", err)
+	}
+	if len(second.Data) != 1 || second.Data[0].Username != "carol" || second.HasMore {
+		t.Fatalf("unexpected second page: %+v (has_more=%v)", second.Data, second.HasMore)
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewUserRepo(db)
+	ctx := context.Background()
+
+	mustCreateUser(t, repo, ctx, &model.User{ID: "u1", Username: "alice", PasswordHash: "h", Role: model.RoleUser})
+
+	if err := repo.UpdateRole(ctx, "u1", model.RoleAdmin); err != nil {
+		t.Fatalf("UpdateRole failed: %v", err)
+	}
+	got, err := repo.GetUserByID(ctx, "u1")
+	if err != nil || got == nil {
+		t.Fatalf("GetUserByID failed: %v", err)
+	}
+	if got.Role != model.RoleAdmin {
+		t.Errorf("expected role admin, got %q", got.Role)
+	}
+
+	if err := repo.UpdateRole(ctx, "missing", model.RoleAdmin); !errors.Is(err, model.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewUserRepo(db)
+	ctx := context.Background()
+
+	mustCreateUser(t, repo, ctx, &model.User{ID: "u1", Username: "alice", PasswordHash: "h", Role: model.RoleUser})
+
+	if err := repo.UpdateProfile(ctx, "u1", "alice2", "Alice"); err != nil {
+		t.Fatalf("UpdateProfile failed: %v", err)
+	}
+	got, err := repo.GetUserByUsername(ctx, "alice2")
+	if err != nil || got == nil {
+		t.Fatalf("GetUserByUsername failed: %v", err)
+	}
+	if got.ID != "u1" || got.DisplayName != "Alice" {
+		t.Errorf("expected u1 with display name Alice, got %s with %q", got.ID, got.DisplayName)
+	}
+
+	if err := repo.UpdateProfile(ctx, "missing", "bob", ""); !errors.Is(err, model.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestBumpTokenVersion(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	repo := sqlite.NewUserRepo(db)
+	ctx := context.Background()
+
+	mustCreateUser(t, repo, ctx, &model.User{ID: "u1", Username: "alice", PasswordHash: "h", Role: model.RoleUser})
+
+	version, err := repo.TokenVersion(ctx, "u1")
+	if err != nil {
+		t.Fatalf("TokenVersion failed: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("expected version 0, got %d", version)
+	}
+
+	if err := repo.BumpTokenVersion(ctx, "u1"); err != nil {
+		t.Fatalf("BumpTokenVersion failed: %v", err)
+	}
+	if version, _ = repo.TokenVersion(ctx, "u1"); version != 1 {
+		t.Errorf("expected version 1, got %d", version)
+	}
+
+	if _, err := repo.TokenVersion(ctx, "missing"); !errors.Is(err, model.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const (
+	watchColumns = "id, user_id, product_id, product_name, max_price, currency, unit, created_at"
+	alertColumns = "id, watch_id, user_id, receipt_id, product_name, store_name, purchase_date, unit_price, max_price, currency, unit, read, created_at"
+)
+
+// WatchRepo implements repository.WatchRepository backed by SQLite.
+type WatchRepo struct {
+	db *DB
+}
+
+// NewWatchRepo creates a new SQLite-backed watch repository.
+func NewWatchRepo(db *DB) *WatchRepo {
+	return &WatchRepo{db: db}
+}
+
+func (r *WatchRepo) CreateWatch(ctx context.Context, watch *model.Watch) error {
+	watch.CreatedAt = time.Now().Unix()
+	_, err := r.db.conn.ExecContext(ctx,
+		`INSERT INTO watches (`+watchColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		watch.ID, watch.UserID, nullString(watch.ProductID), watch.ProductName,
+		watch.MaxPrice, watch.Currency, watch.Unit, watch.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("create watch: %w", err)
+	}
+	return nil
+}
+
+func (r *WatchRepo) ListWatches(ctx context.Context, userID string) ([]*model.Watch, error) {
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT `+watchColumns+` FROM watches WHERE user_id = ? ORDER BY created_at DESC, id`, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list watches: %w", err)
+	}
+	return scanWatches(rows)
+}
+
+func (r *WatchRepo) DeleteWatch(ctx context.Context, userID, id string) error {
+	result, err := r.db.conn.ExecContext(ctx, `DELETE FROM watches WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("delete watch: %w", err)
+	}
+	return expectRow(result, model.ErrWatchNotFound, id)
+}
+
+func (r *WatchRepo) ListAlerts(ctx context.Context, userID string, unreadOnly bool, params model.PaginationParams) (*model.Page[*model.Alert], error) {
+	where := "user_id = ?"
+	if unreadOnly {
+		where += " AND read = 0"
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM alerts WHERE `+where, userID).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count alerts: %w", err)
+	}
+
+	page := &model.Page[*model.Alert]{
+		Data:   []*model.Alert{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	// SortBy and SortOrder are validated by the handler.
+	query := fmt.Sprintf(
+		`SELECT `+alertColumns+` FROM alerts WHERE `+where+` ORDER BY %s %s LIMIT ? OFFSET ?`,
+		params.SortBy, params.SortOrder,
+	)
+	rows, err := r.db.conn.QueryContext(ctx, query, userID, params.Limit, params.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("list alerts: %w", err)
+	}
+	alerts, err := scanAlerts(rows)
+	if err != nil {
+		return nil, err
+	}
+	page.Data = alerts
+	return page, nil
+}
+
+func (r *WatchRepo) MarkAlertRead(ctx context.Context, userID string, id int64) error {
+	result, err := r.db.conn.ExecContext(ctx, `UPDATE alerts SET read = 1 WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("mark alert read: %w", err)
+	}
+	return expectRow(result, model.ErrAlertNotFound, strconv.FormatInt(id, 10))
+}
+
+func (r *WatchRepo) UndeliveredAlerts(ctx context.Context, limit int) ([]*model.Alert, error) {
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT `+alertColumns+` FROM alerts WHERE delivered_at IS NULL ORDER BY id LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list undelivered alerts: %w", err)
+	}
+	return scanAlerts(rows)
+}
+
+func (r *WatchRepo) MarkAlertDelivered(ctx context.Context, id int64) error {
+	if _, err := r.db.conn.ExecContext(ctx,
+		`UPDATE alerts SET delivered_at = ? WHERE id = ?`, time.Now().Unix(), id,
+	); err != nil {
+		return fmt.Errorf("mark alert delivered: %w", err)
+	}
+	return nil
+}
+
+// triggerWatches checks a newly inserted receipt against the watches on its
+// product and records an alert for each one it triggers. Watches on a
+// catalog product match by product ID; the rest match by name.
+func triggerWatches(ctx context.Context, tx *sql.Tx, receipt *model.Receipt) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+watchColumns+` FROM watches
+		WHERE (product_id IS NOT NULL AND product_id = ?)
+			OR (product_id IS NULL AND lower(product_name) = lower(?))`,
+		receipt.ProductID, receipt.ProductName,
+	)
+	if err != nil {
+		return fmt.Errorf("find watches: %w", err)
+	}
+	watches, err := scanWatches(rows)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	for _, w := range watches {
+		unitPrice, triggered, ok := w.Check(receipt.Price, receipt.Amount)
+		if !ok || !triggered {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO alerts (watch_id, user_id, receipt_id, product_name, store_name, purchase_date,
+				unit_price, max_price, currency, unit, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			w.ID, w.UserID, receipt.ID, receipt.ProductName, receipt.StoreName, receipt.PurchaseDate,
+			unitPrice, w.MaxPrice, w.Currency, w.Unit, now,
+		); err != nil {
+			return fmt.Errorf("create alert: %w", err)
+		}
+	}
+	return nil
+}
+
+// scanWatches reads and closes a result set of watch rows.
+func scanWatches(rows *sql.Rows) ([]*model.Watch, error) {
+	defer func() { _ = rows.Close() }()
+
+	watches := []*model.Watch{}
+	for rows.Next() {
+		var w model.Watch
+		var productID sql.NullString
+		if err := rows.Scan(&w.ID, &w.UserID, &productID, &w.ProductName,
+			&w.MaxPrice, &w.Currency, &w.Unit, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan watch: %w", err)
+		}
+		w.ProductID = productID.String
+		watches = append(watches, &w)
+	}
+	return watches, rows.Err()
+}
+
+// scanAlerts reads and closes a result set of alert rows.
+func scanAlerts(rows *sql.Rows) ([]*model.Alert, error) {
+	defer func() { _ = rows.Close() }()
+
+	alerts := []*model.Alert{}
+	for rows.Next() {
+		var a model.Alert
+		if err := rows.Scan(&a.ID, &a.WatchID, &a.UserID, &a.ReceiptID, &a.ProductName, &a.StoreName,
+			&a.PurchaseDate, &a.UnitPrice, &a.MaxPrice, &a.Currency, &a.Unit, &a.Read, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan alert: %w", err)
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,132 @@
+package sqlite_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+type watchEnv struct {
+	*priceEnv
+	watches *sqlite.WatchRepo
+}
+
+func newWatchEnv(t *testing.T) *watchEnv {
+	t.Helper()
+	env := newPriceEnv(t)
+	return &watchEnv{priceEnv: env, watches: sqlite.NewWatchRepo(env.db)}
+}
+
+func (e *watchEnv) watch(t *testing.T, w *model.Watch) {
+	t.Helper()
+	if err := e.watches.CreateWatch(e.ctx, w); err != nil {
+		t.Fatalf("CreateWatch failed: %v", err)
+	}
+}
+
+func (e *watchEnv) alerts(t *testing.T, userID string, unreadOnly bool) []*model.Alert {
+	t.Helper()
+	params := model.PaginationParams{Limit: 20, SortBy: "id", SortOrder: "DESC"}
+	page, err := e.watches.ListAlerts(e.ctx, userID, unreadOnly, params)
+	if err != nil {
+		t.Fatalf("ListAlerts failed: %v", err)
+	}
+	return page.Data
+}
+
+func TestWatchCheck_ConvertsUnits(t *testing.T) {
+	w := &model.Watch{MaxPrice: 4, Currency: "CAD", Unit: "lb"}
+	cases := []struct {
+		price, amount string
+		triggered, ok bool
+	}{
+		{"3.99CAD", "1lb", true, true},
+		{"8.00CAD", "2 lb", true, true},  // exactly 4.00/lb
+		{"8.00CAD", "1kg", true, true},   // 3.63/lb
+		{"4.50CAD", "454g", false, true}, // 4.50/lb
+		{"3.00USD", "1lb", false, false}, // other currency
+		{"3.00CAD", "1", false, false},   // priced per item
+		{"cheap", "1lb", false, false},
+	}
+	for _, c := range cases {
+		_, triggered, ok := w.Check(c.price, c.amount)
+		if triggered != c.triggered || ok != c.ok {
+			t.Errorf("Check(%q, %q) = %v, %v; want %v, %v", c.price, c.amount, triggered, ok, c.triggered, c.ok)
+		}
+	}
+}
+
+func TestCreateReceipt_TriggersMatchingWatches(t *testing.T) {
+	env := newWatchEnv(t)
+	env.seedUser(t, "user-2")
+	env.createProduct(t, &model.Product{ID: "p-butter", Name: "Butter", Aliases: []string{"Salted Butter 454g"}})
+
+	env.watch(t, &model.Watch{ID: "w1", UserID: "user-2", ProductID: "p-butter", ProductName: "Butter", MaxPrice: 4, Currency: "CAD", Unit: "lb"})
+	env.watch(t, &model.Watch{ID: "w2", UserID: "user-2", ProductName: "Eggs", MaxPrice: 0.3, Currency: "CAD", Unit: "each"})
+	env.watch(t, &model.Watch{ID: "w3", UserID: "user-1", ProductID: "p-butter", ProductName: "Butter", MaxPrice: 3, Currency: "CAD", Unit: "lb"})
+
+	env.purchase(t, "r1", "Salted Butter 454g", "2025.04.01", "4.99CAD", "454g", "Costco")  // 4.99/lb
+	env.purchase(t, "r2", "Salted Butter 454g", "2025.04.02", "3.79CAD", "454g", "Walmart") // 3.79/lb
+	env.purchase(t, "r3", "EGGS", "2025.04.02", "3.00CAD", "12", "Walmart")
+	env.purchase(t, "r4", "Bread", "2025.04.02", "1.00CAD", "1", "Walmart")
+
+	alerts := env.alerts(t, "user-2", false)
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts for user-2, got %+v", alerts)
+	}
+	eggs, butter := alerts[0], alerts[1]
+	if eggs.WatchID != "w2" || eggs.ReceiptID != "r3" || !approxEqual(eggs.UnitPrice, 0.25) {
+		t.Errorf("unexpected eggs alert: %+v", eggs)
+	}
+	if butter.WatchID != "w1" || butter.ReceiptID != "r2" || butter.StoreName != "Walmart" ||
+		butter.Unit != "lb" || butter.UnitPrice > 3.8 || butter.UnitPrice < 3.78 {
+		t.Errorf("unexpected butter alert: %+v", butter)
+	}
+	if got := env.alerts(t, "user-1", false); len(got) != 0 {
+		t.Errorf("expected no alerts for user-1's stricter watch, got %+v", got)
+	}
+}
+
+func TestAlerts_ReadAndDelivery(t *testing.T) {
+	env := newWatchEnv(t)
+	env.watch(t, &model.Watch{ID: "w1", UserID: "user-1", ProductName: "Milk", MaxPrice: 2, Currency: "CAD", Unit: "l"})
+	env.purchase(t, "r1", "Milk", "2025.04.01", "5.00CAD", "4L", "Costco")
+	env.purchase(t, "r2", "Milk", "2025.04.02", "6.00CAD", "4L", "Costco")
+
+	alerts := env.alerts(t, "user-1", false)
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	if err := env.watches.MarkAlertRead(env.ctx, "user-1", alerts[1].ID); err != nil {
+		t.Fatalf("MarkAlertRead failed: %v", err)
+	}
+	if unread := env.alerts(t, "user-1", true); len(unread) != 1 || unread[0].ID != alerts[0].ID {
+		t.Errorf("expected only the newest alert unread, got %+v", unread)
+	}
+	if err := env.watches.MarkAlertRead(env.ctx, "user-2", alerts[0].ID); !errors.Is(err, model.ErrAlertNotFound) {
+		t.Errorf("expected ErrAlertNotFound for another user's alert, got %v", err)
+	}
+
+	pending, err := env.watches.UndeliveredAlerts(env.ctx, 10)
+	if err != nil || len(pending) != 2 || pending[0].ReceiptID != "r1" {
+		t.Fatalf("UndeliveredAlerts = %+v, %v; want both, oldest first", pending, err)
+	}
+	if err := env.watches.MarkAlertDelivered(env.ctx, pending[0].ID); err != nil {
+		t.Fatalf("MarkAlertDelivered failed: %v", err)
+	}
+	if pending, _ = env.watches.UndeliveredAlerts(env.ctx, 10); len(pending) != 1 || pending[0].ReceiptID != "r2" {
+		t.Errorf("expected only r2's alert pending, got %+v", pending)
+	}
+
+	if err := env.watches.DeleteWatch(env.ctx, "user-1", "w1"); err != nil {
+		t.Fatalf("DeleteWatch failed: %v", err)
+	}
+	if got := env.alerts(t, "user-1", false); len(got) != 0 {
+		t.Errorf("expected the watch's alerts to be deleted with it, got %+v", got)
+	}
+	if err := env.watches.DeleteWatch(env.ctx, "user-1", "w1"); !errors.Is(err, model.ErrWatchNotFound) {
+		t.Errorf("expected ErrWatchNotFound, got %v", err)
+	}
+}
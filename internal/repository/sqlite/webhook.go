@@ -0,0 +1,261 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+)
+
+const (
+	subscriptionColumns = "id, url, events, secret, active, created_at"
+	deliveryColumns     = "d.id, d.subscription_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at"
+)
+
+// WebhookRepo implements repository.WebhookRepository backed by SQLite.
+type WebhookRepo struct {
+	db *DB
+}
+
+// NewWebhookRepo creates a new SQLite-backed webhook repository.
+func NewWebhookRepo(db *DB) *WebhookRepo {
+	return &WebhookRepo{db: db}
+}
+
+func (r *WebhookRepo) CreateSubscription(ctx context.Context, sub *model.WebhookSubscription) error {
+	events, err := json.Marshal(sub.Events)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+	sub.CreatedAt = time.Now().Unix()
+	if _, err := r.db.conn.ExecContext(ctx,
+		`INSERT INTO webhook_subscriptions (`+subscriptionColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		sub.ID, sub.URL, string(events), sub.Secret, sub.Active, sub.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("create webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepo) GetSubscription(ctx context.Context, id string) (*model.WebhookSubscription, error) {
+	row := r.db.conn.QueryRowContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = ?`, id)
+	sub, err := scanSubscription(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get webhook: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *WebhookRepo) ListSubscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	return listSubscriptions(ctx, r.db.conn, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY created_at, id`)
+}
+
+func (r *WebhookRepo) UpdateSubscription(ctx context.Context, sub *model.WebhookSubscription) error {
+	events, err := json.Marshal(sub.Events)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE webhook_subscriptions SET url = ?, events = ?, active = ? WHERE id = ?`,
+		sub.URL, string(events), sub.Active, sub.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("update webhook: %w", err)
+	}
+	if err := expectRow(result, model.ErrWebhookNotFound, sub.ID); err != nil {
+		return err
+	}
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT created_at FROM webhook_subscriptions WHERE id = ?`, sub.ID,
+	).Scan(&sub.CreatedAt); err != nil {
+		return fmt.Errorf("reload webhook: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepo) DeleteSubscription(ctx context.Context, id string) error {
+	result, err := r.db.conn.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+	return expectRow(result, model.ErrWebhookNotFound, id)
+}
+
+func (r *WebhookRepo) ListDeliveries(ctx context.Context, subscriptionID, status string, params model.PaginationParams) (*model.Page[*model.WebhookDelivery], error) {
+	where := "d.subscription_id = ?"
+	args := []interface{}{subscriptionID}
+	if status != "" {
+		where += " AND d.status = ?"
+		args = append(args, status)
+	}
+
+	var total int
+	if err := r.db.conn.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM webhook_deliveries d WHERE `+where, args...,
+	).Scan(&total); err != nil {
+		return nil, fmt.Errorf("count webhook deliveries: %w", err)
+	}
+
+	page := &model.Page[*model.WebhookDelivery]{
+		Data:   []*model.WebhookDelivery{},
+		Total:  total,
+		Offset: params.Offset,
+		Limit:  params.Limit,
+	}
+	if total > 0 {
+		page.TotalPages = (total + params.Limit - 1) / params.Limit
+	}
+	if total == 0 || params.Offset >= total {
+		return page, nil
+	}
+
+	// SortBy and SortOrder are validated by the handler.
+	query := fmt.Sprintf(
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries d WHERE `+where+` ORDER BY d.%s %s LIMIT ? OFFSET ?`,
+		params.SortBy, params.SortOrder,
+	)
+	rows, err := r.db.conn.QueryContext(ctx, query, append(args, params.Limit, params.Offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	if page.Data, err = scanDeliveries(rows, false); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (r *WebhookRepo) DueDeliveries(ctx context.Context, now int64, limit int) ([]*model.WebhookDelivery, error) {
+	rows, err := r.db.conn.QueryContext(ctx,
+		`SELECT `+deliveryColumns+`, s.url, s.secret
+		FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active = 1
+		ORDER BY d.id LIMIT ?`,
+		model.DeliveryPending, now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list due webhook deliveries: %w", err)
+	}
+	return scanDeliveries(rows, true)
+}
+
+func (r *WebhookRepo) SaveAttempt(ctx context.Context, delivery *model.WebhookDelivery) error {
+	var deliveredAt sql.NullInt64
+	if delivery.DeliveredAt != 0 {
+		deliveredAt = sql.NullInt64{Int64: delivery.DeliveredAt, Valid: true}
+	}
+	if _, err := r.db.conn.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?,
+			last_status_code = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastStatusCode, delivery.LastError, deliveredAt, delivery.ID,
+	); err != nil {
+		return fmt.Errorf("save webhook attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *WebhookRepo) RetryDelivery(ctx context.Context, subscriptionID string, id int64, now int64) error {
+	result, err := r.db.conn.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?
+		WHERE id = ? AND subscription_id = ? AND status = ?`,
+		model.DeliveryPending, now, id, subscriptionID, model.DeliveryDead,
+	)
+	if err != nil {
+		return fmt.Errorf("retry webhook delivery: %w", err)
+	}
+	return expectRow(result, model.ErrDeliveryNotFound, strconv.FormatInt(id, 10))
+}
+
+// queueDeliveries queues a delivery of the event for every active
+// subscription whose filters match it. payload is the event data as JSON.
+func queueDeliveries(ctx context.Context, tx *sql.Tx, event model.Event, payload []byte) error {
+	subs, err := listSubscriptions(ctx, tx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE active = 1`)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if !sub.Wants(event.Type) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO webhook_deliveries (subscription_id, event_type, payload, status, next_attempt_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			sub.ID, event.Type, string(payload), model.DeliveryPending, event.CreatedAt, event.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("queue webhook delivery: %w", err)
+		}
+	}
+	return nil
+}
+
+// rowsQuerier is satisfied by both *sql.DB and *sql.Tx.
+type rowsQuerier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// listSubscriptions runs a query selecting subscriptionColumns.
+func listSubscriptions(ctx context.Context, q rowsQuerier, query string) ([]*model.WebhookSubscription, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	subs := []*model.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// scanSubscription scans a subscription row from either *sql.Row or *sql.Rows.
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*model.WebhookSubscription, error) {
+	var sub model.WebhookSubscription
+	var events string
+	if err := row.Scan(&sub.ID, &sub.URL, &events, &sub.Secret, &sub.Active, &sub.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &sub.Events); err != nil {
+		return nil, fmt.Errorf("unmarshal events: %w", err)
+	}
+	return &sub, nil
+}
+
+// scanDeliveries reads and closes a result set of delivery rows. If
+// withTarget is set, each row ends with the subscription's URL and secret.
+func scanDeliveries(rows *sql.Rows, withTarget bool) ([]*model.WebhookDelivery, error) {
+	defer func() { _ = rows.Close() }()
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		var d model.WebhookDelivery
+		var payload string
+		var deliveredAt sql.NullInt64
+		dest := []interface{}{&d.ID, &d.SubscriptionID, &d.EventType, &payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &deliveredAt}
+		if withTarget {
+			dest = append(dest, &d.URL, &d.Secret)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan webhook delivery: %w", err)
+		}
+		d.Payload, d.DeliveredAt = []byte(payload), deliveredAt.Int64
+		if d.Status != model.DeliveryPending {
+			d.NextAttemptAt = 0
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, rows.Err()
+}
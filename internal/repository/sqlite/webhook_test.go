@@ -0,0 +1,156 @@
+package sqlite_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+)
+
+type webhookEnv struct {
+	*productEnv
+	webhooks *sqlite.WebhookRepo
+}
+
+func newWebhookEnv(t *testing.T) *webhookEnv {
+	t.Helper()
+	env := newProductEnv(t)
+	return &webhookEnv{productEnv: env, webhooks: sqlite.NewWebhookRepo(env.db)}
+}
+
+func (e *webhookEnv) subscribe(t *testing.T, id string, active bool, events ...string) {
+	t.Helper()
+	sub := &model.WebhookSubscription{ID: id, URL: "http://example.test/" + id, Events: events, Secret: "s-" + id, Active: active}
+	if err := e.webhooks.CreateSubscription(e.ctx, sub); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+}
+
+func (e *webhookEnv) deliveries(t *testing.T, subID, status string) []*model.WebhookDelivery {
+	t.Helper()
+	params := model.PaginationParams{Limit: 50, SortBy: "id", SortOrder: "ASC"}
+	page, err := e.webhooks.ListDeliveries(e.ctx, subID, status, params)
+	if err != nil {
+		t.Fatalf("ListDeliveries failed: %v", err)
+	}
+	return page.Data
+}
+
+func TestWebhookSubscription_Wants(t *testing.T) {
+	sub := &model.WebhookSubscription{Events: []string{"receipt.*", "meta.updated"}}
+	for eventType, want := range map[string]bool{
+		model.EventReceiptCreated: true,
+		model.EventReceiptDeleted: true,
+		model.EventMetaUpdated:    true,
+		model.EventMetaCreated:    false,
+	} {
+		if got := sub.Wants(eventType); got != want {
+			t.Errorf("Wants(%q) = %v, want %v", eventType, got, want)
+		}
+	}
+	for filter, want := range map[string]bool{"*": true, "receipt.*": true, "meta.created": true, "receipt": false, "user.*": false} {
+		if got := model.ValidEventFilter(filter); got != want {
+			t.Errorf("ValidEventFilter(%q) = %v, want %v", filter, got, want)
+		}
+	}
+}
+
+func TestReceiptWrites_QueueDeliveries(t *testing.T) {
+	env := newWebhookEnv(t)
+	env.subscribe(t, "all", true, "*")
+	env.subscribe(t, "created", true, model.EventReceiptCreated)
+	env.subscribe(t, "meta", true, "meta.*")
+	env.subscribe(t, "off", false, "*")
+
+	rec := env.sampleReceipt("r1", "user-1")
+	if err := env.receipts.CreateReceipt(env.ctx, rec); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	rec.Price = "4.99CAD"
+	if err := env.receipts.UpdateReceipt(env.ctx, rec, 0); err != nil {
+		t.Fatalf("UpdateReceipt failed: %v", err)
+	}
+	if err := env.receipts.DeleteReceipt(env.ctx, "r1", 0); err != nil {
+		t.Fatalf("DeleteReceipt failed: %v", err)
+	}
+
+	all := env.deliveries(t, "all", "")
+	if len(all) != 3 || all[0].EventType != model.EventReceiptCreated ||
+		all[1].EventType != model.EventReceiptUpdated || all[2].EventType != model.EventReceiptDeleted {
+		t.Fatalf("unexpected deliveries for '*': %+v", all)
+	}
+	var updated map[string]interface{}
+	if err := json.Unmarshal(all[1].Payload, &updated); err != nil || updated["price"] != "4.99CAD" || updated["version"] != 2.0 {
+		t.Errorf("unexpected update payload %s: %v", all[1].Payload, err)
+	}
+	if got := env.deliveries(t, "created", ""); len(got) != 1 || got[0].Status != model.DeliveryPending {
+		t.Errorf("expected one pending receipt.created delivery, got %+v", got)
+	}
+	if got := env.deliveries(t, "meta", ""); len(got) != 0 {
+		t.Errorf("expected no deliveries for meta.*, got %+v", got)
+	}
+	if got := env.deliveries(t, "off", ""); len(got) != 0 {
+		t.Errorf("expected no deliveries for an inactive subscription, got %+v", got)
+	}
+
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r1", "user-1")); err != nil {
+		t.Fatalf("CreateReceipt failed: %v", err)
+	}
+	if err := env.receipts.CreateReceipt(env.ctx, env.sampleReceipt("r1", "user-1")); err == nil {
+		t.Fatal("expected duplicate receipt to fail")
+	}
+	if got := env.deliveries(t, "created", ""); len(got) != 2 {
+		t.Errorf("expected a failed write to queue nothing, got %d deliveries", len(got))
+	}
+}
+
+func TestWebhookDeliveries_AttemptAndRetry(t *testing.T) {
+	env := newWebhookEnv(t)
+	env.subscribe(t, "w1", true, "meta.*")
+	if err := env.meta.UpdateDescription(env.ctx, "productName", "What was bought", 0); err != nil {
+		t.Fatalf("UpdateDescription failed: %v", err)
+	}
+
+	due, err := env.webhooks.DueDeliveries(env.ctx, 1<<40, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("DueDeliveries = %+v, %v; want one", due, err)
+	}
+	d := due[0]
+	if d.EventType != model.EventMetaUpdated || d.URL != "http://example.test/w1" || d.Secret != "s-w1" {
+		t.Errorf("unexpected due delivery: %+v", d)
+	}
+
+	d.Status, d.Attempts, d.LastStatusCode, d.LastError = model.DeliveryDead, 3, 500, "unexpected status 500"
+	if err := env.webhooks.SaveAttempt(env.ctx, d); err != nil {
+		t.Fatalf("SaveAttempt failed: %v", err)
+	}
+	if due, _ = env.webhooks.DueDeliveries(env.ctx, 1<<40, 10); len(due) != 0 {
+		t.Errorf("expected dead deliveries not to be due, got %+v", due)
+	}
+	dead := env.deliveries(t, "w1", model.DeliveryDead)
+	if len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastStatusCode != 500 {
+		t.Fatalf("unexpected dead-letter log: %+v", dead)
+	}
+
+	if err := env.webhooks.RetryDelivery(env.ctx, "other", d.ID, 100); !errors.Is(err, model.ErrDeliveryNotFound) {
+		t.Errorf("expected ErrDeliveryNotFound for another subscription, got %v", err)
+	}
+	if err := env.webhooks.RetryDelivery(env.ctx, "w1", d.ID, 100); err != nil {
+		t.Fatalf("RetryDelivery failed: %v", err)
+	}
+	if err := env.webhooks.RetryDelivery(env.ctx, "w1", d.ID, 100); !errors.Is(err, model.ErrDeliveryNotFound) {
+		t.Errorf("expected a pending delivery not to be retried again, got %v", err)
+	}
+	if due, _ = env.webhooks.DueDeliveries(env.ctx, 100, 10); len(due) != 1 || due[0].Attempts != 0 {
+		t.Errorf("expected the retried delivery due with no attempts, got %+v", due)
+	}
+
+	if err := env.webhooks.DeleteSubscription(env.ctx, "w1"); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+	if err := env.webhooks.DeleteSubscription(env.ctx, "w1"); !errors.Is(err, model.ErrWebhookNotFound) {
+		t.Errorf("expected ErrWebhookNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository"
+)
+
+// Headers sent with every subscription delivery.
+const (
+	HeaderEvent     = "X-GYD-Event"
+	HeaderDelivery  = "X-GYD-Delivery"
+	HeaderTimestamp = "X-GYD-Timestamp"
+	HeaderSignature = "X-GYD-Signature"
+)
+
+const (
+	// retryBase is the wait after the first failed attempt; each further
+	// failure doubles it, up to retryCap.
+	retryBase = 30 * time.Second
+	retryCap  = 6 * time.Hour
+	batchSize = 100
+)
+
+// Sign returns the signature of a delivery: the hex HMAC-SHA256, keyed with
+// the subscription secret, of the timestamp header, a dot and the raw body.
+// Receivers recompute it and compare it to the X-GYD-Signature header after
+// its "sha256=" prefix.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher works through the persistent delivery queue, POSTing each due
+// delivery to its subscription and recording the outcome.
+type Dispatcher struct {
+	store       repository.WebhookRepository
+	http        *http.Client
+	maxAttempts int
+}
+
+// NewDispatcher creates a dispatcher that gives up on a request after
+// timeout and dead-letters a delivery after maxAttempts failed attempts.
+func NewDispatcher(store repository.WebhookRepository, timeout time.Duration, maxAttempts int) *Dispatcher {
+	return &Dispatcher{store: store, http: &http.Client{Timeout: timeout}, maxAttempts: maxAttempts}
+}
+
+// Run calls DeliverDue every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.DeliverDue(ctx, time.Now()); err != nil {
+				slog.Warn("webhook delivery failed", "error", err)
+			}
+		}
+	}
+}
+
+// DeliverDue attempts every delivery due at now, oldest first, and returns
+// how many succeeded. A failed attempt is rescheduled with exponential
+// backoff, or marked dead once maxAttempts is reached.
+func (d *Dispatcher) DeliverDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := d.store.DueDeliveries(ctx, now.Unix(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, delivery := range due {
+		if err := d.attempt(ctx, delivery, now); err != nil {
+			slog.Warn("webhook delivery failed", "delivery", delivery.ID, "url", delivery.URL, "error", err)
+		} else {
+			delivered++
+		}
+		if err := d.store.SaveAttempt(ctx, delivery); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// attempt POSTs a delivery once and updates its status, attempt count and
+// schedule in place. The returned error describes a failed attempt.
+func (d *Dispatcher) attempt(ctx context.Context, delivery *model.WebhookDelivery, now time.Time) error {
+	body, err := json.Marshal(Event{
+		ID:   delivery.ID,
+		Type: delivery.EventType,
+		Time: now.Unix(),
+		Data: json.RawMessage(delivery.Payload),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	status, err := post(ctx, d.http, delivery.URL, body, map[string]string{
+		HeaderEvent:     delivery.EventType,
+		HeaderDelivery:  strconv.FormatInt(delivery.ID, 10),
+		HeaderTimestamp: strconv.FormatInt(now.Unix(), 10),
+		HeaderSignature: "sha256=" + Sign(delivery.Secret, now.Unix(), body),
+	})
+
+	delivery.Attempts++
+	delivery.LastStatusCode = status
+	if err == nil {
+		delivery.Status = model.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = now.Unix()
+		return nil
+	}
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts {
+		delivery.Status = model.DeliveryDead
+		return err
+	}
+	delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts)).Unix()
+	return err
+}
+
+// Backoff returns how long to wait before retrying a delivery that has
+// failed the given number of times.
+func Backoff(attempts int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempts && wait < retryCap; i++ {
+		wait *= 2
+	}
+	if wait > retryCap {
+		wait = retryCap
+	}
+	return wait
+}
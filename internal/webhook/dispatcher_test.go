@@ -0,0 +1,148 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/model"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite"
+	"github.com/gatheryourdeals/data/internal/repository/sqlite/testutil"
+	"github.com/gatheryourdeals/data/internal/webhook"
+)
+
+// dispatchEnv subscribes an httptest receiver to meta events and queues one
+// delivery for it.
+type dispatchEnv struct {
+	ctx      context.Context
+	webhooks *sqlite.WebhookRepo
+	sub      *model.WebhookSubscription
+}
+
+func newDispatchEnv(t *testing.T, receiver *httptest.Server) *dispatchEnv {
+	t.Helper()
+	db := testutil.NewTestDB(t)
+	env := &dispatchEnv{ctx: context.Background(), webhooks: sqlite.NewWebhookRepo(db)}
+	env.sub = &model.WebhookSubscription{ID: "w1", URL: receiver.URL, Events: []string{"meta.*"}, Secret: "topsecret", Active: true}
+	if err := env.webhooks.CreateSubscription(env.ctx, env.sub); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if err := sqlite.NewMetaFieldRepo(db).UpdateDescription(env.ctx, "productName", "What was bought", 0); err != nil {
+		t.Fatalf("UpdateDescription failed: %v", err)
+	}
+	return env
+}
+
+func (e *dispatchEnv) delivery(t *testing.T) *model.WebhookDelivery {
+	t.Helper()
+	params := model.PaginationParams{Limit: 10, SortBy: "id", SortOrder: "ASC"}
+	page, err := e.webhooks.ListDeliveries(e.ctx, e.sub.ID, "", params)
+	if err != nil || len(page.Data) != 1 {
+		t.Fatalf("ListDeliveries = %+v, %v; want one delivery", page, err)
+	}
+	return page.Data[0]
+}
+
+func TestDeliverDue_SignsPayload(t *testing.T) {
+	var got struct {
+		header http.Header
+		body   []byte
+	}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.header = r.Header.Clone()
+		got.body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+	env := newDispatchEnv(t, receiver)
+
+	now := time.Now().Add(time.Minute)
+	n, err := webhook.NewDispatcher(env.webhooks, time.Second, 3).DeliverDue(env.ctx, now)
+	if err != nil || n != 1 {
+		t.Fatalf("DeliverDue = %d, %v; want 1", n, err)
+	}
+
+	ts := got.header.Get(webhook.HeaderTimestamp)
+	if ts != strconv.FormatInt(now.Unix(), 10) || got.header.Get(webhook.HeaderEvent) != model.EventMetaUpdated {
+		t.Errorf("unexpected headers: %v", got.header)
+	}
+	want := "sha256=" + webhook.Sign("topsecret", now.Unix(), got.body)
+	if got.header.Get(webhook.HeaderSignature) != want {
+		t.Errorf("signature %q, want %q", got.header.Get(webhook.HeaderSignature), want)
+	}
+	var event struct {
+		ID   int64           `json:"id"`
+		Type string          `json:"type"`
+		Data model.MetaField `json:"data"`
+	}
+	if err := json.Unmarshal(got.body, &event); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if strconv.FormatInt(event.ID, 10) != got.header.Get(webhook.HeaderDelivery) ||
+		event.Data.FieldName != "productName" || event.Data.Description != "What was bought" {
+		t.Errorf("unexpected event: %+v", event)
+	}
+
+	d := env.delivery(t)
+	if d.Status != model.DeliveryDelivered || d.Attempts != 1 || d.LastStatusCode != 200 || d.DeliveredAt != now.Unix() {
+		t.Errorf("unexpected delivery after success: %+v", d)
+	}
+}
+
+func TestDeliverDue_RetriesThenDeadLetters(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+	env := newDispatchEnv(t, receiver)
+	dispatcher := webhook.NewDispatcher(env.webhooks, time.Second, 3)
+
+	now := time.Now()
+	if n, err := dispatcher.DeliverDue(env.ctx, now); err != nil || n != 0 {
+		t.Fatalf("DeliverDue = %d, %v; want 0", n, err)
+	}
+	d := env.delivery(t)
+	if d.Status != model.DeliveryPending || d.Attempts != 1 || d.LastStatusCode != 502 ||
+		d.NextAttemptAt != now.Add(30*time.Second).Unix() {
+		t.Fatalf("unexpected delivery after first failure: %+v", d)
+	}
+
+	// Not due again until the backoff has passed.
+	_, _ = dispatcher.DeliverDue(env.ctx, now.Add(10*time.Second))
+	if calls.Load() != 1 {
+		t.Errorf("expected no attempt before the backoff, got %d calls", calls.Load())
+	}
+
+	now = now.Add(30 * time.Second)
+	_, _ = dispatcher.DeliverDue(env.ctx, now)
+	if d = env.delivery(t); d.Attempts != 2 || d.NextAttemptAt != now.Add(time.Minute).Unix() {
+		t.Fatalf("expected the backoff to double, got %+v", d)
+	}
+
+	_, _ = dispatcher.DeliverDue(env.ctx, now.Add(time.Minute))
+	if d = env.delivery(t); d.Status != model.DeliveryDead || d.Attempts != 3 {
+		t.Fatalf("expected the delivery dead-lettered after 3 attempts, got %+v", d)
+	}
+	_, _ = dispatcher.DeliverDue(env.ctx, now.Add(time.Hour))
+	if calls.Load() != 3 {
+		t.Errorf("expected no attempts after dead-lettering, got %d calls", calls.Load())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 20: 6 * time.Hour,
+	} {
+		if got := webhook.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
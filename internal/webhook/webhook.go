@@ -0,0 +1,69 @@
+// Package webhook posts JSON event notifications to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Event is the JSON body of every webhook request.
+type Event struct {
+	ID   int64       `json:"id,omitempty"` // delivery ID, set for subscription deliveries
+	Type string      `json:"type"`
+	Time int64       `json:"time"` // Unix epoch seconds (UTC) the event was sent
+	Data interface{} `json:"data"`
+}
+
+// Client sends events to a single webhook URL.
+type Client struct {
+	url  string
+	http *http.Client
+}
+
+// NewClient creates a client that POSTs to url, giving up on a request
+// after timeout.
+func NewClient(url string, timeout time.Duration) *Client {
+	return &Client{url: url, http: &http.Client{Timeout: timeout}}
+}
+
+// Send POSTs an event with the given type and data. Any response other than
+// 2xx is reported as an error so the caller can retry later.
+func (c *Client) Send(ctx context.Context, eventType string, data interface{}) error {
+	body, err := json.Marshal(Event{Type: eventType, Time: time.Now().Unix(), Data: data})
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	if _, err := post(ctx, c.http, c.url, body, nil); err != nil {
+		return fmt.Errorf("post event: %w", err)
+	}
+	return nil
+}
+
+// post sends body to url with the given extra headers and returns the
+// response status. Any status other than 2xx is also reported as an error.
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
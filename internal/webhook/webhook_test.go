@@ -0,0 +1,45 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gatheryourdeals/data/internal/webhook"
+)
+
+func TestSend_PostsEvent(t *testing.T) {
+	var got webhook.Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	client := webhook.NewClient(srv.URL, time.Second)
+	if err := client.Send(context.Background(), "price.alert", map[string]string{"productName": "Butter"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got.Type != "price.alert" || got.Time == 0 || got.Data.(map[string]interface{})["productName"] != "Butter" {
+		t.Errorf("unexpected event: %+v", got)
+	}
+}
+
+func TestSend_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	if err := webhook.NewClient(srv.URL, time.Second).Send(context.Background(), "price.alert", nil); err == nil {
+		t.Error("expected an error for a 503 response")
+	}
+}